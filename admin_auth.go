@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Authentication modes of the admin API (ADMIN_AUTH_MODE)
+const (
+	AdminAuthNone     = "none"     // Socket file permissions only
+	AdminAuthPeerCred = "peercred" // Kernel-reported uid/gid of the connecting process against allowlists
+	AdminAuthTLS      = "tls"      // Mutual TLS; the client certificate's SPIFFE ID against an allowlist
+)
+
+// adminPeerKey keys the caller's peer credentials in a connection context
+type adminPeerKey struct{}
+
+// adminPeer is the process on the other end of an admin socket connection
+type adminPeer struct {
+	PID int
+	UID int
+	GID int
+}
+
+func (p adminPeer) String() string {
+	return fmt.Sprintf("pid=%d uid=%d gid=%d", p.PID, p.UID, p.GID)
+}
+
+// parseAdminIDs parses a comma-separated list of numeric uids or gids
+func parseAdminIDs(value, name string) (map[int]bool, error) {
+	ids := make(map[int]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := strconv.Atoi(entry)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("%s entry %q must be a numeric id", name, entry)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// parseAdminSPIFFEIDs parses ADMIN_ALLOWED_SPIFFE_IDS
+// An entry ending in "/" allows every ID below it, e.g. a whole trust domain
+func parseAdminSPIFFEIDs(value string) ([]string, error) {
+	var ids []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parsed, err := url.Parse(entry)
+		if err != nil || parsed.Scheme != "spiffe" || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return nil, fmt.Errorf("ADMIN_ALLOWED_SPIFFE_IDS entry %q must be a spiffe://trust-domain/path ID", entry)
+		}
+		ids = append(ids, entry)
+	}
+	return ids, nil
+}
+
+// spiffeAllowed reports whether a SPIFFE ID matches the allowlist
+func spiffeAllowed(id string, allowed []string) bool {
+	for _, entry := range allowed {
+		if id == entry || (strings.HasSuffix(entry, "/") && strings.HasPrefix(id, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateSPIFFEID returns the SPIFFE ID of a client certificate
+// An X.509 SVID carries exactly one spiffe:// URI SAN
+func certificateSPIFFEID(cert *x509.Certificate) (string, error) {
+	var ids []string
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			ids = append(ids, uri.String())
+		}
+	}
+	if len(ids) != 1 {
+		return "", fmt.Errorf("client certificate carries %d SPIFFE IDs, expected 1", len(ids))
+	}
+	return ids[0], nil
+}
+
+// loadAdminTLSConfig reads the server certificate and client CA bundle of the admin API
+// The files are read per handshake so rotated SVIDs (e.g. written by a SPIFFE helper) apply
+// without a restart and no long-lived key material has to be baked into the plugin
+func loadAdminTLSConfig(config *DevicePluginConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.AdminTLSCertFile, config.AdminTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin TLS certificate: %w", err)
+	}
+	bundle, err := os.ReadFile(config.AdminTLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin TLS client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("admin TLS client CA %s holds no PEM certificate", config.AdminTLSClientCAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// wrapAdminListener applies the transport side of ADMIN_AUTH_MODE to the admin socket
+func (a *AdminServer) wrapAdminListener(listener net.Listener) (net.Listener, error) {
+	if a.config.AdminAuthMode != AdminAuthTLS {
+		return listener, nil
+	}
+	// Fail at startup rather than on the first handshake
+	if _, err := loadAdminTLSConfig(a.config); err != nil {
+		return nil, err
+	}
+	return tls.NewListener(listener, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return loadAdminTLSConfig(a.config)
+		},
+	}), nil
+}
+
+// adminConnContext records the peer credentials of a unix socket connection
+func (a *AdminServer) adminConnContext(ctx context.Context, conn net.Conn) context.Context {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	pid, uid, gid, err := peerCredentials(unixConn)
+	if err != nil {
+		a.logger.Debug("Cannot read admin API peer credentials", "error", err)
+		return ctx
+	}
+	return context.WithValue(ctx, adminPeerKey{}, adminPeer{PID: pid, UID: uid, GID: gid})
+}
+
+// authorize rejects admin API requests from callers ADMIN_AUTH_MODE does not allow
+func (a *AdminServer) authorize(next http.Handler) http.Handler {
+	if a.config.AdminAuthMode == AdminAuthNone {
+		return next
+	}
+	// Validated by validateConfig
+	uids, _ := parseAdminIDs(a.config.AdminAllowedUIDs, "ADMIN_ALLOWED_UIDS")
+	gids, _ := parseAdminIDs(a.config.AdminAllowedGIDs, "ADMIN_ALLOWED_GIDS")
+	spiffeIDs, _ := parseAdminSPIFFEIDs(a.config.AdminAllowedSPIFFEIDs)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var caller string
+		var err error
+		switch a.config.AdminAuthMode {
+		case AdminAuthPeerCred:
+			peer, ok := r.Context().Value(adminPeerKey{}).(adminPeer)
+			switch {
+			case !ok:
+				err = fmt.Errorf("peer credentials unavailable")
+			case !uids[peer.UID] && !gids[peer.GID]:
+				caller, err = peer.String(), fmt.Errorf("uid %d and gid %d are not allowed", peer.UID, peer.GID)
+			default:
+				caller = peer.String()
+			}
+		case AdminAuthTLS:
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				err = fmt.Errorf("no client certificate")
+				break
+			}
+			caller, err = certificateSPIFFEID(r.TLS.PeerCertificates[0])
+			if err == nil && !spiffeAllowed(caller, spiffeIDs) {
+				err = fmt.Errorf("SPIFFE ID %s is not allowed", caller)
+			}
+		}
+		if err != nil {
+			a.logger.Warn("Admin API request denied",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"caller", caller,
+				"reason", err)
+			writeError(w, http.StatusForbidden, "forbidden: "+err.Error())
+			return
+		}
+		a.logger.Debug("Admin API request", "method", r.Method, "path", r.URL.Path, "caller", caller)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminSocketMode is the permission of the admin socket file
+// Without authentication the file mode is the only guard; with it every local process may
+// connect and each request is checked
+func adminSocketMode(config *DevicePluginConfig) os.FileMode {
+	if config.AdminAuthMode == AdminAuthNone {
+		return 0o660
+	}
+	return 0o666
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// AdminServer serves the local admin API over a unix socket: device leases for
+// non-Kubernetes consumers (host services, docker-compose rigs) and node introspection
+type AdminServer struct {
+	config   *DevicePluginConfig
+	plugin   *VideoDevicePlugin
+	logger   *slog.Logger
+	server   *http.Server
+	listener net.Listener
+
+	// Closed for a socket takeover; the socket path belongs to the new instance
+	handedOver atomic.Bool
+}
+
+// leaseRequest is the body of lease and renew calls
+type leaseRequest struct {
+	Owner      string `json:"owner"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// leaseResponse is returned for lease, renew and release calls
+type leaseResponse struct {
+	Allocation
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// NewAdminServer creates a new AdminServer instance
+func NewAdminServer(config *DevicePluginConfig, plugin *VideoDevicePlugin, logger *slog.Logger) *AdminServer {
+	a := &AdminServer{
+		config: config,
+		plugin: plugin,
+		logger: logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/leases", a.handleListLeases)
+	mux.HandleFunc("POST /v1/leases", a.handleCreateLease)
+	mux.HandleFunc("POST /v1/leases/{id}/renew", a.handleRenewLease)
+	mux.HandleFunc("DELETE /v1/leases/{id}", a.handleReleaseLease)
+	mux.HandleFunc("GET /v1/devices", a.handleListDevices)
+	mux.HandleFunc("GET /v1/holders", a.handleDeviceHolders)
+	mux.HandleFunc("GET /v1/system", a.handleSystemInfo)
+	mux.HandleFunc("GET /v1/watch", a.handleWatch)
+	mux.HandleFunc("GET /v1/capacity", a.handleGetCapacity)
+	mux.HandleFunc("GET /v1/config", a.handleConfig)
+	mux.HandleFunc("GET /v1/listandwatch", a.handleListAndWatch)
+	mux.HandleFunc("GET /v1/quotas", a.handleNamespaceQuotas)
+	mux.HandleFunc("PUT /v1/capacity", a.handleSetCapacity)
+	mux.HandleFunc("POST /v1/pool/defragment", a.handleDefragmentPool)
+
+	a.server = &http.Server{
+		Handler:           a.authorize(mux),
+		ConnContext:       a.adminConnContext,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return a
+}
+
+// Start listens on the admin socket and serves requests in the background
+func (a *AdminServer) Start() error {
+	socketPath := a.config.AdminSocketPath
+	if err := ensureDirectory(filepath.Dir(socketPath)); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %w", err)
+	}
+	if err := cleanupSocket(socketPath); err != nil {
+		a.logger.Warn("Failed to cleanup existing admin socket", "error", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	// Restrict the socket to root and the owning group unless each request is authenticated
+	if err := privilegedChmod(socketPath, adminSocketMode(a.config)); err != nil {
+		a.logger.Warn("Failed to restrict admin socket permissions", "error", err)
+	}
+	served, err := a.wrapAdminListener(listener)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	a.listener = served
+
+	go func() {
+		a.logger.Info("Starting admin API", "socket", socketPath, "auth_mode", a.config.AdminAuthMode)
+		if err := a.server.Serve(served); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Error("Admin API failed", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop shuts down the admin API and removes its socket
+func (a *AdminServer) Stop() {
+	if a.handedOver.Load() {
+		return
+	}
+	if err := a.server.Close(); err != nil {
+		a.logger.Warn("Failed to close admin API", "error", err)
+	}
+	if err := cleanupSocket(a.config.AdminSocketPath); err != nil {
+		a.logger.Warn("Failed to cleanup admin socket", "error", err)
+	}
+}
+
+// handOver closes the admin API for a socket takeover without removing the socket path,
+// which the new instance re-creates when it starts its own admin API
+func (a *AdminServer) handOver() {
+	if a.handedOver.Swap(true) {
+		return
+	}
+	if err := a.server.Close(); err != nil {
+		a.logger.Warn("Failed to close admin API", "error", err)
+	}
+	a.logger.Info("Admin API closed, socket left to the new instance")
+}
+
+// handleListLeases returns all current allocations (kubelet and local)
+func (a *AdminServer) handleListLeases(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.plugin.allocations.List())
+}
+
+// handleCreateLease leases a free healthy device to a local consumer
+func (a *AdminServer) handleCreateLease(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.Owner == "" {
+		writeError(w, http.StatusBadRequest, "owner is required")
+		return
+	}
+
+	ttl, err := a.leaseTTL(req.TTLSeconds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Only healthy advertised devices outside av-bundles and tiers can be leased
+	var candidates []*VideoDevice
+	for _, device := range a.plugin.v4l2Manager.ListAllDevices() {
+		if a.plugin.v4l2Manager.GetDeviceHealth(device.ID) && a.plugin.deviceResource(device.ID) == a.plugin.config.ResourceName && !a.plugin.spares.HeldBack(device.ID) {
+			candidates = append(candidates, device)
+		}
+	}
+
+	allocation, err := a.plugin.allocations.Lease(candidates, req.Owner, ttl)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	device, err := a.plugin.v4l2Manager.GetDeviceByID(allocation.DeviceID)
+	if err == nil {
+		err = a.plugin.runDeviceHooks(r.Context(), HookStageAllocate, device)
+	}
+	if err != nil {
+		_, _ = a.plugin.allocations.Release(allocation.LeaseID)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Kubelet must see the leased device Unhealthy before it places a pod on it
+	a.plugin.requestListAndWatchRefresh()
+
+	a.plugin.decisions.Publish(DecisionEvent{
+		Kind:     DecisionAllocation,
+		Action:   "leased",
+		DeviceID: allocation.DeviceID,
+		Fields:   map[string]string{"lease_id": allocation.LeaseID, "owner": allocation.Owner},
+	})
+	a.logger.Info("Local device lease granted",
+		"lease_id", allocation.LeaseID,
+		"device_id", allocation.DeviceID,
+		"owner", allocation.Owner,
+		"expires_at", allocation.ExpiresAt)
+	writeJSON(w, http.StatusCreated, leaseResponse{
+		Allocation: *allocation,
+		Env:        map[string]string{"VIDEO_DEVICE": allocation.DevicePath},
+	})
+}
+
+// handleRenewLease extends an existing local lease
+func (a *AdminServer) handleRenewLease(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+	}
+
+	ttl, err := a.leaseTTL(req.TTLSeconds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	allocation, err := a.plugin.allocations.Renew(r.PathValue("id"), ttl)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	a.logger.Debug("Local device lease renewed",
+		"lease_id", allocation.LeaseID,
+		"device_id", allocation.DeviceID,
+		"expires_at", allocation.ExpiresAt)
+	writeJSON(w, http.StatusOK, leaseResponse{Allocation: *allocation})
+}
+
+// handleReleaseLease ends a local lease, returning the device to kubelet
+func (a *AdminServer) handleReleaseLease(w http.ResponseWriter, r *http.Request) {
+	allocation, err := a.plugin.allocations.Release(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if device, err := a.plugin.v4l2Manager.GetDeviceByID(allocation.DeviceID); err == nil {
+		if err := a.plugin.runDeviceHooks(r.Context(), HookStageRelease, device); err != nil {
+			a.logger.Warn("Release hooks failed", "device_id", allocation.DeviceID, "error", err)
+		}
+	}
+	a.plugin.requestListAndWatchRefresh()
+
+	a.plugin.decisions.Publish(DecisionEvent{
+		Kind:     DecisionRelease,
+		Action:   "lease_released",
+		DeviceID: allocation.DeviceID,
+		Fields:   map[string]string{"lease_id": allocation.LeaseID, "owner": allocation.Owner},
+	})
+	a.logger.Info("Local device lease released",
+		"lease_id", allocation.LeaseID,
+		"device_id", allocation.DeviceID,
+		"owner", allocation.Owner)
+	writeJSON(w, http.StatusOK, leaseResponse{Allocation: *allocation})
+}
+
+// deviceStatus is a device with its current health, as returned by /v1/devices
+type deviceStatus struct {
+	VideoDevice
+	Healthy    bool                 `json:"healthy"`
+	Role       string               `json:"role"`
+	SkipReason string               `json:"skip_reason,omitempty"`
+	Labels     *DeviceLabels        `json:"labels,omitempty"`
+	History    *DeviceHealthHistory `json:"health_history,omitempty"`
+}
+
+// handleListDevices returns every advertised device with its metadata and health
+func (a *AdminServer) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	skipped := a.plugin.v4l2Manager.GetSkippedDevices()
+
+	devices := a.plugin.v4l2Manager.ListAllDevices()
+	result := make([]deviceStatus, 0, len(devices))
+	for _, device := range devices {
+		status := deviceStatus{
+			VideoDevice: *device,
+			Healthy:     a.plugin.v4l2Manager.GetDeviceHealth(device.ID),
+			SkipReason:  skipped[device.ID],
+			Role:        a.plugin.deviceRole(device.ID),
+		}
+		if labels, ok := a.plugin.labels.Get(device.ID); ok {
+			status.Labels = &labels
+		}
+		if history, ok := a.plugin.health.Get(device.ID); ok {
+			status.History = &history
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleSystemInfo returns kernel, module and runtime facts for triage
+func (a *AdminServer) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, NewSystemInspector(a.logger).Inspect())
+}
+
+// leaseTTL validates a requested TTL, applying the configured default and maximum
+func (a *AdminServer) leaseTTL(seconds int) (time.Duration, error) {
+	if seconds == 0 {
+		seconds = a.config.LeaseDefaultTTL
+	}
+	if seconds < 0 || seconds > a.config.LeaseMaxTTL {
+		return 0, fmt.Errorf("ttl_seconds must be between 1 and %d, got %d", a.config.LeaseMaxTTL, seconds)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
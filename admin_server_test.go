@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestLeaseChangesRefreshDeviceList(t *testing.T) {
+	plugin := newTestPlugin(t, newFakeV4L2Manager(1), func(config *DevicePluginConfig) {
+		config.LeaseDefaultTTL = 60
+		config.LeaseMaxTTL = 3600
+	})
+	admin := NewAdminServer(plugin.config, plugin, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	// Preparation asked for a send no stream was open for yet
+	select {
+	case <-plugin.refreshCh:
+	default:
+	}
+	assertRefresh := func(action, wantHealth string) {
+		t.Helper()
+		select {
+		case <-plugin.refreshCh:
+		default:
+			t.Errorf("%s did not request a ListAndWatch send", action)
+		}
+		devices, _ := plugin.buildDeviceList()
+		if devices[0].Health != wantHealth {
+			t.Errorf("video10 health after %s = %q, want %q", action, devices[0].Health, wantHealth)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	admin.handleCreateLease(rec, httptest.NewRequest(http.MethodPost, "/v1/leases", strings.NewReader(`{"owner":"test"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("lease status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	var lease leaseResponse
+	if err := json.NewDecoder(rec.Body).Decode(&lease); err != nil {
+		t.Fatal(err)
+	}
+	assertRefresh("lease", pluginapi.Unhealthy)
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/leases/"+lease.LeaseID, nil)
+	req.SetPathValue("id", lease.LeaseID)
+	rec = httptest.NewRecorder()
+	admin.handleReleaseLease(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("release status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	assertRefresh("release", pluginapi.Healthy)
+}
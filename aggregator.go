@@ -0,0 +1,211 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeCapacity is one node's view of a device resource
+// Kubelet subtracts unhealthy devices from allocatable, so capacity-allocatable is the unhealthy count
+type NodeCapacity struct {
+	Node      string `json:"node"`
+	Ready     bool   `json:"ready"`
+	Total     int64  `json:"total"`
+	Unhealthy int64  `json:"unhealthy"`
+	Allocated int64  `json:"allocated"`
+	Free      int64  `json:"free"`
+}
+
+// ResourceSummary aggregates one device resource across the cluster
+type ResourceSummary struct {
+	Resource  string         `json:"resource"`
+	Total     int64          `json:"total"`
+	Unhealthy int64          `json:"unhealthy"`
+	Allocated int64          `json:"allocated"`
+	Free      int64          `json:"free"`
+	Nodes     []NodeCapacity `json:"nodes"`
+}
+
+// ClusterSummary is returned by the aggregator summary endpoint
+type ClusterSummary struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Resources   []ResourceSummary `json:"resources"`
+}
+
+// Aggregator computes cluster-wide device capacity from node status and pod requests
+type Aggregator struct {
+	client    *K8sClient
+	resources []string
+	cacheTTL  time.Duration
+	logger    *slog.Logger
+
+	mu       sync.Mutex
+	cached   *ClusterSummary
+	cachedAt time.Time
+}
+
+// NewAggregator creates a new Aggregator for the given resource names
+func NewAggregator(client *K8sClient, resources []string, cacheTTL time.Duration, logger *slog.Logger) *Aggregator {
+	return &Aggregator{
+		client:    client,
+		resources: resources,
+		cacheTTL:  cacheTTL,
+		logger:    logger,
+	}
+}
+
+// Summary returns the cluster summary, recomputing it when the cache is stale
+func (a *Aggregator) Summary(ctx context.Context) (*ClusterSummary, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cached != nil && time.Since(a.cachedAt) < a.cacheTTL {
+		return a.cached, nil
+	}
+
+	summary, err := a.compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.cached = summary
+	a.cachedAt = time.Now()
+	return summary, nil
+}
+
+// compute lists nodes and non-terminated pods and folds them into per-resource totals
+func (a *Aggregator) compute(ctx context.Context) (*ClusterSummary, error) {
+	clientset := a.client.clientset
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	// node -> resource -> devices requested by scheduled pods
+	allocated := make(map[string]map[string]int64)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		for _, resource := range a.resources {
+			if requested := podDeviceRequest(pod, resource); requested > 0 {
+				if allocated[pod.Spec.NodeName] == nil {
+					allocated[pod.Spec.NodeName] = make(map[string]int64)
+				}
+				allocated[pod.Spec.NodeName][resource] += requested
+			}
+		}
+	}
+
+	summary := &ClusterSummary{GeneratedAt: time.Now()}
+	for _, resource := range a.resources {
+		resourceSummary := ResourceSummary{Resource: resource, Nodes: []NodeCapacity{}}
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			capacity, ok := node.Status.Capacity[corev1.ResourceName(resource)]
+			if !ok {
+				continue
+			}
+			allocatable := node.Status.Allocatable[corev1.ResourceName(resource)]
+
+			entry := NodeCapacity{
+				Node:      node.Name,
+				Ready:     nodeReady(node),
+				Total:     capacity.Value(),
+				Unhealthy: capacity.Value() - allocatable.Value(),
+				Allocated: allocated[node.Name][resource],
+			}
+			entry.Free = max(allocatable.Value()-entry.Allocated, 0)
+			// Devices on NotReady nodes cannot be scheduled
+			if !entry.Ready {
+				entry.Free = 0
+			}
+
+			resourceSummary.Total += entry.Total
+			resourceSummary.Unhealthy += entry.Unhealthy
+			resourceSummary.Allocated += entry.Allocated
+			resourceSummary.Free += entry.Free
+			resourceSummary.Nodes = append(resourceSummary.Nodes, entry)
+		}
+		sort.Slice(resourceSummary.Nodes, func(i, j int) bool { return resourceSummary.Nodes[i].Node < resourceSummary.Nodes[j].Node })
+		summary.Resources = append(summary.Resources, resourceSummary)
+	}
+
+	return summary, nil
+}
+
+// nodeReady reports the node's Ready condition
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// runAggregator runs the cluster-wide capacity aggregator until ctx is canceled
+func runAggregator(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	client, err := NewK8sClient(config, logger)
+	if err != nil {
+		return err
+	}
+
+	resources := []string{config.ResourceName}
+	if config.AVBundleResourceName != "" && config.AVBundleResourceName != config.ResourceName {
+		resources = append(resources, config.AVBundleResourceName)
+	}
+	aggregator := NewAggregator(client, resources, time.Duration(config.AggregatorCacheTTL)*time.Second, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/summary", func(w http.ResponseWriter, r *http.Request) {
+		summary, err := aggregator.Summary(r.Context())
+		if err != nil {
+			logger.Warn("Failed to compute cluster summary", "error", err)
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", config.AggregatorPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Starting capacity aggregator", "port", config.AggregatorPort, "resources", resources)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Aggregator server failed", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(config.ShutdownTimeout)*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// AllocateLimiter bounds how many Allocate calls prepare devices at once
+// A burst of pod placements (e.g. scaling up 8 bots) otherwise runs device resets, handoff
+// files and preparation hooks of different pods interleaved, which has left devices with the
+// permissions one hook applied for another pod
+type AllocateLimiter struct {
+	slots   chan struct{} // Nil when concurrency is unbounded
+	waiting atomic.Int64
+	metrics *Metrics
+}
+
+// NewAllocateLimiter creates a limiter admitting limit concurrent calls (0 = unbounded)
+func NewAllocateLimiter(limit int, metrics *Metrics) *AllocateLimiter {
+	limiter := &AllocateLimiter{metrics: metrics}
+	if limit > 0 {
+		limiter.slots = make(chan struct{}, limit)
+	}
+	return limiter
+}
+
+// Acquire waits for a free slot until ctx is done and returns the function releasing it
+// The wait counts toward the caller's deadline, so a queued call still answers kubelet in time
+func (l *AllocateLimiter) Acquire(ctx context.Context) (func(), time.Duration, error) {
+	if l.slots == nil {
+		return func() {}, 0, nil
+	}
+
+	start := time.Now()
+	l.metrics.SetAllocateQueueDepth(int(l.waiting.Add(1)))
+	defer func() {
+		l.metrics.SetAllocateQueueDepth(int(l.waiting.Add(-1)))
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+
+	wait := time.Since(start)
+	l.metrics.ObserveAllocateQueueWait(wait)
+	l.metrics.SetAllocationsInFlight(len(l.slots))
+	return func() {
+		<-l.slots
+		l.metrics.SetAllocationsInFlight(len(l.slots))
+	}, wait, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// allocateErrorDomain is the ErrorInfo domain of Allocate rejections
+const allocateErrorDomain = "video-device-plugin.meeting-baas.io"
+
+// Allocate rejection reasons carried in the ErrorInfo detail
+const (
+	AllocateReasonUnknownDevice       = "UnknownDevice"       // ID not in the current inventory
+	AllocateReasonDeviceNotAdvertised = "DeviceNotAdvertised" // Reserved for another resource, a hot spare or under repair
+	AllocateReasonDeviceLocallyLeased = "DeviceLocallyLeased" // Held by a lease from the admin API
+	AllocateReasonDeviceIDRotated     = "DeviceIDRotated"     // ID retired when the device was recovered
+	AllocateReasonDeviceOtherKubelet  = "DeviceOtherKubelet"  // Allocated through another kubelet (ADDITIONAL_KUBELET_SOCKETS)
+)
+
+// validateDeviceIDs checks requested device IDs against the current inventory
+// Kubelet only hands out IDs from its last ListAndWatch view, so a rejection means that view is
+// stale (e.g. a module reload or spare promotion since the last send); an immediate refresh is queued
+// kubelet is the additional kubelet socket the request came through, empty for the primary
+func (p *VideoDevicePlugin) validateDeviceIDs(resource, kubelet string, kubeletIDs []string) error {
+	for _, kubeletID := range kubeletIDs {
+		err := p.staleDeviceIDError(kubeletID)
+		if p.rotation.Current(kubeletID) {
+			deviceID, _ := splitKubeletDeviceID(kubeletID)
+			err = p.validateDeviceID(resource, kubelet, deviceID)
+		}
+		if err != nil {
+			p.metrics.IncAllocationRejections(status.Convert(err).Code().String())
+			p.requestListAndWatchRefresh()
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDeviceID returns a typed gRPC error when deviceID must not be allocated through resource
+func (p *VideoDevicePlugin) validateDeviceID(resource, kubelet, deviceID string) error {
+	device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+	if err != nil {
+		return allocateError(codes.NotFound, AllocateReasonUnknownDevice,
+			fmt.Sprintf("device %s is not managed by this plugin", deviceID),
+			map[string]string{"device_id": deviceID})
+	}
+
+	metadata := map[string]string{
+		"device_id":  deviceID,
+		"generation": strconv.Itoa(device.Generation),
+	}
+	switch {
+	case p.excluded[deviceID]:
+		metadata["role"] = DeviceRoleExcluded
+		return allocateError(codes.FailedPrecondition, AllocateReasonDeviceNotAdvertised,
+			fmt.Sprintf("device %s is excluded from advertisement", deviceID), metadata)
+	case p.deviceResource(deviceID) != resource:
+		metadata["role"] = DeviceRoleBundle
+		if _, tiered := p.tiers[deviceID]; tiered {
+			metadata["role"] = DeviceRoleTier
+		}
+		return allocateError(codes.FailedPrecondition, AllocateReasonDeviceNotAdvertised,
+			fmt.Sprintf("device %s is reserved for the %s resource", deviceID, p.deviceResource(deviceID)), metadata)
+	case p.spares.HeldBack(deviceID):
+		metadata["role"] = p.spares.Role(deviceID)
+		return allocateError(codes.FailedPrecondition, AllocateReasonDeviceNotAdvertised,
+			fmt.Sprintf("device %s is not advertised (%s)", deviceID, metadata["role"]), metadata)
+	case p.allocations.IsLocallyLeased(deviceID):
+		// Kubelet may race a local lease before the next ListAndWatch update
+		return allocateError(codes.FailedPrecondition, AllocateReasonDeviceLocallyLeased,
+			fmt.Sprintf("device %s is held by a local lease", deviceID), metadata)
+	}
+	if holder, held := p.allocations.HeldByOtherKubelet(deviceID, kubelet); held {
+		// The other kubelets see the device Unhealthy once their next device list went out
+		metadata["kubelet"] = holder
+		return allocateError(codes.FailedPrecondition, AllocateReasonDeviceOtherKubelet,
+			fmt.Sprintf("device %s is allocated through kubelet %s", deviceID, holder), metadata)
+	}
+	return nil
+}
+
+// allocateError builds a gRPC status error with an ErrorInfo detail
+func allocateError(code codes.Code, reason, message string, metadata map[string]string) error {
+	st := status.New(code, message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   allocateErrorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// requestListAndWatchRefresh makes ListAndWatch send the device list now instead of on its next tick
+// Every kubelet's stream is woken, the additional ones included
+func (p *VideoDevicePlugin) requestListAndWatchRefresh() {
+	select {
+	case p.refreshCh <- struct{}{}:
+	default:
+	}
+	for _, endpoint := range p.endpoints {
+		select {
+		case endpoint.refreshCh <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateDeviceIDs(t *testing.T) {
+	const resource = "meeting-baas.io/video-devices"
+	const otherKubelet = "/var/lib/kubelet-b/device-plugins/kubelet.sock"
+
+	tests := []struct {
+		name       string
+		resource   string
+		kubeletID  string
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{name: "current ID", resource: resource, kubeletID: "video11"},
+		{name: "rotated ID", resource: resource, kubeletID: "video10" + deviceIDRotationSeparator + "1"},
+		{name: "unknown device", resource: resource, kubeletID: "video99", wantCode: codes.NotFound, wantReason: AllocateReasonUnknownDevice},
+		{name: "retired ID", resource: resource, kubeletID: "video10", wantCode: codes.FailedPrecondition, wantReason: AllocateReasonDeviceIDRotated},
+		{name: "other resource", resource: "meeting-baas.io/video-premium", kubeletID: "video11", wantCode: codes.FailedPrecondition, wantReason: AllocateReasonDeviceNotAdvertised},
+		{name: "parked", resource: resource, kubeletID: "video12", wantCode: codes.FailedPrecondition, wantReason: AllocateReasonDeviceNotAdvertised},
+		{name: "locally leased", resource: resource, kubeletID: "video13", wantCode: codes.FailedPrecondition, wantReason: AllocateReasonDeviceLocallyLeased},
+		{name: "other kubelet", resource: resource, kubeletID: "video14", wantCode: codes.FailedPrecondition, wantReason: AllocateReasonDeviceOtherKubelet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newFakeV4L2Manager(5)
+			plugin := newTestPlugin(t, manager, func(config *DevicePluginConfig) {
+				config.EnableDeviceIDRotation = true
+			})
+			plugin.RotateDeviceIDs("test", []string{"video10"})
+			plugin.spares.park([]string{"video12"})
+			if _, err := plugin.allocations.Lease([]*VideoDevice{manager.devices["video13"]}, "test", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if err := plugin.allocations.RecordKubeletAllocation([]*VideoDevice{manager.devices["video14"]}, "other", otherKubelet); err != nil {
+				t.Fatal(err)
+			}
+			// Setup changes asked for sends no stream was open for yet
+			select {
+			case <-plugin.refreshCh:
+			default:
+			}
+
+			err := plugin.validateDeviceIDs(tt.resource, "", []string{tt.kubeletID})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("validateDeviceIDs(%s) code = %s, want %s (%v)", tt.kubeletID, code, tt.wantCode, err)
+			}
+
+			refreshed := false
+			select {
+			case <-plugin.refreshCh:
+				refreshed = true
+			default:
+			}
+			if rejected := tt.wantCode != codes.OK; refreshed != rejected {
+				t.Errorf("device list refresh requested = %t, want %t", refreshed, rejected)
+			}
+			if tt.wantReason == "" {
+				return
+			}
+
+			var reason string
+			for _, detail := range status.Convert(err).Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok {
+					reason = info.Reason
+				}
+			}
+			if reason != tt.wantReason {
+				t.Errorf("rejection reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Allocation sources
+const (
+	AllocationSourceKubelet = "kubelet"
+	AllocationSourceLocal   = "local"
+)
+
+// AllocationIDAnnotation carries the Allocate correlation ID into the container runtime
+const AllocationIDAnnotation = "meeting-baas.io/allocation-id"
+
+// Allocation records a device handed out either by kubelet or through a local lease
+type Allocation struct {
+	DeviceID      string    `json:"device_id"`
+	DevicePath    string    `json:"device_path"`
+	Source        string    `json:"source"`                   // kubelet or local
+	LeaseID       string    `json:"lease_id,omitempty"`       // Local leases only
+	Owner         string    `json:"owner,omitempty"`          // Free-form holder description for local leases
+	CorrelationID string    `json:"correlation_id,omitempty"` // Allocate call correlation ID (kubelet allocations)
+	Kubelet       string    `json:"kubelet,omitempty"`        // Additional kubelet socket the allocation came through (empty for the primary)
+	AllocatedAt   time.Time `json:"allocated_at"`             // When the allocation was made
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`     // Local lease expiry (zero for kubelet allocations)
+	RenewedAt     time.Time `json:"renewed_at,omitempty"`     // Last lease renewal
+}
+
+// AllocationTracker is the shared bookkeeping of allocated devices
+type AllocationTracker struct {
+	mu          sync.Mutex
+	allocations map[string]*Allocation // device ID -> allocation
+	onChange    func()                 // Called after every mutation
+}
+
+// NewAllocationTracker creates a new AllocationTracker instance
+func NewAllocationTracker() *AllocationTracker {
+	return &AllocationTracker{
+		allocations: make(map[string]*Allocation),
+	}
+}
+
+// SetChangeHook registers fn to be called after every mutation
+func (t *AllocationTracker) SetChangeHook(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = fn
+}
+
+// notifyChangeLocked calls the change hook; caller must hold t.mu
+func (t *AllocationTracker) notifyChangeLocked() {
+	if t.onChange != nil {
+		t.onChange()
+	}
+}
+
+// Restore re-adds allocations saved by a previous instance, dropping expired local leases
+func (t *AllocationTracker) Restore(saved []Allocation) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	restored := 0
+	for _, allocation := range saved {
+		if allocation.Source == AllocationSourceLocal && now.After(allocation.ExpiresAt) {
+			continue
+		}
+		copied := allocation
+		t.allocations[allocation.DeviceID] = &copied
+		restored++
+	}
+	return restored
+}
+
+// RecordKubeletAllocation records devices a kubelet allocated to a container
+// A new allocation for a device supersedes any previous record of the same kubelet; nothing is
+// recorded when a device is held through another kubelet
+func (t *AllocationTracker) RecordKubeletAllocation(devices []*VideoDevice, correlationID, kubelet string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, device := range devices {
+		if holder, held := t.otherKubeletLocked(device.ID, kubelet); held {
+			return fmt.Errorf("device %s is allocated through kubelet %s", device.ID, holder)
+		}
+	}
+
+	now := time.Now()
+	for _, device := range devices {
+		t.allocations[device.ID] = &Allocation{
+			DeviceID:      device.ID,
+			DevicePath:    device.Path,
+			Source:        AllocationSourceKubelet,
+			AllocatedAt:   now,
+			CorrelationID: correlationID,
+			Kubelet:       kubelet,
+		}
+	}
+	t.notifyChangeLocked()
+	return nil
+}
+
+// HeldByOtherKubelet reports whether deviceID is allocated through a kubelet other than kubelet
+// (empty for the primary) and returns that kubelet's socket, "primary" for the primary one
+func (t *AllocationTracker) HeldByOtherKubelet(deviceID, kubelet string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.otherKubeletLocked(deviceID, kubelet)
+}
+
+func (t *AllocationTracker) otherKubeletLocked(deviceID, kubelet string) (string, bool) {
+	allocation, exists := t.allocations[deviceID]
+	if !exists || allocation.Source != AllocationSourceKubelet || allocation.Kubelet == kubelet {
+		return "", false
+	}
+	if allocation.Kubelet == "" {
+		return "primary", true
+	}
+	return allocation.Kubelet, true
+}
+
+// Lease allocates the first free device from candidates to a local consumer for ttl
+func (t *AllocationTracker) Lease(candidates []*VideoDevice, owner string, ttl time.Duration) (*Allocation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked()
+
+	// Deterministic choice: lowest device ID first
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	for _, device := range candidates {
+		if _, taken := t.allocations[device.ID]; taken {
+			continue
+		}
+
+		leaseID, err := newLeaseID()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		allocation := &Allocation{
+			DeviceID:    device.ID,
+			DevicePath:  device.Path,
+			Source:      AllocationSourceLocal,
+			LeaseID:     leaseID,
+			Owner:       owner,
+			AllocatedAt: now,
+			ExpiresAt:   now.Add(ttl),
+			RenewedAt:   now,
+		}
+		t.allocations[device.ID] = allocation
+		t.notifyChangeLocked()
+		copied := *allocation
+		return &copied, nil
+	}
+
+	return nil, fmt.Errorf("no free devices available for lease")
+}
+
+// Renew extends a local lease by ttl from now
+func (t *AllocationTracker) Renew(leaseID string, ttl time.Duration) (*Allocation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked()
+
+	allocation := t.findLeaseLocked(leaseID)
+	if allocation == nil {
+		return nil, fmt.Errorf("lease not found: %s", leaseID)
+	}
+
+	now := time.Now()
+	allocation.ExpiresAt = now.Add(ttl)
+	allocation.RenewedAt = now
+	t.notifyChangeLocked()
+	copied := *allocation
+	return &copied, nil
+}
+
+// Release ends a local lease
+func (t *AllocationTracker) Release(leaseID string) (*Allocation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	allocation := t.findLeaseLocked(leaseID)
+	if allocation == nil {
+		return nil, fmt.Errorf("lease not found: %s", leaseID)
+	}
+
+	delete(t.allocations, allocation.DeviceID)
+	t.notifyChangeLocked()
+	return allocation, nil
+}
+
+// CorrelationID returns the Allocate correlation ID recorded for a device, if any
+func (t *AllocationTracker) CorrelationID(deviceID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if allocation, exists := t.allocations[deviceID]; exists {
+		return allocation.CorrelationID
+	}
+	return ""
+}
+
+// IsLocallyLeased reports whether a device is currently held by a local lease
+func (t *AllocationTracker) IsLocallyLeased(deviceID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked()
+
+	allocation, exists := t.allocations[deviceID]
+	return exists && allocation.Source == AllocationSourceLocal
+}
+
+// ReleaseCorrelation drops the kubelet allocations recorded by one Allocate call
+func (t *AllocationTracker) ReleaseCorrelation(correlationID string) []Allocation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var released []Allocation
+	for deviceID, allocation := range t.allocations {
+		if allocation.Source == AllocationSourceKubelet && allocation.CorrelationID == correlationID {
+			released = append(released, *allocation)
+			delete(t.allocations, deviceID)
+		}
+	}
+	if len(released) > 0 {
+		t.notifyChangeLocked()
+	}
+	return released
+}
+
+// ReleaseKubeletExcept drops kubelet allocations of devices not in assigned that are older than grace
+// The grace period covers the window between Allocate and kubelet recording the assignment
+func (t *AllocationTracker) ReleaseKubeletExcept(assigned map[string]bool, grace time.Duration) []Allocation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-grace)
+	var released []Allocation
+	for deviceID, allocation := range t.allocations {
+		if allocation.Source != AllocationSourceKubelet || assigned[deviceID] || allocation.AllocatedAt.After(cutoff) {
+			continue
+		}
+		released = append(released, *allocation)
+		delete(t.allocations, deviceID)
+	}
+	if len(released) > 0 {
+		t.notifyChangeLocked()
+	}
+	sort.Slice(released, func(i, j int) bool { return released[i].DeviceID < released[j].DeviceID })
+	return released
+}
+
+// ReleaseKubeletDevices drops the kubelet allocations of deviceIDs made before cutoff
+// Allocations made later belong to a pod kubelet already handed the device to again
+func (t *AllocationTracker) ReleaseKubeletDevices(deviceIDs map[string]bool, cutoff time.Time) []Allocation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var released []Allocation
+	for deviceID := range deviceIDs {
+		allocation, exists := t.allocations[deviceID]
+		if !exists || allocation.Source != AllocationSourceKubelet || allocation.AllocatedAt.After(cutoff) {
+			continue
+		}
+		released = append(released, *allocation)
+		delete(t.allocations, deviceID)
+	}
+	if len(released) > 0 {
+		t.notifyChangeLocked()
+	}
+	sort.Slice(released, func(i, j int) bool { return released[i].DeviceID < released[j].DeviceID })
+	return released
+}
+
+// List returns a snapshot of all current allocations sorted by device ID
+func (t *AllocationTracker) List() []Allocation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked()
+
+	result := make([]Allocation, 0, len(t.allocations))
+	for _, allocation := range t.allocations {
+		result = append(result, *allocation)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DeviceID < result[j].DeviceID })
+	return result
+}
+
+// expireLocked drops local leases past their expiry; caller must hold t.mu
+func (t *AllocationTracker) expireLocked() {
+	now := time.Now()
+	expired := false
+	for deviceID, allocation := range t.allocations {
+		if allocation.Source == AllocationSourceLocal && now.After(allocation.ExpiresAt) {
+			delete(t.allocations, deviceID)
+			expired = true
+		}
+	}
+	if expired {
+		t.notifyChangeLocked()
+	}
+}
+
+// findLeaseLocked finds a local lease by ID; caller must hold t.mu
+func (t *AllocationTracker) findLeaseLocked(leaseID string) *Allocation {
+	for _, allocation := range t.allocations {
+		if allocation.Source == AllocationSourceLocal && allocation.LeaseID == leaseID {
+			return allocation
+		}
+	}
+	return nil
+}
+
+// newLeaseID generates a random lease identifier
+func newLeaseID() (string, error) {
+	id, err := randomHexID(8)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate lease ID: %w", err)
+	}
+	return id, nil
+}
+
+// newCorrelationID generates a random Allocate correlation identifier
+func newCorrelationID() (string, error) {
+	id, err := randomHexID(8)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate correlation ID: %w", err)
+	}
+	return id, nil
+}
+
+// randomHexID returns n random bytes hex-encoded
+func randomHexID(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// allocationReplayEntry is a cached Allocate response for one device ID set
+type allocationReplayEntry struct {
+	response      *pluginapi.ContainerAllocateResponse
+	correlationID string // Allocate call the response belongs to
+	allocatedAt   time.Time
+}
+
+// AllocationReplayCache remembers recent container Allocate responses keyed by device ID set,
+// so kubelet replays after a restart receive identical responses
+type AllocationReplayCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]allocationReplayEntry
+}
+
+// NewAllocationReplayCache creates a cache that remembers responses for window
+func NewAllocationReplayCache(window time.Duration) *AllocationReplayCache {
+	return &AllocationReplayCache{
+		window:  window,
+		entries: make(map[string]allocationReplayEntry),
+	}
+}
+
+// Get returns a copy of the cached response for deviceIDs if it is within the replay window and
+// every device is still allocated under the call that produced it; correlationOf returns the
+// correlation ID a requested device ID is allocated under now. Entries are keyed by the device
+// IDs exactly as requested, so Put and Get must both be given kubelet's IDs. A device released and handed to another pod
+// within the window gets a fresh allocation instead of the previous pod's response.
+func (c *AllocationReplayCache) Get(deviceIDs []string, correlationOf func(deviceID string) string) (*pluginapi.ContainerAllocateResponse, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireLocked()
+
+	key := allocationKey(deviceIDs)
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, time.Time{}, false
+	}
+	for _, deviceID := range deviceIDs {
+		if correlationOf(deviceID) != entry.correlationID {
+			delete(c.entries, key)
+			return nil, time.Time{}, false
+		}
+	}
+
+	response, err := cloneContainerAllocateResponse(entry.response)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return response, entry.allocatedAt, true
+}
+
+// Put stores a copy of the response for deviceIDs allocated by the call correlationID
+func (c *AllocationReplayCache) Put(deviceIDs []string, correlationID string, response *pluginapi.ContainerAllocateResponse) {
+	if c.window <= 0 {
+		return
+	}
+
+	stored, err := cloneContainerAllocateResponse(response)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[allocationKey(deviceIDs)] = allocationReplayEntry{
+		response:      stored,
+		correlationID: correlationID,
+		allocatedAt:   time.Now(),
+	}
+}
+
+// Clear drops all cached responses, e.g. when the devices they name were invalidated
+func (c *AllocationReplayCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]allocationReplayEntry)
+}
+
+// expireLocked drops entries older than the replay window; caller must hold c.mu
+func (c *AllocationReplayCache) expireLocked() {
+	cutoff := time.Now().Add(-c.window)
+	for key, entry := range c.entries {
+		if entry.allocatedAt.Before(cutoff) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// allocationKey builds an order-independent key for a device ID set
+func allocationKey(deviceIDs []string) string {
+	sorted := append([]string(nil), deviceIDs...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// cloneContainerAllocateResponse deep-copies a response through its protobuf encoding
+func cloneContainerAllocateResponse(response *pluginapi.ContainerAllocateResponse) (*pluginapi.ContainerAllocateResponse, error) {
+	data, err := response.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	clone := &pluginapi.ContainerAllocateResponse{}
+	if err := clone.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
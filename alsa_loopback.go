@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// alsaLoopbackModule is the kernel module providing ALSA loopback cards
+const alsaLoopbackModule = "snd_aloop"
+
+// loadALSALoopbackModule loads snd-aloop with one card per av-bundle at fixed indices
+func loadALSALoopbackModule(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	if !config.ManageModule {
+		for _, bundle := range buildAVBundles(config) {
+			if !alsaCardExists(bundle.ALSACard) {
+				logger.Warn("ALSA loopback card missing and MANAGE_MODULE=false; bundle will be unhealthy",
+					"bundle_id", bundle.ID,
+					"alsa_card", bundle.ALSACard)
+			}
+		}
+		return nil
+	}
+
+	if loaded, _ := isModuleLoaded(alsaLoopbackModule); loaded {
+		logger.Info("snd-aloop module already loaded, reusing existing cards")
+		return nil
+	}
+
+	enable := make([]string, config.AVBundleCount)
+	index := make([]string, config.AVBundleCount)
+	ids := make([]string, config.AVBundleCount)
+	for i, bundle := range buildAVBundles(config) {
+		enable[i] = "1"
+		index[i] = fmt.Sprintf("%d", bundle.ALSACard)
+		ids[i] = fmt.Sprintf("AVLoop%d", i)
+	}
+
+	args := []string{
+		"snd-aloop",
+		"enable=" + strings.Join(enable, ","),
+		"index=" + strings.Join(index, ","),
+		"id=" + strings.Join(ids, ","),
+		"pcm_substreams=1",
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
+	defer cancel()
+	if out, err := moduleCommand(ctx, "modprobe", args...); err != nil {
+		return fmt.Errorf("failed to load snd-aloop: %w (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+
+	logger.Info("Loaded snd-aloop module", "cards", config.AVBundleCount, "first_index", config.ALSACardStartIndex)
+	return nil
+}
+
+// cleanupALSALoopbackModule unloads snd-aloop if the plugin manages modules
+func cleanupALSALoopbackModule(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) {
+	if !config.ManageModule {
+		return
+	}
+	if loaded, _ := isModuleLoaded(alsaLoopbackModule); !loaded {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.CleanupTimeout)*time.Second)
+	defer cancel()
+	if out, err := moduleCommand(ctx, "modprobe", "-r", "snd-aloop"); err != nil {
+		logger.Warn("Failed to unload snd-aloop module", "error", err, "output", strings.TrimSpace(string(out)))
+		return
+	}
+	logger.Info("Unloaded snd-aloop module")
+}
+
+// alsaCardExists reports whether the control node for an ALSA card is present
+func alsaCardExists(card int) bool {
+	return checkDeviceExists(fmt.Sprintf("/dev/snd/controlC%d", card))
+}
+
+// alsaCardDevicePaths returns the device nodes a container needs to use an ALSA card
+func alsaCardDevicePaths(card int) ([]string, error) {
+	control := fmt.Sprintf("/dev/snd/controlC%d", card)
+	if !checkDeviceExists(control) {
+		return nil, fmt.Errorf("ALSA card %d not found", card)
+	}
+
+	pcms, err := filepath.Glob(fmt.Sprintf("/dev/snd/pcmC%dD*", card))
+	if err != nil {
+		return nil, err
+	}
+	if len(pcms) == 0 {
+		return nil, fmt.Errorf("ALSA card %d has no PCM devices", card)
+	}
+
+	paths := append([]string{control}, pcms...)
+	if _, err := os.Stat("/dev/snd/timer"); err == nil {
+		paths = append(paths, "/dev/snd/timer")
+	}
+	return paths, nil
+}
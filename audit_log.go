@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audited operation kinds
+const (
+	AuditOpExec    = "exec"
+	AuditOpChmod   = "chmod"
+	AuditOpChown   = "chown"
+	AuditOpSymlink = "symlink"
+	AuditOpWrite   = "write"
+	AuditOpRemove  = "remove"
+)
+
+// auditOutputLimit caps the command output kept in a failed exec record
+const auditOutputLimit = 512
+
+// AuditRecord is one line of the audit log
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Node       string    `json:"node,omitempty"`
+	PID        int       `json:"pid"`
+	Operation  string    `json:"operation"`
+	Target     string    `json:"target"`         // Command name or path
+	Args       []string  `json:"args,omitempty"` // Command arguments or operation parameters
+	Outcome    string    `json:"outcome"`        // success or failure
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"` // Truncated command output of failed execs
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// AuditLog appends a JSON line per privileged host operation to a dedicated file or stream
+// All methods are safe to call on a nil *AuditLog, which disables auditing
+type AuditLog struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer // Nil for stderr
+	node   string
+}
+
+// auditLog is the process-wide audit log, nil unless AUDIT_LOG_PATH is set
+var auditLog *AuditLog
+
+// openAuditLog opens the audit destination; "stderr" writes to the standard error stream
+// Files are opened append-only so records of previous runs are never rewritten
+func openAuditLog(path, node string) (*AuditLog, error) {
+	if path == "stderr" {
+		return &AuditLog{out: os.Stderr, node: node}, nil
+	}
+	if err := ensureDirectory(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	return &AuditLog{out: file, closer: file, node: node}, nil
+}
+
+// Close closes the audit file
+func (a *AuditLog) Close() {
+	if a == nil || a.closer == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_ = a.closer.Close()
+}
+
+// Record appends one record; write failures are reported on stderr and never fail the operation
+func (a *AuditLog) Record(operation, target string, args []string, start time.Time, output []byte, err error) {
+	if a == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:       start.UTC(),
+		Node:       a.node,
+		PID:        os.Getpid(),
+		Operation:  operation,
+		Target:     target,
+		Args:       args,
+		Outcome:    "success",
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Outcome = "failure"
+		record.Error = err.Error()
+		if out := strings.TrimSpace(string(output)); out != "" {
+			if len(out) > auditOutputLimit {
+				out = out[:auditOutputLimit]
+			}
+			record.Output = out
+		}
+	}
+
+	data, marshalErr := json.Marshal(record)
+	if marshalErr != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, writeErr := a.out.Write(append(data, '\n')); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to write audit record: %v\n", writeErr)
+	}
+}
+
+// privilegedCommand runs a host-affecting command and returns its combined output
+func privilegedCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	auditLog.Record(AuditOpExec, name, args, start, out, err)
+	if operation, module := moduleOperation(name, args); operation != "" {
+		moduleMetrics.ObserveModuleOperation(operation, module, time.Since(start), err)
+	}
+	return out, err
+}
+
+// privilegedChmod changes the mode of a host path
+func privilegedChmod(path string, mode os.FileMode) error {
+	start := time.Now()
+	err := os.Chmod(path, mode)
+	auditLog.Record(AuditOpChmod, path, []string{fmt.Sprintf("%04o", mode.Perm())}, start, nil, err)
+	return err
+}
+
+// privilegedChown changes the ownership of a host path (-1 leaves an ID unchanged)
+func privilegedChown(path string, uid, gid int) error {
+	start := time.Now()
+	err := os.Chown(path, uid, gid)
+	auditLog.Record(AuditOpChown, path, []string{fmt.Sprintf("uid=%d", uid), fmt.Sprintf("gid=%d", gid)}, start, nil, err)
+	return err
+}
+
+// privilegedSymlink creates a symlink at path pointing to target
+func privilegedSymlink(target, path string) error {
+	start := time.Now()
+	err := os.Symlink(target, path)
+	auditLog.Record(AuditOpSymlink, path, []string{target}, start, nil, err)
+	return err
+}
+
+// privilegedWriteFile writes a host file outside the plugin's own state
+func privilegedWriteFile(path string, data []byte, perm os.FileMode) error {
+	start := time.Now()
+	err := os.WriteFile(path, data, perm)
+	auditLog.Record(AuditOpWrite, path, []string{fmt.Sprintf("%04o", perm.Perm()), fmt.Sprintf("bytes=%d", len(data))}, start, nil, err)
+	return err
+}
+
+// privilegedRemove removes a host file outside the plugin's own state
+func privilegedRemove(path string) error {
+	start := time.Now()
+	err := os.Remove(path)
+	auditLog.Record(AuditOpRemove, path, nil, start, nil, err)
+	return err
+}
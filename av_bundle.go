@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	"k8s.io/utils/clock"
+)
+
+// AVBundle pairs one loopback video device with one ALSA loopback card
+// Pairing is positional and fixed for the lifetime of the node, so a bundle always
+// maps to the same camera and microphone indices
+type AVBundle struct {
+	ID            string `json:"id"`
+	VideoDeviceID string `json:"video_device_id"`
+	ALSACard      int    `json:"alsa_card"`
+}
+
+// buildAVBundles returns the configured bundles; they take the highest video slots
+func buildAVBundles(config *DevicePluginConfig) []AVBundle {
+	bundles := make([]AVBundle, 0, config.AVBundleCount)
+	firstSlot := config.MaxDevices - config.AVBundleCount
+	for i := 0; i < config.AVBundleCount; i++ {
+		bundles = append(bundles, AVBundle{
+			ID:            fmt.Sprintf("avbundle%d", i),
+			VideoDeviceID: fmt.Sprintf("video%d", config.VideoDeviceStart+firstSlot+i),
+			ALSACard:      config.ALSACardStartIndex + i,
+		})
+	}
+	return bundles
+}
+
+// avBundleVideoIDs returns the video device IDs reserved for bundles
+func avBundleVideoIDs(config *DevicePluginConfig) map[string]bool {
+	reserved := make(map[string]bool, config.AVBundleCount)
+	for _, bundle := range buildAVBundles(config) {
+		reserved[bundle.VideoDeviceID] = true
+	}
+	return reserved
+}
+
+// AVBundlePlugin serves the av-bundle resource: each device is a video device plus its paired ALSA card
+type AVBundlePlugin struct {
+	pluginapi.UnimplementedDevicePluginServer
+	config      *DevicePluginConfig
+	v4l2Manager V4L2Manager
+	allocations *AllocationTracker
+	settings    *RuntimeSettings
+	sent        *ListAndWatchRecorder
+	clock       clock.WithTicker // Time source of the socket wait and the monitor and ListAndWatch loops
+	bundles     map[string]AVBundle
+	logger      *slog.Logger
+	server      *grpc.Server
+	listener    net.Listener
+	watcher     *PluginWatcherServer
+	ctx         context.Context // Lifetime of the monitor and ListAndWatch loops, set by Start
+	cancel      context.CancelFunc
+	mu          sync.RWMutex
+	registered  bool
+	handedOver  bool // Stopped for a socket takeover; the socket path belongs to the new instance
+}
+
+// NewAVBundlePlugin creates a new AVBundlePlugin sharing allocation bookkeeping and runtime settings with the video plugin
+func NewAVBundlePlugin(config *DevicePluginConfig, v4l2Manager V4L2Manager, allocations *AllocationTracker, settings *RuntimeSettings, sent *ListAndWatchRecorder, logger *slog.Logger) *AVBundlePlugin {
+	bundles := make(map[string]AVBundle)
+	for _, bundle := range buildAVBundles(config) {
+		bundles[bundle.ID] = bundle
+	}
+
+	return &AVBundlePlugin{
+		config:      config,
+		v4l2Manager: v4l2Manager,
+		allocations: allocations,
+		settings:    settings,
+		sent:        sent,
+		clock:       clock.RealClock{},
+		bundles:     bundles,
+		logger:      logger.With("resource_name", config.AVBundleResourceName),
+	}
+}
+
+// SetClock replaces the time source of the socket wait and the bundle loops; call it before Start
+func (b *AVBundlePlugin) SetClock(c clock.WithTicker) {
+	b.clock = c
+}
+
+// Start serves the bundle plugin socket and registers it with kubelet
+// Canceling ctx stops its loops like Stop does
+func (b *AVBundlePlugin) Start(ctx context.Context) error {
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	socketPath := b.config.AVBundleSocketPath
+	b.logger.Info("Starting av-bundle device plugin", "socket_path", socketPath, "bundles", len(b.bundles))
+
+	if err := ensureDirectory(filepath.Dir(socketPath)); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	// A previous instance may still serve the socket during a rolling update
+	if err := waitForStaleSocket(b.ctx, b.clock, socketPath, time.Duration(b.config.SocketTakeoverTimeout)*time.Second, b.logger); err != nil {
+		return err
+	}
+
+	b.server = grpc.NewServer(grpcServerOptions(b.config, b.settings, b.logger)...)
+	pluginapi.RegisterDevicePluginServer(b.server, b)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on socket: %w", err)
+	}
+	b.listener = listener
+
+	go func() {
+		if err := b.server.Serve(listener); err != nil {
+			b.logger.Error("av-bundle gRPC server failed", "error", err)
+		}
+	}()
+
+	if usesWatcherRegistration(b.config) {
+		b.watcher = NewPluginWatcherServer(b.config.PluginRegistryDir, b.config.AVBundleResourceName, socketPath, b.setWatcherRegistration, b.logger)
+		if err := b.watcher.Start(); err != nil {
+			b.server.Stop()
+			_ = cleanupSocket(socketPath)
+			return fmt.Errorf("failed to start plugin watcher registration: %w", err)
+		}
+	}
+
+	if usesDirectRegistration(b.config) {
+		if err := b.register(b.ctx); err != nil {
+			if !usesWatcherRegistration(b.config) {
+				b.server.Stop()
+				_ = cleanupSocket(socketPath)
+				return err
+			}
+			b.logger.Warn("Direct kubelet registration failed, relying on the plugin watcher", "error", err)
+		}
+		go b.monitorKubeletRestart()
+	}
+	return nil
+}
+
+// Stop shuts down the bundle plugin and removes its socket
+func (b *AVBundlePlugin) Stop() {
+	if b.stop(false) {
+		b.logger.Info("av-bundle device plugin stopped")
+	}
+}
+
+// handOver stops serving bundles for a socket takeover without removing the socket path,
+// which the new instance re-creates once this one no longer answers on it
+func (b *AVBundlePlugin) handOver() {
+	if b.stop(true) {
+		b.logger.Info("av-bundle device plugin stopped, socket left to the new instance")
+	}
+}
+
+// stop tears down the server and the registration; it reports false once the bundles were handed over
+func (b *AVBundlePlugin) stop(handOver bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handedOver {
+		return false
+	}
+	b.handedOver = handOver
+
+	if b.watcher != nil {
+		b.watcher.Stop()
+	}
+	if b.server != nil {
+		b.server.Stop()
+	}
+	if b.listener != nil {
+		_ = b.listener.Close()
+		b.listener = nil
+	}
+	if !handOver {
+		if err := cleanupSocket(b.config.AVBundleSocketPath); err != nil {
+			b.logger.Warn("Failed to cleanup socket", "error", err)
+		}
+	}
+
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return true
+}
+
+// register registers the bundle resource with kubelet
+func (b *AVBundlePlugin) register(ctx context.Context) error {
+	if err := registerResourceWithKubelet(ctx, b.config.KubeletSocket, b.config.AVBundleSocketPath, b.config.AVBundleResourceName, b.logger); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.registered = true
+	b.mu.Unlock()
+	b.logger.Info("Successfully registered av-bundle resource with kubelet")
+	return nil
+}
+
+// setWatcherRegistration records the registration status reported by the kubelet plugin watcher
+func (b *AVBundlePlugin) setWatcherRegistration(registered bool, reason string) {
+	b.mu.Lock()
+	b.registered = registered
+	b.mu.Unlock()
+}
+
+// monitorKubeletRestart re-registers once the kubelet socket reappears after a restart
+func (b *AVBundlePlugin) monitorKubeletRestart() {
+	ticker := b.clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C():
+			if !checkDeviceExists(b.config.KubeletSocket) {
+				b.mu.Lock()
+				b.registered = false
+				b.mu.Unlock()
+				continue
+			}
+
+			b.mu.RLock()
+			registered := b.registered
+			b.mu.RUnlock()
+			if !registered {
+				if err := b.register(b.ctx); err != nil {
+					b.logger.Error("Failed to re-register av-bundle resource with kubelet", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// GetDevicePluginOptions implements the GetDevicePluginOptions gRPC method
+func (b *AVBundlePlugin) GetDevicePluginOptions(ctx context.Context, req *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+// ListAndWatch implements the ListAndWatch gRPC method
+func (b *AVBundlePlugin) ListAndWatch(req *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) (err error) {
+	watch := b.sent.OpenStream(b.config.AVBundleResourceName, stream, b.logger)
+	defer func() { watch.Close(err) }()
+
+	response := &pluginapi.ListAndWatchResponse{Devices: b.buildDeviceList()}
+	if err := watch.Send(response); err != nil {
+		return err
+	}
+
+	ticker := b.clock.NewTicker(time.Duration(b.config.HealthCheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C():
+			response := &pluginapi.ListAndWatchResponse{Devices: b.buildDeviceList()}
+			if err := watch.Send(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// buildDeviceList reports a bundle healthy only when both halves of the pair are usable
+func (b *AVBundlePlugin) buildDeviceList() []*pluginapi.Device {
+	devices := make([]*pluginapi.Device, 0, len(b.bundles))
+	for _, bundle := range b.bundles {
+		health := pluginapi.Healthy
+		if !avBundleUsable(bundle, b.v4l2Manager, b.allocations) {
+			if b.config.UnhealthyDevicePolicy == UnhealthyPolicyRemove {
+				continue
+			}
+			health = pluginapi.Unhealthy
+		}
+		devices = append(devices, &pluginapi.Device{ID: bundle.ID, Health: health})
+	}
+	return devices
+}
+
+// avBundleUsable reports whether both halves of a bundle are usable and it is not locally leased
+func avBundleUsable(bundle AVBundle, v4l2Manager V4L2Manager, allocations *AllocationTracker) bool {
+	return v4l2Manager.GetDeviceHealth(bundle.VideoDeviceID) &&
+		!allocations.IsLocallyLeased(bundle.VideoDeviceID) &&
+		alsaCardExists(bundle.ALSACard)
+}
+
+// Allocate implements the Allocate gRPC method
+func (b *AVBundlePlugin) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	logger := b.logger.With("correlation_id", correlationID)
+	logger.Info("Allocate called", "requests", len(req.ContainerRequests))
+
+	var responses []*pluginapi.ContainerAllocateResponse
+	for _, containerReq := range req.ContainerRequests {
+		response, err := b.allocateContainer(containerReq, correlationID, logger)
+		if err != nil {
+			logger.Error("Failed to allocate bundle", "error", err)
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+
+	return &pluginapi.AllocateResponse{ContainerResponses: responses}, nil
+}
+
+// allocateContainer builds one response containing the video device and ALSA card of each bundle
+func (b *AVBundlePlugin) allocateContainer(req *pluginapi.ContainerAllocateRequest, correlationID string, logger *slog.Logger) (*pluginapi.ContainerAllocateResponse, error) {
+	response := &pluginapi.ContainerAllocateResponse{
+		Envs: map[string]string{"VIDEO_DEVICE_ALLOCATION_ID": correlationID},
+		Annotations: map[string]string{
+			AllocationIDAnnotation: correlationID,
+		},
+	}
+
+	var videoDevices []*VideoDevice
+	for i, bundleID := range req.DevicesIDs {
+		bundle, exists := b.bundles[bundleID]
+		if !exists {
+			return nil, fmt.Errorf("unknown bundle: %s", bundleID)
+		}
+
+		device, err := b.v4l2Manager.GetDeviceByID(bundle.VideoDeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get video device for bundle %s: %w", bundleID, err)
+		}
+		if b.allocations.IsLocallyLeased(device.ID) {
+			return nil, fmt.Errorf("video device %s of bundle %s is held by a local lease", device.ID, bundleID)
+		}
+
+		audioPaths, err := alsaCardDevicePaths(bundle.ALSACard)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve ALSA card for bundle %s: %w", bundleID, err)
+		}
+
+		response.Devices = append(response.Devices, &pluginapi.DeviceSpec{
+			ContainerPath: device.Path,
+			HostPath:      device.Path,
+			Permissions:   b.config.AVBundleDevicePermissions,
+		})
+		for _, path := range audioPaths {
+			response.Devices = append(response.Devices, &pluginapi.DeviceSpec{
+				ContainerPath: path,
+				HostPath:      path,
+				Permissions:   b.config.AVBundleDevicePermissions,
+			})
+		}
+
+		// The first bundle uses unsuffixed names; additional bundles are numbered
+		suffix := ""
+		if i > 0 {
+			suffix = fmt.Sprintf("_%d", i)
+		}
+		response.Envs["VIDEO_DEVICE"+suffix] = device.Path
+		response.Envs["AUDIO_CARD"+suffix] = fmt.Sprintf("%d", bundle.ALSACard)
+		// snd-aloop: what is played on device 0 is captured on device 1 and vice versa
+		response.Envs["AUDIO_PLAYBACK_DEVICE"+suffix] = fmt.Sprintf("hw:%d,0", bundle.ALSACard)
+		response.Envs["AUDIO_CAPTURE_DEVICE"+suffix] = fmt.Sprintf("hw:%d,1", bundle.ALSACard)
+
+		videoDevices = append(videoDevices, device)
+		logger.Info("Allocated bundle",
+			"bundle_id", bundleID,
+			"video_device", device.Path,
+			"alsa_card", bundle.ALSACard)
+	}
+
+	if len(videoDevices) > 0 {
+		applyTopologyEnvs(response.Envs, b.config, videoDevices[0])
+	}
+	// Bundles are only served to the primary kubelet
+	if err := b.allocations.RecordKubeletAllocation(videoDevices, correlationID, ""); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
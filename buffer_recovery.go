@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// kernelLogDevicePattern extracts the video device number from a kernel log line
+var kernelLogDevicePattern = regexp.MustCompile(`video(\d+)`)
+
+// monitorBufferExhaustion scans the kernel log on every health interval for loopback buffer
+// allocation failures and recreates the affected devices with fewer buffers
+func (p *VideoDevicePlugin) monitorBufferExhaustion() {
+	if !p.config.EnableBufferRecovery || p.v4l2Manager.IsFallbackMode() {
+		return
+	}
+
+	// Kernel log lines already handled; the ring buffer keeps old lines around
+	seen := make(map[string]bool)
+	first := true
+
+	timer := p.clock.NewTimer(p.settings.HealthCheckInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-timer.C():
+			lines, err := readBufferExhaustionLines()
+			if err != nil {
+				p.logger.Debug("Kernel log not available for buffer exhaustion detection", "error", err)
+			}
+
+			current := make(map[string]bool, len(lines))
+			var fresh []string
+			for _, line := range lines {
+				current[line] = true
+				if !seen[line] {
+					fresh = append(fresh, line)
+				}
+			}
+			seen = current
+
+			// Failures logged before this instance started were handled (or not) by its predecessor
+			if !first && len(fresh) > 0 {
+				p.recoverExhaustedDevices(fresh)
+			}
+			first = false
+
+			timer.Reset(p.settings.HealthCheckInterval())
+		}
+	}
+}
+
+// readBufferExhaustionLines returns the buffer exhaustion lines currently in the kernel log
+func readBufferExhaustionLines() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "dmesg").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseBufferExhaustion(string(output)), nil
+}
+
+// recoverExhaustedDevices maps kernel log lines to devices and recreates each once
+// Lines naming no device are attributed to devices currently failing their health check
+func (p *VideoDevicePlugin) recoverExhaustedDevices(lines []string) {
+	affected := make(map[string]bool)
+	unattributed := false
+	for _, line := range lines {
+		p.logger.Warn("Kernel reported loopback buffer allocation failure", "kernel_log", line)
+
+		match := kernelLogDevicePattern.FindStringSubmatch(line)
+		if match == nil {
+			unattributed = true
+			continue
+		}
+		deviceID := "video" + match[1]
+		if _, err := p.v4l2Manager.GetDeviceByID(deviceID); err == nil {
+			affected[deviceID] = true
+		}
+	}
+
+	if unattributed {
+		for deviceID := range p.v4l2Manager.ListAllDevices() {
+			if !p.v4l2Manager.GetDeviceHealth(deviceID) {
+				affected[deviceID] = true
+			}
+		}
+	}
+
+	if len(affected) == 0 {
+		p.logger.Warn("Could not attribute buffer allocation failure to a managed device")
+		return
+	}
+
+	for deviceID := range affected {
+		// Excluded devices belong to host processes that would lose them on recreation
+		if p.excluded[deviceID] {
+			p.logger.Warn("Not recovering excluded device after buffer allocation failure", "device_id", deviceID)
+			continue
+		}
+		if err := p.recoverExhaustedDevice(deviceID); err != nil {
+			p.logger.Error("Buffer exhaustion recovery failed", "device_id", deviceID, "error", err)
+		}
+	}
+}
+
+// recoverExhaustedDevice recreates a device with half its current buffer count
+func (p *VideoDevicePlugin) recoverExhaustedDevice(deviceID string) error {
+	device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+
+	current := p.deviceMaxBuffers(device)
+	reduced := current / 2
+	if reduced < 1 {
+		return fmt.Errorf("device already runs with %d buffer(s), cannot reduce further", current)
+	}
+
+	if p.warmup != nil {
+		p.warmup.Stop(device.Path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+	defer cancel()
+	if err := p.resetDeviceWithContext(ctx, device.Path, reduced); err != nil {
+		return err
+	}
+
+	if err := p.v4l2Manager.SetMaxBuffers(deviceID, reduced); err != nil {
+		return err
+	}
+	if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+		p.logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+	}
+
+	p.metrics.IncBufferRecoveries(deviceID)
+	p.decisions.Publish(DecisionEvent{
+		Kind:     DecisionReconcile,
+		Action:   "buffers_reduced",
+		DeviceID: deviceID,
+		Fields:   map[string]string{"max_buffers": strconv.Itoa(reduced)},
+	})
+	p.logger.Warn("Recreated device with reduced max_buffers after buffer exhaustion",
+		"device_id", deviceID,
+		"device_path", device.Path,
+		"previous_max_buffers", current,
+		"max_buffers", reduced)
+	return nil
+}
+
+// deviceMaxBuffers returns the buffer count a device runs with
+func (p *VideoDevicePlugin) deviceMaxBuffers(device *VideoDevice) int {
+	if device.MaxBuffers > 0 {
+		return device.MaxBuffers
+	}
+	if tier, ok := p.tiers[device.ID]; ok {
+		return tier.MaxBuffers
+	}
+	return p.config.V4L2MaxBuffers
+}
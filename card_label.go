@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// maxCardLabelLength is the longest card label V4L2 reports (v4l2_capability.card holds 32 bytes)
+const maxCardLabelLength = 31
+
+// labelStampTimeout bounds the pod lookup so PreStartContainer never stalls on it
+const labelStampTimeout = 2 * time.Second
+
+// labelStamp is the card label a device is recreated with while one allocation holds it
+type labelStamp struct {
+	correlationID string
+	label         string
+}
+
+// stampCardLabel records the card label identifying the allocation that holds a device
+// The label names the LABEL_STAMP_ANNOTATION value of the pod kubelet assigned the device to,
+// else the pod name, else the allocation's correlation ID. The device picks it up when
+// PreStartContainer recreates it
+func (p *VideoDevicePlugin) stampCardLabel(ctx context.Context, deviceID string) (string, bool) {
+	correlationID := p.allocations.CorrelationID(deviceID)
+	if !p.config.EnableLabelStamping || correlationID == "" {
+		return "", false
+	}
+
+	identity := correlationID
+	ctx, cancel := context.WithTimeout(ctx, labelStampTimeout)
+	defer cancel()
+	owner, err := devicePod(ctx, p.config.PodResourcesSocket, p.deviceResource(deviceID), deviceID)
+	if err != nil {
+		p.logger.Debug("Cannot resolve the pod of an allocated device, stamping the correlation ID", "device_id", deviceID, "error", err)
+	} else if owner.Name != "" {
+		identity = owner.Name
+		if p.k8sClient != nil && p.config.LabelStampAnnotation != "" {
+			if pod, err := p.k8sClient.GetPod(ctx, owner.Namespace, owner.Name); err != nil {
+				p.logger.Debug("Cannot read the pod's label annotation, stamping the pod name", "device_id", deviceID, "error", err)
+			} else if value := strings.TrimSpace(pod.Annotations[p.config.LabelStampAnnotation]); value != "" {
+				identity = value
+			}
+		}
+	}
+
+	// The configured label, not a stamp of an earlier container start of this allocation
+	base := p.config.V4L2CardLabel
+	if tier, ok := p.tiers[deviceID]; ok {
+		base = tier.CardLabel
+	}
+	label := stampedCardLabel(base, identity)
+
+	p.stampMu.Lock()
+	defer p.stampMu.Unlock()
+	p.stamps[deviceID] = labelStamp{correlationID: correlationID, label: label}
+	return label, true
+}
+
+// stampedLabel returns the card label stamped for the allocation currently holding a device
+func (p *VideoDevicePlugin) stampedLabel(deviceID string) (string, bool) {
+	p.stampMu.Lock()
+	defer p.stampMu.Unlock()
+	stamp, ok := p.stamps[deviceID]
+	if !ok {
+		return "", false
+	}
+	// A stamp outlives its allocation only until the device is next recreated
+	if correlationID := p.allocations.CorrelationID(deviceID); correlationID == "" || correlationID != stamp.correlationID {
+		delete(p.stamps, deviceID)
+		return "", false
+	}
+	return stamp.label, true
+}
+
+// stampedCardLabel joins the base label and an allocation identity within the V4L2 label length
+// The identity is kept whole when possible since it is what tells allocations apart
+func stampedCardLabel(base, identity string) string {
+	identity = sanitizeCardLabel(identity)
+	if len(identity) >= maxCardLabelLength {
+		return identity[:maxCardLabelLength]
+	}
+	if room := maxCardLabelLength - len(identity) - 1; len(base) > room {
+		base = strings.TrimSpace(base[:room])
+	}
+	if base == "" {
+		return identity
+	}
+	return base + " " + identity
+}
+
+// sanitizeCardLabel replaces characters that do not belong in a card label
+func sanitizeCardLabel(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("-_.:/", r):
+			return r
+		}
+		return '_'
+	}, value)
+}
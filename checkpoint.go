@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// checkpointFile is the file name of the bookkeeping checkpoint inside StateDir
+const checkpointFile = "checkpoint.json"
+
+// checkpointVersion is bumped whenever the checkpoint layout changes incompatibly
+// Checkpoints with another version are ignored rather than half-restored
+const checkpointVersion = 1
+
+// Checkpoint is the plugin bookkeeping persisted across DaemonSet pod restarts
+type Checkpoint struct {
+	Version        int                     `json:"version"`
+	SavedAt        time.Time               `json:"saved_at"`
+	Devices        []VideoDevice           `json:"devices"`
+	Labels         map[string]DeviceLabels `json:"labels,omitempty"`
+	Allocations    []Allocation            `json:"allocations,omitempty"`
+	SpareRoles     map[string]string       `json:"spare_roles,omitempty"`
+	IDRotations    map[string]int          `json:"id_rotations,omitempty"`
+	FallbackMode   bool                    `json:"fallback_mode"`
+	FallbackReason string                  `json:"fallback_reason,omitempty"`
+}
+
+// Checkpointer writes a checkpoint after every bookkeeping mutation
+// Mutations only mark the state dirty; a single writer goroutine coalesces bursts
+type Checkpointer struct {
+	path     string
+	snapshot func() *Checkpoint
+	clock    clock.PassiveClock // Stamps SavedAt
+	logger   *slog.Logger
+	dirty    chan struct{}
+	cancel   context.CancelFunc
+	doneCh   chan struct{}
+	started  bool
+}
+
+// NewCheckpointer creates a checkpointer writing snapshot() to stateDir
+func NewCheckpointer(stateDir string, snapshot func() *Checkpoint, logger *slog.Logger) *Checkpointer {
+	return &Checkpointer{
+		path:     filepath.Join(stateDir, checkpointFile),
+		snapshot: snapshot,
+		clock:    clock.RealClock{},
+		logger:   logger,
+		dirty:    make(chan struct{}, 1),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// SetClock replaces the time source that stamps SavedAt; call it before Start
+func (c *Checkpointer) SetClock(now clock.PassiveClock) {
+	if c != nil {
+		c.clock = now
+	}
+}
+
+// MarkDirty schedules a checkpoint write; it never blocks and is safe on a nil Checkpointer
+func (c *Checkpointer) MarkDirty() {
+	if c == nil {
+		return
+	}
+	select {
+	case c.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the writer goroutine until ctx is canceled or Stop is called
+// Stop still writes the final checkpoint when ctx was canceled first
+func (c *Checkpointer) Start(ctx context.Context) {
+	c.started = true
+	ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(c.doneCh)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.dirty:
+				c.write()
+			}
+		}
+	}()
+}
+
+// Stop stops the writer and writes a final checkpoint
+func (c *Checkpointer) Stop() {
+	if c == nil || !c.started {
+		return
+	}
+	c.cancel()
+	<-c.doneCh
+	c.write()
+}
+
+// Abandon stops the writer without a final write, leaving the file to another instance
+func (c *Checkpointer) Abandon() {
+	if c == nil || !c.started {
+		return
+	}
+	c.started = false
+	c.cancel()
+	<-c.doneCh
+}
+
+// write persists the current snapshot atomically
+func (c *Checkpointer) write() {
+	checkpoint := c.snapshot()
+	checkpoint.Version = checkpointVersion
+	checkpoint.SavedAt = c.clock.Now()
+
+	if err := writeCheckpoint(c.path, checkpoint); err != nil {
+		c.logger.Warn("Failed to write checkpoint", "path", c.path, "error", err)
+	}
+}
+
+// writeCheckpoint writes a checkpoint file atomically
+func writeCheckpoint(path string, checkpoint *Checkpoint) error {
+	if err := ensureDirectory(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to install checkpoint: %w", err)
+	}
+	return nil
+}
+
+// loadCheckpoint reads a checkpoint; a missing file returns nil without error
+func loadCheckpoint(stateDir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, checkpointFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if checkpoint.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d (want %d)", checkpoint.Version, checkpointVersion)
+	}
+	return checkpoint, nil
+}
+
+// checkpointSnapshot collects the plugin bookkeeping into a checkpoint
+func (p *VideoDevicePlugin) checkpointSnapshot() *Checkpoint {
+	checkpoint := &Checkpoint{
+		Labels:         p.labels.Snapshot(),
+		Allocations:    p.allocations.List(),
+		SpareRoles:     p.spares.Snapshot(),
+		IDRotations:    p.rotation.Snapshot(),
+		FallbackMode:   p.v4l2Manager.IsFallbackMode(),
+		FallbackReason: p.v4l2Manager.GetFallbackReason(),
+	}
+	for _, device := range p.v4l2Manager.ListAllDevices() {
+		checkpoint.Devices = append(checkpoint.Devices, *device)
+	}
+	return checkpoint
+}
+
+// RestoreCheckpoint restores bookkeeping saved by a previous instance, then starts checkpointing
+// Must be called after device discovery and before Start registers with kubelet; checkpointing
+// runs until ctx is canceled or the plugin stops
+func (p *VideoDevicePlugin) RestoreCheckpoint(ctx context.Context) {
+	if p.checkpoint == nil {
+		return
+	}
+	defer p.checkpoint.Start(ctx)
+
+	checkpoint, err := loadCheckpoint(p.config.StateDir)
+	if err != nil {
+		p.logger.Warn("Ignoring checkpoint", "error", err)
+		return
+	}
+	if checkpoint == nil {
+		p.logger.Info("No checkpoint found, starting with fresh bookkeeping")
+		return
+	}
+	p.applyCheckpoint(checkpoint, "checkpoint")
+}
+
+// applyCheckpoint restores saved bookkeeping from a checkpoint file or a previous instance
+func (p *VideoDevicePlugin) applyCheckpoint(checkpoint *Checkpoint, source string) {
+	fallbackChanged := checkpoint.FallbackMode != p.v4l2Manager.IsFallbackMode()
+	if fallbackChanged {
+		p.logger.Warn("Fallback status changed since the checkpoint was written",
+			"checkpoint_fallback_mode", checkpoint.FallbackMode,
+			"checkpoint_fallback_reason", checkpoint.FallbackReason,
+			"fallback_mode", p.v4l2Manager.IsFallbackMode())
+	}
+
+	devices := p.v4l2Manager.RestoreDevices(checkpoint.Devices)
+	labels := p.labels.Restore(checkpoint.Labels)
+	allocations := p.allocations.Restore(checkpoint.Allocations)
+	spares := p.spares.Restore(checkpoint.SpareRoles, p.config.HotSpareCount)
+	rotations := p.rotation.Restore(checkpoint.IDRotations)
+
+	// Kubelet's view still holds the placeholder devices of the fallback run
+	if fallbackChanged && checkpoint.FallbackMode {
+		var deviceIDs []string
+		for deviceID := range p.v4l2Manager.ListAllDevices() {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+		p.RotateDeviceIDs("recovered from fallback mode", deviceIDs)
+	}
+
+	// Handoff files of allocations released while no plugin was running are orphaned
+	p.pruneHandoffs()
+
+	p.logger.Info("Restored checkpoint",
+		"source", source,
+		"saved_at", checkpoint.SavedAt,
+		"devices", devices,
+		"labels", labels,
+		"allocations", allocations,
+		"spare_roles_restored", spares,
+		"id_rotations", rotations)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// newCheckpointPlugin builds a prepared plugin checkpointing to stateDir
+func newCheckpointPlugin(t *testing.T, stateDir string) *VideoDevicePlugin {
+	t.Helper()
+	return newTestPlugin(t, newFakeV4L2Manager(2), func(config *DevicePluginConfig) {
+		config.EnableCheckpoint = true
+		config.StateDir = stateDir
+		config.EnableDeviceIDRotation = true
+	})
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	stateDir := t.TempDir()
+	fakeClock := clocktesting.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	previous := newCheckpointPlugin(t, stateDir)
+	previous.SetClock(fakeClock)
+	previous.RestoreCheckpoint(context.Background())
+	resp, err := previous.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"video10"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.checkpoint.Stop()
+
+	saved, err := loadCheckpoint(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.SavedAt.Equal(fakeClock.Now()) {
+		t.Errorf("SavedAt = %v, want the injected clock's %v", saved.SavedAt, fakeClock.Now())
+	}
+
+	restored := newCheckpointPlugin(t, stateDir)
+	restored.RestoreCheckpoint(context.Background())
+	t.Cleanup(restored.checkpoint.Abandon)
+	if got, want := restored.allocations.CorrelationID("video10"), allocationID(resp); got != want {
+		t.Errorf("restored allocation of video10 = %q, want %q", got, want)
+	}
+	if got := restored.allocations.CorrelationID("video11"); got != "" {
+		t.Errorf("video11 restored as allocated under %q", got)
+	}
+}
+
+func TestCheckpointVersionMismatch(t *testing.T) {
+	stateDir := t.TempDir()
+	checkpoint := &Checkpoint{
+		Version:     checkpointVersion + 1,
+		Allocations: []Allocation{{DeviceID: "video10", Source: "kubelet", CorrelationID: "newer"}},
+	}
+	if err := writeCheckpoint(filepath.Join(stateDir, checkpointFile), checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCheckpoint(stateDir); err == nil {
+		t.Error("loadCheckpoint accepted a checkpoint of another version")
+	}
+	plugin := newCheckpointPlugin(t, stateDir)
+	plugin.RestoreCheckpoint(context.Background())
+	t.Cleanup(plugin.checkpoint.Abandon)
+	if got := plugin.allocations.CorrelationID("video10"); got != "" {
+		t.Errorf("allocation of video10 restored from a checkpoint of another version under %q", got)
+	}
+}
+
+func TestCheckpointerFinalWrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		stop      func(*Checkpointer)
+		wantWrite bool
+	}{
+		{"stop", (*Checkpointer).Stop, true},
+		{"abandon", (*Checkpointer).Abandon, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateDir := t.TempDir()
+			snapshot := func() *Checkpoint { return &Checkpoint{} }
+			checkpointer := NewCheckpointer(stateDir, snapshot, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			checkpointer.Start(context.Background())
+			tt.stop(checkpointer)
+
+			_, err := os.Stat(filepath.Join(stateDir, checkpointFile))
+			if written := err == nil; written != tt.wantWrite {
+				t.Errorf("checkpoint written = %t, want %t", written, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestApplyCheckpointRotatesRecoveredFallbackDevices(t *testing.T) {
+	plugin := newCheckpointPlugin(t, t.TempDir())
+	plugin.applyCheckpoint(&Checkpoint{
+		Version:        checkpointVersion,
+		FallbackMode:   true,
+		FallbackReason: "module not loaded",
+		Allocations:    []Allocation{{DeviceID: "video11", DevicePath: "/dev/video11", Source: "kubelet", CorrelationID: "held"}},
+	}, "test")
+
+	// Kubelet still tracks the placeholder devices of the fallback run under their old IDs
+	if got, want := plugin.rotation.KubeletID("video10"), "video10"+deviceIDRotationSeparator+"1"; got != want {
+		t.Errorf("kubelet ID of the recovered device = %q, want %q", got, want)
+	}
+	if got := plugin.rotation.KubeletID("video11"); got != "video11" {
+		t.Errorf("kubelet ID of the allocated device = %q, want it kept as video11", got)
+	}
+}
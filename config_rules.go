@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config rule severities
+const (
+	ConfigSeverityWarning = "warning" // Startup continues, possibly with a clamped value
+	ConfigSeverityError   = "error"   // Startup is refused
+)
+
+// v4l2loopbackMaxBuffers is the driver's compile-time MAX_BUFFERS
+const v4l2loopbackMaxBuffers = 32
+
+// v4l2loopbackParamsDir exposes the parameters of the loaded module
+const v4l2loopbackParamsDir = "/sys/module/v4l2loopback/parameters"
+
+// ConfigFinding is a violated configuration rule with remediation text
+type ConfigFinding struct {
+	Rule        string
+	Severity    string
+	Message     string
+	Remediation string
+	Clamped     bool // The offending value was adjusted and startup continues
+}
+
+// String formats the finding for logs and errors
+func (f ConfigFinding) String() string {
+	return fmt.Sprintf("[%s] %s. Remediation: %s", f.Rule, f.Message, f.Remediation)
+}
+
+// configRule cross-validates settings known to break v4l2loopback or its consumers
+type configRule struct {
+	Name        string
+	Severity    string
+	Violated    func(config *DevicePluginConfig) bool
+	Message     func(config *DevicePluginConfig) string
+	Remediation string
+	Clamp       func(config *DevicePluginConfig) // Optional, warning rules only: adjusts the value in place
+}
+
+// configRules is evaluated top to bottom; clamps of earlier rules are visible to later ones
+var configRules = []configRule{
+	{
+		Name:     "max-buffers-minimum",
+		Severity: ConfigSeverityError,
+		Violated: func(c *DevicePluginConfig) bool { return c.V4L2MaxBuffers < 1 },
+		Message: func(c *DevicePluginConfig) string {
+			return fmt.Sprintf("V4L2_MAX_BUFFERS=%d leaves v4l2loopback without buffers", c.V4L2MaxBuffers)
+		},
+		Remediation: "set V4L2_MAX_BUFFERS to 2 or more",
+	},
+	{
+		// With a single buffer producer and consumer take turns and frames stall
+		Name:     "max-buffers-single",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool { return c.V4L2MaxBuffers == 1 },
+		Message: func(c *DevicePluginConfig) string {
+			return "V4L2_MAX_BUFFERS=1 stalls consumers while the producer queues a frame; clamped to 2"
+		},
+		Remediation: "set V4L2_MAX_BUFFERS to 2 or more",
+		Clamp:       func(c *DevicePluginConfig) { c.V4L2MaxBuffers = 2 },
+	},
+	{
+		Name:     "max-buffers-driver-limit",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool { return c.V4L2MaxBuffers > v4l2loopbackMaxBuffers },
+		Message: func(c *DevicePluginConfig) string {
+			return fmt.Sprintf("V4L2_MAX_BUFFERS=%d exceeds the v4l2loopback limit of %d; clamped", c.V4L2MaxBuffers, v4l2loopbackMaxBuffers)
+		},
+		Remediation: fmt.Sprintf("set V4L2_MAX_BUFFERS to at most %d", v4l2loopbackMaxBuffers),
+		Clamp:       func(c *DevicePluginConfig) { c.V4L2MaxBuffers = v4l2loopbackMaxBuffers },
+	},
+	{
+		Name:     "buffer-recovery-floor",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool { return c.EnableBufferRecovery && c.V4L2MaxBuffers < 4 },
+		Message: func(c *DevicePluginConfig) string {
+			return fmt.Sprintf("buffer exhaustion recovery halves V4L2_MAX_BUFFERS=%d down to a single buffer", c.V4L2MaxBuffers)
+		},
+		Remediation: "set V4L2_MAX_BUFFERS to 4 or more, or ENABLE_BUFFER_RECOVERY=false",
+	},
+	{
+		Name:     "exclusive-caps-value",
+		Severity: ConfigSeverityError,
+		Violated: func(c *DevicePluginConfig) bool { return c.V4L2ExclusiveCaps != 0 && c.V4L2ExclusiveCaps != 1 },
+		Message: func(c *DevicePluginConfig) string {
+			return fmt.Sprintf("V4L2_EXCLUSIVE_CAPS=%d is not a valid v4l2loopback value", c.V4L2ExclusiveCaps)
+		},
+		Remediation: "set V4L2_EXCLUSIVE_CAPS to 0 or 1",
+	},
+	{
+		// Chromium only enumerates devices announcing CAPTURE alone, which needs exclusive_caps=1
+		Name:     "exclusive-caps-chromium",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool { return c.V4L2ExclusiveCaps == 0 },
+		Message: func(c *DevicePluginConfig) string {
+			return "V4L2_EXCLUSIVE_CAPS=0 hides the devices from Chromium-based consumers"
+		},
+		Remediation: "set V4L2_EXCLUSIVE_CAPS=1 unless consumers must open devices before a producer",
+	},
+	{
+		// The placeholder would turn the device capture-only and lock the pod's producer out
+		Name:     "warmup-exclusive-caps",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool {
+			if !c.EnableWarmupProducer || c.V4L2ExclusiveCaps == 0 {
+				return false
+			}
+			for _, tier := range buildDeviceTiers(c) {
+				if tier.ExclusiveCaps == 0 {
+					return false
+				}
+			}
+			return true
+		},
+		Message: func(c *DevicePluginConfig) string {
+			return "ENABLE_WARMUP_PRODUCER only runs on devices with exclusive_caps=0 and no device has it"
+		},
+		Remediation: "set V4L2_EXCLUSIVE_CAPS=0 (or a tier's exclusive_caps=0) or ENABLE_WARMUP_PRODUCER=false",
+	},
+	{
+		Name:     "producer-write-access",
+		Severity: ConfigSeverityWarning,
+		Violated: func(c *DevicePluginConfig) bool { return !strings.Contains(c.VideoDevicePermissions, "w") },
+		Message: func(c *DevicePluginConfig) string {
+			return fmt.Sprintf("VIDEO_DEVICE_PERMISSIONS=%q does not let containers write frames", c.VideoDevicePermissions)
+		},
+		Remediation: "use VIDEO_DEVICE_PERMISSIONS=rw when pods produce video",
+	},
+}
+
+// applyConfigRules evaluates the static rules, clamping values of violated warning rules
+func applyConfigRules(config *DevicePluginConfig) []ConfigFinding {
+	var findings []ConfigFinding
+	for _, rule := range configRules {
+		if !rule.Violated(config) {
+			continue
+		}
+		finding := ConfigFinding{
+			Rule:        rule.Name,
+			Severity:    rule.Severity,
+			Message:     rule.Message(config),
+			Remediation: rule.Remediation,
+		}
+		if rule.Clamp != nil && rule.Severity == ConfigSeverityWarning {
+			rule.Clamp(config)
+			finding.Clamped = true
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// checkLoadedModuleRules compares the configuration against parameters of the loaded module
+// These limits are only known at runtime, e.g. when the host loaded the module (MANAGE_MODULE=false)
+func checkLoadedModuleRules(config *DevicePluginConfig) []ConfigFinding {
+	var findings []ConfigFinding
+
+	// A warm-up producer and the pod's producer hold the device at the same time during handover
+	if maxOpeners, ok := readModuleParamInt("max_openers"); ok && config.EnableWarmupProducer && maxOpeners < 2 {
+		findings = append(findings, ConfigFinding{
+			Rule:        "warmup-max-openers",
+			Severity:    ConfigSeverityWarning,
+			Message:     fmt.Sprintf("v4l2loopback max_openers=%d leaves no room for the pod's producer while the warm-up producer runs", maxOpeners),
+			Remediation: "load v4l2loopback with max_openers=10 or set ENABLE_WARMUP_PRODUCER=false",
+		})
+	}
+
+	maxWidth, okWidth := readModuleParamInt("max_width")
+	maxHeight, okHeight := readModuleParamInt("max_height")
+	if okWidth && okHeight && config.EnableWarmupProducer && (config.WarmupFrameWidth > maxWidth || config.WarmupFrameHeight > maxHeight) {
+		findings = append(findings, ConfigFinding{
+			Rule:        "warmup-frame-size",
+			Severity:    ConfigSeverityWarning,
+			Message:     fmt.Sprintf("warm-up frame %dx%d exceeds the module limit %dx%d", config.WarmupFrameWidth, config.WarmupFrameHeight, maxWidth, maxHeight),
+			Remediation: "lower WARMUP_FRAME_WIDTH/WARMUP_FRAME_HEIGHT or load the module with larger max_width/max_height",
+		})
+	}
+
+	if maxBuffers, ok := readModuleParamInt("max_buffers"); ok && maxBuffers < 2 {
+		findings = append(findings, ConfigFinding{
+			Rule:        "loaded-max-buffers",
+			Severity:    ConfigSeverityWarning,
+			Message:     fmt.Sprintf("the loaded module runs with max_buffers=%d; consumers will stall", maxBuffers),
+			Remediation: "reload v4l2loopback with max_buffers=2 or more",
+		})
+	}
+
+	return findings
+}
+
+// readModuleParamInt reads an integer parameter of the loaded v4l2loopback module
+func readModuleParamInt(name string) (int, bool) {
+	data, err := os.ReadFile(filepath.Join(v4l2loopbackParamsDir, name))
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// logConfigFindings logs findings at a level matching their severity
+func logConfigFindings(findings []ConfigFinding, logger *slog.Logger) {
+	for _, finding := range findings {
+		attrs := []any{"rule", finding.Rule, "remediation", finding.Remediation, "clamped", finding.Clamped}
+		if finding.Severity == ConfigSeverityError {
+			logger.Error(finding.Message, attrs...)
+		} else {
+			logger.Warn(finding.Message, attrs...)
+		}
+	}
+}
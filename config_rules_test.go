@@ -0,0 +1,105 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestApplyConfigRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		configure   func(*DevicePluginConfig)
+		wantRules   []string
+		wantErrors  int // Findings with error severity
+		wantBuffers int // V4L2MaxBuffers after clamping
+	}{
+		{
+			name:        "valid",
+			configure:   func(c *DevicePluginConfig) {},
+			wantBuffers: 8,
+		},
+		{
+			name:        "no buffers",
+			configure:   func(c *DevicePluginConfig) { c.V4L2MaxBuffers = 0 },
+			wantRules:   []string{"max-buffers-minimum"},
+			wantErrors:  1,
+			wantBuffers: 0,
+		},
+		{
+			name:        "single buffer is clamped",
+			configure:   func(c *DevicePluginConfig) { c.V4L2MaxBuffers = 1 },
+			wantRules:   []string{"max-buffers-single"},
+			wantBuffers: 2,
+		},
+		{
+			name:        "driver limit is clamped",
+			configure:   func(c *DevicePluginConfig) { c.V4L2MaxBuffers = 64 },
+			wantRules:   []string{"max-buffers-driver-limit"},
+			wantBuffers: v4l2loopbackMaxBuffers,
+		},
+		{
+			// The clamp to 2 is what the recovery floor sees
+			name: "clamp is visible to later rules",
+			configure: func(c *DevicePluginConfig) {
+				c.V4L2MaxBuffers = 1
+				c.EnableBufferRecovery = true
+			},
+			wantRules:   []string{"max-buffers-single", "buffer-recovery-floor"},
+			wantBuffers: 2,
+		},
+		{
+			name:        "invalid exclusive caps",
+			configure:   func(c *DevicePluginConfig) { c.V4L2ExclusiveCaps = 2 },
+			wantRules:   []string{"exclusive-caps-value"},
+			wantErrors:  1,
+			wantBuffers: 8,
+		},
+		{
+			name:        "exclusive caps off",
+			configure:   func(c *DevicePluginConfig) { c.V4L2ExclusiveCaps = 0 },
+			wantRules:   []string{"exclusive-caps-chromium"},
+			wantBuffers: 8,
+		},
+		{
+			name:        "warm-up producer without a shared device",
+			configure:   func(c *DevicePluginConfig) { c.EnableWarmupProducer = true },
+			wantRules:   []string{"warmup-exclusive-caps"},
+			wantBuffers: 8,
+		},
+		{
+			name:        "read-only devices",
+			configure:   func(c *DevicePluginConfig) { c.VideoDevicePermissions = "r" },
+			wantRules:   []string{"producer-write-access"},
+			wantBuffers: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &DevicePluginConfig{
+				V4L2MaxBuffers:         8,
+				V4L2ExclusiveCaps:      1,
+				VideoDevicePermissions: "rw",
+			}
+			tt.configure(config)
+
+			findings := applyConfigRules(config)
+			var rules []string
+			errors := 0
+			for _, finding := range findings {
+				rules = append(rules, finding.Rule)
+				if finding.Severity == ConfigSeverityError {
+					errors++
+				}
+			}
+			if !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("violated rules = %v, want %v", rules, tt.wantRules)
+			}
+			if errors != tt.wantErrors {
+				t.Errorf("error findings = %d, want %d", errors, tt.wantErrors)
+			}
+			if config.V4L2MaxBuffers != tt.wantBuffers {
+				t.Errorf("V4L2MaxBuffers = %d, want %d", config.V4L2MaxBuffers, tt.wantBuffers)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ConfigMapWatcher applies dynamic settings from a named ConfigMap as it changes
+type ConfigMapWatcher struct {
+	client    *K8sClient
+	namespace string
+	name      string
+	settings  *RuntimeSettings
+	logger    *slog.Logger
+	cancel    context.CancelFunc
+}
+
+// NewConfigMapWatcher creates a new ConfigMapWatcher instance
+func NewConfigMapWatcher(client *K8sClient, namespace, name string, settings *RuntimeSettings, logger *slog.Logger) *ConfigMapWatcher {
+	return &ConfigMapWatcher{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		settings:  settings,
+		logger:    logger.With("configmap", namespace+"/"+name),
+	}
+}
+
+// Start watches the ConfigMap in the background, re-establishing the watch when it ends,
+// until ctx is canceled or Stop is called
+func (w *ConfigMapWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	go func() {
+		for {
+			if err := w.watch(ctx); err != nil && ctx.Err() == nil {
+				w.logger.Warn("ConfigMap watch failed, retrying", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+}
+
+// Stop ends the watch
+func (w *ConfigMapWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// watch runs a single list+watch cycle until the watch channel closes
+func (w *ConfigMapWatcher) watch(ctx context.Context) error {
+	configMaps := w.client.clientset.CoreV1().ConfigMaps(w.namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", w.name).String()
+
+	list, err := configMaps.List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		w.apply(&list.Items[i])
+	}
+
+	watcher, err := configMaps.Watch(ctx, metav1.ListOptions{
+		FieldSelector:   selector,
+		ResourceVersion: list.ResourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for event := range watcher.ResultChan() {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			if configMap, ok := event.Object.(*corev1.ConfigMap); ok {
+				w.apply(configMap)
+			}
+		case watch.Deleted:
+			// Keep the last applied values; the environment is only the startup baseline
+			w.logger.Warn("ConfigMap deleted, keeping last applied settings")
+		}
+	}
+	return nil
+}
+
+// apply hands the ConfigMap data to the runtime settings and reports rejected keys
+// A ConfigMap written for a newer settings schema is applied as far as this plugin understands
+// it: the skew is reported once and keys this version does not know are skipped quietly
+func (w *ConfigMapWatcher) apply(configMap *corev1.ConfigMap) {
+	data := make(map[string]string, len(configMap.Data))
+	for key, value := range configMap.Data {
+		data[key] = value
+	}
+	delete(data, settingsSchemaKey)
+
+	version, newer, err := settingsSkew(configMap.Data)
+	if err != nil {
+		w.logger.Warn("Ignoring settings schema version", "resource_version", configMap.ResourceVersion, "error", err)
+	}
+	if newer {
+		w.logger.Warn("ConfigMap was written for a newer settings schema, applying only the settings this plugin version understands",
+			"resource_version", configMap.ResourceVersion,
+			"configmap_schema", version,
+			"supported_schema", settingsSchemaVersion,
+			"plugin_version", pluginVersion)
+	}
+
+	for _, err := range w.settings.Apply(data, w.logger) {
+		if newer && errors.Is(err, ErrUnsupportedSetting) {
+			w.logger.Debug("Skipping setting of a newer schema", "resource_version", configMap.ResourceVersion, "error", err)
+			continue
+		}
+		w.logger.Warn("Ignoring dynamic setting", "resource_version", configMap.ResourceVersion, "error", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Kinds of disagreement between kubelet's device view and the node
+const (
+	DivergencePhantom         = "phantom"          // Allocatable at kubelet, but no device node backs it
+	DivergenceMissing         = "missing"          // Advertised by the plugin, but not allocatable at kubelet
+	DivergenceUnknownAssigned = "unknown_assigned" // Assigned to a container, but unknown to the plugin
+)
+
+// conformanceKinds lists every divergence kind so cleared gauges are reset to zero
+var conformanceKinds = []string{DivergencePhantom, DivergenceMissing, DivergenceUnknownAssigned}
+
+// conformanceQueryTimeout bounds each PodResources query of a conformance check
+const conformanceQueryTimeout = 10 * time.Second
+
+// kubeletDivergence is one device ID on which kubelet and the node disagree
+type kubeletDivergence struct {
+	Resource string
+	Kind     string
+	DeviceID string
+}
+
+// monitorConformance periodically compares kubelet's view of the devices with the node
+// A divergence is only reported once two consecutive checks agree on it, since kubelet
+// legitimately lags a ListAndWatch send by a moment
+func (p *VideoDevicePlugin) monitorConformance() {
+	if p.config.ConformanceCheckInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(p.config.ConformanceCheckInterval) * time.Second
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous map[kubeletDivergence]bool
+	reported := false
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		p.mu.RLock()
+		registered := p.registered
+		p.mu.RUnlock()
+		if !registered {
+			previous = nil
+			continue
+		}
+
+		current, err := p.checkConformance()
+		if err != nil {
+			p.logger.Warn("Conformance check failed", "error", err)
+			continue
+		}
+
+		var confirmed []kubeletDivergence
+		for divergence := range current {
+			if previous[divergence] {
+				confirmed = append(confirmed, divergence)
+			}
+		}
+		previous = current
+
+		p.reportConformance(confirmed, &reported)
+	}
+}
+
+// checkConformance returns the device IDs on which kubelet's view disagrees with the node
+func (p *VideoDevicePlugin) checkConformance() (map[kubeletDivergence]bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, conformanceQueryTimeout)
+	defer cancel()
+
+	allocatable, err := listAllocatableDevices(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := listAssignedDevices(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Device node backing each advertised ID, by resource
+	expected := map[string]map[string]string{p.config.ResourceName: {}}
+	advertised, _ := p.buildDeviceList()
+	for _, device := range advertised {
+		expected[p.config.ResourceName][device.ID] = p.devicePath(device.ID)
+	}
+	for _, tier := range p.tiers {
+		if expected[tier.ResourceName] != nil {
+			continue
+		}
+		expected[tier.ResourceName] = make(map[string]string)
+		tierDevices, _ := p.resourceDeviceList(tier.ResourceName)
+		for _, device := range tierDevices {
+			expected[tier.ResourceName][device.ID] = p.devicePath(device.ID)
+		}
+	}
+	if p.config.AVBundleCount > 0 {
+		expected[p.config.AVBundleResourceName] = make(map[string]string)
+		for _, bundle := range buildAVBundles(p.config) {
+			// Removed bundles are not expected in kubelet's view
+			if p.config.UnhealthyDevicePolicy == UnhealthyPolicyRemove && !avBundleUsable(bundle, p.v4l2Manager, p.allocations) {
+				continue
+			}
+			expected[p.config.AVBundleResourceName][bundle.ID] = p.devicePath(bundle.VideoDeviceID)
+		}
+	}
+
+	// Fallback devices are paths that never exist, so node presence is not checked there
+	checkNodes := !p.v4l2Manager.IsFallbackMode()
+
+	divergences := make(map[kubeletDivergence]bool)
+	for resource, devices := range expected {
+		for deviceID := range allocatable[resource] {
+			path, known := devices[deviceID]
+			if !known || (checkNodes && !checkDeviceExists(path)) {
+				divergences[kubeletDivergence{resource, DivergencePhantom, deviceID}] = true
+			}
+		}
+		for deviceID := range devices {
+			if !allocatable[resource][deviceID] {
+				divergences[kubeletDivergence{resource, DivergenceMissing, deviceID}] = true
+			}
+		}
+		for deviceID := range assigned[resource] {
+			if _, known := devices[deviceID]; !known {
+				divergences[kubeletDivergence{resource, DivergenceUnknownAssigned, deviceID}] = true
+			}
+		}
+	}
+	return divergences, nil
+}
+
+// devicePath returns the device node of a video device, empty if the manager does not know it
+func (p *VideoDevicePlugin) devicePath(deviceID string) string {
+	deviceID, _ = splitKubeletDeviceID(deviceID)
+	device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+	if err != nil {
+		return ""
+	}
+	return device.Path
+}
+
+// reportConformance publishes confirmed divergences as metrics, a log line and a node event
+// reported tracks whether the last event announced a divergence, so events fire on transitions
+func (p *VideoDevicePlugin) reportConformance(confirmed []kubeletDivergence, reported *bool) {
+	counts := make(map[string]map[string]int)
+	resources := []string{p.config.ResourceName}
+	if p.config.AVBundleCount > 0 {
+		resources = append(resources, p.config.AVBundleResourceName)
+	}
+	for _, tier := range buildDeviceTiers(p.config) {
+		resources = append(resources, tier.ResourceName)
+	}
+	for _, resource := range resources {
+		counts[resource] = make(map[string]int)
+	}
+	for _, divergence := range confirmed {
+		counts[divergence.Resource][divergence.Kind]++
+	}
+	for _, resource := range resources {
+		for _, kind := range conformanceKinds {
+			p.metrics.SetKubeletViewDivergences(resource, kind, counts[resource][kind])
+		}
+	}
+
+	if len(confirmed) == 0 {
+		if *reported {
+			p.logger.Info("Kubelet device view matches the node again")
+			p.recordEvent(corev1.EventTypeNormal, "KubeletViewConverged", "Kubelet device view matches the node again")
+			*reported = false
+		}
+		return
+	}
+
+	details := make([]string, 0, len(confirmed))
+	for _, divergence := range confirmed {
+		details = append(details, fmt.Sprintf("%s %s (%s)", divergence.Resource, divergence.DeviceID, divergence.Kind))
+	}
+	sort.Strings(details)
+	message := fmt.Sprintf("Kubelet device view diverges from the node on %d device(s): %s", len(details), strings.Join(details, ", "))
+
+	p.logger.Warn("Kubelet device view diverges from the node", "divergences", details)
+	if !*reported {
+		p.recordEvent(corev1.EventTypeWarning, "KubeletViewDiverged", message)
+		*reported = true
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of plugin decisions published on the watch stream
+const (
+	DecisionAllocation   = "allocation"   // Kubelet Allocate calls, local leases and their rollback
+	DecisionRelease      = "release"      // Allocations released by pod reconciliation or local lease release
+	DecisionHealth       = "health"       // Device health transitions and flap damping
+	DecisionRegistration = "registration" // Kubelet registration changes
+	DecisionReconcile    = "reconcile"    // Corrective actions: permissions, hot spares, buffer recovery
+)
+
+// decisionSubscriberBuffer is the number of events queued per watcher before events are dropped
+const decisionSubscriberBuffer = 256
+
+// watchHeartbeatInterval is how often an idle watch stream sends an SSE comment
+const watchHeartbeatInterval = 15 * time.Second
+
+// DecisionEvent is one structured plugin decision
+type DecisionEvent struct {
+	Seq           uint64            `json:"seq"`
+	Time          time.Time         `json:"time"`
+	Kind          string            `json:"kind"`
+	Action        string            `json:"action"`
+	DeviceID      string            `json:"device_id,omitempty"`
+	Resource      string            `json:"resource,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Message       string            `json:"message,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"`
+}
+
+// decisionSubscriber is one open watch stream
+type decisionSubscriber struct {
+	events  chan DecisionEvent
+	kinds   map[string]bool // Empty receives every kind
+	dropped int
+}
+
+// DecisionStream fans plugin decisions out to watch subscribers
+// Publishing never blocks: events for a subscriber whose buffer is full are dropped and counted
+type DecisionStream struct {
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[*decisionSubscriber]struct{}
+}
+
+// NewDecisionStream creates an empty decision stream
+func NewDecisionStream() *DecisionStream {
+	return &DecisionStream{subscribers: make(map[*decisionSubscriber]struct{})}
+}
+
+// Publish sends an event to every subscriber interested in its kind
+func (d *DecisionStream) Publish(event DecisionEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.subscribers) == 0 {
+		return
+	}
+
+	d.seq++
+	event.Seq = d.seq
+	event.Time = time.Now().UTC()
+	for subscriber := range d.subscribers {
+		if len(subscriber.kinds) > 0 && !subscriber.kinds[event.Kind] {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+// Subscribe registers a watcher for the given kinds (all kinds when empty)
+func (d *DecisionStream) Subscribe(kinds []string) *decisionSubscriber {
+	subscriber := &decisionSubscriber{
+		events: make(chan DecisionEvent, decisionSubscriberBuffer),
+		kinds:  make(map[string]bool, len(kinds)),
+	}
+	for _, kind := range kinds {
+		subscriber.kinds[kind] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+// Unsubscribe removes a watcher and returns how many events it missed
+func (d *DecisionStream) Unsubscribe(subscriber *decisionSubscriber) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subscribers, subscriber)
+	return subscriber.dropped
+}
+
+// handleWatch streams plugin decisions as server-sent events until the client disconnects
+// ?kinds=allocation,health narrows the stream to the listed kinds
+func (a *AdminServer) handleWatch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	var kinds []string
+	if param := r.URL.Query().Get("kinds"); param != "" {
+		for _, kind := range strings.Split(param, ",") {
+			switch kind = strings.TrimSpace(kind); kind {
+			case DecisionAllocation, DecisionRelease, DecisionHealth, DecisionRegistration, DecisionReconcile:
+				kinds = append(kinds, kind)
+			default:
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown decision kind %q", kind))
+				return
+			}
+		}
+	}
+
+	subscriber := a.plugin.decisions.Subscribe(kinds)
+	defer func() {
+		if dropped := a.plugin.decisions.Unsubscribe(subscriber); dropped > 0 {
+			a.logger.Warn("Watch client fell behind, events were dropped", "dropped", dropped)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	a.logger.Debug("Watch client connected", "kinds", kinds)
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event := <-subscriber.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Kind, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
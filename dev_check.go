@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkHostDev returns why the container's /dev cannot show the host's video device nodes, or ""
+// Devices created by the module only appear when /dev is the host devtmpfs (hostPath /dev); the
+// tmpfs /dev of a container, privileged ones included, is a copy taken at container start
+func checkHostDev() string {
+	fsType, found, err := mountFSType("/dev")
+	if err != nil {
+		return ""
+	}
+	if found && fsType != "devtmpfs" {
+		return fmt.Sprintf("/dev is a container-private %s mount instead of the host devtmpfs; "+
+			"mount the host /dev into the plugin (hostPath /dev)", fsType)
+	}
+
+	// Existing video devices must be visible with the numbers the kernel registered
+	entries, err := os.ReadDir("/sys/class/video4linux")
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		want, err := os.ReadFile(filepath.Join("/sys/class/video4linux", name, "dev"))
+		if err != nil {
+			continue
+		}
+		path := filepath.Join("/dev", name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Sprintf("kernel device %s has no node at %s; mount the host /dev (hostPath /dev) into the plugin", name, path)
+		}
+		major, minor, ok := fileDeviceNumbers(info)
+		if !ok {
+			continue
+		}
+		got := fmt.Sprintf("%d:%d", major, minor)
+		if got != strings.TrimSpace(string(want)) {
+			return fmt.Sprintf("%s is %s but the kernel registered %s as %s; /dev holds stale nodes instead of the host devtmpfs",
+				path, got, name, strings.TrimSpace(string(want)))
+		}
+	}
+	return ""
+}
+
+// mountFSType returns the filesystem type mounted at mountPoint in this mount namespace
+func mountFSType(mountPoint string) (string, bool, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+
+	// Later entries shadow earlier ones mounted at the same point
+	fsType, found := "", false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[4] != mountPoint {
+			continue
+		}
+		for i := 5; i < len(fields)-1; i++ {
+			if fields[i] == "-" {
+				fsType, found = fields[i+1], true
+				break
+			}
+		}
+	}
+	return fsType, found, scanner.Err()
+}
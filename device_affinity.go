@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PreferredDeviceAnnotation names the video device a pod wants back after a restart (e.g. "video12")
+const PreferredDeviceAnnotation = "meeting-baas.io/preferred-device"
+
+// affinityLookupTimeout bounds the pod lookup so GetPreferredAllocation never stalls kubelet
+const affinityLookupTimeout = 2 * time.Second
+
+// preferredDeviceRequests returns the devices requested through PreferredDeviceAnnotation by pods
+// waiting on this node for the main resource, oldest pod first
+// Kubelet does not say which pod a GetPreferredAllocation call is for, so every waiting pod's
+// preference is honored while its device is available; with one bot starting at a time it is exact
+func (p *VideoDevicePlugin) preferredDeviceRequests(ctx context.Context) []string {
+	if p.k8sClient == nil || !p.config.EnableDeviceAffinity {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, affinityLookupTimeout)
+	defer cancel()
+	pods, err := p.k8sClient.ListPendingPods(ctx, p.config.PodWatchNamespace)
+	if err != nil {
+		p.logger.Warn("Cannot resolve preferred devices, allocating without affinity", "error", err)
+		return nil
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+
+	var preferred []string
+	for i := range pods {
+		value, ok := pods[i].Annotations[PreferredDeviceAnnotation]
+		if !ok || podDeviceRequest(&pods[i], p.config.ResourceName) == 0 {
+			continue
+		}
+		deviceID := strings.TrimSpace(value)
+		if !strings.HasPrefix(deviceID, "video") {
+			deviceID = "video" + deviceID
+		}
+		preferred = append(preferred, deviceID)
+	}
+	return preferred
+}
+
+// applyDeviceAffinity moves the first wanted device that is available into mustInclude
+// It returns the extended mustInclude and the wanted devices left for later container requests
+func applyDeviceAffinity(wanted, available, mustInclude []string, size int) ([]string, []string) {
+	if len(mustInclude) >= size {
+		return mustInclude, wanted
+	}
+	for i, deviceID := range wanted {
+		if slices.Contains(available, deviceID) && !slices.Contains(mustInclude, deviceID) {
+			rest := append(append([]string{}, wanted[:i]...), wanted[i+1:]...)
+			return append(append([]string{}, mustInclude...), deviceID), rest
+		}
+	}
+	return mustInclude, wanted
+}
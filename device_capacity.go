@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ErrCapacityConflict is returned when the pool cannot shrink without taking allocated devices
+var ErrCapacityConflict = errors.New("capacity change conflicts with allocated devices")
+
+// CapacityStatus is the runtime size of the main resource pool, as returned by /v1/capacity
+type CapacityStatus struct {
+	Advertised int      `json:"advertised"`       // Devices currently advertised to kubelet
+	Max        int      `json:"max"`              // Devices the pool can advertise (created slots minus spares)
+	Parked     []string `json:"parked"`           // Devices withdrawn by capacity changes
+	Failed     []string `json:"failed,omitempty"` // Devices a grow unparked but could not create; advertised Unhealthy
+}
+
+// capacityRequest is the body of PUT /v1/capacity
+type capacityRequest struct {
+	Advertised int `json:"advertised"`
+}
+
+// park withdraws devices from advertisement until they are unparked
+func (s *HotSparePool) park(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.roles[id] = DeviceRoleParked
+	}
+	s.notifyChangeLocked()
+}
+
+// unpark returns parked devices to advertisement
+func (s *HotSparePool) unpark(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if s.roles[id] == DeviceRoleParked {
+			delete(s.roles, id)
+		}
+	}
+	s.notifyChangeLocked()
+}
+
+// poolDevices returns the sorted main-resource devices that are advertised or parked
+// Hot spares and devices under repair keep their own lifecycle and are not resized
+func (p *VideoDevicePlugin) poolDevices() (advertised, parked []string) {
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		if p.deviceResource(deviceID) != p.config.ResourceName {
+			continue
+		}
+		switch p.spares.Role(deviceID) {
+		case DeviceRoleAdvertised:
+			advertised = append(advertised, deviceID)
+		case DeviceRoleParked:
+			parked = append(parked, deviceID)
+		}
+	}
+	sort.Strings(advertised)
+	sort.Strings(parked)
+	return advertised, parked
+}
+
+// Capacity returns the current runtime size of the main resource pool
+func (p *VideoDevicePlugin) Capacity() CapacityStatus {
+	advertised, parked := p.poolDevices()
+	if parked == nil {
+		parked = []string{}
+	}
+	return CapacityStatus{Advertised: len(advertised), Max: len(advertised) + len(parked), Parked: parked}
+}
+
+// ResizePool grows or shrinks the number of devices advertised for the main resource
+// Shrinking parks free devices, highest numbers first, and fails with ErrCapacityConflict when
+// too few are free; growing unparks devices, recreating missing ones through v4l2loopback-ctl.
+// Devices that could not be created are unparked anyway and listed in Failed.
+// A kubelet Allocate racing a shrink is rejected by allocate validation and retried
+func (p *VideoDevicePlugin) ResizePool(ctx context.Context, target int) (CapacityStatus, error) {
+	p.resizeMu.Lock()
+	defer p.resizeMu.Unlock()
+
+	advertised, parked := p.poolDevices()
+	if target < 0 || target > len(advertised)+len(parked) {
+		return p.Capacity(), fmt.Errorf("advertised must be between 0 and %d, got %d", len(advertised)+len(parked), target)
+	}
+
+	var failed []string
+	switch {
+	case target < len(advertised):
+		busy := make(map[string]bool)
+		for _, allocation := range p.allocations.List() {
+			busy[allocation.DeviceID] = true
+		}
+		// Allocations missing from the tracker (e.g. restarts without a checkpoint) still count
+		if assigned, err := p.assignedVideoDevices(ctx); err == nil {
+			for deviceID := range assigned {
+				busy[deviceID] = true
+			}
+		}
+
+		var free []string
+		for i := len(advertised) - 1; i >= 0; i-- {
+			if !busy[advertised[i]] {
+				free = append(free, advertised[i])
+			}
+		}
+		excess := len(advertised) - target
+		if len(free) < excess {
+			return p.Capacity(), fmt.Errorf("%w: %d of %d advertised devices are free, %d must be parked",
+				ErrCapacityConflict, len(free), len(advertised), excess)
+		}
+		p.spares.park(free[:excess])
+		p.logger.Info("Parked devices to shrink the pool", "devices", free[:excess], "advertised", target)
+		p.decisions.Publish(DecisionEvent{
+			Kind:    DecisionReconcile,
+			Action:  "pool_shrunk",
+			Message: fmt.Sprintf("parked %v", free[:excess]),
+			Fields:  map[string]string{"advertised": fmt.Sprintf("%d", target)},
+		})
+
+	case target > len(advertised):
+		wanted := parked[:target-len(advertised)]
+		for _, deviceID := range wanted {
+			device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+			if err != nil || p.v4l2Manager.IsFallbackMode() || checkDeviceExists(device.Path) {
+				continue
+			}
+			resetCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+			err = p.resetDeviceWithContext(resetCtx, device.Path, p.deviceMaxBuffers(device))
+			cancel()
+			if err != nil {
+				// Still unparked; it is advertised Unhealthy like any broken slot
+				p.logger.Warn("Failed to create parked device", "device_id", deviceID, "error", err)
+				failed = append(failed, deviceID)
+				continue
+			}
+			if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+				p.logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+			}
+		}
+		p.spares.unpark(wanted)
+		p.logger.Info("Unparked devices to grow the pool", "devices", wanted, "advertised", target)
+		p.decisions.Publish(DecisionEvent{
+			Kind:    DecisionReconcile,
+			Action:  "pool_grown",
+			Message: fmt.Sprintf("unparked %v", wanted),
+			Fields:  map[string]string{"advertised": fmt.Sprintf("%d", target)},
+		})
+
+	default:
+		return p.Capacity(), nil
+	}
+
+	p.requestListAndWatchRefresh()
+	status := p.Capacity()
+	status.Failed = failed
+	return status, nil
+}
+
+// handleGetCapacity returns the runtime size of the main resource pool
+func (a *AdminServer) handleGetCapacity(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.plugin.Capacity())
+}
+
+// handleSetCapacity grows or shrinks the number of advertised devices
+func (a *AdminServer) handleSetCapacity(w http.ResponseWriter, r *http.Request) {
+	var req capacityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	status, err := a.plugin.ResizePool(r.Context(), req.Advertised)
+	switch {
+	case errors.Is(err, ErrCapacityConflict):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	case len(status.Failed) > 0:
+		// The pool grew, but part of it is advertised Unhealthy
+		writeJSON(w, http.StatusMultiStatus, status)
+	default:
+		writeJSON(w, http.StatusOK, status)
+	}
+}
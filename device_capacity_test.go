@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestResizePool(t *testing.T) {
+	tests := []struct {
+		name           string
+		parked         []string // Parked before the resize
+		allocated      []string
+		missing        bool // Parked devices have no device node and must be created
+		target         int
+		wantErr        error
+		wantAdvertised int
+		wantParked     []string
+		wantFailed     []string
+	}{
+		{
+			name:           "shrink parks the highest free devices",
+			allocated:      []string{"video13"},
+			target:         2,
+			wantAdvertised: 2,
+			wantParked:     []string{"video11", "video12"},
+		},
+		{
+			name:           "shrink conflicts with allocated devices",
+			allocated:      []string{"video11", "video12", "video13"},
+			target:         2,
+			wantErr:        ErrCapacityConflict,
+			wantAdvertised: 4,
+			wantParked:     []string{},
+		},
+		{
+			name:           "grow unparks existing devices",
+			parked:         []string{"video12", "video13"},
+			target:         4,
+			wantAdvertised: 4,
+			wantParked:     []string{},
+		},
+		{
+			name:           "grow reports devices it could not create",
+			parked:         []string{"video12", "video13"},
+			missing:        true,
+			target:         3,
+			wantAdvertised: 3,
+			wantParked:     []string{"video13"},
+			wantFailed:     []string{"video12"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newFakeV4L2Manager(4)
+			dir := t.TempDir()
+			for id, device := range manager.devices {
+				device.Path = filepath.Join(dir, id)
+				if tt.missing && slices.Contains(tt.parked, id) {
+					continue
+				}
+				if err := os.WriteFile(device.Path, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			plugin := newTestPlugin(t, manager, nil)
+			plugin.spares.park(tt.parked)
+			for _, id := range tt.allocated {
+				if err := plugin.allocations.RecordKubeletAllocation([]*VideoDevice{manager.devices[id]}, "held-"+id, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx := context.Background()
+			if tt.missing {
+				// Creation fails before v4l2loopback-ctl runs
+				canceled, cancel := context.WithCancel(ctx)
+				cancel()
+				ctx = canceled
+			}
+			status, err := plugin.ResizePool(ctx, tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResizePool error = %v, want %v", err, tt.wantErr)
+			}
+			if status.Advertised != tt.wantAdvertised {
+				t.Errorf("advertised = %d, want %d", status.Advertised, tt.wantAdvertised)
+			}
+			if status.Max != 4 {
+				t.Errorf("max = %d, want 4", status.Max)
+			}
+			if !slices.Equal(status.Parked, tt.wantParked) {
+				t.Errorf("parked = %v, want %v", status.Parked, tt.wantParked)
+			}
+			if !slices.Equal(status.Failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", status.Failed, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DeviceSetPlan lists the v4l2loopback-ctl operations that turn a partially created device set
+// into the configured one without reloading the module
+type DeviceSetPlan struct {
+	Missing []int  // Numbers in the range without a device node
+	Surplus []int  // Free loopback devices directly above the range, left by a larger MAX_DEVICES
+	Busy    []int  // Loopback devices above the range that are held open and stay untouched
+	Blocked string // Why the set cannot be converged in place ("" when it can)
+}
+
+// planDeviceSetConvergence inspects the device range of a loaded module
+// A range holding foreign or impostor nodes is blocked; only missing and surplus loopback
+// devices can be fixed with v4l2loopback-ctl
+func planDeviceSetConvergence(config *DevicePluginConfig) DeviceSetPlan {
+	var plan DeviceSetPlan
+	end := config.VideoDeviceStart + config.MaxDevices
+	for n := config.VideoDeviceStart; n < end; n++ {
+		devicePath := fmt.Sprintf("/dev/video%d", n)
+		switch classifyVideoNode(n) {
+		case videoNodeFree:
+			plan.Missing = append(plan.Missing, n)
+		case videoNodeForeign:
+			plan.Blocked = fmt.Sprintf("%s is not a v4l2loopback device", devicePath)
+			return plan
+		default:
+			if reason := impostorReason(devicePath); reason != "" {
+				plan.Blocked = fmt.Sprintf("%s %s", devicePath, reason)
+				return plan
+			}
+		}
+	}
+
+	// A previous run with a larger MAX_DEVICES leaves its devices contiguous above the range
+	for n := end; n <= config.VideoDeviceCeiling && classifyVideoNode(n) == videoNodeLoopback; n++ {
+		if len(findDeviceHolders(fmt.Sprintf("/dev/video%d", n))) > 0 {
+			plan.Busy = append(plan.Busy, n)
+			continue
+		}
+		plan.Surplus = append(plan.Surplus, n)
+	}
+	return plan
+}
+
+// convergeDeviceSet repairs a loaded module's device set through v4l2loopback-ctl
+// A crash between module load and device population, or a device deleted by hand, leaves the
+// module loaded with some devices missing; reloading it would cut off every pod using the others.
+// It returns an error when the set could not be converged and the module has to be reloaded.
+func convergeDeviceSet(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	plan := planDeviceSetConvergence(config)
+	if plan.Blocked != "" {
+		return fmt.Errorf("device set cannot be converged in place: %s", plan.Blocked)
+	}
+	if len(plan.Missing) == 0 && len(plan.Surplus) == 0 {
+		return verifyV4L2Configuration(config, logger)
+	}
+
+	logger.Info("Converging partially created device set",
+		"missing", plan.Missing,
+		"surplus", plan.Surplus,
+		"busy_surplus", plan.Busy)
+
+	tiers := tierVideoIDs(config)
+	for _, n := range plan.Missing {
+		devicePath := fmt.Sprintf("/dev/video%d", n)
+		label, maxBuffers, exclusiveCaps := config.V4L2CardLabel, config.V4L2MaxBuffers, config.V4L2ExclusiveCaps
+		if tier, ok := tiers[fmt.Sprintf("video%d", n)]; ok {
+			label, maxBuffers, exclusiveCaps = tier.CardLabel, tier.MaxBuffers, tier.ExclusiveCaps
+		}
+
+		ctlCtx, cancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
+		out, err := privilegedCommand(ctlCtx, "v4l2loopback-ctl", "add",
+			"-n", label,
+			"-b", fmt.Sprintf("%d", maxBuffers),
+			"-x", fmt.Sprintf("%d", exclusiveCaps),
+			devicePath)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to add missing device %s: %w (output: %s)", devicePath, err, strings.TrimSpace(string(out)))
+		}
+		logger.Info("Added missing device", "device_path", devicePath)
+	}
+
+	// Surplus devices are never advertised; removing them is tidy-up and failures are not fatal
+	for _, n := range plan.Surplus {
+		devicePath := fmt.Sprintf("/dev/video%d", n)
+		ctlCtx, cancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
+		out, err := privilegedCommand(ctlCtx, "v4l2loopback-ctl", "delete", devicePath)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to remove surplus device", "device_path", devicePath, "error", err, "output", strings.TrimSpace(string(out)))
+			continue
+		}
+		logger.Info("Removed surplus device", "device_path", devicePath)
+	}
+
+	if err := waitForDeviceNodes(ctx, config, time.Duration(config.DeviceCreationTimeout)*time.Second); err != nil {
+		return err
+	}
+	return verifyV4L2Configuration(config, logger)
+}
+
+// waitForDeviceNodes waits for udev/devtmpfs to create the nodes of the whole range
+func waitForDeviceNodes(ctx context.Context, config *DevicePluginConfig, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		missing := ""
+		for n := config.VideoDeviceStart; n < config.VideoDeviceStart+config.MaxDevices; n++ {
+			if devicePath := fmt.Sprintf("/dev/video%d", n); !checkDeviceExists(devicePath) {
+				missing = devicePath
+				break
+			}
+		}
+		if missing == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %s did not appear within %s", missing, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for device %s: %w", missing, context.Cause(ctx))
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
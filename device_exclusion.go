@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseExcludedDevices parses EXCLUDED_DEVICES into the set of video device IDs kept from kubelet
+// Entries are /dev/videoN numbers ("12" or "video12") inside the RESOURCE_NAME part of the range;
+// bundle, hot spare and tier slots cannot be excluded
+func parseExcludedDevices(config *DevicePluginConfig) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	if strings.TrimSpace(config.ExcludedDevices) == "" {
+		return excluded, nil
+	}
+
+	reserved := avBundleVideoIDs(config)
+	for _, id := range hotSpareIDs(config) {
+		reserved[id] = true
+	}
+	for id := range tierVideoIDs(config) {
+		reserved[id] = true
+	}
+
+	for _, entry := range strings.Split(config.ExcludedDevices, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(entry, "video"))
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("EXCLUDED_DEVICES entry %q is not a video device number", entry)
+		}
+		if number < config.VideoDeviceStart || number >= config.VideoDeviceStart+config.MaxDevices {
+			return nil, fmt.Errorf("EXCLUDED_DEVICES entry video%d is outside the device range video%d-video%d",
+				number, config.VideoDeviceStart, config.VideoDeviceStart+config.MaxDevices-1)
+		}
+		deviceID := fmt.Sprintf("video%d", number)
+		if reserved[deviceID] {
+			return nil, fmt.Errorf("EXCLUDED_DEVICES entry %s is an av-bundle, hot spare or tier slot", deviceID)
+		}
+		excluded[deviceID] = true
+	}
+	return excluded, nil
+}
+
+// excludedVideoIDs returns the video device IDs excluded from advertisement
+func excludedVideoIDs(config *DevicePluginConfig) map[string]bool {
+	excluded, _ := parseExcludedDevices(config)
+	return excluded
+}
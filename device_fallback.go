@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Fallback granularities (FALLBACK_GRANULARITY)
+const (
+	FallbackGranularityNode   = "node"   // A failed module load replaces the whole pool with placeholders
+	FallbackGranularityDevice = "device" // Additionally, slots the loaded module did not create get a placeholder each
+)
+
+// SetSlotFallback makes CreateDevices stand in a placeholder for every missing slot
+// instead of advertising it as unhealthy
+func (v *v4l2Manager) SetSlotFallback(enabled bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.slotFallback = enabled
+}
+
+// createSlotPlaceholders replaces missing slots with fallback placeholders and returns their paths
+// Slots whose placeholder cannot be created stay skipped; caller must hold v.mu
+func (v *v4l2Manager) createSlotPlaceholders(deviceIDs []string) []string {
+	var created []string
+	for _, deviceID := range deviceIDs {
+		devicePath := v.fallbackPrefix + strings.TrimPrefix(deviceID, "video")
+		if err := v.createFallbackDeviceFile(devicePath); err != nil {
+			v.logger.Error("Failed to create fallback placeholder for missing slot",
+				"device_id", deviceID,
+				"device_path", devicePath,
+				"error", err)
+			continue
+		}
+		v.devices[deviceID] = &VideoDevice{
+			ID:         deviceID,
+			Path:       devicePath,
+			Fallback:   true,
+			Generation: v.retired[deviceID] + 1,
+			CreatedAt:  time.Now(),
+		}
+		delete(v.skipped, deviceID)
+		created = append(created, devicePath)
+	}
+	return created
+}
+
+// removeSlotPlaceholders deletes the placeholder files of per-device fallback slots
+// Whole-pool fallback placeholders are left to CleanupFallbackDevices; caller must hold v.mu
+func (v *v4l2Manager) removeSlotPlaceholders() {
+	if v.fallbackMode {
+		return
+	}
+	for _, device := range v.devices {
+		if !device.Fallback || !strings.HasPrefix(device.Path, v.fallbackPrefix) {
+			continue
+		}
+		if err := os.Remove(device.Path); err != nil && !os.IsNotExist(err) {
+			v.logger.Warn("Failed to remove fallback placeholder", "device_path", device.Path, "error", err)
+			continue
+		}
+		v.logger.Debug("Removed fallback placeholder", "device_id", device.ID, "device_path", device.Path)
+	}
+}
+
+// fallbackDevicePaths returns the paths of the placeholders among allocated devices
+func fallbackDevicePaths(devices []*VideoDevice) []string {
+	var paths []string
+	for _, device := range devices {
+		if device.Fallback {
+			paths = append(paths, device.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// holderPodLookupTimeout bounds resolving holder pod UIDs to pod names
+const holderPodLookupTimeout = 2 * time.Second
+
+// DeviceHolder describes a process holding a device file open
+type DeviceHolder struct {
+	PID         int    `json:"pid"`
+	Comm        string `json:"comm"`
+	PodUID      string `json:"pod_uid,omitempty"`      // From the process cgroup path, empty outside pods
+	ContainerID string `json:"container_id,omitempty"` // From the process cgroup path
+	Pod         string `json:"pod,omitempty"`          // namespace/name when the pod UID could be resolved
+	Writer      bool   `json:"writer,omitempty"`       // Opened for writing (O_WRONLY or O_RDWR), as producers do
+}
+
+// DeviceHolderReport lists the processes holding each video device open
+type DeviceHolderReport struct {
+	// HostPIDNamespace is false when the plugin only sees its own pod's processes (no hostPID),
+	// in which case holders in other pods are missing from the report
+	HostPIDNamespace bool                      `json:"host_pid_namespace"`
+	Devices          map[string][]DeviceHolder `json:"devices"`
+}
+
+// podCgroupPattern matches the pod UID in cgroupfs (pod<uid>) and systemd (pod<uid with _>.slice) layouts
+var podCgroupPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+// containerCgroupPattern matches a container ID as the last cgroup path element
+// (<id>, cri-containerd-<id>.scope, crio-<id>.scope, docker-<id>.scope)
+var containerCgroupPattern = regexp.MustCompile(`(?:^|[-/])([0-9a-f]{64})(?:\.scope)?$`)
+
+// findDeviceHolders scans /proc for processes (other than this one) with devicePath open
+func findDeviceHolders(devicePath string) []DeviceHolder {
+	return findHolders([]string{devicePath})[devicePath]
+}
+
+// findHolders scans /proc once for processes (other than this one) holding any of devicePaths open
+func findHolders(devicePaths []string) map[string][]DeviceHolder {
+	wanted := make(map[string]bool, len(devicePaths))
+	for _, path := range devicePaths {
+		wanted[path] = true
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	holders := make(map[string][]DeviceHolder)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// Process exited or is not accessible
+			continue
+		}
+
+		held := make(map[string]int) // device path -> index of this process in holders[path]
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !wanted[target] {
+				continue
+			}
+			writer := fdOpenedForWriting(filepath.Join("/proc", entry.Name(), "fdinfo", fd.Name()))
+			if index, exists := held[target]; exists {
+				holders[target][index].Writer = holders[target][index].Writer || writer
+				continue
+			}
+			held[target] = len(holders[target])
+
+			comm, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+			cgroup, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "cgroup"))
+			podUID, containerID := parseCgroupPod(string(cgroup))
+			holders[target] = append(holders[target], DeviceHolder{
+				PID:         pid,
+				Comm:        strings.TrimSpace(string(comm)),
+				PodUID:      podUID,
+				ContainerID: containerID,
+				Writer:      writer,
+			})
+		}
+	}
+
+	return holders
+}
+
+// fdOpenedForWriting reports whether the open flags in /proc/<pid>/fdinfo/<fd> allow writing
+func fdOpenedForWriting(fdinfoPath string) bool {
+	data, err := os.ReadFile(fdinfoPath)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		value, found := strings.CutPrefix(line, "flags:")
+		if !found {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+		if err != nil {
+			return false
+		}
+		mode := flags & syscall.O_ACCMODE
+		return mode == syscall.O_WRONLY || mode == syscall.O_RDWR
+	}
+	return false
+}
+
+// parseCgroupPod extracts the pod UID and container ID from /proc/<pid>/cgroup contents
+func parseCgroupPod(cgroup string) (string, string) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		match := podCgroupPattern.FindStringSubmatch(parts[2])
+		if match == nil {
+			continue
+		}
+		podUID := strings.ReplaceAll(match[1], "_", "-")
+		containerID := ""
+		if container := containerCgroupPattern.FindStringSubmatch(parts[2]); container != nil {
+			containerID = container[1]
+		}
+		return podUID, containerID
+	}
+	return "", ""
+}
+
+// hostPIDNamespace reports whether /proc shows the host's processes
+// In a private PID namespace PID 1 is the container's own process, inside a pod cgroup
+func hostPIDNamespace() bool {
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	podUID, _ := parseCgroupPod(string(cgroup))
+	return podUID == "" && !strings.Contains(string(cgroup), "docker") && !strings.Contains(string(cgroup), "containerd")
+}
+
+// deviceHolderReport lists the holders of every video device, naming their pods when possible
+func (p *VideoDevicePlugin) deviceHolderReport(ctx context.Context) DeviceHolderReport {
+	devices := p.v4l2Manager.ListAllDevices()
+	paths := make([]string, 0, len(devices))
+	for _, device := range devices {
+		paths = append(paths, device.Path)
+	}
+	byPath := findHolders(paths)
+
+	report := DeviceHolderReport{
+		HostPIDNamespace: hostPIDNamespace(),
+		Devices:          make(map[string][]DeviceHolder),
+	}
+	for _, device := range devices {
+		if holders := byPath[device.Path]; len(holders) > 0 {
+			report.Devices[device.ID] = holders
+		}
+	}
+	if len(report.Devices) == 0 || p.k8sClient == nil {
+		return report
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, holderPodLookupTimeout)
+	defer cancel()
+	pods, err := p.k8sClient.ListNodePods(ctx)
+	if err != nil {
+		p.logger.Debug("Cannot resolve device holder pods", "error", err)
+		return report
+	}
+	for _, holders := range report.Devices {
+		for i := range holders {
+			holders[i].Pod = pods[holders[i].PodUID]
+		}
+	}
+	return report
+}
+
+// logDeviceHolders logs who holds the plugin's devices, explaining a busy module or device
+func (p *VideoDevicePlugin) logDeviceHolders(ctx context.Context, reason string) {
+	report := p.deviceHolderReport(ctx)
+	if len(report.Devices) == 0 {
+		p.logger.Info("No process holds a video device open", "reason", reason, "host_pid_namespace", report.HostPIDNamespace)
+		return
+	}
+
+	ids := make([]string, 0, len(report.Devices))
+	for deviceID := range report.Devices {
+		ids = append(ids, deviceID)
+	}
+	sort.Strings(ids)
+	for _, deviceID := range ids {
+		for _, holder := range report.Devices[deviceID] {
+			p.logger.Warn("Video device held open",
+				"reason", reason,
+				"device_id", deviceID,
+				"pid", holder.PID,
+				"comm", holder.Comm,
+				"pod", holder.Pod,
+				"pod_uid", holder.PodUID,
+				"container_id", holder.ContainerID)
+		}
+	}
+	if !report.HostPIDNamespace {
+		p.logger.Warn("Plugin does not share the host PID namespace, holders in other pods are not visible", "reason", reason)
+	}
+}
+
+// handleDeviceHolders returns the processes holding video devices open
+func (a *AdminServer) handleDeviceHolders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.plugin.deviceHolderReport(r.Context()))
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device hook stages
+const (
+	HookStageCreate   = "create"   // After a device node is created or recreated
+	HookStageAllocate = "allocate" // Before a device is handed to a container or local lease
+	HookStageRelease  = "release"  // After a device is returned
+)
+
+// Built-in hook types
+const (
+	HookTypePermissions = "permissions" // chmod/chown the device node
+	HookTypeFormat      = "format"      // Set the output format
+	HookTypeClearFrame  = "clear"       // Write one black frame
+	HookTypeExec        = "exec"        // Run a site-specific command
+)
+
+// defaultHookTimeout bounds a hook when its spec sets no timeout
+const defaultHookTimeout = 10 * time.Second
+
+// DeviceHook prepares a device at a lifecycle stage
+type DeviceHook interface {
+	Name() string
+	Run(ctx context.Context, stage string, device *VideoDevice) error
+}
+
+// DeviceHookSpec configures one hook in DEVICE_HOOKS_FILE
+type DeviceHookSpec struct {
+	Name        string   `json:"name,omitempty"`          // Name used in logs (defaults to the type)
+	Type        string   `json:"type"`                    // permissions, format, clear or exec
+	Stages      []string `json:"stages"`                  // Stages the hook runs at
+	Timeout     int      `json:"timeout,omitempty"`       // Seconds before the hook is cancelled (default 10)
+	FailOnError bool     `json:"fail_on_error,omitempty"` // Fail the operation instead of logging the error
+
+	Mode string `json:"mode,omitempty"` // permissions: octal mode (e.g. "0660")
+	UID  *int   `json:"uid,omitempty"`  // permissions: owner (unchanged when unset)
+	GID  *int   `json:"gid,omitempty"`  // permissions: group (unchanged when unset)
+
+	Width  int `json:"width,omitempty"`  // format/clear: frame width
+	Height int `json:"height,omitempty"` // format/clear: frame height
+
+	Command []string `json:"command,omitempty"` // exec: argv; DEVICE_ID, DEVICE_PATH and HOOK_STAGE are set in the environment
+}
+
+// deviceHooksFile is the layout of DEVICE_HOOKS_FILE
+type deviceHooksFile struct {
+	Hooks []DeviceHookSpec `json:"hooks"`
+}
+
+// configuredHook is a hook with its stage selection and failure policy
+type configuredHook struct {
+	hook        DeviceHook
+	stages      map[string]bool
+	timeout     time.Duration
+	failOnError bool
+}
+
+// DeviceHookRunner runs the configured hooks in file order
+// All methods are safe to call on a nil *DeviceHookRunner, which runs nothing
+type DeviceHookRunner struct {
+	hooks  []configuredHook
+	logger *slog.Logger
+}
+
+// LoadDeviceHooks reads and validates the hooks file
+func LoadDeviceHooks(path string, logger *slog.Logger) (*DeviceHookRunner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device hooks file: %w", err)
+	}
+
+	var file deviceHooksFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse device hooks file %s: %w", path, err)
+	}
+
+	runner := &DeviceHookRunner{logger: logger}
+	for i, spec := range file.Hooks {
+		hook, err := newDeviceHook(spec)
+		if err != nil {
+			return nil, fmt.Errorf("hook %d: %w", i, err)
+		}
+
+		stages := make(map[string]bool, len(spec.Stages))
+		for _, stage := range spec.Stages {
+			if stage != HookStageCreate && stage != HookStageAllocate && stage != HookStageRelease {
+				return nil, fmt.Errorf("hook %d (%s): unknown stage %q", i, hook.Name(), stage)
+			}
+			stages[stage] = true
+		}
+		if len(stages) == 0 {
+			return nil, fmt.Errorf("hook %d (%s): no stages configured", i, hook.Name())
+		}
+
+		timeout := defaultHookTimeout
+		if spec.Timeout > 0 {
+			timeout = time.Duration(spec.Timeout) * time.Second
+		}
+		runner.hooks = append(runner.hooks, configuredHook{
+			hook:        hook,
+			stages:      stages,
+			timeout:     timeout,
+			failOnError: spec.FailOnError,
+		})
+	}
+
+	logger.Info("Loaded device preparation hooks", "path", path, "hooks", len(runner.hooks))
+	return runner, nil
+}
+
+// newDeviceHook builds a hook from its spec
+func newDeviceHook(spec DeviceHookSpec) (DeviceHook, error) {
+	name := spec.Name
+	if name == "" {
+		name = spec.Type
+	}
+
+	switch spec.Type {
+	case HookTypePermissions:
+		hook := &permissionsHook{name: name, uid: -1, gid: -1}
+		if spec.Mode != "" {
+			mode, err := strconv.ParseUint(spec.Mode, 8, 32)
+			if err != nil || mode > 0o777 {
+				return nil, fmt.Errorf("invalid mode %q", spec.Mode)
+			}
+			hook.mode = os.FileMode(mode)
+		}
+		if spec.UID != nil {
+			hook.uid = *spec.UID
+		}
+		if spec.GID != nil {
+			hook.gid = *spec.GID
+		}
+		if hook.mode == 0 && hook.uid < 0 && hook.gid < 0 {
+			return nil, fmt.Errorf("permissions hook %s sets none of mode, uid, gid", name)
+		}
+		return hook, nil
+	case HookTypeFormat, HookTypeClearFrame:
+		if spec.Width <= 0 || spec.Height <= 0 || spec.Width%2 != 0 {
+			return nil, fmt.Errorf("%s hook %s needs a positive even width and positive height", spec.Type, name)
+		}
+		return &formatHook{name: name, width: uint32(spec.Width), height: uint32(spec.Height), clear: spec.Type == HookTypeClearFrame}, nil
+	case HookTypeExec:
+		if len(spec.Command) == 0 {
+			return nil, fmt.Errorf("exec hook %s has no command", name)
+		}
+		return &execHook{name: name, command: spec.Command}, nil
+	default:
+		return nil, fmt.Errorf("unknown hook type %q", spec.Type)
+	}
+}
+
+// Run runs every hook configured for stage on device
+// Errors of fail_on_error hooks are returned; others are logged
+func (r *DeviceHookRunner) Run(ctx context.Context, stage string, device *VideoDevice) error {
+	if r == nil {
+		return nil
+	}
+
+	for _, configured := range r.hooks {
+		if !configured.stages[stage] {
+			continue
+		}
+		// A cancelled caller (e.g. an Allocate past ALLOCATION_TIMEOUT) runs no further hooks
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		hookCtx, cancel := context.WithTimeout(ctx, configured.timeout)
+		start := time.Now()
+		err := configured.hook.Run(hookCtx, stage, device)
+		cancel()
+
+		if err == nil {
+			r.logger.Debug("Device hook completed",
+				"hook", configured.hook.Name(),
+				"stage", stage,
+				"device_id", device.ID,
+				"duration", time.Since(start).String())
+			continue
+		}
+		if configured.failOnError {
+			return fmt.Errorf("%s hook %s failed for %s: %w", stage, configured.hook.Name(), device.ID, err)
+		}
+		r.logger.Warn("Device hook failed",
+			"hook", configured.hook.Name(),
+			"stage", stage,
+			"device_id", device.ID,
+			"error", err)
+	}
+	return nil
+}
+
+// permissionsHook applies mode and ownership to the device node
+type permissionsHook struct {
+	name string
+	mode os.FileMode // Zero leaves the mode unchanged
+	uid  int         // -1 leaves the owner unchanged
+	gid  int         // -1 leaves the group unchanged
+}
+
+// Name implements DeviceHook
+func (h *permissionsHook) Name() string { return h.name }
+
+// Run implements DeviceHook
+func (h *permissionsHook) Run(ctx context.Context, stage string, device *VideoDevice) error {
+	if h.mode != 0 {
+		if err := privilegedChmod(device.Path, h.mode); err != nil {
+			return err
+		}
+	}
+	if h.uid >= 0 || h.gid >= 0 {
+		if err := privilegedChown(device.Path, h.uid, h.gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatHook sets the output format and optionally writes a black frame
+type formatHook struct {
+	name   string
+	width  uint32
+	height uint32
+	clear  bool
+}
+
+// Name implements DeviceHook
+func (h *formatHook) Name() string { return h.name }
+
+// Run implements DeviceHook
+func (h *formatHook) Run(ctx context.Context, stage string, device *VideoDevice) error {
+	fd, err := openVideoOutput(device.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open device: %w", err)
+	}
+	defer closeVideoOutput(fd)
+
+	sizeImage, err := setOutputFormat(fd, h.width, h.height)
+	if err != nil {
+		return err
+	}
+	if !h.clear {
+		return nil
+	}
+
+	// Black in YUYV: Y=0x10, neutral chroma
+	frame := make([]byte, max(sizeImage, h.width*h.height*2))
+	for i := 0; i < len(frame); i += 2 {
+		frame[i] = 0x10
+		frame[i+1] = 0x80
+	}
+	if err := writeVideoFrame(fd, frame[:sizeImage]); err != nil {
+		return fmt.Errorf("failed to write black frame: %w", err)
+	}
+	return nil
+}
+
+// execHook runs an operator-provided command
+type execHook struct {
+	name    string
+	command []string
+}
+
+// Name implements DeviceHook
+func (h *execHook) Name() string { return h.name }
+
+// Run implements DeviceHook
+func (h *execHook) Run(ctx context.Context, stage string, device *VideoDevice) error {
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"DEVICE_ID="+device.ID,
+		"DEVICE_PATH="+device.Path,
+		"HOOK_STAGE="+stage)
+
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	auditLog.Record(AuditOpExec, h.command[0], append(h.command[1:len(h.command):len(h.command)], "device="+device.ID, "stage="+stage), start, out, err)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// runDeviceHooks runs the hooks of a stage; fallback placeholders are never prepared
+func (p *VideoDevicePlugin) runDeviceHooks(ctx context.Context, stage string, device *VideoDevice) error {
+	if p.hooks == nil || p.v4l2Manager.IsFallbackMode() || device.Fallback {
+		return nil
+	}
+	return p.hooks.Run(ctx, stage, device)
+}
+
+// prepareDevices runs the create hooks on the devices discovered at startup
+func (p *VideoDevicePlugin) prepareDevices() {
+	for _, device := range p.v4l2Manager.ListAllDevices() {
+		if err := p.runDeviceHooks(context.Background(), HookStageCreate, device); err != nil {
+			p.logger.Error("Failed to prepare device", "device_id", device.ID, "error", err)
+		}
+	}
+}
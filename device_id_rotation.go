@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// deviceIDRotationSeparator joins a video device ID and its rotation in the ID advertised to
+// kubelet (video12 becomes video12-r1 after its first rotation)
+const deviceIDRotationSeparator = "-r"
+
+// DeviceIDRotation tracks how often the kubelet-facing ID of each video device was rotated
+// Rotating a recovered device gives kubelet a device object it has never seen, so allocations
+// computed against the pre-recovery list fail validation instead of landing on the new node
+type DeviceIDRotation struct {
+	mu       sync.Mutex
+	epochs   map[string]int // device ID -> rotation count, absent when never rotated
+	onChange func()         // Called after every mutation
+}
+
+// NewDeviceIDRotation creates a tracker where every device is advertised under its plain ID
+func NewDeviceIDRotation() *DeviceIDRotation {
+	return &DeviceIDRotation{epochs: make(map[string]int)}
+}
+
+// splitKubeletDeviceID returns the video device ID and rotation encoded in a kubelet device ID
+// IDs without a rotation suffix, av-bundle IDs included, are returned unchanged with rotation 0
+func splitKubeletDeviceID(kubeletID string) (string, int) {
+	i := strings.LastIndex(kubeletID, deviceIDRotationSeparator)
+	if i <= 0 {
+		return kubeletID, 0
+	}
+	epoch, err := strconv.Atoi(kubeletID[i+len(deviceIDRotationSeparator):])
+	if err != nil || epoch < 1 {
+		return kubeletID, 0
+	}
+	return kubeletID[:i], epoch
+}
+
+// KubeletID returns the ID a video device is currently advertised under
+func (r *DeviceIDRotation) KubeletID(deviceID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if epoch := r.epochs[deviceID]; epoch > 0 {
+		return deviceID + deviceIDRotationSeparator + strconv.Itoa(epoch)
+	}
+	return deviceID
+}
+
+// Current reports whether a kubelet device ID names the device's current rotation
+func (r *DeviceIDRotation) Current(kubeletID string) bool {
+	deviceID, epoch := splitKubeletDeviceID(kubeletID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.epochs[deviceID] == epoch
+}
+
+// rotate advances the rotation of each device
+func (r *DeviceIDRotation) rotate(deviceIDs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, deviceID := range deviceIDs {
+		r.epochs[deviceID]++
+	}
+	if r.onChange != nil {
+		r.onChange()
+	}
+}
+
+// SetChangeHook registers fn to be called after every mutation
+func (r *DeviceIDRotation) SetChangeHook(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = fn
+}
+
+// Snapshot returns a copy of the rotation counts
+func (r *DeviceIDRotation) Snapshot() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := make(map[string]int, len(r.epochs))
+	for id, epoch := range r.epochs {
+		snapshot[id] = epoch
+	}
+	return snapshot
+}
+
+// Restore takes over the rotation counts of a previous instance
+// Pods still hold devices under rotated IDs, so reverting to plain IDs would let kubelet
+// hand the same device out twice
+func (r *DeviceIDRotation) Restore(saved map[string]int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, epoch := range saved {
+		if epoch > 0 {
+			r.epochs[id] = epoch
+		}
+	}
+	return len(saved)
+}
+
+// RotateDeviceIDs advertises recovered devices under new IDs and pushes the list to kubelet
+// Devices still held by an allocation keep their ID: kubelet tracks the holder by that ID and
+// would consider the renamed device free. It returns the rotated device IDs
+func (p *VideoDevicePlugin) RotateDeviceIDs(reason string, deviceIDs []string) []string {
+	if !p.config.EnableDeviceIDRotation {
+		return nil
+	}
+
+	var rotated []string
+	for _, deviceID := range deviceIDs {
+		if p.allocations.CorrelationID(deviceID) != "" {
+			p.logger.Info("Keeping device ID of allocated device", "device_id", deviceID, "reason", reason)
+			continue
+		}
+		rotated = append(rotated, deviceID)
+	}
+	if len(rotated) == 0 {
+		return nil
+	}
+	sort.Strings(rotated)
+
+	p.rotation.rotate(rotated)
+	// Cached responses refer to the pre-recovery device objects
+	p.replays.Clear()
+
+	kubeletIDs := make([]string, 0, len(rotated))
+	for _, deviceID := range rotated {
+		kubeletIDs = append(kubeletIDs, p.rotation.KubeletID(deviceID))
+	}
+	p.logger.Info("Rotated device IDs", "reason", reason, "devices", rotated, "kubelet_ids", kubeletIDs)
+	p.decisions.Publish(DecisionEvent{
+		Kind:    DecisionReconcile,
+		Action:  "device_ids_rotated",
+		Message: reason,
+		Fields:  map[string]string{"kubelet_ids": strings.Join(kubeletIDs, ",")},
+	})
+	p.requestListAndWatchRefresh()
+	return rotated
+}
+
+// kubeletDeviceIDs maps video device IDs to the IDs kubelet knows them by
+func (p *VideoDevicePlugin) kubeletDeviceIDs(deviceIDs []string) []string {
+	ids := make([]string, 0, len(deviceIDs))
+	for _, deviceID := range deviceIDs {
+		ids = append(ids, p.rotation.KubeletID(deviceID))
+	}
+	return ids
+}
+
+// videoDeviceIDs maps kubelet device IDs of any rotation back to video device IDs
+func videoDeviceIDs(kubeletIDs []string) []string {
+	ids := make([]string, 0, len(kubeletIDs))
+	for _, kubeletID := range kubeletIDs {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		ids = append(ids, deviceID)
+	}
+	return ids
+}
+
+// deviceIDsByVideoID indexes kubelet device IDs by the video device they name
+func deviceIDsByVideoID(kubeletIDs []string) map[string]string {
+	index := make(map[string]string, len(kubeletIDs))
+	for _, kubeletID := range kubeletIDs {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		index[deviceID] = kubeletID
+	}
+	return index
+}
+
+// staleDeviceIDError is the Allocate rejection of an ID from before the device's last rotation
+func (p *VideoDevicePlugin) staleDeviceIDError(kubeletID string) error {
+	deviceID, epoch := splitKubeletDeviceID(kubeletID)
+	current := p.rotation.KubeletID(deviceID)
+	return allocateError(codes.FailedPrecondition, AllocateReasonDeviceIDRotated,
+		fmt.Sprintf("device ID %s was retired by recovery, the device is now advertised as %s", kubeletID, current),
+		map[string]string{"device_id": deviceID, "kubelet_id": kubeletID, "rotation": strconv.Itoa(epoch), "current_id": current})
+}
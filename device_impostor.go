@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// videoDeviceMajor is the character device major of every video4linux node
+const videoDeviceMajor = 81
+
+// loopbackDriverName is the driver VIDIOC_QUERYCAP reports for v4l2loopback devices
+const loopbackDriverName = "v4l2 loopback"
+
+// impostorReason returns why the node at devicePath is not a genuine v4l2loopback device, or ""
+// A node must be a character device (not a symlink or regular file) with the video4linux major,
+// backed by a virtual video4linux sysfs entry with the same device numbers
+func impostorReason(devicePath string) string {
+	info, err := os.Lstat(devicePath)
+	if err != nil {
+		return ""
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, _ := os.Readlink(devicePath)
+		return fmt.Sprintf("is a symlink to %q", target)
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Sprintf("is not a character device (mode %s)", info.Mode())
+	}
+
+	major, minor, ok := fileDeviceNumbers(info)
+	if !ok {
+		// Device numbers are unavailable on this platform
+		return ""
+	}
+	if major != videoDeviceMajor {
+		return fmt.Sprintf("has major %d instead of the video4linux major %d", major, videoDeviceMajor)
+	}
+
+	sysfsPath := filepath.Join("/sys/class/video4linux", filepath.Base(devicePath))
+	registered, err := os.ReadFile(filepath.Join(sysfsPath, "dev"))
+	if err != nil {
+		return "has no video4linux sysfs entry"
+	}
+	if got := fmt.Sprintf("%d:%d", major, minor); got != strings.TrimSpace(string(registered)) {
+		return fmt.Sprintf("is %s but the kernel registered %s", got, strings.TrimSpace(string(registered)))
+	}
+	if target, err := filepath.EvalSymlinks(sysfsPath); err == nil && !strings.Contains(target, "/devices/virtual/video4linux/") {
+		return "belongs to a hardware video driver, not v4l2loopback"
+	}
+	return ""
+}
+
+// impostorDriverReason checks the driver a genuine-looking node reports (discovery only)
+func impostorDriverReason(device *VideoDevice) string {
+	if device.Driver != "" && device.Driver != loopbackDriverName {
+		return fmt.Sprintf("reports driver %q instead of %q", device.Driver, loopbackDriverName)
+	}
+	return ""
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DeviceLabels is plugin-side metadata attached to an advertised device
+// Kubelet's device list carries no labels, so these live in an internal registry and are
+// only consumed by GetPreferredAllocation and the admin API
+type DeviceLabels struct {
+	FormatProfile string    `json:"format_profile"`           // v4l2loopback parameters the device was created with
+	Generation    int       `json:"generation"`               // Device generation, see VideoDevice.Generation
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"` // Freshly (re)created devices settle until this time
+}
+
+// InCooldown reports whether the device is still settling after being (re)created
+func (l DeviceLabels) InCooldown(now time.Time) bool {
+	return now.Before(l.CooldownUntil)
+}
+
+// groupKey identifies devices that behave identically for a consumer
+func (l DeviceLabels) groupKey() string {
+	return fmt.Sprintf("%s@%d", l.FormatProfile, l.Generation)
+}
+
+// deviceFormatProfile describes the v4l2loopback parameters a device was created with
+func deviceFormatProfile(maxBuffers, exclusiveCaps int) string {
+	return fmt.Sprintf("max_buffers=%d,exclusive_caps=%d", maxBuffers, exclusiveCaps)
+}
+
+// DeviceLabelRegistry tracks labels of advertised devices
+type DeviceLabelRegistry struct {
+	mu            sync.RWMutex
+	labels        map[string]DeviceLabels // device ID -> labels
+	maxBuffers    int                     // Configured V4L2_MAX_BUFFERS
+	exclusiveCaps int
+	cooldown      time.Duration
+	onChange      func() // Called after every mutation
+}
+
+// NewDeviceLabelRegistry creates a registry for devices created with the configured parameters
+func NewDeviceLabelRegistry(config *DevicePluginConfig) *DeviceLabelRegistry {
+	return &DeviceLabelRegistry{
+		labels:        make(map[string]DeviceLabels),
+		maxBuffers:    config.V4L2MaxBuffers,
+		exclusiveCaps: config.V4L2ExclusiveCaps,
+		cooldown:      time.Duration(config.DeviceCooldown) * time.Second,
+	}
+}
+
+// Observe records the current state of a device; a new generation starts a cooldown
+// Devices seen for the first time are not put in cooldown so startup inventory is usable at once
+func (r *DeviceLabelRegistry) Observe(device *VideoDevice) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	labels, known := r.labels[device.ID]
+	if known && labels.Generation == device.Generation {
+		return
+	}
+
+	maxBuffers := r.maxBuffers
+	if device.MaxBuffers > 0 {
+		maxBuffers = device.MaxBuffers
+	}
+	labels.FormatProfile = deviceFormatProfile(maxBuffers, r.exclusiveCaps)
+	labels.Generation = device.Generation
+	if known {
+		labels.CooldownUntil = time.Now().Add(r.cooldown)
+	}
+	r.labels[device.ID] = labels
+	if r.onChange != nil {
+		r.onChange()
+	}
+}
+
+// SetChangeHook registers fn to be called after every mutation
+func (r *DeviceLabelRegistry) SetChangeHook(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = fn
+}
+
+// Snapshot returns a copy of all labels
+func (r *DeviceLabelRegistry) Snapshot() map[string]DeviceLabels {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make(map[string]DeviceLabels, len(r.labels))
+	for id, labels := range r.labels {
+		snapshot[id] = labels
+	}
+	return snapshot
+}
+
+// Restore re-adds labels saved by a previous instance so cooldowns survive restarts
+func (r *DeviceLabelRegistry) Restore(saved map[string]DeviceLabels) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, labels := range saved {
+		r.labels[id] = labels
+	}
+	return len(saved)
+}
+
+// Get returns the labels of a device
+func (r *DeviceLabelRegistry) Get(deviceID string) (DeviceLabels, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	labels, exists := r.labels[deviceID]
+	return labels, exists
+}
+
+// PreferredDevices picks size devices from available, always including mustInclude
+// Devices sharing the format profile and generation of the must-include set (or of the
+// largest matching group) are preferred so a pod's cameras behave identically, and devices
+// in cooldown are used only when nothing else is available
+func (r *DeviceLabelRegistry) PreferredDevices(available, mustInclude []string, size int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	chosen := append([]string(nil), mustInclude...)
+	if len(chosen) >= size {
+		return chosen[:size]
+	}
+
+	included := make(map[string]bool, len(mustInclude))
+	for _, id := range mustInclude {
+		included[id] = true
+	}
+	var candidates []string
+	for _, id := range available {
+		if !included[id] {
+			candidates = append(candidates, id)
+		}
+	}
+
+	anchor := r.anchorGroupLocked(candidates, mustInclude, size-len(chosen), now)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		li, lj := r.labels[candidates[i]], r.labels[candidates[j]]
+		if mi, mj := li.groupKey() == anchor, lj.groupKey() == anchor; mi != mj {
+			return mi
+		}
+		if ci, cj := li.InCooldown(now), lj.InCooldown(now); ci != cj {
+			return !ci
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	for _, id := range candidates {
+		if len(chosen) == size {
+			break
+		}
+		chosen = append(chosen, id)
+	}
+	return chosen
+}
+
+// anchorGroupLocked returns the label group new devices should match; caller must hold r.mu
+func (r *DeviceLabelRegistry) anchorGroupLocked(candidates, mustInclude []string, needed int, now time.Time) string {
+	if len(mustInclude) > 0 {
+		return r.labels[mustInclude[0]].groupKey()
+	}
+
+	type group struct {
+		total int
+		ready int
+	}
+	groups := make(map[string]*group)
+	for _, id := range candidates {
+		labels := r.labels[id]
+		g := groups[labels.groupKey()]
+		if g == nil {
+			g = &group{}
+			groups[labels.groupKey()] = g
+		}
+		g.total++
+		if !labels.InCooldown(now) {
+			g.ready++
+		}
+	}
+
+	// Prefer a group that can satisfy the request, then the one with most settled devices
+	best, bestGroup := "", (*group)(nil)
+	for key, g := range groups {
+		if bestGroup == nil {
+			best, bestGroup = key, g
+			continue
+		}
+		fits, bestFits := g.total >= needed, bestGroup.total >= needed
+		switch {
+		case fits != bestFits:
+			if fits {
+				best, bestGroup = key, g
+			}
+		case g.ready != bestGroup.ready:
+			if g.ready > bestGroup.ready {
+				best, bestGroup = key, g
+			}
+		case key < best:
+			best, bestGroup = key, g
+		}
+	}
+	return best
+}
+
+// GetPreferredAllocation implements the GetPreferredAllocation gRPC method
+func (p *VideoDevicePlugin) GetPreferredAllocation(ctx context.Context, req *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	response := &pluginapi.PreferredAllocationResponse{}
+	wanted := p.preferredDeviceRequests(ctx)
+	for _, containerReq := range req.ContainerRequests {
+		// Preferences are computed on video device IDs and answered with kubelet's IDs
+		kubeletIDs := deviceIDsByVideoID(append(append([]string{}, containerReq.AvailableDeviceIDs...), containerReq.MustIncludeDeviceIDs...))
+		available := videoDeviceIDs(containerReq.AvailableDeviceIDs)
+		mustInclude := videoDeviceIDs(containerReq.MustIncludeDeviceIDs)
+		if len(wanted) > 0 {
+			mustInclude, wanted = applyDeviceAffinity(wanted, available, mustInclude, int(containerReq.AllocationSize))
+			if len(mustInclude) > len(containerReq.MustIncludeDeviceIDs) {
+				p.logger.Info("Preferring device requested by pod annotation", "device_id", mustInclude[len(mustInclude)-1])
+			}
+		}
+		var deviceIDs []string
+		for _, deviceID := range p.labels.PreferredDevices(available, mustInclude, int(containerReq.AllocationSize)) {
+			deviceIDs = append(deviceIDs, kubeletIDs[deviceID])
+		}
+		response.ContainerResponses = append(response.ContainerResponses, &pluginapi.ContainerPreferredAllocationResponse{
+			DeviceIDs: deviceIDs,
+		})
+	}
+	return response, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// populateDeviceMetadata fills driver, card label, sysfs path and device numbers (best effort)
+// Fields that cannot be read are left empty; a failed read never makes a device unusable
+func populateDeviceMetadata(device *VideoDevice) {
+	name := filepath.Base(device.Path)
+
+	if sysfsPath, err := filepath.EvalSymlinks(filepath.Join("/sys/class/video4linux", name)); err == nil {
+		device.SysfsPath = sysfsPath
+		if label, err := os.ReadFile(filepath.Join(sysfsPath, "name")); err == nil {
+			device.CardLabel = strings.TrimSpace(string(label))
+		}
+	}
+
+	if stat, err := os.Stat(device.Path); err == nil {
+		if major, minor, ok := fileDeviceNumbers(stat); ok {
+			device.Major = major
+			device.Minor = minor
+		}
+	}
+
+	if driver, card, err := queryCapability(device.Path); err == nil {
+		device.Driver = driver
+		if device.CardLabel == "" {
+			device.CardLabel = card
+		}
+	}
+}
+
+// deviceNumbers formats major:minor for logging
+func deviceNumbers(device *VideoDevice) string {
+	return fmt.Sprintf("%d:%d", device.Major, device.Minor)
+}
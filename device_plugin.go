@@ -5,15 +5,21 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	"k8s.io/utils/clock"
 )
 
 // VideoDevicePlugin implements the Kubernetes device plugin gRPC server
@@ -21,29 +27,145 @@ type VideoDevicePlugin struct {
 	pluginapi.UnimplementedDevicePluginServer
 	config      *DevicePluginConfig
 	v4l2Manager V4L2Manager
+	k8sClient   *K8sClient
+	warmup      *WarmupProducer
+	allocations *AllocationTracker
+	replays     *AllocationReplayCache
+	limiter     *AllocateLimiter      // Bounds concurrent Allocate processing (MAX_CONCURRENT_ALLOCATIONS)
+	reserved    map[string]bool       // Video device IDs advertised through the av-bundle resource
+	excluded    map[string]bool       // Video device IDs created but never advertised (EXCLUDED_DEVICES)
+	tiers       map[string]DeviceTier // Video device IDs advertised through a device tier resource
+	labels      *DeviceLabelRegistry
+	spares      *HotSparePool         // Video devices held back from kubelet
+	rotation    *DeviceIDRotation     // Rotation suffixes of the device IDs advertised to kubelet
+	health      *HealthHistory        // Per-device health transitions and flap damping
+	decisions   *DecisionStream       // Structured decisions streamed to admin watch clients
+	sent        *ListAndWatchRecorder // Last device list sent to kubelet per resource
+	preparation *DevicePreparation    // Holds devices Unhealthy until permissions and formats are applied
+	checkpoint  *Checkpointer         // Nil when checkpointing is disabled
+	hooks       *DeviceHookRunner     // Nil when no device hooks are configured
+	liveness    *LivenessLease        // Nil unless ENABLE_LIVENESS_LEASE is set
+	settings    *RuntimeSettings
+	metrics     *Metrics
+	clock       clock.WithTicker // Time source of the registration, ListAndWatch and monitor loops
 	logger      *slog.Logger
 	server      *grpc.Server
 	listener    net.Listener
-	stopCh      chan struct{}
+	watcher     *PluginWatcherServer // Plugin watcher registration (watcher/both registration modes)
+	endpoints   []*kubeletEndpoint   // Additional kubelets (ADDITIONAL_KUBELET_SOCKETS)
+	takeover    *TakeoverServer      // Hands the socket to the next instance (ENABLE_SOCKET_TAKEOVER)
+	ctx         context.Context      // Lifetime of the plugin loops, derived from the context passed to Start
+	cancel      context.CancelFunc   // Ends ctx; called by Stop and after a socket takeover
+	refreshCh   chan struct{}        // Wakes ListAndWatch for an immediate send
 	mu          sync.RWMutex
 	registered  bool
+
+	// Set once the re-registration policy gives up; terminal until restart
+	registrationGaveUp bool
+
+	// Device paths created through v4l2loopback-ctl add during this run
+	ctlMu    sync.Mutex
+	ctlAdded map[string]bool
+
+	// Serializes runtime pool resizes
+	resizeMu sync.Mutex
+
+	// Card labels stamped for the allocations holding devices (ENABLE_LABEL_STAMPING)
+	stampMu sync.Mutex
+	stamps  map[string]labelStamp
+
+	// Callers of syncDeviceList waiting for the next ListAndWatch send
+	sendWaiters []chan struct{}
+
+	// Last node condition state reported to the API server
+	conditionReported bool
+	conditionReady    bool
+
+	// Socket takeover by the next instance: in progress, and completed
+	takingOver atomic.Bool
+	takenOver  atomic.Bool
+
+	// Stops of the components outside the plugin, run when a new instance takes over
+	takeoverHooks []func()
+
+	// Shadow instance (MODE=shadow): advertises under SHADOW_RESOURCE_NAME and never touches devices
+	shadow bool
+
+	// Module change waiting for a reload until no pod holds a device, guarded by mu
+	pendingModuleChange string
 }
 
 // NewVideoDevicePlugin creates a new VideoDevicePlugin instance
-func NewVideoDevicePlugin(config *DevicePluginConfig, v4l2Manager V4L2Manager, logger *slog.Logger) *VideoDevicePlugin {
+// k8sClient and metrics may be nil when the corresponding integration is disabled
+func NewVideoDevicePlugin(config *DevicePluginConfig, v4l2Manager V4L2Manager, k8sClient *K8sClient, metrics *Metrics, logger *slog.Logger) *VideoDevicePlugin {
 	plugin := &VideoDevicePlugin{
 		config:      config,
 		v4l2Manager: v4l2Manager,
+		k8sClient:   k8sClient,
+		metrics:     metrics,
+		allocations: NewAllocationTracker(),
+		replays:     NewAllocationReplayCache(time.Duration(config.AllocationReplayWindow) * time.Second),
+		limiter:     NewAllocateLimiter(config.MaxConcurrentAllocations, metrics),
+		reserved:    avBundleVideoIDs(config),
+		tiers:       tierVideoIDs(config),
+		excluded:    excludedVideoIDs(config),
+		labels:      NewDeviceLabelRegistry(config),
+		spares:      NewHotSparePool(config),
+		rotation:    NewDeviceIDRotation(),
+		settings:    NewRuntimeSettings(config),
+		health:      NewHealthHistory(config),
+		decisions:   NewDecisionStream(),
+		sent:        NewListAndWatchRecorder(config, metrics),
+		preparation: NewDevicePreparation(),
+		ctlAdded:    make(map[string]bool),
+		clock:       clock.RealClock{},
+		stamps:      make(map[string]labelStamp),
 		logger:      logger,
-		stopCh:      make(chan struct{}),
+		refreshCh:   make(chan struct{}, 1),
+		endpoints:   newKubeletEndpoints(config),
 		registered:  false,
 	}
 
+	// Replaced by Start; a plugin that is never started (soak harness) ends with Stop
+	plugin.ctx, plugin.cancel = context.WithCancel(context.Background())
+	plugin.health.SetHooks(plugin.onHealthTransition, plugin.onHealthDamping)
+	v4l2Manager.SetHealthHistory(plugin.health)
+	metrics.WatchListAndWatchSends(plugin.sent)
+
+	// The producer hand-over is detected through /proc, which only shows pods with hostPID
+	if config.EnableWarmupProducer {
+		if hostPIDNamespace() {
+			plugin.warmup = NewWarmupProducer(config, logger)
+		} else {
+			logger.Warn("Warm-up producer disabled: it cannot see the pod's producer without hostPID")
+		}
+	}
+
+	if config.EnableCheckpoint && config.StateDir != "" {
+		plugin.checkpoint = NewCheckpointer(config.StateDir, plugin.checkpointSnapshot, logger)
+		v4l2Manager.SetChangeHook(plugin.checkpoint.MarkDirty)
+		plugin.allocations.SetChangeHook(plugin.checkpoint.MarkDirty)
+		plugin.labels.SetChangeHook(plugin.checkpoint.MarkDirty)
+		plugin.spares.SetChangeHook(plugin.checkpoint.MarkDirty)
+		plugin.rotation.SetChangeHook(plugin.checkpoint.MarkDirty)
+	}
+
 	return plugin
 }
 
+// SetClock replaces the time source of the plugin loops, letting tests advance time
+// deterministically (e.g. with k8s.io/utils/clock/testing.FakeClock); call it before Start
+func (p *VideoDevicePlugin) SetClock(c clock.WithTicker) {
+	p.clock = c
+	p.checkpoint.SetClock(c)
+	p.health.SetClock(c)
+}
+
 // Start starts the device plugin server
-func (p *VideoDevicePlugin) Start() error {
+// ctx bounds startup (socket takeover, registration) and the lifetime of the plugin loops:
+// canceling it stops the monitors and ListAndWatch streams, Stop tears down the server
+func (p *VideoDevicePlugin) Start(ctx context.Context) error {
+	p.ctx, p.cancel = context.WithCancel(ctx)
 	p.logger.Info("Starting video device plugin",
 		"resource_name", p.config.ResourceName,
 		"socket_path", p.config.SocketPath)
@@ -53,44 +175,62 @@ func (p *VideoDevicePlugin) Start() error {
 		return fmt.Errorf("failed to create socket directory: %w", err)
 	}
 
-	// Clean up any existing socket
-	if err := cleanupSocket(p.config.SocketPath); err != nil {
-		p.logger.Warn("Failed to cleanup existing socket", "error", err)
+	// Ask a running instance to hand over its socket and bookkeeping
+	var pending *pendingTakeover
+	if p.config.EnableSocketTakeover {
+		var err error
+		if pending, err = requestTakeover(ctx, p.config, p.logger); err != nil {
+			p.logger.Warn("Socket takeover failed, waiting for the previous instance to exit", "error", err)
+		}
 	}
 
 	// Create gRPC server
-	p.server = grpc.NewServer()
+	p.server = grpc.NewServer(grpcServerOptions(p.config, p.settings, p.logger)...)
 	pluginapi.RegisterDevicePluginServer(p.server, p)
 
-	// Start gRPC server
-	listener, err := net.Listen("unix", p.config.SocketPath)
-	if err != nil {
-		return fmt.Errorf("failed to listen on socket: %w", err)
+	if pending != nil {
+		p.listener = pending.listener
+		if pending.state.Checkpoint != nil && pending.state.Checkpoint.Version == checkpointVersion {
+			p.applyCheckpoint(pending.state.Checkpoint, "previous instance")
+		}
+		p.logger.Info("Took over the plugin socket of the running instance",
+			"previous_plugin_version", pending.state.PluginVersion)
+	} else {
+		// Take over any existing socket once its previous owner is confirmed dead
+		if err := p.takeOverSocket(ctx); err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("unix", p.config.SocketPath)
+		if err != nil {
+			return fmt.Errorf("failed to listen on socket: %w", err)
+		}
+		p.listener = listener
 	}
-	p.listener = listener
 
 	// Start server in goroutine
 	serverReady := make(chan struct{})
+	listener := p.listener
 	go func() {
 		p.logger.Info("Starting gRPC server", "socket", p.config.SocketPath)
 		// Signal that server is ready to accept connections
 		close(serverReady)
-		if err := p.server.Serve(listener); err != nil {
-			p.logger.Error("gRPC server failed", "error", err)
-		}
+		p.serve(listener)
 	}()
 
 	// Wait for server to be ready
 	select {
 	case <-serverReady:
 		// Server started successfully
-	case <-time.After(5 * time.Second):
+	case <-p.clock.After(5 * time.Second):
 		return fmt.Errorf("gRPC server failed to start within timeout")
 	}
 
-	// Register with kubelet
-	if err := p.RegisterWithKubelet(); err != nil {
-		// Cleanup to avoid leaving a dangling socket
+	// Cleanup to avoid leaving dangling sockets when registration cannot proceed
+	abort := func() {
+		if p.watcher != nil {
+			p.watcher.Stop()
+		}
 		if p.server != nil {
 			p.server.Stop()
 		}
@@ -98,21 +238,163 @@ func (p *VideoDevicePlugin) Start() error {
 			_ = p.listener.Close()
 			p.listener = nil
 		}
+		// A taken-over socket is still served by the previous instance
+		if pending != nil {
+			pending.confirm(fmt.Errorf("new instance failed to register"))
+			return
+		}
 		_ = cleanupSocket(p.config.SocketPath)
-		return fmt.Errorf("failed to register with kubelet: %w", err)
 	}
 
-	// Start kubelet restart monitoring
-	go p.monitorKubeletRestart()
+	// Publish the plugin watcher socket; kubelet registers us when it discovers it
+	if usesWatcherRegistration(p.config) {
+		p.watcher = NewPluginWatcherServer(p.config.PluginRegistryDir, p.config.ResourceName, p.config.SocketPath, p.setWatcherRegistration, p.logger)
+		if err := p.watcher.Start(); err != nil {
+			abort()
+			return fmt.Errorf("failed to start plugin watcher registration: %w", err)
+		}
+	}
+
+	// Register with kubelet
+	if usesDirectRegistration(p.config) {
+		if err := p.RegisterWithKubelet(ctx); err != nil {
+			if !usesWatcherRegistration(p.config) {
+				abort()
+				return fmt.Errorf("failed to register with kubelet: %w", err)
+			}
+			p.logger.Warn("Direct kubelet registration failed, relying on the plugin watcher", "error", err)
+		}
+
+		// Start kubelet restart monitoring; the plugin watcher re-discovers the socket on its own
+		go p.monitorKubeletRestart()
+	}
+
+	// Nested kubelets get the main resource through their own plugin sockets
+	p.startKubeletEndpoints()
+
+	// Kubelet now talks to this instance; let the previous one exit
+	if pending != nil {
+		pending.confirm(nil)
+	}
+
+	// Offer the socket to the next instance
+	if p.config.EnableSocketTakeover {
+		p.takeover = NewTakeoverServer(p, p.logger)
+		if err := p.takeover.Start(); err != nil {
+			p.logger.Warn("Failed to start the socket takeover endpoint", "error", err)
+			p.takeover = nil
+		}
+	}
+
+	// Start readiness monitoring for the node condition
+	go p.monitorReadiness()
+
+	// Start permission drift reconciliation
+	go p.monitorPermissions()
+
+	// Start buffer exhaustion detection and recovery
+	go p.monitorBufferExhaustion()
+
+	// Start hot spare promotion and repair
+	go p.monitorHotSpares()
+
+	// Start comparing kubelet's device view with the node
+	go p.monitorConformance()
+
+	// Start exporting the devices each namespace holds
+	go p.monitorNamespaceQuotas()
+
+	// Start compacting the idle pool in its maintenance window
+	go p.monitorPoolDefragmentation()
 
 	p.logger.Info("Video device plugin started successfully")
 	return nil
 }
 
+// serve runs the gRPC server on a listener; closing the listener for a takeover is not a failure
+func (p *VideoDevicePlugin) serve(listener net.Listener) {
+	if err := p.server.Serve(listener); err != nil && !p.takingOver.Load() {
+		p.logger.Error("gRPC server failed", "error", err)
+	}
+}
+
+// takeOverSocket removes an existing plugin socket only after probing that no live
+// instance is serving it, waiting with backoff while a previous instance (e.g., during
+// a rolling update) is still alive
+func (p *VideoDevicePlugin) takeOverSocket(ctx context.Context) error {
+	return waitForStaleSocket(ctx, p.clock, p.config.SocketPath, time.Duration(p.config.SocketTakeoverTimeout)*time.Second, p.logger)
+}
+
+// waitForStaleSocket removes an existing socket once no live instance answers on it, probing with
+// backoff for at most timeout; the main, av-bundle and tier plugins all start through it
+func waitForStaleSocket(ctx context.Context, c clock.Clock, socketPath string, timeout time.Duration, logger *slog.Logger) error {
+	if !checkDeviceExists(socketPath) {
+		return nil
+	}
+
+	deadline := c.Now().Add(timeout)
+	backoff := 500 * time.Millisecond
+	for probeSocketAlive(socketPath) {
+		if c.Now().After(deadline) {
+			return fmt.Errorf("socket %s is still served by another instance after %s", socketPath, timeout)
+		}
+
+		logger.Warn("Existing plugin socket is alive, waiting for previous instance to exit",
+			"socket", socketPath,
+			"retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for socket %s: %w", socketPath, context.Cause(ctx))
+		case <-c.After(backoff):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+
+	logger.Info("Existing plugin socket is stale, removing", "socket", socketPath)
+	if err := cleanupSocket(socketPath); err != nil {
+		logger.Warn("Failed to cleanup existing socket", "error", err)
+	}
+	return nil
+}
+
+// probeSocketAlive reports whether a device plugin gRPC server answers on socketPath
+func probeSocketAlive(socketPath string) bool {
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = pluginapi.NewDevicePluginClient(conn).GetDevicePluginOptions(ctx, &pluginapi.Empty{})
+	return err == nil
+}
+
 // Stop stops the device plugin server
 func (p *VideoDevicePlugin) Stop() error {
 	p.logger.Info("Stopping video device plugin")
 
+	if p.takeover != nil {
+		p.takeover.Stop()
+	}
+
+	// The next instance owns the socket, the devices and the bookkeeping now
+	if p.IsTakenOver() {
+		p.logger.Info("Video device plugin stopped after handing over to the new instance")
+		return nil
+	}
+
+	// Withdraw readiness before the devices disappear
+	p.updateNodeCondition(false, "PluginStopped", "Video device plugin is shutting down")
+
+	// Release devices held by placeholder producers
+	if p.warmup != nil {
+		p.warmup.StopAll()
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -125,23 +407,34 @@ func (p *VideoDevicePlugin) Stop() error {
 		p.listener = nil
 	}
 
+	// Withdraw the registration socket before the endpoint it points to disappears
+	if p.watcher != nil {
+		p.watcher.Stop()
+	}
+
 	// Clean up socket
 	if err := cleanupSocket(p.config.SocketPath); err != nil {
 		p.logger.Warn("Failed to cleanup socket", "error", err)
 	}
+	p.stopKubeletEndpoints()
+
+	p.cancel()
 
-	close(p.stopCh)
+	// Persist final bookkeeping for the next instance
+	if p.checkpoint != nil {
+		p.checkpoint.Stop()
+	}
 	p.logger.Info("Video device plugin stopped")
 	return nil
 }
 
-// WaitForShutdown waits for shutdown signal
+// WaitForShutdown waits until the plugin is stopped or its context is canceled
 func (p *VideoDevicePlugin) WaitForShutdown() {
-	<-p.stopCh
+	<-p.ctx.Done()
 }
 
 // RegisterWithKubelet registers the device plugin with kubelet
-func (p *VideoDevicePlugin) RegisterWithKubelet() error {
+func (p *VideoDevicePlugin) RegisterWithKubelet(ctx context.Context) error {
 	p.mu.RLock()
 	already := p.registered
 	p.mu.RUnlock()
@@ -149,18 +442,56 @@ func (p *VideoDevicePlugin) RegisterWithKubelet() error {
 		return nil
 	}
 
+	// Spread registrations across the fleet after a kubelet bounce
+	if delay := jitteredDuration(time.Duration(p.config.RegistrationDelay)*time.Second, time.Duration(p.config.RegistrationJitter)*time.Second); delay > 0 {
+		p.logger.Info("Delaying kubelet registration", "delay", delay.String())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("plugin stopped before registration: %w", context.Cause(ctx))
+		case <-p.clock.After(delay):
+		}
+	}
+
 	p.logger.Info("Registering with kubelet",
 		"resource_name", p.config.ResourceName,
 		"kubelet_socket", p.config.KubeletSocket)
 
+	if err := registerResourceWithKubelet(ctx, p.config.KubeletSocket, p.config.SocketPath, p.config.ResourceName, p.logger); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.registered = true
+	p.mu.Unlock()
+	p.logger.Info("Successfully registered with kubelet")
+	p.decisions.Publish(DecisionEvent{Kind: DecisionRegistration, Action: "registered", Resource: p.config.ResourceName})
+	return nil
+}
+
+// setWatcherRegistration records the registration status reported by the kubelet plugin watcher
+func (p *VideoDevicePlugin) setWatcherRegistration(registered bool, reason string) {
+	p.mu.Lock()
+	p.registered = registered
+	p.mu.Unlock()
+	action := "unregistered"
+	if registered {
+		action = "registered"
+	}
+	p.decisions.Publish(DecisionEvent{Kind: DecisionRegistration, Action: action, Resource: p.config.ResourceName, Message: reason})
+	go p.refreshReadiness()
+}
+
+// registerResourceWithKubelet registers the plugin served on socketPath for resourceName
+// ctx bounds the call together with a 5 second registration timeout
+func registerResourceWithKubelet(ctx context.Context, kubeletSocket, socketPath, resourceName string, logger *slog.Logger) error {
 	// Connect to kubelet socket (Unix domain socket)
-	conn, err := grpc.NewClient("unix://"+p.config.KubeletSocket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient("unix://"+kubeletSocket, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return fmt.Errorf("failed to connect to kubelet: %w", err)
 	}
 	defer func() {
 		if closeErr := conn.Close(); closeErr != nil {
-			p.logger.Warn("Failed to close kubelet connection", "error", closeErr)
+			logger.Warn("Failed to close kubelet connection", "error", closeErr)
 		}
 	}()
 
@@ -170,119 +501,127 @@ func (p *VideoDevicePlugin) RegisterWithKubelet() error {
 	// Create registration request
 	req := &pluginapi.RegisterRequest{
 		Version:      pluginapi.Version,
-		Endpoint:     filepath.Base(p.config.SocketPath),
-		ResourceName: p.config.ResourceName,
+		Endpoint:     filepath.Base(socketPath),
+		ResourceName: resourceName,
 	}
 
 	// Send registration request with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	_, err = client.Register(ctx, req)
-	if err != nil {
+	if _, err := client.Register(ctx, req); err != nil {
 		return fmt.Errorf("failed to register with kubelet: %w", err)
 	}
-
-	p.mu.Lock()
-	p.registered = true
-	p.mu.Unlock()
-	p.logger.Info("Successfully registered with kubelet")
 	return nil
 }
 
 // ListAndWatch implements the ListAndWatch gRPC method
 func (p *VideoDevicePlugin) ListAndWatch(req *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
-	p.logger.Debug("ListAndWatch called")
-
-	// Get all devices (always report all available devices)
-	allDevices := p.v4l2Manager.ListAllDevices()
+	return p.listAndWatch(stream, "", p.refreshCh)
+}
 
-	var devices []*pluginapi.Device
-	healthyCount := 0
-	for _, device := range allDevices {
-		// Check health of each device individually
-		deviceHealthy := p.v4l2Manager.GetDeviceHealth(device.ID)
-		if deviceHealthy {
-			healthyCount++
-		}
+// listAndWatch streams the main resource's device list to one kubelet
+// kubelet is the additional kubelet socket, empty for the primary; refreshCh wakes this stream only
+func (p *VideoDevicePlugin) listAndWatch(stream pluginapi.DevicePlugin_ListAndWatchServer, kubelet string, refreshCh <-chan struct{}) (err error) {
+	logger := p.logger
+	recorder := p.sent
+	takeSendWaiters := p.takeSendWaiters
+	if kubelet != "" {
+		// Snapshots, send metrics and syncDeviceList describe the primary kubelet
+		logger = logger.With("kubelet_socket", kubelet)
+		recorder = nil
+		takeSendWaiters = func() []chan struct{} { return nil }
+	}
+	watch := recorder.OpenStream(p.config.ResourceName, stream, logger.With("resource_name", p.config.ResourceName))
+	defer func() { watch.Close(err) }()
 
-		health := pluginapi.Healthy
-		if !deviceHealthy {
-			health = pluginapi.Unhealthy
+	// Smooth the initial send when many plugins reconnect at once
+	if delay := jitteredDuration(0, time.Duration(p.config.ListAndWatchJitter)*time.Second); delay > 0 {
+		logger.Debug("Delaying initial ListAndWatch send", "delay", delay.String())
+		select {
+		case <-p.ctx.Done():
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-p.clock.After(delay):
 		}
+	}
 
-		devices = append(devices, &pluginapi.Device{
-			ID:     device.ID,
-			Health: health,
-		})
+	// Never advertise devices before their permissions were applied; past the timeout the
+	// list goes out with every device Unhealthy and is corrected once preparation finishes
+	prepareTimeout := time.Duration(p.config.DevicePrepareTimeout) * time.Second
+	select {
+	case <-p.preparation.Done():
+	case <-p.ctx.Done():
+		return nil
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	case <-p.clock.After(prepareTimeout):
+		logger.Warn("Devices not prepared in time, advertising them Unhealthy until they are",
+			"timeout", prepareTimeout.String())
 	}
 
+	// Get all devices (always report all available devices)
+	waiters := takeSendWaiters()
+	devices, healthyCount := p.kubeletDeviceList(p.config.ResourceName, kubelet)
+
 	// Log device status with fallback mode information
 	if p.v4l2Manager.IsFallbackMode() {
-		p.logger.Warn("Found video devices (FALLBACK MODE)",
+		logger.Warn("Found video devices (FALLBACK MODE)",
 			"device_count", len(devices),
 			"healthy_count", healthyCount,
 			"unhealthy_count", len(devices)-healthyCount,
 			"fallback_reason", p.v4l2Manager.GetFallbackReason(),
 			"note", "Devices are dummy paths - applications should handle gracefully")
 	} else {
-		p.logger.Info("Found video devices",
+		logger.Info("Found video devices",
 			"device_count", len(devices),
 			"healthy_count", healthyCount,
-			"unhealthy_count", len(devices)-healthyCount)
+			"unhealthy_count", len(devices)-healthyCount,
+			"skipped_devices", p.v4l2Manager.GetSkippedDevices())
 	}
 
 	// Send initial device list
 	response := &pluginapi.ListAndWatchResponse{
 		Devices: devices,
 	}
-	if err := stream.Send(response); err != nil {
+	if err := watch.Send(response); err != nil {
 		return err
 	}
+	releaseSendWaiters(waiters)
+	p.liveness.Beat()
 
-	// Simple health monitoring loop (like GPU plugin)
-	ticker := time.NewTicker(time.Duration(p.config.HealthCheckInterval) * time.Second)
-	defer ticker.Stop()
+	// Simple health monitoring loop (like GPU plugin), with jittered ticks
+	// The interval is re-read on every tick so dynamic setting changes apply without a reconnect
+	timer := p.clock.NewTimer(jitteredInterval(p.settings.HealthCheckInterval(), p.config.HealthCheckJitterPercent))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-p.stopCh:
-			p.logger.Debug("ListAndWatch stopping")
+		case <-p.ctx.Done():
 			return nil
-		case <-ticker.C:
+		case <-refreshCh:
+			// Rejected allocations and device invalidation ask for an immediate send
+			// Fire the timer now; Reset discards any stale tick (Go 1.23+ timer semantics)
+			logger.Info("Refreshing device list on request")
+			timer.Reset(0)
+		case <-timer.C():
+			timer.Reset(jitteredInterval(p.settings.HealthCheckInterval(), p.config.HealthCheckJitterPercent))
+
 			// Periodic health check
 
 			// Send updated device list with per-device health status
-			allDevices := p.v4l2Manager.ListAllDevices()
-
-			var devices []*pluginapi.Device
-			healthyCount := 0
-			for _, device := range allDevices {
-				// Check health of each device individually
-				deviceHealthy := p.v4l2Manager.GetDeviceHealth(device.ID)
-				if deviceHealthy {
-					healthyCount++
-				}
-
-				health := pluginapi.Healthy
-				if !deviceHealthy {
-					health = pluginapi.Unhealthy
-				}
-
-				devices = append(devices, &pluginapi.Device{
-					ID:     device.ID,
-					Health: health,
-				})
-			}
+			waiters := takeSendWaiters()
+			devices, healthyCount := p.kubeletDeviceList(p.config.ResourceName, kubelet)
 
 			// Log health check with fallback mode information
 			if p.v4l2Manager.IsFallbackMode() {
-				p.logger.Debug("Health check completed (FALLBACK MODE)",
+				logger.Debug("Health check completed (FALLBACK MODE)",
 					"device_count", len(devices),
 					"healthy_count", healthyCount,
 					"unhealthy_count", len(devices)-healthyCount,
 					"fallback_reason", p.v4l2Manager.GetFallbackReason())
 			} else {
-				p.logger.Debug("Health check completed",
+				logger.Debug("Health check completed",
 					"device_count", len(devices),
 					"healthy_count", healthyCount,
 					"unhealthy_count", len(devices)-healthyCount)
@@ -291,102 +630,410 @@ func (p *VideoDevicePlugin) ListAndWatch(req *pluginapi.Empty, stream pluginapi.
 			response := &pluginapi.ListAndWatchResponse{
 				Devices: devices,
 			}
-			if err := stream.Send(response); err != nil {
-				p.logger.Error("Failed to send device list", "error", err)
+			if err := watch.Send(response); err != nil {
 				return err
 			}
+			releaseSendWaiters(waiters)
+			p.liveness.Beat()
 		}
 	}
 }
 
+// syncDeviceList pushes the current device list to kubelet now and waits until it was sent
+// It returns false when no ListAndWatch stream delivered it within timeout
+func (p *VideoDevicePlugin) syncDeviceList(timeout time.Duration) bool {
+	done := make(chan struct{})
+	p.mu.Lock()
+	p.sendWaiters = append(p.sendWaiters, done)
+	p.mu.Unlock()
+
+	p.requestListAndWatchRefresh()
+	select {
+	case <-done:
+		return true
+	case <-p.clock.After(timeout):
+		return false
+	}
+}
+
+// takeSendWaiters claims the callers waiting for a send that starts after they registered
+func (p *VideoDevicePlugin) takeSendWaiters() []chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiters := p.sendWaiters
+	p.sendWaiters = nil
+	return waiters
+}
+
+// releaseSendWaiters wakes callers whose device list was sent
+func releaseSendWaiters(waiters []chan struct{}) {
+	for _, done := range waiters {
+		close(done)
+	}
+}
+
+// Policies for reporting devices kubelet must not hand out (UNHEALTHY_DEVICE_POLICY)
+const (
+	UnhealthyPolicyAdvertise = "advertise" // Keep them in the list marked Unhealthy
+	UnhealthyPolicyRemove    = "remove"    // Leave them out of the list, reducing capacity
+)
+
+// buildDeviceList builds the device list reported to kubelet with per-device health
+// Devices held by local leases are reported Unhealthy so kubelet does not hand them out
+func (p *VideoDevicePlugin) buildDeviceList() ([]*pluginapi.Device, int) {
+	return p.resourceDeviceList(p.config.ResourceName)
+}
+
+// resourceDeviceList builds the device list of one resource served from the video range
+func (p *VideoDevicePlugin) resourceDeviceList(resource string) ([]*pluginapi.Device, int) {
+	return p.kubeletDeviceList(resource, "")
+}
+
+// kubeletDeviceList builds the device list of a resource as one kubelet sees it
+// kubelet is the additional kubelet socket, empty for the primary; devices allocated through
+// another kubelet are reported Unhealthy so two kubelets never hand out the same device
+func (p *VideoDevicePlugin) kubeletDeviceList(resource, kubelet string) ([]*pluginapi.Device, int) {
+	allDevices := p.v4l2Manager.ListAllDevices()
+
+	var devices []*pluginapi.Device
+	healthyCount := 0
+	for _, device := range allDevices {
+		p.labels.Observe(device)
+
+		// Bundle and tier devices are only advertised through their resource; spares are held back
+		if p.deviceResource(device.ID) != resource || p.spares.HeldBack(device.ID) {
+			continue
+		}
+
+		// Check health of each device individually
+		deviceHealthy := p.preparation.Prepared(device.ID) && p.v4l2Manager.GetDeviceHealth(device.ID) && !p.allocations.IsLocallyLeased(device.ID)
+		if _, held := p.allocations.HeldByOtherKubelet(device.ID, kubelet); held {
+			deviceHealthy = false
+		}
+		if deviceHealthy {
+			healthyCount++
+		}
+
+		health := pluginapi.Healthy
+		if !deviceHealthy {
+			// Some schedulers mishandle Unhealthy devices; removal shrinks capacity instead
+			if p.config.UnhealthyDevicePolicy == UnhealthyPolicyRemove {
+				continue
+			}
+			health = pluginapi.Unhealthy
+		}
+
+		devices = append(devices, &pluginapi.Device{
+			ID:     p.rotation.KubeletID(device.ID),
+			Health: health,
+		})
+	}
+
+	return devices, healthyCount
+}
+
 // Allocate implements the Allocate gRPC method
 func (p *VideoDevicePlugin) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
-	p.logger.Info("Allocate called", "requests", len(req.ContainerRequests))
+	return p.allocateResource(ctx, p.config.ResourceName, req)
+}
 
+// allocateResource answers an Allocate request for one resource served from the video range
+func (p *VideoDevicePlugin) allocateResource(ctx context.Context, resource string, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	// Correlate every log line, record and response produced for this call
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	logger := p.logger.With("correlation_id", correlationID)
+	if resource != p.config.ResourceName {
+		logger = logger.With("resource_name", resource)
+	}
+	logger.Info("Allocate called", "requests", len(req.ContainerRequests))
+
+	if p.shadow {
+		logger.Warn("Refusing Allocate on the shadow resource")
+		return nil, status.Error(codes.FailedPrecondition, shadowAllocationMessage)
+	}
+
+	// The bookkeeping was handed to the instance taking over; kubelet retries on it
+	if p.takingOver.Load() {
+		logger.Warn("Refusing Allocate while handing over to a new instance")
+		return nil, status.Error(codes.Unavailable, "plugin is handing over to a new instance")
+	}
+
+	// Never block kubelet longer than ALLOCATION_TIMEOUT, even when a hook ignores cancellation
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.config.AllocationTimeout)*time.Second)
+	defer cancel()
+
+	// Bursts of pod placements queue here so their device preparation never interleaves;
+	// the wait counts toward ALLOCATION_TIMEOUT
+	release, wait, err := p.limiter.Acquire(ctx)
+	if err != nil {
+		logger.Error("Allocate timed out waiting for a free allocation slot",
+			"waited", wait.String(),
+			"max_concurrent_allocations", p.config.MaxConcurrentAllocations)
+		return nil, status.FromContextError(err).Err()
+	}
+	if wait > time.Second {
+		logger.Info("Allocate waited for a free allocation slot", "waited", wait.String())
+	}
+
+	type allocateResult struct {
+		responses []*pluginapi.ContainerAllocateResponse
+		err       error
+	}
+	done := make(chan allocateResult, 1)
+	go func() {
+		// An abandoned attempt keeps its slot until its hooks have actually returned
+		defer release()
+		responses, err := p.allocateContainers(ctx, resource, req, correlationID, logger)
+		done <- allocateResult{responses, err}
+	}()
+
+	var result allocateResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		// The abandoned attempt cleans up after itself once it returns
+		go func() {
+			if late := <-done; late.err == nil {
+				p.rollbackAllocation(correlationID, logger)
+			}
+		}()
+		result.err = ctx.Err()
+	}
+
+	if result.err != nil {
+		p.rollbackAllocation(correlationID, logger)
+		p.decisions.Publish(DecisionEvent{
+			Kind:          DecisionAllocation,
+			Action:        "failed",
+			Resource:      resource,
+			CorrelationID: correlationID,
+			Message:       result.err.Error(),
+		})
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			logger.Error("Allocate did not complete in time", "timeout_seconds", p.config.AllocationTimeout, "error", result.err)
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		logger.Error("Failed to allocate container", "error", result.err)
+		return nil, result.err
+	}
+
+	// Only complete responses are replayed to duplicates
+	for i, containerReq := range req.ContainerRequests {
+		p.replays.Put(containerReq.DevicesIDs, correlationID, result.responses[i])
+		for _, deviceID := range containerReq.DevicesIDs {
+			p.decisions.Publish(DecisionEvent{
+				Kind:          DecisionAllocation,
+				Action:        "allocated",
+				DeviceID:      deviceID,
+				Resource:      resource,
+				CorrelationID: correlationID,
+			})
+		}
+	}
+
+	return &pluginapi.AllocateResponse{
+		ContainerResponses: result.responses,
+	}, nil
+}
+
+// allocateContainers builds the response of each container request in order
+func (p *VideoDevicePlugin) allocateContainers(ctx context.Context, resource string, req *pluginapi.AllocateRequest, correlationID string, logger *slog.Logger) ([]*pluginapi.ContainerAllocateResponse, error) {
 	var responses []*pluginapi.ContainerAllocateResponse
 
+	// A response cached for another kubelet's allocation of the same IDs is never replayed
+	// Requests carry kubelet IDs (video10~1 after a rotation), the tracker holds video device IDs
+	kubelet := allocatingKubelet(ctx)
+	correlationOf := func(kubeletID string) string {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		if _, held := p.allocations.HeldByOtherKubelet(deviceID, kubelet); held {
+			return ""
+		}
+		return p.allocations.CorrelationID(deviceID)
+	}
+
 	for i, containerReq := range req.ContainerRequests {
-		p.logger.Debug("Processing container request",
-			"container_index", i,
-			"device_ids", containerReq.DevicesIDs)
+		// Kubelet replays Allocate after restarts; answer duplicates identically
+		if cached, allocatedAt, ok := p.replays.Get(containerReq.DevicesIDs, correlationOf); ok {
+			p.metrics.IncAllocationReplays()
+			logger.Info("Duplicate Allocate request, returning cached response",
+				"container_index", i,
+				"device_ids", containerReq.DevicesIDs,
+				"originally_allocated_at", allocatedAt)
+			responses = append(responses, cached)
+			continue
+		}
 
-		response, err := p.allocateContainer(containerReq)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		response, err := p.allocateContainer(ctx, resource, containerReq, correlationID, logger.With("container_index", i))
 		if err != nil {
-			p.logger.Error("Failed to allocate container", "error", err)
 			return nil, err
 		}
 		responses = append(responses, response)
 	}
+	return responses, nil
+}
 
-	finalResponse := &pluginapi.AllocateResponse{
-		ContainerResponses: responses,
-	}
-
-	p.logger.Debug("Allocate response created",
-		"container_responses_count", len(finalResponse.ContainerResponses))
+// rollbackAllocation undoes the bookkeeping, handoff files and allocate hooks of a failed Allocate call
+func (p *VideoDevicePlugin) rollbackAllocation(correlationID string, logger *slog.Logger) {
+	released := p.allocations.ReleaseCorrelation(correlationID)
+	for _, allocation := range released {
+		p.removeHandoff(correlationID, allocation.DeviceID)
 
-	return finalResponse, nil
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.config.AllocationTimeout)*time.Second)
+		device := &VideoDevice{ID: allocation.DeviceID, Path: allocation.DevicePath}
+		if err := p.runDeviceHooks(ctx, HookStageRelease, device); err != nil {
+			logger.Warn("Release hooks failed while rolling back allocation", "device_id", allocation.DeviceID, "error", err)
+		}
+		cancel()
+	}
+	if len(released) > 0 {
+		logger.Warn("Rolled back partial allocation", "devices", len(released))
+		p.decisions.Publish(DecisionEvent{
+			Kind:          DecisionAllocation,
+			Action:        "rolled_back",
+			CorrelationID: correlationID,
+			Fields:        map[string]string{"devices": strconv.Itoa(len(released))},
+		})
+	}
 }
 
 // GetDevicePluginOptions implements the GetDevicePluginOptions gRPC method
 func (p *VideoDevicePlugin) GetDevicePluginOptions(ctx context.Context, req *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
-	p.logger.Debug("GetDevicePluginOptions called")
-
+	// The shadow never allocates, so there is nothing to prepare before a container starts
 	return &pluginapi.DevicePluginOptions{
-		PreStartRequired:                true,
-		GetPreferredAllocationAvailable: false,
+		PreStartRequired:                !p.shadow,
+		GetPreferredAllocationAvailable: true,
 	}, nil
 }
 
 // PreStartContainer implements the PreStartContainer gRPC method
 func (p *VideoDevicePlugin) PreStartContainer(ctx context.Context, req *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
-	p.logger.Info("PreStartContainer called", "devices", req.DevicesIDs)
+	if p.shadow {
+		return nil, status.Error(codes.FailedPrecondition, shadowAllocationMessage)
+	}
+
+	// Containers allocated before a rotation start under the retired IDs
+	deviceIDs := videoDeviceIDs(req.DevicesIDs)
+	logger := p.logger
+	if len(deviceIDs) > 0 {
+		if correlationID := p.allocations.CorrelationID(deviceIDs[0]); correlationID != "" {
+			logger = logger.With("correlation_id", correlationID)
+		}
+	}
+	logger.Info("PreStartContainer called", "devices", req.DevicesIDs)
+
+	// A namespace over its quota keeps its devices but its container does not start
+	if err := p.enforceNamespaceQuota(ctx, deviceIDs); err != nil {
+		p.reportPodReadiness(deviceIDs, false, "NamespaceQuotaExceeded", err.Error())
+		return nil, err
+	}
 
 	// Skip device reset in fallback mode
 	if p.v4l2Manager.IsFallbackMode() {
-		p.logger.Warn("PreStartContainer called in FALLBACK MODE - skipping device reset",
+		logger.Warn("PreStartContainer called in FALLBACK MODE - skipping device reset",
 			"devices", req.DevicesIDs,
 			"fallback_reason", p.v4l2Manager.GetFallbackReason(),
 			"note", "Devices are dummy paths - no actual reset needed")
+		p.reportPodReadiness(deviceIDs, false, "FallbackMode", p.v4l2Manager.GetFallbackReason())
 		return &pluginapi.PreStartContainerResponse{}, nil
 	}
 
 	// Always reset devices to ensure they are fresh
-	for _, deviceID := range req.DevicesIDs {
+	var placeholders []string
+	for _, deviceID := range deviceIDs {
 		device, err := p.v4l2Manager.GetDeviceByID(deviceID)
 		if err != nil {
-			p.logger.Warn("Failed to get device info", "device_id", deviceID, "error", err)
+			logger.Warn("Failed to get device info", "device_id", deviceID, "error", err)
 			continue
 		}
 
-		p.logger.Info("Resetting device", "device_id", deviceID, "device_path", device.Path)
+		// A slot the module failed to create is a dummy path with nothing to reset
+		if device.Fallback {
+			logger.Warn("Skipping reset of fallback placeholder", "device_id", deviceID, "device_path", device.Path)
+			placeholders = append(placeholders, deviceID)
+			continue
+		}
+
+		logger.Info("Resetting device", "device_id", deviceID, "device_path", device.Path)
+
+		// The recreated device carries a label naming the meeting it serves
+		stamped := false
+		if label, ok := p.stampCardLabel(ctx, deviceID); ok {
+			logger.Info("Stamping card label", "device_id", deviceID, "card_label", label)
+			stamped = true
+		}
+
+		// A placeholder producer from a previous allocation must release the device first
+		if p.warmup != nil {
+			p.warmup.Stop(device.Path)
+		}
 
 		// Bound each reset by DeviceCreationTimeout to avoid hangs
 		resetCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.DeviceCreationTimeout)*time.Second)
 
 		// Reset the device using v4l2loopback-ctl with timeout
-		err = p.resetDeviceWithContext(resetCtx, device.Path)
+		err = p.resetDeviceWithContext(resetCtx, device.Path, p.deviceMaxBuffers(device))
 		cancel() // Release context immediately after reset operation
 
 		if err != nil {
-			p.logger.Error("Failed to reset device", "device_id", deviceID, "device_path", device.Path, "error", err)
+			logger.Error("Failed to reset device", "device_id", deviceID, "device_path", device.Path, "error", err)
+			p.reportPodReadiness(deviceIDs, false, "DeviceResetFailed", err.Error())
 			return nil, fmt.Errorf("failed to reset device %s: %w", deviceID, err)
 		}
 
-		p.logger.Info("Device reset successfully", "device_id", deviceID, "device_path", device.Path)
+		// The recreated node is a new generation with possibly different device numbers
+		if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+			logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+		}
+
+		logger.Info("Device reset successfully", "device_id", deviceID, "device_path", device.Path)
+
+		// Bots read the card label from the handoff file as well
+		if stamped && p.config.HandoffDir != "" {
+			if refreshed, err := p.v4l2Manager.GetDeviceByID(deviceID); err == nil {
+				if _, err := p.writeHandoff(refreshed, p.allocations.CorrelationID(deviceID)); err != nil {
+					logger.Warn("Failed to update handoff file with the stamped label", "device_id", deviceID, "error", err)
+				}
+			}
+		}
+
+		// Show a placeholder frame until the pod's producer takes over; with exclusive_caps=1
+		// the placeholder would hold the only output slot and lock the producer out
+		if _, exclusiveCaps := p.deviceCreateParams(deviceID); p.warmup != nil && exclusiveCaps == 0 {
+			p.warmup.Start(p.ctx, device.Path)
+		}
+	}
+
+	// Bots gated on the readiness condition only get traffic once their cameras check out
+	var unhealthy []string
+	for _, deviceID := range deviceIDs {
+		if !p.v4l2Manager.GetDeviceHealth(deviceID) {
+			unhealthy = append(unhealthy, deviceID)
+		}
+	}
+	if len(unhealthy) > 0 {
+		p.reportPodReadiness(deviceIDs, false, "DeviceUnhealthy", "unhealthy after reset: "+strings.Join(unhealthy, ","))
+	} else if len(placeholders) > 0 {
+		p.reportPodReadiness(deviceIDs, false, "FallbackDevice", "fallback placeholders: "+strings.Join(placeholders, ","))
+	} else {
+		p.reportPodReadiness(deviceIDs, true, "DeviceVerified", "video devices reset and healthy")
 	}
 
 	return &pluginapi.PreStartContainerResponse{}, nil
 }
 
-// resetDeviceWithContext resets a v4l2loopback device by deleting and recreating it
+// resetDeviceWithContext resets a v4l2loopback device by deleting and recreating it with maxBuffers buffers
 // The context is used to enforce a timeout on the external command execution
-func (p *VideoDevicePlugin) resetDeviceWithContext(ctx context.Context, devicePath string) error {
+func (p *VideoDevicePlugin) resetDeviceWithContext(ctx context.Context, devicePath string, maxBuffers int) error {
 	p.logger.Debug("Resetting device", "device_path", devicePath)
 
 	// Delete the device
-	deleteCmd := exec.CommandContext(ctx, "v4l2loopback-ctl", "delete", devicePath)
-	if out, err := deleteCmd.CombinedOutput(); err != nil {
+	if out, err := privilegedCommand(ctx, "v4l2loopback-ctl", "delete", devicePath); err != nil {
 		// Check if the error is due to timeout
 		if ctx.Err() == context.DeadlineExceeded {
 			p.logger.Error("Device delete operation timed out", "device_path", devicePath, "timeout_seconds", p.config.DeviceCreationTimeout)
@@ -395,6 +1042,7 @@ func (p *VideoDevicePlugin) resetDeviceWithContext(ctx context.Context, devicePa
 		p.logger.Debug("Failed to delete device (may not exist)", "device_path", devicePath, "error", err, "output", strings.TrimSpace(string(out)))
 	} else {
 		p.logger.Debug("Device deleted successfully", "device_path", devicePath)
+		p.setCtlAdded(devicePath, false)
 	}
 
 	// Check if context was cancelled before proceeding with recreation
@@ -403,13 +1051,12 @@ func (p *VideoDevicePlugin) resetDeviceWithContext(ctx context.Context, devicePa
 	}
 
 	// Recreate the device with same configuration
-	addCmd := exec.CommandContext(ctx, "v4l2loopback-ctl", "add",
-		"-n", p.config.V4L2CardLabel,
-		"-b", fmt.Sprintf("%d", p.config.V4L2MaxBuffers),
-		"-x", fmt.Sprintf("%d", p.config.V4L2ExclusiveCaps),
-		devicePath)
-
-	if out, err := addCmd.CombinedOutput(); err != nil {
+	cardLabel, exclusiveCaps := p.deviceCreateParams(filepath.Base(devicePath))
+	if out, err := privilegedCommand(ctx, "v4l2loopback-ctl", "add",
+		"-n", cardLabel,
+		"-b", fmt.Sprintf("%d", maxBuffers),
+		"-x", fmt.Sprintf("%d", exclusiveCaps),
+		devicePath); err != nil {
 		// Check if the error is due to timeout
 		if ctx.Err() == context.DeadlineExceeded {
 			p.logger.Error("Device recreate operation timed out", "device_path", devicePath, "timeout_seconds", p.config.DeviceCreationTimeout)
@@ -420,14 +1067,40 @@ func (p *VideoDevicePlugin) resetDeviceWithContext(ctx context.Context, devicePa
 	}
 
 	p.logger.Debug("Device recreated successfully", "device_path", devicePath)
-	return nil
+	p.setCtlAdded(devicePath, true)
+
+	device := &VideoDevice{ID: filepath.Base(devicePath), Path: devicePath}
+	if err := p.preformatDevice(device); err != nil {
+		p.logger.Warn("Failed to pre-format recreated device", "device_path", devicePath, "error", err)
+	}
+	return p.runDeviceHooks(ctx, HookStageCreate, device)
 }
 
-// Note: GetPreferredAllocation and PreStartContainer are handled by the embedded
-// pluginapi.UnimplementedDevicePluginServer which provides appropriate "not implemented" responses.
+// setCtlAdded records whether a device currently exists because of our v4l2loopback-ctl add
+func (p *VideoDevicePlugin) setCtlAdded(devicePath string, added bool) {
+	p.ctlMu.Lock()
+	defer p.ctlMu.Unlock()
+	if added {
+		p.ctlAdded[devicePath] = true
+	} else {
+		delete(p.ctlAdded, devicePath)
+	}
+}
+
+// CtlAddedDevices returns the device paths created through v4l2loopback-ctl add during this run
+func (p *VideoDevicePlugin) CtlAddedDevices() []string {
+	p.ctlMu.Lock()
+	defer p.ctlMu.Unlock()
+	paths := make([]string, 0, len(p.ctlAdded))
+	for path := range p.ctlAdded {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
 
 // allocateContainer allocates devices for a container
-func (p *VideoDevicePlugin) allocateContainer(req *pluginapi.ContainerAllocateRequest) (*pluginapi.ContainerAllocateResponse, error) {
+func (p *VideoDevicePlugin) allocateContainer(ctx context.Context, resource string, req *pluginapi.ContainerAllocateRequest, correlationID string, logger *slog.Logger) (*pluginapi.ContainerAllocateResponse, error) {
 	// Get the number of devices requested
 	deviceCount := len(req.DevicesIDs)
 
@@ -435,51 +1108,123 @@ func (p *VideoDevicePlugin) allocateContainer(req *pluginapi.ContainerAllocateRe
 		return &pluginapi.ContainerAllocateResponse{}, nil
 	}
 
-	p.logger.Info("Allocating devices for container", "device_count", deviceCount, "device_ids", req.DevicesIDs)
+	logger.Info("Allocating devices for container", "device_count", deviceCount, "device_ids", req.DevicesIDs)
 
-	// Kubelet tells us which device to allocate
-	deviceID := req.DevicesIDs[0] // Kubelet tells us which specific device to allocate
+	// Reject IDs from a stale kubelet view with a typed error
+	kubelet := allocatingKubelet(ctx)
+	if err := p.validateDeviceIDs(resource, kubelet, req.DevicesIDs); err != nil {
+		return nil, err
+	}
 
-	// Get the device information (no allocation state tracking needed)
-	device, err := p.v4l2Manager.GetDeviceByID(deviceID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get device %s: %w", deviceID, err)
+	// Every requested device is prepared; the response only carries this container's devices
+	allocated := make([]*VideoDevice, 0, deviceCount)
+	for _, deviceID := range videoDeviceIDs(req.DevicesIDs) {
+		device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get device %s: %w", deviceID, err)
+		}
+		if err := p.runDeviceHooks(ctx, HookStageAllocate, device); err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, device)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.allocations.RecordKubeletAllocation(allocated, correlationID, kubelet); err != nil {
+		// Another kubelet won the device while the hooks ran
+		return nil, allocateError(codes.FailedPrecondition, AllocateReasonDeviceOtherKubelet, err.Error(), nil)
+	}
+	if len(p.endpoints) > 0 {
+		// The other kubelets must see the device Unhealthy before they hand it out
+		p.requestListAndWatchRefresh()
 	}
 
-	// Create environment variable
+	// VIDEO_DEVICE keeps naming the first device for single-device bots
+	paths := make([]string, 0, len(allocated))
+	for _, device := range allocated {
+		paths = append(paths, device.Path)
+	}
 	envVars := map[string]string{
-		"VIDEO_DEVICE": device.Path,
+		"VIDEO_DEVICE":               allocated[0].Path,
+		"VIDEO_DEVICES":              strings.Join(paths, ","),
+		"VIDEO_DEVICE_ALLOCATION_ID": correlationID,
 	}
-
-	// Create device specification - mount actual device to same path in container
-	devices := []*pluginapi.DeviceSpec{
-		{
-			ContainerPath: device.Path, // Mount to same path as host (video{VideoDeviceStartNumber}, etc.)
-			HostPath:      device.Path, // Actual device on host (video{VideoDeviceStartNumber}, etc.)
-			Permissions:   "rw",
-		},
+	if tier, ok := p.tiers[allocated[0].ID]; ok {
+		envVars["VIDEO_DEVICE_TIER"] = tier.Name
+	}
+	applyTopologyEnvs(envVars, p.config, allocated[0])
+	if placeholders := fallbackDevicePaths(allocated); len(placeholders) > 0 {
+		envVars["VIDEO_DEVICE_FALLBACK"] = strings.Join(placeholders, ",")
 	}
 
-	// Log device allocation with fallback mode information
-	if p.v4l2Manager.IsFallbackMode() {
-		p.logger.Warn("Allocated device (FALLBACK MODE)",
-			"device_id", device.ID,
-			"host_path", device.Path,
-			"container_path", device.Path,
-			"env_var", fmt.Sprintf("VIDEO_DEVICE=%s", device.Path),
-			"fallback_reason", p.v4l2Manager.GetFallbackReason(),
-			"note", "This is a dummy device path - application should handle gracefully")
-	} else {
-		p.logger.Info("Allocated device",
-			"device_id", device.ID,
-			"host_path", device.Path,
-			"container_path", device.Path,
-			"env_var", fmt.Sprintf("VIDEO_DEVICE=%s", device.Path))
+	var devices []*pluginapi.DeviceSpec
+	var mounts []*pluginapi.Mount
+	for i, device := range allocated {
+		// Mount the actual device to the same path in the container
+		devices = append(devices, &pluginapi.DeviceSpec{
+			ContainerPath: device.Path,
+			HostPath:      device.Path,
+			Permissions:   p.config.VideoDevicePermissions,
+		})
+
+		// Mount the metadata handoff file when configured
+		if p.config.HandoffDir != "" {
+			mount, err := p.writeHandoff(device, correlationID)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				mount.ContainerPath = extraHandoffPath(mount.ContainerPath, device.ID)
+			}
+			mounts = append(mounts, mount)
+			if i == 0 {
+				envVars["VIDEO_DEVICE_HANDOFF"] = mount.ContainerPath
+			}
+		}
+
+		// Card metadata readers get the sysfs directory without running privileged
+		if p.config.EnableSysfsMount && !device.Fallback {
+			mount, err := p.sysfsMount(device)
+			if err != nil {
+				return nil, err
+			}
+			mounts = append(mounts, mount)
+			if i == 0 {
+				envVars["VIDEO_DEVICE_SYSFS"] = mount.ContainerPath
+			}
+		}
+
+		// Log device allocation with fallback mode information
+		if p.v4l2Manager.IsFallbackMode() {
+			logger.Warn("Allocated device (FALLBACK MODE)",
+				"device_id", device.ID,
+				"host_path", device.Path,
+				"container_path", device.Path,
+				"fallback_reason", p.v4l2Manager.GetFallbackReason(),
+				"note", "This is a dummy device path - application should handle gracefully")
+		} else if device.Fallback {
+			logger.Warn("Allocated fallback placeholder for a missing device slot",
+				"device_id", device.ID,
+				"host_path", device.Path,
+				"container_path", device.Path,
+				"note", "This is a dummy device path - application should handle gracefully")
+		} else {
+			logger.Info("Allocated device",
+				"device_id", device.ID,
+				"host_path", device.Path,
+				"container_path", device.Path)
+		}
 	}
+	logger.Info("Container allocation prepared", "devices", paths, "env_var", fmt.Sprintf("VIDEO_DEVICE=%s", allocated[0].Path))
 
 	response := &pluginapi.ContainerAllocateResponse{
 		Devices: devices,
+		Mounts:  mounts,
 		Envs:    envVars,
+		Annotations: map[string]string{
+			AllocationIDAnnotation: correlationID,
+		},
 	}
 
 	return response, nil
@@ -499,59 +1244,241 @@ func (p *VideoDevicePlugin) GetHealthStatus() *HealthCheck {
 	if !devicesReady {
 		errors = append(errors, "No devices are ready")
 	}
+	for deviceID, reason := range p.v4l2Manager.GetSkippedDevices() {
+		errors = append(errors, fmt.Sprintf("Device %s unusable: %s", deviceID, reason))
+	}
+	endpoints := p.kubeletEndpointStatuses()
+	for _, endpoint := range endpoints {
+		if !endpoint.Primary && !endpoint.Registered {
+			errors = append(errors, fmt.Sprintf("Not registered with kubelet %s", endpoint.KubeletSocket))
+		}
+	}
 
 	return &HealthCheck{
+		Version:      healthStatusVersion,
 		Healthy:      healthy,
 		V4L2Healthy:  v4l2Healthy,
 		DevicesReady: devicesReady,
-		LastChecked:  time.Now(),
+		LastChecked:  p.clock.Now(),
 		Errors:       errors,
+		Devices:      p.deviceHealthStatuses(),
+
+		PendingModuleChange: p.PendingModuleChange(),
+		KubeletEndpoints:    endpoints,
 	}
 }
 
 // monitorKubeletRestart monitors for kubelet restarts and re-registers when needed
 func (p *VideoDevicePlugin) monitorKubeletRestart() {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := p.clock.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-p.stopCh:
+		case <-p.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			// Check if kubelet socket still exists
-			if !checkDeviceExists(p.config.KubeletSocket) {
-				p.logger.Warn("Kubelet socket not found, kubelet may have restarted")
-
-				// Wait for kubelet to come back up
-				for {
-					select {
-					case <-p.stopCh:
-						return
-					case <-time.After(5 * time.Second):
-						if checkDeviceExists(p.config.KubeletSocket) {
-							p.logger.Info("Kubelet socket found, attempting re-registration")
-
-							// Reset registration status
-							p.mu.Lock()
-							p.registered = false
-							p.mu.Unlock()
-
-							// Re-register with kubelet
-							if err := p.RegisterWithKubelet(); err != nil {
-								p.logger.Error("Failed to re-register with kubelet", "error", err)
-								continue
-							}
-
-							p.logger.Info("Successfully re-registered with kubelet after restart")
-							// Continue outer monitoring loop for future restarts
-							goto continueOuter
-						}
-					}
-				}
+			if checkDeviceExists(p.config.KubeletSocket) {
+				continue
+			}
+
+			p.logger.Warn("Kubelet socket not found, kubelet may have restarted")
+			if !p.reregister() {
+				return
+			}
+		}
+	}
+}
+
+// reregister waits for kubelet to come back and re-registers with exponential backoff
+// It returns false when the plugin stops or the retry policy gives up
+func (p *VideoDevicePlugin) reregister() bool {
+	backoff := time.Duration(p.config.ReregisterInitialBackoff) * time.Second
+	maxBackoff := time.Duration(p.config.ReregisterMaxBackoff) * time.Second
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.ctx.Done():
+			return false
+		case <-p.clock.After(backoff):
+		}
+
+		if checkDeviceExists(p.config.KubeletSocket) {
+			p.logger.Info("Kubelet socket found, attempting re-registration", "attempt", attempt)
+
+			// Reset registration status
+			p.mu.Lock()
+			p.registered = false
+			p.mu.Unlock()
+			p.decisions.Publish(DecisionEvent{
+				Kind:     DecisionRegistration,
+				Action:   "reregistering",
+				Resource: p.config.ResourceName,
+				Fields:   map[string]string{"attempt": strconv.Itoa(attempt)},
+			})
+
+			err := p.RegisterWithKubelet(p.ctx)
+			if err == nil {
+				p.logger.Info("Successfully re-registered with kubelet after restart", "attempts", attempt)
+				return true
+			}
+			p.logger.Error("Failed to re-register with kubelet", "attempt", attempt, "error", err)
+		} else {
+			p.logger.Debug("Kubelet socket still missing", "attempt", attempt)
+		}
+
+		if p.config.ReregisterMaxAttempts > 0 && attempt >= p.config.ReregisterMaxAttempts {
+			p.giveUpRegistration(attempt)
+			return false
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// giveUpRegistration enters the terminal unregistered state: readiness stays false until the
+// plugin is restarted, so stuck nodes surface in monitoring instead of retrying silently
+func (p *VideoDevicePlugin) giveUpRegistration(attempts int) {
+	p.mu.Lock()
+	p.registered = false
+	p.registrationGaveUp = true
+	p.mu.Unlock()
+
+	message := fmt.Sprintf("Gave up re-registering with kubelet after %d attempts; restart the plugin pod", attempts)
+	p.decisions.Publish(DecisionEvent{Kind: DecisionRegistration, Action: "gave_up", Resource: p.config.ResourceName, Message: message})
+	p.logger.Error("Giving up kubelet re-registration", "attempts", attempts, "kubelet_socket", p.config.KubeletSocket)
+	p.refreshReadiness()
+	p.recordEvent(corev1.EventTypeWarning, "KubeletRegistrationGaveUp", message)
+}
+
+// recordEvent records a node event when events are enabled
+func (p *VideoDevicePlugin) recordEvent(eventType, reason, message string) {
+	if p.k8sClient == nil || !p.config.EnableEvents {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.k8sClient.RecordNodeEvent(ctx, eventType, reason, message); err != nil {
+		p.logger.Debug("Failed to record node event", "reason", reason, "error", err)
+	}
+}
+
+// evaluateReadiness determines whether the node can serve real video devices
+func (p *VideoDevicePlugin) evaluateReadiness() (bool, string, string) {
+	if p.v4l2Manager.IsFallbackMode() {
+		return false, "FallbackMode", fmt.Sprintf("Devices are dummy placeholders: %s", p.v4l2Manager.GetFallbackReason())
+	}
+
+	p.mu.RLock()
+	registered := p.registered
+	gaveUp := p.registrationGaveUp
+	p.mu.RUnlock()
+	if gaveUp {
+		return false, "RegistrationGaveUp", "Device plugin gave up re-registering with kubelet"
+	}
+	if !registered {
+		return false, "NotRegistered", "Device plugin is not registered with kubelet"
+	}
+
+	// Locally leased devices are busy, not broken, so they count towards the threshold
+	healthy := 0
+	for _, device := range p.v4l2Manager.ListAllDevices() {
+		if p.v4l2Manager.GetDeviceHealth(device.ID) {
+			healthy++
+		}
+	}
+
+	required := p.minHealthyDevices()
+	if healthy < required {
+		message := fmt.Sprintf("%d of %d video devices healthy, %d required", healthy, p.config.MaxDevices, required)
+		if health := p.GetHealthStatus(); len(health.Errors) > 0 {
+			message += ": " + strings.Join(health.Errors, "; ")
+		}
+		return false, "DevicesUnhealthy", message
+	}
+
+	return true, "DevicesReady", fmt.Sprintf("%d of %d video devices healthy (%d required)", healthy, p.config.MaxDevices, required)
+}
+
+// minHealthyDevices returns the healthy device count required for readiness
+func (p *VideoDevicePlugin) minHealthyDevices() int {
+	if minHealthy := p.settings.MinHealthyDevices(); minHealthy > 0 {
+		return minHealthy
+	}
+	return p.config.MaxDevices
+}
+
+// refreshReadiness re-evaluates readiness and updates the node condition if it changed
+func (p *VideoDevicePlugin) refreshReadiness() {
+	ready, reason, message := p.evaluateReadiness()
+	p.updateNodeCondition(ready, reason, message)
+}
+
+// updateNodeCondition patches the node condition only when its status changes
+func (p *VideoDevicePlugin) updateNodeCondition(ready bool, reason, message string) {
+	if p.k8sClient == nil || !p.config.EnableNodeCondition {
+		return
+	}
+
+	p.mu.RLock()
+	unchanged := p.conditionReported && p.conditionReady == ready
+	p.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.k8sClient.SetNodeCondition(ctx, p.config.NodeConditionType, ready, reason, message); err != nil {
+		p.logger.Warn("Failed to update node condition", "condition", p.config.NodeConditionType, "error", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.conditionReported = true
+	p.conditionReady = ready
+	p.mu.Unlock()
+}
+
+// monitorReadiness periodically re-evaluates readiness so degradation flips the node condition
+func (p *VideoDevicePlugin) monitorReadiness() {
+	if p.k8sClient == nil || !p.config.EnableNodeCondition {
+		return
+	}
+
+	timer := p.clock.NewTimer(p.settings.HealthCheckInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-timer.C():
+			p.refreshReadiness()
+			timer.Reset(p.settings.HealthCheckInterval())
+		}
+	}
+}
+
+// monitorPermissions periodically re-asserts device permissions/ownership that other agents reset
+func (p *VideoDevicePlugin) monitorPermissions() {
+	if p.config.PermissionReconcileInterval <= 0 {
+		return
+	}
+
+	ticker := p.clock.NewTicker(time.Duration(p.config.PermissionReconcileInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+			for _, deviceID := range p.v4l2Manager.ReconcilePermissions() {
+				p.metrics.IncPermissionCorrections(deviceID)
+				p.decisions.Publish(DecisionEvent{Kind: DecisionReconcile, Action: "permissions_corrected", DeviceID: deviceID})
 			}
+			p.releasePreparedDevices()
 		}
-	continueOuter:
-		// label target for goto to resume outer loop
 	}
 }
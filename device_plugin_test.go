@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// benchmarkDevices is the pool size of the benchmarks, a fully packed node
+const benchmarkDevices = 64
+
+// discardListAndWatchStream accepts every device list send
+type discardListAndWatchStream struct {
+	grpc.ServerStream
+}
+
+func (discardListAndWatchStream) Send(*pluginapi.ListAndWatchResponse) error { return nil }
+
+func newBenchmarkPlugin(b *testing.B) *VideoDevicePlugin {
+	b.Helper()
+	plugin, err := newSoakPlugin(b.TempDir(), benchmarkDevices)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return plugin
+}
+
+func BenchmarkGetDeviceHealth(b *testing.B) {
+	plugin := newBenchmarkPlugin(b)
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		plugin.v4l2Manager.GetDeviceHealth(fmt.Sprintf("video%d", VideoDeviceStartNumber+i%benchmarkDevices))
+	}
+}
+
+func BenchmarkHealthStatus(b *testing.B) {
+	plugin := newBenchmarkPlugin(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		plugin.GetHealthStatus()
+	}
+}
+
+func BenchmarkAllocate(b *testing.B) {
+	plugin := newBenchmarkPlugin(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		deviceID := fmt.Sprintf("video%d", VideoDeviceStartNumber+i%benchmarkDevices)
+		resp, err := plugin.Allocate(ctx, &pluginapi.AllocateRequest{
+			ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{deviceID}}},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Release so the next lap allocates again instead of replaying the cached response
+		plugin.allocations.ReleaseCorrelation(resp.ContainerResponses[0].Envs["VIDEO_DEVICE_ALLOCATION_ID"])
+	}
+}
+
+func BenchmarkListAndWatchSend(b *testing.B) {
+	plugin := newBenchmarkPlugin(b)
+	watch := plugin.sent.OpenStream(plugin.config.ResourceName, discardListAndWatchStream{}, plugin.logger)
+	b.ReportAllocs()
+	for b.Loop() {
+		devices, _ := plugin.buildDeviceList()
+		if err := watch.Send(&pluginapi.ListAndWatchResponse{Devices: devices}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// fakeV4L2Manager serves a fixed device set whose health the test sets
+type fakeV4L2Manager struct {
+	devices map[string]*VideoDevice
+	healthy map[string]bool
+}
+
+func newFakeV4L2Manager(count int) *fakeV4L2Manager {
+	m := &fakeV4L2Manager{devices: make(map[string]*VideoDevice), healthy: make(map[string]bool)}
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("video%d", VideoDeviceStartNumber+i)
+		m.devices[id] = &VideoDevice{ID: id, Path: "/dev/" + id}
+		m.healthy[id] = true
+	}
+	return m
+}
+
+func (m *fakeV4L2Manager) CreateDevices(count int) error { return nil }
+
+func (m *fakeV4L2Manager) GetDeviceByID(deviceID string) (*VideoDevice, error) {
+	device, ok := m.devices[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("device %s not found", deviceID)
+	}
+	return device, nil
+}
+
+func (m *fakeV4L2Manager) IsHealthy(maxDevices int) bool           { return true }
+func (m *fakeV4L2Manager) GetDeviceCount(maxDevices int) int       { return len(m.devices) }
+func (m *fakeV4L2Manager) ListAllDevices() map[string]*VideoDevice { return m.devices }
+func (m *fakeV4L2Manager) GetDeviceHealth(deviceID string) bool    { return m.healthy[deviceID] }
+func (m *fakeV4L2Manager) GetDeviceCheck(deviceID string) (DeviceCheck, bool) {
+	return DeviceCheck{}, false
+}
+func (m *fakeV4L2Manager) GetSkippedDevices() map[string]string              { return nil }
+func (m *fakeV4L2Manager) ReconcilePermissions() []string                    { return nil }
+func (m *fakeV4L2Manager) PermissionMismatches() map[string]string           { return nil }
+func (m *fakeV4L2Manager) IsFallbackMode() bool                              { return false }
+func (m *fakeV4L2Manager) GetFallbackReason() string                         { return "" }
+func (m *fakeV4L2Manager) EnableFallbackMode(reason string, count int) error { return nil }
+func (m *fakeV4L2Manager) RefreshDevice(deviceID string) error               { return nil }
+func (m *fakeV4L2Manager) SetChangeHook(fn func())                           {}
+func (m *fakeV4L2Manager) SetHealthHistory(history *HealthHistory)           {}
+func (m *fakeV4L2Manager) SetSlotFallback(enabled bool)                      {}
+func (m *fakeV4L2Manager) RestoreDevices(saved []VideoDevice) int            { return 0 }
+func (m *fakeV4L2Manager) SetMaxBuffers(deviceID string, maxBuffers int) error {
+	return nil
+}
+func (m *fakeV4L2Manager) InvalidateDevices() int  { return 0 }
+func (m *fakeV4L2Manager) CleanupFallbackDevices() {}
+
+// newTestPlugin builds a prepared plugin over manager with the settings of a default deployment
+func newTestPlugin(t *testing.T, manager V4L2Manager, configure func(*DevicePluginConfig)) *VideoDevicePlugin {
+	t.Helper()
+	config := &DevicePluginConfig{
+		MaxDevices:            len(manager.ListAllDevices()),
+		ResourceName:          "meeting-baas.io/video-devices",
+		HealthCheckInterval:   30,
+		VideoDeviceStart:      VideoDeviceStartNumber,
+		AllocationTimeout:     30,
+		UnhealthyDevicePolicy: UnhealthyPolicyAdvertise,
+	}
+	if configure != nil {
+		configure(config)
+	}
+	plugin := NewVideoDevicePlugin(config, manager, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	plugin.prepareForAdvertisement()
+	return plugin
+}
+
+func TestBuildDeviceListUnhealthyDevicePolicy(t *testing.T) {
+	tests := []struct {
+		policy      string
+		wantDevices map[string]string // Kubelet ID -> health
+	}{
+		{UnhealthyPolicyAdvertise, map[string]string{
+			"video10": pluginapi.Healthy,
+			"video11": pluginapi.Unhealthy,
+			"video12": pluginapi.Healthy,
+		}},
+		{UnhealthyPolicyRemove, map[string]string{
+			"video10": pluginapi.Healthy,
+			"video12": pluginapi.Healthy,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			manager := newFakeV4L2Manager(3)
+			manager.healthy["video11"] = false
+			plugin := newTestPlugin(t, manager, func(config *DevicePluginConfig) {
+				config.UnhealthyDevicePolicy = tt.policy
+			})
+
+			devices, healthyCount := plugin.buildDeviceList()
+			if healthyCount != 2 {
+				t.Errorf("healthy count = %d, want 2", healthyCount)
+			}
+			got := make(map[string]string, len(devices))
+			for _, device := range devices {
+				got[device.ID] = device.Health
+			}
+			if len(got) != len(tt.wantDevices) {
+				t.Fatalf("devices = %v, want %v", got, tt.wantDevices)
+			}
+			for id, health := range tt.wantDevices {
+				if got[id] != health {
+					t.Errorf("device %s health = %q, want %q", id, got[id], health)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateIsolatesContainers(t *testing.T) {
+	manager := newFakeV4L2Manager(4)
+	plugin := newTestPlugin(t, manager, func(config *DevicePluginConfig) {
+		config.HandoffDir = t.TempDir()
+	})
+
+	requested := [][]string{{"video10", "video11"}, {"video12"}, {"video13"}}
+	req := &pluginapi.AllocateRequest{}
+	for _, ids := range requested {
+		req.ContainerRequests = append(req.ContainerRequests, &pluginapi.ContainerAllocateRequest{DevicesIDs: ids})
+	}
+	resp, err := plugin.Allocate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ContainerResponses) != len(requested) {
+		t.Fatalf("container responses = %d, want %d", len(resp.ContainerResponses), len(requested))
+	}
+
+	for i, ids := range requested {
+		container := resp.ContainerResponses[i]
+		var want []string
+		for _, id := range ids {
+			want = append(want, "/dev/"+id)
+		}
+
+		var got []string
+		for _, device := range container.Devices {
+			got = append(got, device.HostPath)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("container %d devices = %v, want %v", i, got, want)
+		}
+		if devices := container.Envs["VIDEO_DEVICES"]; devices != strings.Join(want, ",") {
+			t.Errorf("container %d VIDEO_DEVICES = %q, want %q", i, devices, strings.Join(want, ","))
+		}
+		if device := container.Envs["VIDEO_DEVICE"]; device != want[0] {
+			t.Errorf("container %d VIDEO_DEVICE = %q, want %q", i, device, want[0])
+		}
+		if len(container.Mounts) != len(ids) {
+			t.Errorf("container %d handoff mounts = %d, want %d", i, len(container.Mounts), len(ids))
+		}
+		for _, mount := range container.Mounts {
+			if !slices.ContainsFunc(ids, func(id string) bool { return strings.Contains(mount.HostPath, id) }) {
+				t.Errorf("container %d mounts the handoff file %s of another container", i, mount.HostPath)
+			}
+		}
+	}
+}
+
+// allocationID returns the correlation ID the first container of resp was allocated under
+func allocationID(resp *pluginapi.AllocateResponse) string {
+	return resp.ContainerResponses[0].Envs["VIDEO_DEVICE_ALLOCATION_ID"]
+}
+
+func TestAllocateReplaysDuplicates(t *testing.T) {
+	tests := []struct {
+		name        string
+		rotate      bool   // Rotate video10 before the first Allocate
+		kubelet     string // Additional kubelet the duplicate comes through, empty for the primary
+		wantReplay  bool
+		wantErrCode codes.Code
+	}{
+		{name: "duplicate", wantReplay: true},
+		{name: "duplicate after rotation", rotate: true, wantReplay: true},
+		{name: "other kubelet", kubelet: "/var/lib/kubelet-b/device-plugins/kubelet.sock", wantErrCode: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := newTestPlugin(t, newFakeV4L2Manager(2), func(config *DevicePluginConfig) {
+				config.AllocationReplayWindow = 60
+				config.EnableDeviceIDRotation = true
+			})
+			if tt.rotate {
+				plugin.RotateDeviceIDs("test", []string{"video10"})
+			}
+			req := &pluginapi.AllocateRequest{
+				ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{plugin.rotation.KubeletID("video10")}}},
+			}
+
+			first, err := plugin.Allocate(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var second *pluginapi.AllocateResponse
+			if tt.kubelet == "" {
+				second, err = plugin.Allocate(context.Background(), req)
+			} else {
+				endpoint := kubeletEndpointServer{VideoDevicePlugin: plugin, endpoint: &kubeletEndpoint{KubeletSocket: tt.kubelet}}
+				second, err = endpoint.Allocate(context.Background(), req)
+			}
+
+			if tt.wantErrCode != codes.OK {
+				if status.Code(err) != tt.wantErrCode {
+					t.Fatalf("duplicate Allocate error = %v, want code %s", err, tt.wantErrCode)
+				}
+				if got := plugin.allocations.CorrelationID("video10"); got != allocationID(first) {
+					t.Errorf("video10 allocated under %q after the rejected call, want %q", got, allocationID(first))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replayed := allocationID(second) == allocationID(first); replayed != tt.wantReplay {
+				t.Errorf("duplicate Allocate replayed = %t, want %t (allocation IDs %q, %q)",
+					replayed, tt.wantReplay, allocationID(first), allocationID(second))
+			}
+		})
+	}
+}
+
+// recordingListAndWatchStream hands every device list send to the test
+type recordingListAndWatchStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	sends chan []*pluginapi.Device
+}
+
+func (s recordingListAndWatchStream) Context() context.Context { return s.ctx }
+
+func (s recordingListAndWatchStream) Send(resp *pluginapi.ListAndWatchResponse) error {
+	s.sends <- resp.Devices
+	return nil
+}
+
+func TestListAndWatchSendsHealthOnInterval(t *testing.T) {
+	manager := newFakeV4L2Manager(2)
+	plugin := newTestPlugin(t, manager, nil)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	plugin.SetClock(fakeClock)
+	plugin.ctx, plugin.cancel = context.WithCancel(context.Background())
+	defer plugin.cancel()
+
+	// Preparation asked for a send no stream was open for yet
+	select {
+	case <-plugin.refreshCh:
+	default:
+	}
+
+	stream := recordingListAndWatchStream{ctx: context.Background(), sends: make(chan []*pluginapi.Device, 1)}
+	go func() { _ = plugin.ListAndWatch(&pluginapi.Empty{}, stream) }()
+	receive := func() []*pluginapi.Device {
+		t.Helper()
+		select {
+		case devices := <-stream.sends:
+			return devices
+		case <-time.After(5 * time.Second):
+			t.Fatal("no device list sent")
+			return nil
+		}
+	}
+	receive()
+	// Expire the preparation wait so only the health timer is left waiting
+	fakeClock.Step(0)
+	for !fakeClock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+
+	manager.healthy["video11"] = false
+	// Health is only re-read once the interval elapsed
+	fakeClock.Step(29 * time.Second)
+	select {
+	case <-stream.sends:
+		t.Fatal("device list sent before the health check interval")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fakeClock.Step(time.Second)
+	for _, device := range receive() {
+		want := pluginapi.Healthy
+		if device.ID == "video11" {
+			want = pluginapi.Unhealthy
+		}
+		if device.Health != want {
+			t.Errorf("device %s health = %q, want %q", device.ID, device.Health, want)
+		}
+	}
+}
+
+// stallingHook stalls the allocate hook of one device until stall is closed and reports release hooks
+type stallingHook struct {
+	deviceID    string
+	honorCancel bool
+	stall       chan struct{}
+	released    chan string
+}
+
+func (h *stallingHook) Name() string { return "stall" }
+
+func (h *stallingHook) Run(ctx context.Context, stage string, device *VideoDevice) error {
+	if stage == HookStageRelease {
+		h.released <- device.ID
+		return nil
+	}
+	if device.ID != h.deviceID {
+		return nil
+	}
+	if !h.honorCancel {
+		<-h.stall
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-h.stall:
+		return nil
+	}
+}
+
+func TestAllocateTimeoutRollsBack(t *testing.T) {
+	for _, honorCancel := range []bool{true, false} {
+		t.Run(fmt.Sprintf("honor_cancel=%t", honorCancel), func(t *testing.T) {
+			plugin := newTestPlugin(t, newFakeV4L2Manager(2), func(config *DevicePluginConfig) {
+				config.AllocationTimeout = 1
+				config.AllocationReplayWindow = 60
+			})
+			hook := &stallingHook{deviceID: "video11", honorCancel: honorCancel, stall: make(chan struct{}), released: make(chan string, 2)}
+			plugin.hooks = &DeviceHookRunner{
+				hooks: []configuredHook{{
+					hook:        hook,
+					stages:      map[string]bool{HookStageAllocate: true, HookStageRelease: true},
+					timeout:     time.Minute,
+					failOnError: true,
+				}},
+				logger: plugin.logger,
+			}
+
+			// The first container is allocated before the second one stalls past ALLOCATION_TIMEOUT
+			_, err := plugin.Allocate(context.Background(), &pluginapi.AllocateRequest{
+				ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"video10"}}, {DevicesIDs: []string{"video11"}}},
+			})
+			close(hook.stall)
+			if status.Code(err) != codes.DeadlineExceeded {
+				t.Fatalf("Allocate error = %v, want code %s", err, codes.DeadlineExceeded)
+			}
+
+			select {
+			case deviceID := <-hook.released:
+				if deviceID != "video10" {
+					t.Errorf("rollback released %s, want video10", deviceID)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("rollback did not run the release hooks of video10")
+			}
+			if leftover := plugin.allocations.List(); len(leftover) > 0 {
+				t.Errorf("allocations left after the rollback: %+v", leftover)
+			}
+			for _, deviceID := range []string{"video10", "video11"} {
+				if _, _, ok := plugin.replays.Get([]string{deviceID}, plugin.allocations.CorrelationID); ok {
+					t.Errorf("replay cache holds a response for %s after the rollback", deviceID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "fmt"
+
+// preformatDevices sets the default output format on every idle device
+// Consumers negotiating against a device that already carries a format skip the slow first
+// negotiation some kernels impose on a fresh loopback device
+func (p *VideoDevicePlugin) preformatDevices() {
+	if !p.config.PreformatDevices || p.v4l2Manager.IsFallbackMode() {
+		return
+	}
+
+	allocated := make(map[string]bool)
+	for _, allocation := range p.allocations.List() {
+		allocated[allocation.DeviceID] = true
+	}
+
+	formatted := 0
+	for deviceID, device := range p.v4l2Manager.ListAllDevices() {
+		// A restored allocation may have its producer running already
+		if allocated[deviceID] || device.Fallback {
+			continue
+		}
+		if err := p.preformatDevice(device); err != nil {
+			p.logger.Warn("Failed to pre-format device", "device_id", deviceID, "error", err)
+			continue
+		}
+		formatted++
+	}
+	p.logger.Info("Pre-formatted devices",
+		"devices", formatted,
+		"width", p.config.PreformatWidth,
+		"height", p.config.PreformatHeight,
+		"pixel_format", "YUYV")
+}
+
+// preformatDevice opens a device as producer, sets the default YUYV format and closes it again
+func (p *VideoDevicePlugin) preformatDevice(device *VideoDevice) error {
+	if !p.config.PreformatDevices || p.v4l2Manager.IsFallbackMode() {
+		return nil
+	}
+
+	fd, err := openVideoOutput(device.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for output: %w", device.Path, err)
+	}
+	defer closeVideoOutput(fd)
+
+	if _, err := setOutputFormat(fd, uint32(p.config.PreformatWidth), uint32(p.config.PreformatHeight)); err != nil {
+		return fmt.Errorf("failed to set format on %s: %w", device.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// DevicePreparation is the barrier between device creation and advertising devices as Healthy
+// Devices are only handed to kubelet once their permissions were verified and the optional
+// format priming finished; until the barrier is released every device is reported Unhealthy
+type DevicePreparation struct {
+	mu         sync.RWMutex
+	done       chan struct{}
+	released   bool
+	unprepared map[string]string // device ID -> why it is still held back after release
+}
+
+// NewDevicePreparation creates an unreleased barrier
+func NewDevicePreparation() *DevicePreparation {
+	return &DevicePreparation{
+		done:       make(chan struct{}),
+		unprepared: make(map[string]string),
+	}
+}
+
+// Release opens the barrier; devices in unprepared stay Unhealthy until marked prepared
+func (d *DevicePreparation) Release(unprepared map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.released {
+		return
+	}
+	for deviceID, reason := range unprepared {
+		d.unprepared[deviceID] = reason
+	}
+	d.released = true
+	close(d.done)
+}
+
+// Done is closed once the barrier was released
+func (d *DevicePreparation) Done() <-chan struct{} {
+	return d.done
+}
+
+// Prepared reports whether a device may be advertised Healthy
+func (d *DevicePreparation) Prepared(deviceID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.released {
+		return false
+	}
+	_, held := d.unprepared[deviceID]
+	return !held
+}
+
+// Held returns the IDs of devices still held back after release
+func (d *DevicePreparation) Held() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	held := make([]string, 0, len(d.unprepared))
+	for deviceID := range d.unprepared {
+		held = append(held, deviceID)
+	}
+	return held
+}
+
+// MarkPrepared releases a device held back at release time and reports whether it was held
+func (d *DevicePreparation) MarkPrepared(deviceID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, held := d.unprepared[deviceID]; !held {
+		return false
+	}
+	delete(d.unprepared, deviceID)
+	return true
+}
+
+// prepareForAdvertisement primes device formats, verifies permissions and releases the barrier
+// Kubelet may already be connected; its initial device list waits for this up to DEVICE_PREPARE_TIMEOUT
+func (p *VideoDevicePlugin) prepareForAdvertisement() {
+	start := time.Now()
+
+	// Set a default format so the first consumer of each device negotiates instantly
+	p.preformatDevices()
+
+	// Udev may have reset the nodes since creation; re-assert before checking
+	p.v4l2Manager.ReconcilePermissions()
+	unprepared := p.v4l2Manager.PermissionMismatches()
+	for deviceID, reason := range unprepared {
+		p.logger.Warn("Device permissions not applied, advertising it Unhealthy until corrected",
+			"device_id", deviceID,
+			"reason", reason)
+	}
+
+	p.preparation.Release(unprepared)
+	p.logger.Info("Devices prepared for advertisement",
+		"duration", time.Since(start).String(),
+		"unprepared", len(unprepared))
+
+	// A stream that timed out waiting sent everything Unhealthy; correct it now
+	p.requestListAndWatchRefresh()
+}
+
+// releasePreparedDevices advertises devices held back at startup whose permissions match by now
+func (p *VideoDevicePlugin) releasePreparedDevices() {
+	held := p.preparation.Held()
+	if len(held) == 0 {
+		return
+	}
+
+	mismatches := p.v4l2Manager.PermissionMismatches()
+	released := false
+	for _, deviceID := range held {
+		if _, mismatched := mismatches[deviceID]; mismatched {
+			continue
+		}
+		if p.preparation.MarkPrepared(deviceID) {
+			p.logger.Info("Device permissions applied, advertising it", "device_id", deviceID)
+			released = true
+		}
+	}
+	if released {
+		p.requestListAndWatchRefresh()
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// videoNodeOwner classifies who owns a /dev/videoN number
+type videoNodeOwner int
+
+const (
+	videoNodeFree videoNodeOwner = iota
+	videoNodeLoopback
+	videoNodeForeign
+)
+
+// classifyVideoNode reports whether /dev/videoN is free, a v4l2loopback device or owned by another driver
+// v4l2loopback devices are virtual and live under /sys/devices/virtual/video4linux
+func classifyVideoNode(number int) videoNodeOwner {
+	devicePath := fmt.Sprintf("/dev/video%d", number)
+	if !checkDeviceExists(devicePath) {
+		return videoNodeFree
+	}
+
+	target, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/class/video4linux/video%d", number))
+	if err != nil {
+		// A device node without sysfs backing is stale or foreign; never reuse it
+		return videoNodeForeign
+	}
+	if strings.Contains(target, "/devices/virtual/video4linux/") {
+		return videoNodeLoopback
+	}
+	return videoNodeForeign
+}
+
+// rangeCollisions returns the numbers in [start, start+count) held by non-loopback devices
+func rangeCollisions(start, count int) []int {
+	var collisions []int
+	for n := start; n < start+count; n++ {
+		if classifyVideoNode(n) == videoNodeForeign {
+			collisions = append(collisions, n)
+		}
+	}
+	return collisions
+}
+
+// selectDeviceRange picks the first video number of the device range
+// It prefers the range recorded in state (stable across restarts), then the configured start,
+// then the next contiguous range free of foreign devices up to VideoDeviceCeiling.
+// The chosen range is recorded in state.
+func selectDeviceRange(config *DevicePluginConfig, logger *slog.Logger) (int, error) {
+	candidates := []int{config.VideoDeviceStart}
+
+	state, err := loadPluginState(config.StateDir)
+	if err != nil {
+		logger.Warn("Ignoring unreadable plugin state", "error", err)
+	}
+	if state != nil && state.MaxDevices == config.MaxDevices && state.VideoDeviceStart != config.VideoDeviceStart &&
+		state.VideoDeviceStart+config.MaxDevices-1 <= config.VideoDeviceCeiling {
+		candidates = append([]int{state.VideoDeviceStart}, candidates...)
+	}
+	for start := config.VideoDeviceStart + 1; start+config.MaxDevices-1 <= config.VideoDeviceCeiling; start++ {
+		candidates = append(candidates, start)
+	}
+
+	for _, start := range candidates {
+		collisions := rangeCollisions(start, config.MaxDevices)
+		if len(collisions) > 0 {
+			logger.Warn("Video device range collides with non-loopback devices",
+				"range", fmt.Sprintf("/dev/video%d-%d", start, start+config.MaxDevices-1),
+				"collisions", collisions)
+			continue
+		}
+
+		if start != config.VideoDeviceStart {
+			logger.Warn("Using relocated video device range",
+				"configured_start", config.VideoDeviceStart,
+				"chosen_start", start,
+				"range", fmt.Sprintf("/dev/video%d-%d", start, start+config.MaxDevices-1))
+		}
+
+		if err := savePluginState(config.StateDir, &PluginState{VideoDeviceStart: start, MaxDevices: config.MaxDevices}); err != nil {
+			logger.Warn("Failed to record chosen device range", "error", err)
+		}
+		return start, nil
+	}
+
+	return 0, fmt.Errorf("no free range of %d video devices between /dev/video%d and /dev/video%d",
+		config.MaxDevices, config.VideoDeviceStart, config.VideoDeviceCeiling)
+}
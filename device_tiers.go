@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// tierNamePattern restricts tier names to characters valid in resource and socket names
+var tierNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// DeviceTier is a contiguous slice of the video range advertised as its own resource
+// with its own v4l2loopback parameters (e.g. video10-13 "standard", video14-17 "premium")
+type DeviceTier struct {
+	Name           string   `json:"name"`
+	ResourceName   string   `json:"resource_name"`
+	GeneratedName  string   `json:"generated_name,omitempty"` // Generated resource name, set when it was sanitized into ResourceName
+	SocketPath     string   `json:"socket_path"`
+	VideoDeviceIDs []string `json:"video_device_ids"`
+	MaxBuffers     int      `json:"max_buffers"`
+	ExclusiveCaps  int      `json:"exclusive_caps"`
+	CardLabel      string   `json:"card_label"`
+}
+
+// parseDeviceTiers parses DEVICE_TIERS, e.g. "premium:4:max_buffers=4,exclusive_caps=1;lowlat:2"
+// Each tier is name:count[:key=value,...] with keys max_buffers, exclusive_caps, card_label and resource.
+// Tiers take the slots directly below the hot spare and av-bundle slots, in declaration order;
+// the remaining slots at the start of the range stay in the default resource.
+func parseDeviceTiers(config *DevicePluginConfig) ([]DeviceTier, error) {
+	var tiers []DeviceTier
+	total := 0
+	seen := map[string]bool{config.ResourceName: true, config.AVBundleResourceName: true}
+
+	for _, spec := range strings.Split(config.DeviceTiers, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("DEVICE_TIERS entry %q must be name:count[:key=value,...]", spec)
+		}
+		name := parts[0]
+		if !tierNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("DEVICE_TIERS name %q must be lowercase alphanumeric with dashes", name)
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("DEVICE_TIERS count of tier %s must be a positive integer, got %q", name, parts[1])
+		}
+
+		tier := DeviceTier{
+			Name:          name,
+			ResourceName:  config.ResourceName + "-" + name,
+			SocketPath:    filepath.Join(filepath.Dir(config.SocketPath), "video-device-plugin-"+name+".sock"),
+			MaxBuffers:    config.V4L2MaxBuffers,
+			ExclusiveCaps: config.V4L2ExclusiveCaps,
+			CardLabel:     config.V4L2CardLabel,
+		}
+		if len(parts) == 3 {
+			if err := tier.applyParams(parts[2]); err != nil {
+				return nil, err
+			}
+		}
+		// A generated name is adapted to kubelet's naming rules; an explicit one must already follow them
+		if generated := config.ResourceName + "-" + name; tier.ResourceName == generated {
+			if tier.ResourceName = sanitizeResourceName(generated); tier.ResourceName != generated {
+				tier.GeneratedName = generated
+			}
+		} else if err := validateResourceName("DEVICE_TIERS resource of tier "+name, tier.ResourceName); err != nil {
+			return nil, err
+		}
+		if seen[tier.ResourceName] {
+			return nil, fmt.Errorf("DEVICE_TIERS resource %s of tier %s is already in use", tier.ResourceName, name)
+		}
+		seen[tier.ResourceName] = true
+
+		for i := 0; i < count; i++ {
+			tier.VideoDeviceIDs = append(tier.VideoDeviceIDs, strconv.Itoa(i))
+		}
+		total += count
+		tiers = append(tiers, tier)
+	}
+
+	// The default resource keeps at least one slot
+	firstSlot := config.MaxDevices - config.AVBundleCount - config.HotSpareCount - total
+	if len(tiers) > 0 && firstSlot < 1 {
+		return nil, fmt.Errorf("DEVICE_TIERS need %d devices but only %d of MAX_DEVICES (%d) remain after hot spares and av-bundles",
+			total, config.MaxDevices-config.AVBundleCount-config.HotSpareCount-1, config.MaxDevices)
+	}
+	for i := range tiers {
+		for j := range tiers[i].VideoDeviceIDs {
+			tiers[i].VideoDeviceIDs[j] = fmt.Sprintf("video%d", config.VideoDeviceStart+firstSlot)
+			firstSlot++
+		}
+	}
+	return tiers, nil
+}
+
+// applyParams applies the key=value overrides of a tier spec
+func (t *DeviceTier) applyParams(params string) error {
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return fmt.Errorf("DEVICE_TIERS parameter %q of tier %s must be key=value", param, t.Name)
+		}
+		switch key {
+		case "max_buffers":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 2 || n > v4l2loopbackMaxBuffers {
+				return fmt.Errorf("DEVICE_TIERS max_buffers of tier %s must be 2-%d, got %q", t.Name, v4l2loopbackMaxBuffers, value)
+			}
+			t.MaxBuffers = n
+		case "exclusive_caps":
+			if value != "0" && value != "1" {
+				return fmt.Errorf("DEVICE_TIERS exclusive_caps of tier %s must be 0 or 1, got %q", t.Name, value)
+			}
+			t.ExclusiveCaps, _ = strconv.Atoi(value)
+		case "card_label":
+			t.CardLabel = value
+		case "resource":
+			t.ResourceName = value
+		default:
+			return fmt.Errorf("DEVICE_TIERS parameter %q of tier %s is not supported", key, t.Name)
+		}
+	}
+	return nil
+}
+
+// buildDeviceTiers returns the configured tiers; DEVICE_TIERS is validated at startup
+func buildDeviceTiers(config *DevicePluginConfig) []DeviceTier {
+	tiers, _ := parseDeviceTiers(config)
+	return tiers
+}
+
+// tierVideoIDs maps each video device ID served by a tier to its tier
+func tierVideoIDs(config *DevicePluginConfig) map[string]DeviceTier {
+	byDevice := make(map[string]DeviceTier)
+	for _, tier := range buildDeviceTiers(config) {
+		for _, deviceID := range tier.VideoDeviceIDs {
+			byDevice[deviceID] = tier
+		}
+	}
+	return byDevice
+}
+
+// deviceResource returns the resource a video device is advertised through, empty for excluded devices
+func (p *VideoDevicePlugin) deviceResource(deviceID string) string {
+	if p.excluded[deviceID] {
+		return ""
+	}
+	if p.reserved[deviceID] {
+		return p.config.AVBundleResourceName
+	}
+	if tier, ok := p.tiers[deviceID]; ok {
+		return tier.ResourceName
+	}
+	return p.config.ResourceName
+}
+
+// deviceCreateParams returns the card label and exclusive_caps a device is (re)created with
+// A label stamped for the allocation holding the device replaces the configured one
+func (p *VideoDevicePlugin) deviceCreateParams(deviceID string) (string, int) {
+	label, exclusiveCaps := p.config.V4L2CardLabel, p.config.V4L2ExclusiveCaps
+	if tier, ok := p.tiers[deviceID]; ok {
+		label, exclusiveCaps = tier.CardLabel, tier.ExclusiveCaps
+	}
+	if stamped, ok := p.stampedLabel(deviceID); ok {
+		label = stamped
+	}
+	return label, exclusiveCaps
+}
+
+// applyTierParameters recreates tier devices whose max_buffers differs from the module-wide value
+// v4l2loopback takes max_buffers once per module load, so tiers get theirs through v4l2loopback-ctl.
+// Devices already running with an explicit buffer count or held by an allocation are left alone.
+func (p *VideoDevicePlugin) applyTierParameters() {
+	if len(p.tiers) == 0 || p.v4l2Manager.IsFallbackMode() {
+		return
+	}
+
+	allocated := make(map[string]bool)
+	for _, allocation := range p.allocations.List() {
+		allocated[allocation.DeviceID] = true
+	}
+
+	for deviceID, tier := range p.tiers {
+		if tier.MaxBuffers == p.config.V4L2MaxBuffers {
+			continue
+		}
+		device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+		if err != nil || device.MaxBuffers > 0 {
+			continue
+		}
+		if allocated[deviceID] {
+			p.logger.Warn("Tier device is allocated, keeping module-wide max_buffers until it is released",
+				"device_id", deviceID, "tier", tier.Name)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+		err = p.resetDeviceWithContext(ctx, device.Path, tier.MaxBuffers)
+		cancel()
+		if err != nil {
+			p.logger.Error("Failed to apply tier parameters", "device_id", deviceID, "tier", tier.Name, "error", err)
+			continue
+		}
+		if err := p.v4l2Manager.SetMaxBuffers(deviceID, tier.MaxBuffers); err != nil {
+			p.logger.Warn("Failed to record tier max_buffers", "device_id", deviceID, "error", err)
+		}
+		if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+			p.logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+		}
+		p.logger.Info("Applied tier parameters", "device_id", deviceID, "tier", tier.Name, "max_buffers", tier.MaxBuffers)
+	}
+}
+
+// DeviceTierPlugin serves one device tier as its own resource
+// Inventory, health and allocation bookkeeping are shared with the video plugin
+type DeviceTierPlugin struct {
+	pluginapi.UnimplementedDevicePluginServer
+	tier       DeviceTier
+	plugin     *VideoDevicePlugin
+	logger     *slog.Logger
+	server     *grpc.Server
+	listener   net.Listener
+	watcher    *PluginWatcherServer
+	ctx        context.Context // Lifetime of the monitor and ListAndWatch loops, set by Start
+	cancel     context.CancelFunc
+	mu         sync.RWMutex
+	registered bool
+	handedOver bool // Stopped for a socket takeover; the socket path belongs to the new instance
+}
+
+// NewDeviceTierPlugin creates a new DeviceTierPlugin for tier
+func NewDeviceTierPlugin(tier DeviceTier, plugin *VideoDevicePlugin, logger *slog.Logger) *DeviceTierPlugin {
+	return &DeviceTierPlugin{
+		tier:   tier,
+		plugin: plugin,
+		logger: logger.With("resource_name", tier.ResourceName, "tier", tier.Name),
+	}
+}
+
+// Start serves the tier plugin socket and registers it with kubelet
+// Canceling ctx stops its loops like Stop does
+func (t *DeviceTierPlugin) Start(ctx context.Context) error {
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	config := t.plugin.config
+	socketPath := t.tier.SocketPath
+	t.logger.Info("Starting device tier plugin", "socket_path", socketPath, "devices", t.tier.VideoDeviceIDs)
+
+	if err := ensureDirectory(filepath.Dir(socketPath)); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	// A previous instance may still serve the socket during a rolling update
+	if err := waitForStaleSocket(t.ctx, t.plugin.clock, socketPath, time.Duration(config.SocketTakeoverTimeout)*time.Second, t.logger); err != nil {
+		return err
+	}
+
+	t.server = grpc.NewServer(grpcServerOptions(t.plugin.config, t.plugin.settings, t.logger)...)
+	pluginapi.RegisterDevicePluginServer(t.server, t)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on socket: %w", err)
+	}
+	t.listener = listener
+
+	go func() {
+		if err := t.server.Serve(listener); err != nil {
+			t.logger.Error("Device tier gRPC server failed", "error", err)
+		}
+	}()
+
+	if usesWatcherRegistration(config) {
+		t.watcher = NewPluginWatcherServer(config.PluginRegistryDir, t.tier.ResourceName, socketPath, t.setWatcherRegistration, t.logger)
+		if err := t.watcher.Start(); err != nil {
+			t.server.Stop()
+			_ = cleanupSocket(socketPath)
+			return fmt.Errorf("failed to start plugin watcher registration: %w", err)
+		}
+	}
+
+	if usesDirectRegistration(config) {
+		if err := t.register(t.ctx); err != nil {
+			if !usesWatcherRegistration(config) {
+				t.server.Stop()
+				_ = cleanupSocket(socketPath)
+				return err
+			}
+			t.logger.Warn("Direct kubelet registration failed, relying on the plugin watcher", "error", err)
+		}
+		go t.monitorKubeletRestart()
+	}
+	return nil
+}
+
+// Stop shuts down the tier plugin and removes its socket
+func (t *DeviceTierPlugin) Stop() {
+	if t.stop(false) {
+		t.logger.Info("Device tier plugin stopped")
+	}
+}
+
+// handOver stops serving the tier for a socket takeover without removing the socket path,
+// which the new instance re-creates once this one no longer answers on it
+func (t *DeviceTierPlugin) handOver() {
+	if t.stop(true) {
+		t.logger.Info("Device tier plugin stopped, socket left to the new instance")
+	}
+}
+
+// stop tears down the server and the registration; it reports false once the tier was handed over
+func (t *DeviceTierPlugin) stop(handOver bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.handedOver {
+		return false
+	}
+	t.handedOver = handOver
+
+	if t.watcher != nil {
+		t.watcher.Stop()
+	}
+	if t.server != nil {
+		t.server.Stop()
+	}
+	if t.listener != nil {
+		_ = t.listener.Close()
+		t.listener = nil
+	}
+	if !handOver {
+		if err := cleanupSocket(t.tier.SocketPath); err != nil {
+			t.logger.Warn("Failed to cleanup socket", "error", err)
+		}
+	}
+
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return true
+}
+
+// register registers the tier resource with kubelet
+func (t *DeviceTierPlugin) register(ctx context.Context) error {
+	if err := registerResourceWithKubelet(ctx, t.plugin.config.KubeletSocket, t.tier.SocketPath, t.tier.ResourceName, t.logger); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.registered = true
+	t.mu.Unlock()
+	t.logger.Info("Successfully registered device tier resource with kubelet")
+	return nil
+}
+
+// setWatcherRegistration records the registration status reported by the kubelet plugin watcher
+func (t *DeviceTierPlugin) setWatcherRegistration(registered bool, reason string) {
+	t.mu.Lock()
+	t.registered = registered
+	t.mu.Unlock()
+}
+
+// monitorKubeletRestart re-registers once the kubelet socket reappears after a restart
+func (t *DeviceTierPlugin) monitorKubeletRestart() {
+	ticker := t.plugin.clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C():
+			if !checkDeviceExists(t.plugin.config.KubeletSocket) {
+				t.mu.Lock()
+				t.registered = false
+				t.mu.Unlock()
+				continue
+			}
+
+			t.mu.RLock()
+			registered := t.registered
+			t.mu.RUnlock()
+			if !registered {
+				if err := t.register(t.ctx); err != nil {
+					t.logger.Error("Failed to re-register device tier resource with kubelet", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// GetDevicePluginOptions implements the GetDevicePluginOptions gRPC method
+func (t *DeviceTierPlugin) GetDevicePluginOptions(ctx context.Context, req *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+// ListAndWatch implements the ListAndWatch gRPC method
+func (t *DeviceTierPlugin) ListAndWatch(req *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) (err error) {
+	watch := t.plugin.sent.OpenStream(t.tier.ResourceName, stream, t.logger)
+	defer func() { watch.Close(err) }()
+
+	devices, _ := t.plugin.resourceDeviceList(t.tier.ResourceName)
+	response := &pluginapi.ListAndWatchResponse{Devices: devices}
+	if err := watch.Send(response); err != nil {
+		return err
+	}
+
+	ticker := t.plugin.clock.NewTicker(t.plugin.settings.HealthCheckInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C():
+			devices, _ := t.plugin.resourceDeviceList(t.tier.ResourceName)
+			response := &pluginapi.ListAndWatchResponse{Devices: devices}
+			if err := watch.Send(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Allocate implements the Allocate gRPC method
+func (t *DeviceTierPlugin) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	return t.plugin.allocateResource(ctx, t.tier.ResourceName, req)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Where a topology hint came from, reported to consumers as VIDEO_DEVICE_TOPOLOGY_SOURCE
+const (
+	TopologySourceSysfs      = "sysfs"       // numa_node of the device or one of its parents
+	TopologySourceSingleNode = "single-node" // The host has one NUMA node, so all memory is local to it
+	TopologySourceConfig     = "config"      // TOPOLOGY_NUMA_NODE / TOPOLOGY_CPUS
+)
+
+// numaNodesDir lists the host's NUMA nodes and their CPUs
+const numaNodesDir = "/sys/devices/system/node"
+
+// TopologyHint is the NUMA node and CPUs a device's consumers should pin their threads to
+type TopologyHint struct {
+	NUMANode int    // -1 when unknown
+	CPUs     string // Kernel cpulist format (e.g. "0-7,16-23"), empty when unknown
+	Source   string
+}
+
+// deviceTopologyHint resolves the topology hint for a device (best effort)
+// Configured values win; otherwise the NUMA node is read from sysfs and the CPUs from the node's cpulist
+func deviceTopologyHint(config *DevicePluginConfig, device *VideoDevice) (TopologyHint, bool) {
+	hint := TopologyHint{NUMANode: config.TopologyNUMANode, CPUs: config.TopologyCPUs, Source: TopologySourceConfig}
+	if hint.NUMANode < 0 {
+		// v4l2loopback devices are virtual and usually have no numa_node of their own,
+		// but their buffers are always local on a single-node host
+		if node, ok := sysfsNUMANode(device.SysfsPath); ok {
+			hint.NUMANode, hint.Source = node, TopologySourceSysfs
+		} else if nodes := numaNodes(); len(nodes) == 1 {
+			hint.NUMANode, hint.Source = nodes[0], TopologySourceSingleNode
+		}
+	}
+	if hint.CPUs == "" && hint.NUMANode >= 0 {
+		if cpus, err := os.ReadFile(filepath.Join(numaNodesDir, fmt.Sprintf("node%d", hint.NUMANode), "cpulist")); err == nil {
+			hint.CPUs = strings.TrimSpace(string(cpus))
+		}
+	}
+	return hint, hint.NUMANode >= 0 || hint.CPUs != ""
+}
+
+// applyTopologyEnvs adds the topology hint of a device to a container's environment
+func applyTopologyEnvs(envs map[string]string, config *DevicePluginConfig, device *VideoDevice) {
+	hint, ok := deviceTopologyHint(config, device)
+	if !ok {
+		return
+	}
+	if hint.NUMANode >= 0 {
+		envs["VIDEO_DEVICE_NUMA_NODE"] = strconv.Itoa(hint.NUMANode)
+	}
+	if hint.CPUs != "" {
+		envs["VIDEO_DEVICE_CPUS"] = hint.CPUs
+	}
+	envs["VIDEO_DEVICE_TOPOLOGY_SOURCE"] = hint.Source
+}
+
+// sysfsNUMANode walks from a device's sysfs directory up to /sys/devices looking for a numa_node
+func sysfsNUMANode(sysfsPath string) (int, bool) {
+	for dir := sysfsPath; strings.HasPrefix(dir, "/sys/devices/"); dir = filepath.Dir(dir) {
+		data, err := os.ReadFile(filepath.Join(dir, "numa_node"))
+		if err != nil {
+			continue
+		}
+		// -1 means the bus does not know; a parent may
+		if node, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && node >= 0 {
+			return node, true
+		}
+	}
+	return -1, false
+}
+
+// numaNodes returns the host's online NUMA node numbers, empty when they cannot be read
+func numaNodes() []int {
+	data, err := os.ReadFile(filepath.Join(numaNodesDir, "online"))
+	if err != nil {
+		return nil
+	}
+	nodes, err := parseCPUList(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// parseCPUList parses the kernel list format used by cpulist and online ("0-3,8,10-11")
+func parseCPUList(list string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid list entry %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid list range %q", part)
+			}
+		}
+		for value := start; value <= end; value++ {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
@@ -4,44 +4,62 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"syscall"
-
-	"golang.org/x/sys/unix"
 )
 
+// verifiedDevice is one entry of the startup device inventory
+type verifiedDevice struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	UID  int    `json:"uid"`
+	GID  int    `json:"gid"`
+	Rdev string `json:"rdev,omitempty"`
+}
+
 // verifyVideoDevices verifies that video devices were created
+// The inventory is logged as a single summary record; per-device details are logged at debug level
 func verifyVideoDevices(config *DevicePluginConfig, logger *slog.Logger) error {
 	logger.Info("Verifying video devices...")
 
-	deviceCount := 0
-	for i := VideoDeviceStartNumber; i < VideoDeviceStartNumber+config.MaxDevices; i++ {
+	var inventory []verifiedDevice
+	var missing []string
+	for i := config.VideoDeviceStart; i < config.VideoDeviceStart+config.MaxDevices; i++ {
 		devicePath := fmt.Sprintf("/dev/video%d", i)
-		if stat, err := os.Stat(devicePath); err == nil {
-			if (stat.Mode() & os.ModeCharDevice) == 0 {
-				logger.Warn("non-char device at expected path", "path", devicePath, "mode", stat.Mode().String())
-				continue
-			}
-			deviceCount++
-			if st, ok := stat.Sys().(*syscall.Stat_t); ok {
-				maj := unix.Major(uint64(st.Rdev))
-				min := unix.Minor(uint64(st.Rdev))
-				logger.Info("video device",
-					"path", devicePath,
-					"mode", stat.Mode().String(),
-					"uid", st.Uid,
-					"gid", st.Gid,
-					"rdev", fmt.Sprintf("%d,%d", maj, min),
-					"mtime", stat.ModTime())
-			} else {
-				logger.Info("video device", "path", devicePath, "mode", stat.Mode().String())
-			}
+		stat, err := os.Stat(devicePath)
+		if err != nil {
+			missing = append(missing, devicePath)
+			continue
+		}
+		if (stat.Mode() & os.ModeCharDevice) == 0 {
+			logger.Warn("non-char device at expected path", "path", devicePath, "mode", stat.Mode().String())
+			continue
+		}
+
+		device := verifiedDevice{Path: devicePath, Mode: stat.Mode().String()}
+		uid, gid, hasOwner := fileOwner(stat)
+		if maj, min, ok := fileDeviceNumbers(stat); ok && hasOwner {
+			device.UID, device.GID = uid, gid
+			device.Rdev = fmt.Sprintf("%d,%d", maj, min)
+			logger.Debug("video device",
+				"path", devicePath,
+				"mode", device.Mode,
+				"uid", uid,
+				"gid", gid,
+				"rdev", device.Rdev,
+				"mtime", stat.ModTime())
+		} else {
+			logger.Debug("video device", "path", devicePath, "mode", device.Mode)
 		}
+		inventory = append(inventory, device)
 	}
 
-	if deviceCount == 0 {
+	if len(inventory) == 0 {
 		return fmt.Errorf("no video devices found")
 	}
 
-	logger.Info("video devices found", "count", deviceCount, "requested", config.MaxDevices)
+	logger.Info("video devices found",
+		"count", len(inventory),
+		"requested", config.MaxDevices,
+		"missing", missing,
+		"devices", inventory)
 	return nil
 }
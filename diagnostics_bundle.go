@@ -0,0 +1,251 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bounds of the data captured into a diagnostics bundle
+const (
+	recentLogLines   = 1000 // Log records kept in memory for the bundle
+	bundleDmesgLines = 500  // Trailing kernel log lines included
+)
+
+// recentLogs keeps the last log records written by setupLogger's handler
+var recentLogs = &logRing{max: recentLogLines}
+
+// logRing is an io.Writer keeping the last max writes; slog handlers write one record per call
+type logRing struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	max   int
+}
+
+// Write implements io.Writer
+func (r *logRing) Write(p []byte) (int, error) {
+	line := append([]byte(nil), p...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) < r.max {
+		r.lines = append(r.lines, line)
+	} else {
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % r.max
+	}
+	return len(p), nil
+}
+
+// Bytes returns the kept records, oldest first
+func (r *logRing) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var buf bytes.Buffer
+	for i := range r.lines {
+		buf.Write(r.lines[(r.next+i)%len(r.lines)])
+	}
+	return buf.Bytes()
+}
+
+// FatalDiagnostics captures a diagnostics bundle before the plugin exits on a fatal error
+// Nodes are often replaced before anyone looks at them; the bundle on the host path survives
+// the container and carries what a postmortem needs in one file
+type FatalDiagnostics struct {
+	config  *DevicePluginConfig
+	manager V4L2Manager // Nil until the device manager exists
+	logger  *slog.Logger
+}
+
+// NewFatalDiagnostics creates the fatal exit handler of the plugin
+func NewFatalDiagnostics(config *DevicePluginConfig, logger *slog.Logger) *FatalDiagnostics {
+	return &FatalDiagnostics{config: config, logger: logger}
+}
+
+// SetDeviceManager adds the device inventory to bundles written from now on
+func (f *FatalDiagnostics) SetDeviceManager(manager V4L2Manager) {
+	f.manager = manager
+}
+
+// Exit logs the fatal error, writes the diagnostics bundle and exits with status 1
+func (f *FatalDiagnostics) Exit(msg string, err error) {
+	// Attribute the record to the failing call site rather than this helper
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	record := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
+	record.Add("error", err)
+	_ = f.logger.Handler().Handle(context.Background(), record)
+	f.Bundle(msg, err)
+	os.Exit(1)
+}
+
+// Bundle writes the diagnostics bundle of an already logged fatal error and logs its location
+func (f *FatalDiagnostics) Bundle(reason string, err error) {
+	if !f.config.EnableDiagnosticsBundle {
+		return
+	}
+	path, bundleErr := f.writeBundle(reason, err)
+	if bundleErr != nil {
+		f.logger.Warn("Failed to write diagnostics bundle", "error", bundleErr)
+		return
+	}
+	f.logger.Error("Wrote diagnostics bundle for the fatal error", "bundle", path)
+}
+
+// bundleSummary is the summary.json of a bundle
+type bundleSummary struct {
+	Reason        string    `json:"reason"`
+	Error         string    `json:"error,omitempty"`
+	Node          string    `json:"node"`
+	PluginVersion string    `json:"plugin_version"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Classified kernel log signatures when the fatal error is a module load failure
+	ModuleDiagnostics *ModuleDiagnostics `json:"module_diagnostics,omitempty"`
+}
+
+// bundleDevices is the devices.json of a bundle
+type bundleDevices struct {
+	Devices        []*VideoDevice    `json:"devices"`
+	Skipped        map[string]string `json:"skipped,omitempty"`
+	FallbackMode   bool              `json:"fallback_mode"`
+	FallbackReason string            `json:"fallback_reason,omitempty"`
+}
+
+// writeBundle writes the tarball into DIAGNOSTICS_DIR and prunes old bundles
+func (f *FatalDiagnostics) writeBundle(reason string, cause error) (string, error) {
+	if err := ensureDirectory(f.config.DiagnosticsDir); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("video-device-plugin-%s-%s.tar.gz", f.config.NodeName, now.Format("20060102T150405Z"))
+	path := filepath.Join(f.config.DiagnosticsDir, name)
+
+	summary := bundleSummary{Reason: reason, Node: f.config.NodeName, PluginVersion: pluginVersion, CreatedAt: now}
+	if cause != nil {
+		summary.Error = cause.Error()
+	}
+	var moduleErr *ModuleLoadError
+	if errors.As(cause, &moduleErr) {
+		summary.ModuleDiagnostics = moduleErr.Diagnostics
+	}
+
+	entries := []struct {
+		name string
+		data func() ([]byte, error)
+	}{
+		{"summary.json", func() ([]byte, error) { return json.MarshalIndent(summary, "", "  ") }},
+		{"config.json", func() ([]byte, error) { return json.MarshalIndent(effectiveConfig(f.config, nil), "", "  ") }},
+		{"system-info.json", func() ([]byte, error) {
+			return json.MarshalIndent(NewSystemInspector(f.logger).Inspect(), "", "  ")
+		}},
+		{"devices.json", f.deviceInventory},
+		{"dmesg.txt", recentKernelLog},
+		{"goroutines.txt", goroutineDump},
+		{"plugin.log", func() ([]byte, error) { return recentLogs.Bytes(), nil }},
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		// A section that cannot be collected is replaced by its error, the rest is still useful
+		data, err := entry.data()
+		if err != nil {
+			data = []byte(fmt.Sprintf("unavailable: %v\n", err))
+		}
+		header := &tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", entry.name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", entry.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to finish bundle: %w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+	pruneDiagnosticsBundles(f.config.DiagnosticsDir, f.config.DiagnosticsMaxBundles, f.logger)
+	return path, nil
+}
+
+// deviceInventory renders the devices known to the manager
+func (f *FatalDiagnostics) deviceInventory() ([]byte, error) {
+	if f.manager == nil {
+		return nil, fmt.Errorf("device manager not initialized")
+	}
+
+	inventory := bundleDevices{
+		Skipped:        f.manager.GetSkippedDevices(),
+		FallbackMode:   f.manager.IsFallbackMode(),
+		FallbackReason: f.manager.GetFallbackReason(),
+	}
+	for _, device := range f.manager.ListAllDevices() {
+		inventory.Devices = append(inventory.Devices, device)
+	}
+	sort.Slice(inventory.Devices, func(i, j int) bool { return inventory.Devices[i].ID < inventory.Devices[j].ID })
+	return json.MarshalIndent(inventory, "", "  ")
+}
+
+// recentKernelLog returns the trailing kernel log lines
+func recentKernelLog() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "dmesg").Output()
+	if err != nil {
+		return nil, fmt.Errorf("dmesg not available or restricted: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) > bundleDmesgLines {
+		lines = lines[len(lines)-bundleDmesgLines:]
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// goroutineDump returns the stacks of all goroutines
+func goroutineDump() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneDiagnosticsBundles keeps the newest keep bundles so a crash-looping plugin cannot fill the disk
+func pruneDiagnosticsBundles(dir string, keep int, logger *slog.Logger) {
+	bundles, err := filepath.Glob(filepath.Join(dir, "video-device-plugin-*.tar.gz"))
+	if err != nil || len(bundles) <= keep {
+		return
+	}
+
+	// Names embed a sortable UTC timestamp after the node name
+	sort.Strings(bundles)
+	for _, path := range bundles[:len(bundles)-keep] {
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove old diagnostics bundle", "bundle", path, "error", err)
+		}
+	}
+}
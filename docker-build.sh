@@ -30,11 +30,17 @@ FULL_IMAGE_NAME="${DOCKER_REGISTRY}/${IMAGE_NAME}:${IMAGE_TAG}"
 # Kernel version for module compilation (default if not set)
 KERNEL_VERSION="${KERNEL_VERSION:-6.8.0-90-generic}"
 
+# Go build tags (e.g. "minimal" for a binary without the Kubernetes API client)
+BUILD_TAGS="${BUILD_TAGS:-}"
+
 echo "Building Docker image: $FULL_IMAGE_NAME"
 echo "Using kernel version: $KERNEL_VERSION"
+if [ -n "$BUILD_TAGS" ]; then
+    echo "Using build tags: $BUILD_TAGS"
+fi
 
-# Build the image with kernel version build arg
-docker build --build-arg KERNEL_VERSION="$KERNEL_VERSION" --tag "$FULL_IMAGE_NAME" .
+# Build the image with kernel version and build tags build args
+docker build --build-arg KERNEL_VERSION="$KERNEL_VERSION" --build-arg BUILD_TAGS="$BUILD_TAGS" --tag "$FULL_IMAGE_NAME" .
 
 echo "Docker image built successfully"
 
@@ -0,0 +1,181 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// e2eOptions configures an end-to-end run against a live cluster
+type e2eOptions struct {
+	Kubeconfig   string
+	ResourceName string
+	Namespace    string
+	Image        string
+	Timeout      time.Duration
+}
+
+// e2eReport is printed as JSON at the end of an end-to-end run
+type e2eReport struct {
+	Node        string   `json:"node,omitempty"`
+	Allocatable int64    `json:"allocatable"`
+	Pod         string   `json:"pod,omitempty"`
+	Phase       string   `json:"phase,omitempty"`
+	Output      string   `json:"output,omitempty"`
+	Duration    string   `json:"duration"`
+	Violations  []string `json:"violations,omitempty"`
+}
+
+// e2eProbeScript runs in the test pod and fails unless the allocated device is a usable node
+const e2eProbeScript = `set -e
+echo "VIDEO_DEVICE=$VIDEO_DEVICE"
+test -c "$VIDEO_DEVICE"
+exec 3>"$VIDEO_DEVICE"
+echo "opened $VIDEO_DEVICE for writing"
+`
+
+// runE2E implements the "e2e" subcommand: against a cluster running the plugin (e.g. kind,
+// see hack/kind) it waits for the resource to be advertised, schedules a pod requesting one
+// device and checks that VIDEO_DEVICE is a character device the pod can open
+func runE2E(args []string) int {
+	opts := e2eOptions{}
+	fs := flag.NewFlagSet("e2e", flag.ContinueOnError)
+	fs.StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the test cluster (default: $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&opts.ResourceName, "resource", "meeting-baas.io/video-devices", "extended resource the test pod requests")
+	fs.StringVar(&opts.Namespace, "namespace", "default", "namespace of the test pod")
+	fs.StringVar(&opts.Image, "image", "busybox:1.36", "image of the test pod")
+	fs.DurationVar(&opts.Timeout, "timeout", 5*time.Minute, "fail if the run takes longer than this")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	report, err := e2e(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if len(report.Violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// e2e waits for an advertised device, then runs the probe pod and collects its result
+func e2e(ctx context.Context, opts e2eOptions) (*e2eReport, error) {
+	start := time.Now()
+	report := &e2eReport{}
+	defer func() { report.Duration = time.Since(start).Round(time.Millisecond).String() }()
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		rules.ExplicitPath = opts.Kubeconfig
+	}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	// The plugin must have registered and advertised at least one device
+	resourceName := corev1.ResourceName(opts.ResourceName)
+	for {
+		nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range nodes.Items {
+			if quantity, ok := node.Status.Allocatable[resourceName]; ok && quantity.Value() > 0 {
+				report.Node, report.Allocatable = node.Name, quantity.Value()
+				break
+			}
+		}
+		if report.Allocatable > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			report.Violations = append(report.Violations, fmt.Sprintf("no node advertises %s", opts.ResourceName))
+			return report, nil
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	one := resource.MustParse("1")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "video-device-e2e-", Namespace: opts.Namespace},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:    "probe",
+				Image:   opts.Image,
+				Command: []string{"/bin/sh", "-c", e2eProbeScript},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{resourceName: one},
+					Limits:   corev1.ResourceList{resourceName: one},
+				},
+			}},
+		},
+	}
+	pod, err = clientset.CoreV1().Pods(opts.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test pod: %w", err)
+	}
+	report.Pod = pod.Name
+	defer func() {
+		// Cleanup must not be skipped when the run timed out
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = clientset.CoreV1().Pods(opts.Namespace).Delete(cleanupCtx, pod.Name, metav1.DeleteOptions{})
+	}()
+
+	for {
+		current, err := clientset.CoreV1().Pods(opts.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to get test pod: %w", err)
+		}
+		if err == nil {
+			report.Phase = string(current.Status.Phase)
+			if current.Status.Phase == corev1.PodSucceeded || current.Status.Phase == corev1.PodFailed {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			report.Violations = append(report.Violations, fmt.Sprintf("test pod did not finish, last phase %q", report.Phase))
+			return report, nil
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	logs, err := clientset.CoreV1().Pods(opts.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw(ctx)
+	if err == nil {
+		report.Output = strings.TrimSpace(string(logs))
+	}
+	if report.Phase != string(corev1.PodSucceeded) {
+		report.Violations = append(report.Violations, "VIDEO_DEVICE is not a usable device in the test pod")
+	}
+	return report, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Sources a configuration value can come from, in increasing precedence
+const (
+	ConfigSourceDefault = "default" // Built-in default or derived value
+	ConfigSourceFile    = "file"    // The .env file in the working directory
+	ConfigSourceEnv     = "env"     // Process environment
+	ConfigSourceMounted = "mounted" // File named by <KEY>_FILE (e.g. a Secret volume)
+	ConfigSourceFlag    = "flag"    // Command-line flag or --feature-gates
+	ConfigSourceRuntime = "runtime" // Dynamic-settings ConfigMap override
+	ConfigSourceStatus  = "status"  // Set by the plugin itself (e.g. fallback mode)
+)
+
+// redactedValue replaces the value of secret settings
+const redactedValue = "<redacted>"
+
+// envFileKeys are the variables loadEnvFile took from the .env file rather than the environment
+var envFileKeys = map[string]bool{}
+
+// secretKeyMarkers mark settings whose values are never rendered
+var secretKeyMarkers = []string{"TOKEN", "PASSWORD", "SECRET", "CREDENTIAL", "PRIVATE_KEY", "HEADERS"}
+
+// EffectiveSetting is one resolved configuration value and where it came from
+type EffectiveSetting struct {
+	Key    string `json:"key"` // Environment variable name
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveConfig renders the resolved configuration, secrets redacted
+// settings may be nil when no plugin is running (config print)
+func effectiveConfig(config *DevicePluginConfig, settings *RuntimeSettings) []EffectiveSetting {
+	var overrides map[string]any
+	if settings != nil {
+		overrides = settings.Snapshot()
+	}
+
+	value := reflect.ValueOf(config).Elem()
+	fields := value.Type()
+	result := make([]EffectiveSetting, 0, fields.NumField())
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := strings.ToUpper(name)
+		setting := EffectiveSetting{Key: key, Value: value.Field(i).Interface(), Source: ConfigSourceDefault}
+
+		switch {
+		case key == "FALLBACK_MODE_REASON":
+			setting.Source = ConfigSourceStatus
+		case flagKeys[key]:
+			setting.Source = ConfigSourceFlag
+		case envFileKeys[key]:
+			setting.Source = ConfigSourceFile
+		case mountedFileKeys[key] != "":
+			setting.Source = ConfigSourceMounted
+		case os.Getenv(key) != "":
+			setting.Source = ConfigSourceEnv
+		}
+		if override, ok := overrides[name]; ok && override != setting.Value {
+			setting.Value, setting.Source = override, ConfigSourceRuntime
+		}
+		if isSecretSetting(key) && !value.Field(i).IsZero() {
+			setting.Value = redactedValue
+		}
+		result = append(result, setting)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// isSecretSetting reports whether a setting holds a credential
+func isSecretSetting(key string) bool {
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleConfig returns the effective configuration including runtime overrides
+func (a *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, effectiveConfig(a.config, a.plugin.settings))
+}
+
+// runConfig implements the "config" subcommand; "config print" renders the configuration this
+// environment resolves to. Runtime overrides are only known to a running plugin (GET /v1/config)
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: video-device-plugin config print [-format env|json]")
+		return 2
+	}
+
+	config := loadConfig()
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := fs.String("format", "env", "output format: env or json")
+	registerFeatureFlags(fs, config)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := validateConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: configuration is invalid: %v\n", err)
+	}
+	settings := effectiveConfig(config, nil)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(settings)
+	case "env":
+		for _, setting := range settings {
+			fmt.Printf("%s=%v # %s\n", setting.Key, setting.Value, setting.Source)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "config print: unknown format %q\n", *format)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// featureGate is a boolean feature that can be toggled through its environment variable, its own
+// --<name> flag or the aggregate --feature-gates flag
+type featureGate struct {
+	Gate  string // Name in --feature-gates (e.g. Metrics)
+	Key   string // Environment variable, also the flag name in lower kebab case
+	field func(config *DevicePluginConfig) *bool
+}
+
+// featureGates are the toggles surfaced as flags, in --help order
+var featureGates = []featureGate{
+	{"Metrics", "ENABLE_METRICS", func(c *DevicePluginConfig) *bool { return &c.EnableMetrics }},
+	{"AdminAPI", "ENABLE_ADMIN_API", func(c *DevicePluginConfig) *bool { return &c.EnableAdminAPI }},
+	{"FallbackMode", "ENABLE_FALLBACK_MODE", func(c *DevicePluginConfig) *bool { return &c.EnableFallbackMode }},
+	{"ModuleManagement", "MANAGE_MODULE", func(c *DevicePluginConfig) *bool { return &c.ManageModule }},
+	{"KeepModuleOnExit", "KEEP_MODULE_ON_EXIT", func(c *DevicePluginConfig) *bool { return &c.KeepModuleOnExit }},
+	{"Checkpoint", "ENABLE_CHECKPOINT", func(c *DevicePluginConfig) *bool { return &c.EnableCheckpoint }},
+	{"BufferRecovery", "ENABLE_BUFFER_RECOVERY", func(c *DevicePluginConfig) *bool { return &c.EnableBufferRecovery }},
+	{"UdevRules", "ENABLE_UDEV_RULES", func(c *DevicePluginConfig) *bool { return &c.EnableUdevRules }},
+	{"SysfsMount", "ENABLE_SYSFS_MOUNT", func(c *DevicePluginConfig) *bool { return &c.EnableSysfsMount }},
+	{"LabelStamping", "ENABLE_LABEL_STAMPING", func(c *DevicePluginConfig) *bool { return &c.EnableLabelStamping }},
+	{"PreformatDevices", "PREFORMAT_DEVICES", func(c *DevicePluginConfig) *bool { return &c.PreformatDevices }},
+	{"WarmupProducer", "ENABLE_WARMUP_PRODUCER", func(c *DevicePluginConfig) *bool { return &c.EnableWarmupProducer }},
+	{"DeviceIDRotation", "ENABLE_DEVICE_ID_ROTATION", func(c *DevicePluginConfig) *bool { return &c.EnableDeviceIDRotation }},
+	{"KubernetesAPI", "ENABLE_KUBERNETES_API", func(c *DevicePluginConfig) *bool { return &c.EnableKubernetesAPI }},
+	{"NodeCondition", "ENABLE_NODE_CONDITION", func(c *DevicePluginConfig) *bool { return &c.EnableNodeCondition }},
+	{"Events", "ENABLE_EVENTS", func(c *DevicePluginConfig) *bool { return &c.EnableEvents }},
+	{"PodWatch", "ENABLE_POD_WATCH", func(c *DevicePluginConfig) *bool { return &c.EnablePodWatch }},
+	{"ExhaustionWatch", "ENABLE_EXHAUSTION_WATCH", func(c *DevicePluginConfig) *bool { return &c.EnableExhaustionWatch }},
+	{"DeviceAffinity", "ENABLE_DEVICE_AFFINITY", func(c *DevicePluginConfig) *bool { return &c.EnableDeviceAffinity }},
+	{"SystemdNotify", "ENABLE_SYSTEMD_NOTIFY", func(c *DevicePluginConfig) *bool { return &c.EnableSystemdNotify }},
+	{"DevCheck", "ENABLE_DEV_CHECK", func(c *DevicePluginConfig) *bool { return &c.EnableDevCheck }},
+	{"SocketTakeover", "ENABLE_SOCKET_TAKEOVER", func(c *DevicePluginConfig) *bool { return &c.EnableSocketTakeover }},
+	{"LivenessLease", "ENABLE_LIVENESS_LEASE", func(c *DevicePluginConfig) *bool { return &c.EnableLivenessLease }},
+	{"DiagnosticsBundle", "ENABLE_DIAGNOSTICS_BUNDLE", func(c *DevicePluginConfig) *bool { return &c.EnableDiagnosticsBundle }},
+}
+
+// flagKeys are the settings set on the command line, reported with the flag source
+var flagKeys = map[string]bool{}
+
+// flagSet reports whether a flag of the default command line was given
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// flagName returns the command-line flag of a setting (ENABLE_METRICS -> enable-metrics)
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// registerFeatureFlags adds a bool flag per feature gate and the aggregate --feature-gates flag
+// Defaults come from the environment; flags apply in command-line order, so the last one wins
+func registerFeatureFlags(fs *flag.FlagSet, config *DevicePluginConfig) {
+	for _, gate := range featureGates {
+		fs.Var(&featureFlag{gate: gate, config: config}, flagName(gate.Key),
+			fmt.Sprintf("enable the %s feature (env %s)", gate.Gate, gate.Key))
+	}
+	fs.Func("feature-gates", "comma-separated Feature=true|false pairs; features: "+featureGateNames(), func(value string) error {
+		return applyFeatureGates(config, value)
+	})
+}
+
+// applyFeatureGates applies a --feature-gates value such as "Metrics=true,AdminAPI=false"
+func applyFeatureGates(config *DevicePluginConfig, value string) error {
+	gates := make(map[string]featureGate, len(featureGates))
+	for _, gate := range featureGates {
+		gates[strings.ToLower(gate.Gate)] = gate
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("feature gate %q must be Feature=true|false", entry)
+		}
+		gate, known := gates[strings.ToLower(strings.TrimSpace(name))]
+		if !known {
+			return fmt.Errorf("unknown feature gate %q (known: %s)", name, featureGateNames())
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("feature gate %s must be true or false, got %q", gate.Gate, raw)
+		}
+		*gate.field(config) = enabled
+		flagKeys[gate.Key] = true
+	}
+	return nil
+}
+
+// featureGateNames lists the gate names for usage and error messages
+func featureGateNames() string {
+	names := make([]string, 0, len(featureGates))
+	for _, gate := range featureGates {
+		names = append(names, gate.Gate)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// featureFlag is the flag.Value of one feature gate's own flag
+type featureFlag struct {
+	gate   featureGate
+	config *DevicePluginConfig
+}
+
+// String implements flag.Value
+func (f *featureFlag) String() string {
+	if f.config == nil {
+		return ""
+	}
+	return strconv.FormatBool(*f.gate.field(f.config))
+}
+
+// Set implements flag.Value
+func (f *featureFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*f.gate.field(f.config) = enabled
+	flagKeys[f.gate.Key] = true
+	return nil
+}
+
+// IsBoolFlag lets --enable-metrics be given without a value
+func (f *featureFlag) IsBoolFlag() bool { return true }
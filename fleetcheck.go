@@ -0,0 +1,264 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// fleetcheckOptions configures a fleet validation run against a live cluster
+type fleetcheckOptions struct {
+	Kubeconfig    string
+	ResourceName  string
+	Namespace     string
+	Selector      string
+	ProbePort     int
+	ConditionType string
+	ExpectVersion string
+	Timeout       time.Duration
+}
+
+// fleetNode is one plugin instance as seen from the API server and its probe endpoints
+type fleetNode struct {
+	Node             string   `json:"node"`
+	Ready            bool     `json:"ready"`
+	Pod              string   `json:"pod,omitempty"`
+	PodPhase         string   `json:"pod_phase,omitempty"`
+	Version          string   `json:"version,omitempty"`
+	SettingsSchema   string   `json:"settings_schema,omitempty"`
+	Capacity         int64    `json:"capacity"`
+	Allocatable      int64    `json:"allocatable"`
+	Unhealthy        int64    `json:"unhealthy"`
+	Fallback         bool     `json:"fallback"`
+	FallbackReason   string   `json:"fallback_reason,omitempty"`
+	UnhealthyDevices []string `json:"unhealthy_devices,omitempty"`
+	ProbeError       string   `json:"probe_error,omitempty"`
+}
+
+// fleetReport is printed as JSON at the end of a fleetcheck run
+type fleetReport struct {
+	Resource       string            `json:"resource"`
+	Versions       map[string]int    `json:"versions"`
+	Capacity       int64             `json:"capacity"`
+	Allocatable    int64             `json:"allocatable"`
+	Unhealthy      int64             `json:"unhealthy"`
+	FallbackNodes  []string          `json:"fallback_nodes,omitempty"`
+	UnhealthyNodes map[string]string `json:"unhealthy_nodes,omitempty"` // node -> unhealthy devices
+	Nodes          []fleetNode       `json:"nodes"`
+	Duration       string            `json:"duration"`
+	Violations     []string          `json:"violations,omitempty"`
+}
+
+// runFleetcheck implements the "fleetcheck" subcommand: it collects every plugin instance's
+// version, capacity, fallback state and unhealthy devices from node status and, with a probe
+// port, from each pod's /healthz and /readyz through the API server, and fails on anything
+// that should block a release
+func runFleetcheck(args []string) int {
+	opts := fleetcheckOptions{}
+	fs := flag.NewFlagSet("fleetcheck", flag.ContinueOnError)
+	fs.StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the cluster (default: $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&opts.ResourceName, "resource", "meeting-baas.io/video-devices", "extended resource advertised by the plugin")
+	fs.StringVar(&opts.Namespace, "namespace", "kube-system", "namespace of the plugin DaemonSet")
+	fs.StringVar(&opts.Selector, "selector", "name=video-device-plugin", "label selector of the plugin pods")
+	fs.IntVar(&opts.ProbePort, "probe-port", 0, "PROBE_PORT of the plugin pods; 0 skips the health endpoints")
+	fs.StringVar(&opts.ConditionType, "condition", "VideoDevicesReady", "NODE_CONDITION_TYPE of the plugin (read when the plugin sets it)")
+	fs.StringVar(&opts.ExpectVersion, "expect-version", "", "fail unless every instance runs this version (default: fail on mixed versions)")
+	fs.DurationVar(&opts.Timeout, "timeout", time.Minute, "fail if the run takes longer than this")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	report, err := fleetcheck(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fleetcheck: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if len(report.Violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fleetcheck builds the consolidated report of all plugin instances
+func fleetcheck(ctx context.Context, opts fleetcheckOptions) (*fleetReport, error) {
+	start := time.Now()
+	report := &fleetReport{Resource: opts.ResourceName, Versions: make(map[string]int)}
+	defer func() { report.Duration = time.Since(start).Round(time.Millisecond).String() }()
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		rules.ExplicitPath = opts.Kubeconfig
+	}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := clientset.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: opts.Selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugin pods: %w", err)
+	}
+	podsByNode := make(map[string]*corev1.Pod)
+	for i := range pods.Items {
+		if pod := &pods.Items[i]; pod.Spec.NodeName != "" {
+			podsByNode[pod.Spec.NodeName] = pod
+		}
+	}
+
+	// A node belongs to the fleet when it runs a plugin pod or advertises the resource
+	resourceName := corev1.ResourceName(opts.ResourceName)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		capacity, advertised := node.Status.Capacity[resourceName]
+		pod := podsByNode[node.Name]
+		if !advertised && pod == nil {
+			continue
+		}
+		allocatable := node.Status.Allocatable[resourceName]
+
+		entry := fleetNode{
+			Node:           node.Name,
+			Ready:          nodeReady(node),
+			Version:        node.Annotations[PluginVersionAnnotation],
+			SettingsSchema: node.Annotations[SettingsSchemaAnnotation],
+			Capacity:       capacity.Value(),
+			Allocatable:    allocatable.Value(),
+			Unhealthy:      capacity.Value() - allocatable.Value(),
+		}
+		for _, condition := range node.Status.Conditions {
+			if string(condition.Type) == opts.ConditionType && condition.Reason == "FallbackMode" {
+				entry.Fallback, entry.FallbackReason = true, condition.Message
+			}
+		}
+		if pod != nil {
+			entry.Pod, entry.PodPhase = pod.Namespace+"/"+pod.Name, string(pod.Status.Phase)
+			if opts.ProbePort > 0 && pod.Status.Phase == corev1.PodRunning {
+				if err := probeFleetPod(ctx, clientset, pod, opts.ProbePort, &entry); err != nil {
+					entry.ProbeError = err.Error()
+				}
+			}
+		}
+		report.Nodes = append(report.Nodes, entry)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool { return report.Nodes[i].Node < report.Nodes[j].Node })
+
+	for _, entry := range report.Nodes {
+		version := entry.Version
+		if version == "" {
+			version = "unknown"
+		}
+		report.Versions[version]++
+		report.Capacity += entry.Capacity
+		report.Allocatable += entry.Allocatable
+		report.Unhealthy += entry.Unhealthy
+		if entry.Fallback {
+			report.FallbackNodes = append(report.FallbackNodes, entry.Node)
+		}
+		if len(entry.UnhealthyDevices) > 0 {
+			if report.UnhealthyNodes == nil {
+				report.UnhealthyNodes = make(map[string]string)
+			}
+			report.UnhealthyNodes[entry.Node] = strings.Join(entry.UnhealthyDevices, ",")
+		}
+	}
+	report.Violations = fleetViolations(report, opts)
+	return report, nil
+}
+
+// probeFleetPod reads a plugin pod's verbose health and readiness through the API server proxy
+func probeFleetPod(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, port int, entry *fleetNode) error {
+	pods := clientset.CoreV1().Pods(pod.Namespace)
+
+	body, err := pods.ProxyGet("http", pod.Name, fmt.Sprint(port), "/healthz", map[string]string{"verbose": "1"}).DoRaw(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read /healthz: %w", err)
+	}
+	var health HealthCheck
+	if err := json.Unmarshal(body, &health); err != nil {
+		return fmt.Errorf("failed to decode /healthz: %w", err)
+	}
+	for _, device := range health.Devices {
+		if !device.Healthy && device.Role != DeviceRoleExcluded {
+			entry.UnhealthyDevices = append(entry.UnhealthyDevices, device.ID)
+		}
+	}
+
+	// /readyz answers 503 when not ready; its body still carries the reason
+	body, err = pods.ProxyGet("http", pod.Name, fmt.Sprint(port), "/readyz", nil).DoRaw(ctx)
+	var readiness readinessResponse
+	if jsonErr := json.Unmarshal(body, &readiness); jsonErr != nil {
+		if err != nil {
+			return fmt.Errorf("failed to read /readyz: %w", err)
+		}
+		return fmt.Errorf("failed to decode /readyz: %w", jsonErr)
+	}
+	if readiness.Reason == "FallbackMode" {
+		entry.Fallback, entry.FallbackReason = true, readiness.Message
+	}
+	return nil
+}
+
+// fleetViolations lists everything in the report that should block a release
+func fleetViolations(report *fleetReport, opts fleetcheckOptions) []string {
+	var violations []string
+	if len(report.Nodes) == 0 {
+		return []string{fmt.Sprintf("no node runs the plugin or advertises %s", opts.ResourceName)}
+	}
+	if opts.ExpectVersion != "" {
+		for _, entry := range report.Nodes {
+			if entry.Version != opts.ExpectVersion {
+				violations = append(violations, fmt.Sprintf("%s runs version %q, expected %q", entry.Node, entry.Version, opts.ExpectVersion))
+			}
+		}
+	} else if len(report.Versions) > 1 {
+		violations = append(violations, fmt.Sprintf("mixed plugin versions: %v", report.Versions))
+	}
+	for _, entry := range report.Nodes {
+		switch {
+		case entry.Pod == "":
+			violations = append(violations, fmt.Sprintf("%s advertises %s but runs no plugin pod", entry.Node, opts.ResourceName))
+		case entry.PodPhase != string(corev1.PodRunning):
+			violations = append(violations, fmt.Sprintf("%s plugin pod is %s", entry.Node, entry.PodPhase))
+		case entry.Capacity == 0:
+			violations = append(violations, fmt.Sprintf("%s runs the plugin but advertises no %s", entry.Node, opts.ResourceName))
+		}
+		if entry.Fallback {
+			violations = append(violations, fmt.Sprintf("%s is in fallback mode: %s", entry.Node, entry.FallbackReason))
+		}
+		if entry.Unhealthy > 0 {
+			violations = append(violations, fmt.Sprintf("%s has %d unhealthy devices", entry.Node, entry.Unhealthy))
+		}
+		if entry.ProbeError != "" {
+			violations = append(violations, fmt.Sprintf("%s health endpoints: %s", entry.Node, entry.ProbeError))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc"
+)
+
+// grpcServerOptions returns the options of the device plugin gRPC servers (main, tiers and av-bundles)
+// Size and stream limits left at 0 keep the gRPC defaults (4 MiB receive, unlimited send and streams)
+func grpcServerOptions(config *DevicePluginConfig, settings *RuntimeSettings, logger *slog.Logger) []grpc.ServerOption {
+	options := grpcTraceOptions(settings, logger)
+	if config.GRPCMaxRecvMsgSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(config.GRPCMaxRecvMsgSize))
+	}
+	if config.GRPCMaxSendMsgSize > 0 {
+		options = append(options, grpc.MaxSendMsgSize(config.GRPCMaxSendMsgSize))
+	}
+	if config.GRPCMaxConcurrentStreams > 0 {
+		options = append(options, grpc.MaxConcurrentStreams(uint32(config.GRPCMaxConcurrentStreams)))
+	}
+	if config.GRPCWriteBufferSize > 0 {
+		options = append(options, grpc.WriteBufferSize(config.GRPCWriteBufferSize))
+	}
+	return options
+}
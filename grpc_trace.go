@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// grpcTraceOptions returns server options installing the request/response trace interceptors
+// Tracing is logged at debug level and only while the grpc_trace runtime setting is on
+func grpcTraceOptions(settings *RuntimeSettings, logger *slog.Logger) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryTraceInterceptor(settings, logger)),
+		grpc.ChainStreamInterceptor(streamTraceInterceptor(settings, logger)),
+	}
+}
+
+// unaryTraceInterceptor logs method, peer, duration and request/response summaries of unary calls
+func unaryTraceInterceptor(settings *RuntimeSettings, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !settings.GRPCTrace() {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := []any{
+			"method", info.FullMethod,
+			"peer", grpcPeer(ctx),
+			"duration", time.Since(start).String(),
+			"request", summarizeGRPCMessage(req),
+		}
+		if err != nil {
+			attrs = append(attrs, "code", status.Code(err).String(), "error", err)
+		} else {
+			attrs = append(attrs, "response", summarizeGRPCMessage(resp))
+		}
+		logger.Debug("gRPC call", attrs...)
+		return resp, err
+	}
+}
+
+// streamTraceInterceptor logs stream lifetimes and a summary of every message sent
+func streamTraceInterceptor(settings *RuntimeSettings, logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !settings.GRPCTrace() {
+			return handler(srv, &tracedServerStream{ServerStream: stream, settings: settings})
+		}
+
+		start := time.Now()
+		streamLogger := logger.With("method", info.FullMethod, "peer", grpcPeer(stream.Context()))
+		streamLogger.Debug("gRPC stream opened")
+
+		err := handler(srv, &tracedServerStream{ServerStream: stream, settings: settings, logger: streamLogger})
+
+		attrs := []any{"duration", time.Since(start).String()}
+		if err != nil {
+			attrs = append(attrs, "code", status.Code(err).String(), "error", err)
+		}
+		streamLogger.Debug("gRPC stream closed", attrs...)
+		return err
+	}
+}
+
+// tracedServerStream logs each sent message while tracing is on
+// Long-lived streams (ListAndWatch) pick up runtime toggles on their next send
+type tracedServerStream struct {
+	grpc.ServerStream
+	settings *RuntimeSettings
+	logger   *slog.Logger
+	sent     int
+}
+
+// SendMsg implements grpc.ServerStream
+func (s *tracedServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	s.sent++
+	if s.logger != nil && s.settings.GRPCTrace() {
+		s.logger.Debug("gRPC stream send", "sequence", s.sent, "message", summarizeGRPCMessage(m), "error", err)
+	}
+	return err
+}
+
+// grpcPeer returns the remote address of a call
+func grpcPeer(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil && p.Addr.String() != "" {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// summarizeGRPCMessage reduces device plugin messages to IDs, counts and env/annotation keys
+// Env and annotation values are left out so traces never carry workload configuration
+func summarizeGRPCMessage(m any) any {
+	switch msg := m.(type) {
+	case *pluginapi.AllocateRequest:
+		requests := make([][]string, 0, len(msg.ContainerRequests))
+		for _, req := range msg.ContainerRequests {
+			requests = append(requests, req.DevicesIDs)
+		}
+		return map[string]any{"container_device_ids": requests}
+	case *pluginapi.AllocateResponse:
+		containers := make([]map[string]any, 0, len(msg.ContainerResponses))
+		for _, resp := range msg.ContainerResponses {
+			paths := make([]string, 0, len(resp.Devices))
+			for _, device := range resp.Devices {
+				paths = append(paths, device.HostPath)
+			}
+			containers = append(containers, map[string]any{
+				"devices":     paths,
+				"env":         sortedKeys(resp.Envs),
+				"annotations": sortedKeys(resp.Annotations),
+			})
+		}
+		return map[string]any{"containers": containers}
+	case *pluginapi.PreStartContainerRequest:
+		return map[string]any{"device_ids": msg.DevicesIDs}
+	case *pluginapi.PreferredAllocationRequest:
+		requests := make([]map[string]any, 0, len(msg.ContainerRequests))
+		for _, req := range msg.ContainerRequests {
+			requests = append(requests, map[string]any{
+				"available":    len(req.AvailableDeviceIDs),
+				"must_include": req.MustIncludeDeviceIDs,
+				"size":         req.AllocationSize,
+			})
+		}
+		return map[string]any{"containers": requests}
+	case *pluginapi.PreferredAllocationResponse:
+		preferred := make([][]string, 0, len(msg.ContainerResponses))
+		for _, resp := range msg.ContainerResponses {
+			preferred = append(preferred, resp.DeviceIDs)
+		}
+		return map[string]any{"preferred": preferred}
+	case *pluginapi.ListAndWatchResponse:
+		healthy := 0
+		for _, device := range msg.Devices {
+			if device.Health == pluginapi.Healthy {
+				healthy++
+			}
+		}
+		return map[string]any{"devices": len(msg.Devices), "healthy": healthy}
+	case nil:
+		return nil
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
+
+// sortedKeys returns the keys of a string map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// handoffVersion is bumped on incompatible layout changes; new fields are added without a bump
+const handoffVersion = 1
+
+// DeviceHandoff is the metadata file mounted into an allocated container
+// Bots read it at startup instead of deriving everything from VIDEO_DEVICE
+type DeviceHandoff struct {
+	Version      int             `json:"version"`
+	AllocationID string          `json:"allocation_id"`
+	AllocatedAt  time.Time       `json:"allocated_at"`
+	FallbackMode bool            `json:"fallback_mode"` // Devices are placeholders, not real loopback devices
+	Devices      []HandoffDevice `json:"devices"`
+}
+
+// HandoffDevice describes one allocated device
+type HandoffDevice struct {
+	ID            string `json:"id"`
+	Path          string `json:"path"`
+	CardLabel     string `json:"card_label,omitempty"`
+	Driver        string `json:"driver,omitempty"`
+	Generation    int    `json:"generation"`
+	MaxBuffers    int    `json:"max_buffers"`
+	ExclusiveCaps int    `json:"exclusive_caps"`
+	FormatProfile string `json:"format_profile"`
+}
+
+// handoffFileName returns the host file name of a container's handoff file
+// The correlation ID is shared by the containers of one Allocate call, the device ID is not
+func handoffFileName(correlationID, deviceID string) string {
+	return fmt.Sprintf("%s-%s.json", correlationID, deviceID)
+}
+
+// writeHandoff writes the handoff file of an allocation and returns the mount exposing it
+func (p *VideoDevicePlugin) writeHandoff(device *VideoDevice, correlationID string) (*pluginapi.Mount, error) {
+	handoff := DeviceHandoff{
+		Version:      handoffVersion,
+		AllocationID: correlationID,
+		AllocatedAt:  time.Now(),
+		FallbackMode: p.v4l2Manager.IsFallbackMode(),
+		Devices: []HandoffDevice{{
+			ID:            device.ID,
+			Path:          device.Path,
+			CardLabel:     device.CardLabel,
+			Driver:        device.Driver,
+			Generation:    device.Generation,
+			MaxBuffers:    p.deviceMaxBuffers(device),
+			ExclusiveCaps: p.config.V4L2ExclusiveCaps,
+			FormatProfile: deviceFormatProfile(p.deviceMaxBuffers(device), p.config.V4L2ExclusiveCaps),
+		}},
+	}
+
+	data, err := json.MarshalIndent(handoff, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal handoff: %w", err)
+	}
+	if err := ensureDirectory(p.config.HandoffDir); err != nil {
+		return nil, fmt.Errorf("failed to create handoff directory: %w", err)
+	}
+
+	path := filepath.Join(p.config.HandoffDir, handoffFileName(correlationID, device.ID))
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write handoff: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to install handoff: %w", err)
+	}
+
+	return &pluginapi.Mount{
+		ContainerPath: p.config.HandoffContainerPath,
+		HostPath:      path,
+		ReadOnly:      true,
+	}, nil
+}
+
+// extraHandoffPath returns where the handoff of a container's second and later devices is mounted
+// (handoff.json becomes handoff-video12.json)
+func extraHandoffPath(containerPath, deviceID string) string {
+	ext := filepath.Ext(containerPath)
+	return strings.TrimSuffix(containerPath, ext) + "-" + deviceID + ext
+}
+
+// removeHandoff deletes the handoff file of a released allocation
+func (p *VideoDevicePlugin) removeHandoff(correlationID, deviceID string) {
+	if p.config.HandoffDir == "" || correlationID == "" {
+		return
+	}
+	path := filepath.Join(p.config.HandoffDir, handoffFileName(correlationID, deviceID))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		p.logger.Warn("Failed to remove handoff file", "path", path, "error", err)
+	}
+}
+
+// pruneHandoffs removes handoff files of allocations that are no longer tracked
+func (p *VideoDevicePlugin) pruneHandoffs() {
+	if p.config.HandoffDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(p.config.HandoffDir)
+	if err != nil {
+		return
+	}
+
+	live := make(map[string]bool)
+	for _, allocation := range p.allocations.List() {
+		live[handoffFileName(allocation.CorrelationID, allocation.DeviceID)] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") || live[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(p.config.HandoffDir, name)); err == nil {
+			p.logger.Debug("Removed stale handoff file", "file", name)
+		}
+	}
+}
+
+// sysfsMount returns a read-only mount of the device's video4linux sysfs directory
+// The class entry is a symlink, so the resolved /sys/devices path is mounted
+func (p *VideoDevicePlugin) sysfsMount(device *VideoDevice) (*pluginapi.Mount, error) {
+	name := filepath.Base(device.Path)
+	hostPath, err := filepath.EvalSymlinks(filepath.Join("/sys/class/video4linux", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sysfs directory of %s: %w", name, err)
+	}
+	return &pluginapi.Mount{
+		ContainerPath: filepath.Join(p.config.SysfsContainerPath, name),
+		HostPath:      hostPath,
+		ReadOnly:      true,
+	}, nil
+}
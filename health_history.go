@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+)
+
+// HealthTransition is one change of a device's observed health
+type HealthTransition struct {
+	Healthy bool      `json:"healthy"`
+	At      time.Time `json:"at"`
+}
+
+// DeviceHealthHistory is the recorded health history of one device
+type DeviceHealthHistory struct {
+	Transitions []HealthTransition `json:"transitions"`
+	Flapping    bool               `json:"flapping"`
+	DampedUntil *time.Time         `json:"damped_until,omitempty"`
+}
+
+// deviceHealthState is the bookkeeping of one device inside HealthHistory
+type deviceHealthState struct {
+	observed    bool
+	healthy     bool
+	transitions []HealthTransition
+	dampedUntil time.Time
+	damped      bool
+}
+
+// HealthHistory keeps the last health transitions per device and damps flapping devices
+// A device whose health changes FlapThreshold times within FlapWindow is reported Unhealthy
+// until it has not flapped for a stabilization window
+type HealthHistory struct {
+	mu            sync.Mutex
+	size          int
+	threshold     int
+	window        time.Duration
+	stabilization time.Duration
+	clock         clock.PassiveClock // Stamps transitions and ends damping
+	devices       map[string]*deviceHealthState
+	onTransition  func(deviceID string, healthy bool)
+	onDamping     func(deviceID string, damped bool)
+}
+
+// NewHealthHistory creates a health history from the flap detection settings
+func NewHealthHistory(config *DevicePluginConfig) *HealthHistory {
+	return &HealthHistory{
+		size:          config.HealthHistorySize,
+		threshold:     config.HealthFlapThreshold,
+		window:        time.Duration(config.HealthFlapWindow) * time.Second,
+		stabilization: time.Duration(config.HealthStabilizationWindow) * time.Second,
+		clock:         clock.RealClock{},
+		devices:       make(map[string]*deviceHealthState),
+	}
+}
+
+// SetClock replaces the time source of transitions and damping windows
+func (h *HealthHistory) SetClock(now clock.PassiveClock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock = now
+}
+
+// SetHooks registers functions called on every recorded transition and damping change
+func (h *HealthHistory) SetHooks(onTransition func(deviceID string, healthy bool), onDamping func(deviceID string, damped bool)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onTransition = onTransition
+	h.onDamping = onDamping
+}
+
+// Observe records a health check result and returns the health to advertise
+// It is safe to call on a nil *HealthHistory, which passes the result through
+func (h *HealthHistory) Observe(deviceID string, healthy bool) bool {
+	if h == nil {
+		return healthy
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	state, ok := h.devices[deviceID]
+	if !ok {
+		state = &deviceHealthState{}
+		h.devices[deviceID] = state
+	}
+
+	// The first observation establishes the baseline and is not a transition
+	if state.observed && state.healthy != healthy {
+		state.transitions = append(state.transitions, HealthTransition{Healthy: healthy, At: now})
+		if h.size > 0 && len(state.transitions) > h.size {
+			state.transitions = state.transitions[len(state.transitions)-h.size:]
+		}
+		if h.onTransition != nil {
+			h.onTransition(deviceID, healthy)
+		}
+		if h.flapping(state, now) {
+			state.dampedUntil = now.Add(h.stabilization)
+		}
+	}
+	state.observed = true
+	state.healthy = healthy
+
+	damped := now.Before(state.dampedUntil)
+	if damped != state.damped {
+		state.damped = damped
+		if h.onDamping != nil {
+			h.onDamping(deviceID, damped)
+		}
+	}
+	return healthy && !damped
+}
+
+// flapping reports whether enough transitions fall inside the flap window
+func (h *HealthHistory) flapping(state *deviceHealthState, now time.Time) bool {
+	if h.threshold <= 0 {
+		return false
+	}
+	recent := 0
+	for _, transition := range state.transitions {
+		if now.Sub(transition.At) <= h.window {
+			recent++
+		}
+	}
+	return recent >= h.threshold
+}
+
+// Get returns the health history of a device
+func (h *HealthHistory) Get(deviceID string) (DeviceHealthHistory, bool) {
+	if h == nil {
+		return DeviceHealthHistory{}, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.devices[deviceID]
+	if !ok {
+		return DeviceHealthHistory{}, false
+	}
+	history := DeviceHealthHistory{
+		Transitions: append([]HealthTransition(nil), state.transitions...),
+		Flapping:    h.clock.Now().Before(state.dampedUntil),
+	}
+	if history.Flapping {
+		dampedUntil := state.dampedUntil
+		history.DampedUntil = &dampedUntil
+	}
+	return history, true
+}
+
+// onHealthTransition records a device health transition in the metrics
+func (p *VideoDevicePlugin) onHealthTransition(deviceID string, healthy bool) {
+	state := "unhealthy"
+	if healthy {
+		state = "healthy"
+	}
+	p.metrics.IncHealthTransitions(deviceID, state)
+	p.decisions.Publish(DecisionEvent{Kind: DecisionHealth, Action: state, DeviceID: deviceID})
+}
+
+// onHealthDamping reports a device entering or leaving flap damping
+// Called with the V4L2 manager lock held, so the node event is sent asynchronously
+func (p *VideoDevicePlugin) onHealthDamping(deviceID string, damped bool) {
+	p.metrics.SetDeviceFlapping(deviceID, damped)
+	action := "stabilized"
+	if damped {
+		action = "damped"
+	}
+	p.decisions.Publish(DecisionEvent{Kind: DecisionHealth, Action: action, DeviceID: deviceID})
+	if !damped {
+		p.logger.Info("Flapping device stabilized", "device_id", deviceID)
+		return
+	}
+	message := fmt.Sprintf("Device %s changed health %d times within %ds, reported Unhealthy for %ds",
+		deviceID, p.config.HealthFlapThreshold, p.config.HealthFlapWindow, p.config.HealthStabilizationWindow)
+	p.logger.Warn("Device is flapping, damping its health", "device_id", deviceID,
+		"stabilization_seconds", p.config.HealthStabilizationWindow)
+	go p.recordEvent(corev1.EventTypeWarning, "DeviceFlapping", message)
+}
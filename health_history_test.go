@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestHealthHistoryObserve(t *testing.T) {
+	// One health check result, taken after the previous one
+	type check struct {
+		after   time.Duration
+		healthy bool
+		want    bool // Health to advertise
+	}
+	tests := []struct {
+		name         string
+		checks       []check
+		wantFlapping bool
+	}{
+		{
+			name:   "steady",
+			checks: []check{{0, true, true}, {time.Second, true, true}, {time.Second, true, true}},
+		},
+		{
+			name:   "single failure passes through",
+			checks: []check{{0, true, true}, {time.Second, false, false}, {time.Second, true, true}},
+		},
+		{
+			name: "flapping is damped",
+			checks: []check{
+				{0, true, true}, {time.Second, false, false}, {time.Second, true, true},
+				{time.Second, false, false}, {time.Second, true, false},
+			},
+			wantFlapping: true,
+		},
+		{
+			name: "damping ends after stabilization",
+			checks: []check{
+				{0, true, true}, {time.Second, false, false}, {time.Second, true, true},
+				{time.Second, false, false}, {time.Second, true, false},
+				{119 * time.Second, true, false}, {time.Second, true, true},
+			},
+		},
+		{
+			name: "transitions outside the window do not count",
+			checks: []check{
+				{0, true, true}, {time.Second, false, false}, {61 * time.Second, true, true},
+				{61 * time.Second, false, false}, {time.Second, true, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := NewHealthHistory(&DevicePluginConfig{
+				HealthHistorySize:         10,
+				HealthFlapThreshold:       3,
+				HealthFlapWindow:          60,
+				HealthStabilizationWindow: 120,
+			})
+			fakeClock := clocktesting.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+			history.SetClock(fakeClock)
+
+			for i, c := range tt.checks {
+				fakeClock.Step(c.after)
+				if got := history.Observe("video10", c.healthy); got != c.want {
+					t.Errorf("check %d (healthy=%t) advertised %t, want %t", i, c.healthy, got, c.want)
+				}
+			}
+			state, _ := history.Get("video10")
+			if state.Flapping != tt.wantFlapping {
+				t.Errorf("flapping = %t, want %t", state.Flapping, tt.wantFlapping)
+			}
+		})
+	}
+}
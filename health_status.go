@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// healthStatusVersion is the layout version of HealthCheck, bumped on incompatible changes
+const healthStatusVersion = 1
+
+// healthPodLookupTimeout bounds the PodResources query of a verbose health report
+const healthPodLookupTimeout = 2 * time.Second
+
+// healthStatusSchema is the JSON schema of HealthCheck, served at /healthz/schema
+const healthStatusSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://meeting-baas.io/schemas/video-device-plugin/health-status-v1.json",
+  "title": "Video device plugin health status",
+  "type": "object",
+  "required": ["version", "healthy", "v4l2_healthy", "devices_ready", "last_checked"],
+  "properties": {
+    "version": {"const": 1},
+    "healthy": {"type": "boolean"},
+    "v4l2_healthy": {"type": "boolean"},
+    "devices_ready": {"type": "boolean"},
+    "last_checked": {"type": "string", "format": "date-time"},
+    "errors": {"type": "array", "items": {"type": "string"}},
+    "pending_module_change": {"type": "string"},
+    "devices": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["id", "path", "role", "healthy", "allocated"],
+        "properties": {
+          "id": {"type": "string"},
+          "path": {"type": "string"},
+          "role": {"enum": ["advertised", "spare", "repairing", "bundle", "tier", "excluded", "parked"]},
+          "healthy": {"type": "boolean"},
+          "last_error": {"type": "string"},
+          "last_checked": {"type": "string", "format": "date-time"},
+          "allocated": {"type": "boolean"},
+          "allocated_pod": {"type": "string"}
+        }
+      }
+    },
+    "kubelet_endpoints": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["kubelet_socket", "socket_path", "primary", "registered"],
+        "properties": {
+          "kubelet_socket": {"type": "string"},
+          "socket_path": {"type": "string"},
+          "primary": {"type": "boolean"},
+          "registered": {"type": "boolean"},
+          "gave_up": {"type": "boolean"},
+          "registered_at": {"type": "string", "format": "date-time"},
+          "last_error": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
+
+// deviceRole returns the role of a video device in the plugin's resources
+func (p *VideoDevicePlugin) deviceRole(deviceID string) string {
+	if p.excluded[deviceID] {
+		return DeviceRoleExcluded
+	} else if p.reserved[deviceID] {
+		return DeviceRoleBundle
+	} else if _, tiered := p.tiers[deviceID]; tiered {
+		return DeviceRoleTier
+	}
+	return p.spares.Role(deviceID)
+}
+
+// deviceHealthStatuses returns the per-device breakdown of the health status, sorted by ID
+// Health comes from each device's last check, so building it never runs a check itself
+func (p *VideoDevicePlugin) deviceHealthStatuses() []DeviceHealthStatus {
+	allocations := make(map[string]Allocation)
+	for _, allocation := range p.allocations.List() {
+		allocations[allocation.DeviceID] = allocation
+	}
+
+	var statuses []DeviceHealthStatus
+	for _, device := range p.v4l2Manager.ListAllDevices() {
+		status := DeviceHealthStatus{
+			ID:   device.ID,
+			Path: device.Path,
+			Role: p.deviceRole(device.ID),
+		}
+		if check, ok := p.v4l2Manager.GetDeviceCheck(device.ID); ok {
+			checkedAt := check.CheckedAt
+			status.Healthy = check.Healthy
+			status.LastError = check.Error
+			status.LastChecked = &checkedAt
+		}
+		if allocation, ok := allocations[device.ID]; ok {
+			status.Allocated = true
+			status.AllocatedPod = allocation.Owner
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// verboseHealthStatus is the health status with the pod holding each kubelet-allocated device
+// Pods are resolved through the PodResources API; without it only local lease owners are named
+func (p *VideoDevicePlugin) verboseHealthStatus(ctx context.Context) *HealthCheck {
+	health := p.GetHealthStatus()
+
+	ctx, cancel := context.WithTimeout(ctx, healthPodLookupTimeout)
+	defer cancel()
+	pods, err := listDevicePods(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		p.logger.Debug("Cannot resolve allocated pods for the health report", "error", err)
+		return health
+	}
+
+	bundles := make(map[string]string)
+	for _, bundle := range buildAVBundles(p.config) {
+		bundles[bundle.VideoDeviceID] = bundle.ID
+	}
+	for i := range health.Devices {
+		status := &health.Devices[i]
+		resource, kubeletID := p.deviceResource(status.ID), p.rotation.KubeletID(status.ID)
+		if bundleID, ok := bundles[status.ID]; ok {
+			kubeletID = bundleID
+		}
+		if pod, ok := pods[resource][kubeletID]; ok {
+			status.AllocatedPod = pod.String()
+		}
+	}
+	return health
+}
+
+// handleHealthz serves liveness; ?verbose=1 adds the full health status with per-device details
+func handleHealthz(plugin *VideoDevicePlugin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("verbose") {
+		case "", "0", "false":
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		default:
+			writeJSON(w, http.StatusOK, plugin.verboseHealthStatus(r.Context()))
+		}
+	}
+}
+
+// handleHealthSchema serves the JSON schema of the verbose health status
+func handleHealthSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(healthStatusSchema))
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Device roles within the video resource pool
+const (
+	DeviceRoleAdvertised = "advertised" // Reported to kubelet
+	DeviceRoleSpare      = "spare"      // Created and healthy, held back from the scheduler
+	DeviceRoleRepairing  = "repairing"  // Withdrawn after failing; becomes a spare once repaired
+	DeviceRoleBundle     = "bundle"     // Advertised through the av-bundle resource
+	DeviceRoleTier       = "tier"       // Advertised through a device tier resource
+	DeviceRoleExcluded   = "excluded"   // Created but never advertised (EXCLUDED_DEVICES)
+	DeviceRoleParked     = "parked"     // Withdrawn by a runtime capacity change (PUT /v1/capacity)
+)
+
+// hotSpareIDs returns the device IDs initially held back as hot spares
+// Spares take the slots directly below the av-bundle slots
+func hotSpareIDs(config *DevicePluginConfig) []string {
+	firstSlot := config.MaxDevices - config.AVBundleCount - config.HotSpareCount
+	ids := make([]string, 0, config.HotSpareCount)
+	for i := 0; i < config.HotSpareCount; i++ {
+		ids = append(ids, fmt.Sprintf("video%d", config.VideoDeviceStart+firstSlot+i))
+	}
+	return ids
+}
+
+// HotSparePool tracks which video devices are held back from kubelet
+// Devices not in the pool are advertised
+type HotSparePool struct {
+	mu       sync.Mutex
+	roles    map[string]string // device ID -> DeviceRoleSpare or DeviceRoleRepairing
+	onChange func()            // Called after every mutation
+}
+
+// NewHotSparePool creates a pool holding the configured spare slots
+func NewHotSparePool(config *DevicePluginConfig) *HotSparePool {
+	pool := &HotSparePool{roles: make(map[string]string)}
+	for _, id := range hotSpareIDs(config) {
+		pool.roles[id] = DeviceRoleSpare
+	}
+	return pool
+}
+
+// HeldBack reports whether a device must not be advertised
+func (s *HotSparePool) HeldBack(deviceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, held := s.roles[deviceID]
+	return held
+}
+
+// Role returns the pool role of a device, DeviceRoleAdvertised if it is not held back
+func (s *HotSparePool) Role(deviceID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if role, held := s.roles[deviceID]; held {
+		return role
+	}
+	return DeviceRoleAdvertised
+}
+
+// withRole returns the sorted IDs of devices holding role
+func (s *HotSparePool) withRole(role string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, r := range s.roles {
+		if r == role {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// promote advertises spare in place of broken, which is withdrawn for repair
+func (s *HotSparePool) promote(broken, spare string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.roles, spare)
+	s.roles[broken] = DeviceRoleRepairing
+	s.notifyChangeLocked()
+}
+
+// markRepaired returns a repaired device to the spare pool
+func (s *HotSparePool) markRepaired(deviceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[deviceID] = DeviceRoleSpare
+	s.notifyChangeLocked()
+}
+
+// SetChangeHook registers fn to be called after every mutation
+func (s *HotSparePool) SetChangeHook(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// notifyChangeLocked calls the change hook; caller must hold s.mu
+func (s *HotSparePool) notifyChangeLocked() {
+	if s.onChange != nil {
+		s.onChange()
+	}
+}
+
+// Snapshot returns a copy of the held-back roles
+func (s *HotSparePool) Snapshot() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]string, len(s.roles))
+	for id, role := range s.roles {
+		snapshot[id] = role
+	}
+	return snapshot
+}
+
+// Restore replaces the roles with ones saved by a previous instance, so promoted spares stay
+// advertised and parked devices stay parked; saved roles are ignored when HOT_SPARE_COUNT
+// changed since they were written
+func (s *HotSparePool) Restore(saved map[string]string, count int) bool {
+	spares := 0
+	for _, role := range saved {
+		if role != DeviceRoleParked {
+			spares++
+		}
+	}
+	if spares != count {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = make(map[string]string, len(saved))
+	for id, role := range saved {
+		s.roles[id] = role
+	}
+	return true
+}
+
+// monitorHotSpares swaps unhealthy advertised devices for healthy spares on every health
+// interval and repairs withdrawn devices in the background, keeping advertised capacity constant
+func (p *VideoDevicePlugin) monitorHotSpares() {
+	if p.config.HotSpareCount == 0 || p.v4l2Manager.IsFallbackMode() {
+		return
+	}
+
+	timer := p.clock.NewTimer(p.settings.HealthCheckInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-timer.C():
+			p.promoteHotSpares()
+			p.repairWithdrawnDevices()
+			timer.Reset(p.settings.HealthCheckInterval())
+		}
+	}
+}
+
+// promoteHotSpares replaces each unhealthy advertised device with a healthy spare
+func (p *VideoDevicePlugin) promoteHotSpares() {
+	var broken []string
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		if p.deviceResource(deviceID) != p.config.ResourceName || p.spares.HeldBack(deviceID) {
+			continue
+		}
+		if !p.v4l2Manager.GetDeviceHealth(deviceID) {
+			broken = append(broken, deviceID)
+		}
+	}
+	sort.Strings(broken)
+
+	spares := p.spares.withRole(DeviceRoleSpare)
+	for _, deviceID := range broken {
+		spare := ""
+		for len(spares) > 0 && spare == "" {
+			if p.v4l2Manager.GetDeviceHealth(spares[0]) {
+				spare = spares[0]
+			}
+			spares = spares[1:]
+		}
+		if spare == "" {
+			p.logger.Warn("Advertised device is unhealthy and no healthy hot spare is left", "device_id", deviceID)
+			return
+		}
+
+		p.spares.promote(deviceID, spare)
+		p.logger.Warn("Promoted hot spare to replace unhealthy device",
+			"device_id", deviceID,
+			"spare_device_id", spare)
+		p.decisions.Publish(DecisionEvent{
+			Kind:     DecisionReconcile,
+			Action:   "spare_promoted",
+			DeviceID: deviceID,
+			Fields:   map[string]string{"spare_device_id": spare},
+		})
+	}
+}
+
+// repairWithdrawnDevices recreates withdrawn devices and returns healthy ones to the spare pool
+func (p *VideoDevicePlugin) repairWithdrawnDevices() {
+	// Recreating a device would disrupt the production instance's pods
+	if p.shadow {
+		return
+	}
+
+	for _, deviceID := range p.spares.withRole(DeviceRoleRepairing) {
+		device, err := p.v4l2Manager.GetDeviceByID(deviceID)
+		if err != nil {
+			continue
+		}
+
+		if !p.v4l2Manager.GetDeviceHealth(deviceID) {
+			ctx, cancel := context.WithTimeout(p.ctx, time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+			err := p.resetDeviceWithContext(ctx, device.Path, p.deviceMaxBuffers(device))
+			cancel()
+			if err != nil {
+				p.logger.Warn("Failed to repair withdrawn device, will retry", "device_id", deviceID, "error", err)
+				continue
+			}
+			if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+				p.logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+			}
+			if !p.v4l2Manager.GetDeviceHealth(deviceID) {
+				p.logger.Warn("Withdrawn device still unhealthy after recreation, will retry", "device_id", deviceID)
+				continue
+			}
+		}
+
+		p.spares.markRepaired(deviceID)
+		p.logger.Info("Repaired withdrawn device, now a hot spare", "device_id", deviceID)
+		p.decisions.Publish(DecisionEvent{Kind: DecisionReconcile, Action: "spare_repaired", DeviceID: deviceID})
+		p.RotateDeviceIDs("repaired after withdrawal", []string{deviceID})
+	}
+}
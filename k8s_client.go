@@ -0,0 +1,281 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
+)
+
+// kubernetesAPIBuilt reports whether this binary includes the Kubernetes API client
+const kubernetesAPIBuilt = true
+
+// K8sClient wraps the Kubernetes API client used by the device plugin
+type K8sClient struct {
+	clientset kubernetes.Interface
+	nodeName  string
+	clock     clock.PassiveClock // Time source of condition and event timestamps
+	logger    *slog.Logger
+}
+
+// NewK8sClient creates a new K8sClient using the in-cluster service account
+func NewK8sClient(config *DevicePluginConfig, logger *slog.Logger) (*K8sClient, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	return &K8sClient{
+		clientset: clientset,
+		nodeName:  config.NodeName,
+		clock:     clock.RealClock{},
+		logger:    logger,
+	}, nil
+}
+
+// SetClock replaces the time source, letting tests control timestamps
+func (k *K8sClient) SetClock(c clock.PassiveClock) {
+	k.clock = c
+}
+
+// SetNodeCondition patches a condition on this node's status
+// The strategic merge patch merges conditions by type, so other conditions are left untouched
+func (k *K8sClient) SetNodeCondition(ctx context.Context, conditionType string, ready bool, reason, message string) error {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	now := metav1.NewTime(k.clock.Now())
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{
+				{
+					Type:               corev1.NodeConditionType(conditionType),
+					Status:             status,
+					Reason:             reason,
+					Message:            message,
+					LastHeartbeatTime:  now,
+					LastTransitionTime: now,
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node condition patch: %w", err)
+	}
+
+	_, err = k.clientset.CoreV1().Nodes().Patch(ctx, k.nodeName, types.StrategicMergePatchType, data, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("failed to patch node condition %s: %w", conditionType, err)
+	}
+
+	k.logger.Info("Node condition updated",
+		"node", k.nodeName,
+		"condition", conditionType,
+		"status", status,
+		"reason", reason)
+	return nil
+}
+
+// SetPodCondition patches a condition on a pod's status, e.g. the condition of a readiness gate
+// Like node conditions, pod conditions are merged by type
+func (k *K8sClient) SetPodCondition(ctx context.Context, namespace, name, conditionType string, ready bool, reason, message string) error {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	now := metav1.NewTime(k.clock.Now())
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.PodCondition{
+				{
+					Type:               corev1.PodConditionType(conditionType),
+					Status:             status,
+					Reason:             reason,
+					Message:            message,
+					LastProbeTime:      now,
+					LastTransitionTime: now,
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pod condition patch: %w", err)
+	}
+
+	_, err = k.clientset.CoreV1().Pods(namespace).Patch(ctx, name, types.StrategicMergePatchType, data, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("failed to patch pod condition %s on %s/%s: %w", conditionType, namespace, name, err)
+	}
+
+	k.logger.Info("Pod condition updated",
+		"pod", namespace+"/"+name,
+		"condition", conditionType,
+		"status", status,
+		"reason", reason)
+	return nil
+}
+
+// RecordNodeEvent emits a Kubernetes Event about this node (visible in kubectl describe node)
+func (k *K8sClient) RecordNodeEvent(ctx context.Context, eventType, reason, message string) error {
+	now := metav1.NewTime(k.clock.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: k.nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: k.nodeName,
+			// Kubelet uses the node name as the UID for node events
+			UID: types.UID(k.nodeName),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "video-device-plugin", Host: k.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := k.clientset.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create node event %s: %w", reason, err)
+	}
+	return nil
+}
+
+// GetPod returns a pod by namespace and name
+func (k *K8sClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	pod, err := k.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, err)
+	}
+	return pod, nil
+}
+
+// ListPendingPods returns this node's pods that are scheduled but not started yet
+func (k *K8sClient) ListPendingPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	selector := fields.AndSelectors(
+		fields.OneTermEqualSelector("spec.nodeName", k.nodeName),
+		fields.OneTermEqualSelector("status.phase", string(corev1.PodPending)),
+	)
+	pods, err := k.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending pods: %w", err)
+	}
+	return pods.Items, nil
+}
+
+// ListNodePods returns the pods scheduled on this node in every namespace
+func (k *K8sClient) ListNodePods(ctx context.Context) (map[string]string, error) {
+	selector := fields.OneTermEqualSelector("spec.nodeName", k.nodeName)
+	pods, err := k.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node pods: %w", err)
+	}
+	names := make(map[string]string, len(pods.Items))
+	for _, pod := range pods.Items {
+		names[string(pod.UID)] = pod.Namespace + "/" + pod.Name
+	}
+	return names, nil
+}
+
+// AnnotateNodeVersions publishes the plugin version and settings schema on this node
+func (k *K8sClient) AnnotateNodeVersions(ctx context.Context) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{
+				PluginVersionAnnotation:  pluginVersion,
+				SettingsSchemaAnnotation: strconv.Itoa(settingsSchemaVersion),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal node annotation patch: %w", err)
+	}
+	if _, err := k.clientset.CoreV1().Nodes().Patch(ctx, k.nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to annotate node %s: %w", k.nodeName, err)
+	}
+	return nil
+}
+
+// RenewLease creates or renews a Lease held by holder for duration
+// A newly created Lease is owned by this node so it is deleted together with the node
+func (k *K8sClient) RenewLease(ctx context.Context, namespace, name, holder string, duration time.Duration) error {
+	leases := k.clientset.CoordinationV1().Leases(namespace)
+	now := metav1.NewMicroTime(k.clock.Now())
+	seconds := int32(duration.Seconds())
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/name": "video-device-plugin",
+					"kubernetes.io/hostname": k.nodeName,
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if node, err := k.clientset.CoreV1().Nodes().Get(ctx, k.nodeName, metav1.GetOptions{}); err == nil {
+			lease.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}}
+		}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create lease %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lease %s/%s: %w", namespace, name, err)
+	}
+
+	// A new holder (restart, rolling update) marks a fresh acquisition
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to renew lease %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
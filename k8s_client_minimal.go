@@ -0,0 +1,138 @@
+//go:build minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+)
+
+// kubernetesAPIBuilt reports whether this binary includes the Kubernetes API client
+// Minimal builds (-tags minimal) leave out client-go, the informers and every API-backed feature,
+// so the plugin only talks to kubelet and needs no ServiceAccount or RBAC
+const kubernetesAPIBuilt = false
+
+// errNoKubernetesAPI is returned by every API call of a minimal build
+var errNoKubernetesAPI = errors.New("built without Kubernetes API support (-tags minimal)")
+
+// K8sClient is empty in minimal builds; NewK8sClient never returns one
+type K8sClient struct{}
+
+// NewK8sClient always fails in minimal builds
+func NewK8sClient(config *DevicePluginConfig, logger *slog.Logger) (*K8sClient, error) {
+	return nil, errNoKubernetesAPI
+}
+
+// SetClock is a no-op in minimal builds
+func (k *K8sClient) SetClock(c clock.PassiveClock) {}
+
+// SetNodeCondition is unavailable in minimal builds
+func (k *K8sClient) SetNodeCondition(ctx context.Context, conditionType string, ready bool, reason, message string) error {
+	return errNoKubernetesAPI
+}
+
+// SetPodCondition is unavailable in minimal builds
+func (k *K8sClient) SetPodCondition(ctx context.Context, namespace, name, conditionType string, ready bool, reason, message string) error {
+	return errNoKubernetesAPI
+}
+
+// RecordNodeEvent is unavailable in minimal builds
+func (k *K8sClient) RecordNodeEvent(ctx context.Context, eventType, reason, message string) error {
+	return errNoKubernetesAPI
+}
+
+// GetPod is unavailable in minimal builds
+func (k *K8sClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	return nil, errNoKubernetesAPI
+}
+
+// ListPendingPods is unavailable in minimal builds
+func (k *K8sClient) ListPendingPods(ctx context.Context, namespace string) ([]corev1.Pod, error) {
+	return nil, errNoKubernetesAPI
+}
+
+// ListNodePods is unavailable in minimal builds
+func (k *K8sClient) ListNodePods(ctx context.Context) (map[string]string, error) {
+	return nil, errNoKubernetesAPI
+}
+
+// AnnotateNodeVersions is unavailable in minimal builds
+func (k *K8sClient) AnnotateNodeVersions(ctx context.Context) error {
+	return errNoKubernetesAPI
+}
+
+// RenewLease is unavailable in minimal builds
+func (k *K8sClient) RenewLease(ctx context.Context, namespace, name, holder string, duration time.Duration) error {
+	return errNoKubernetesAPI
+}
+
+// podWatchSelectors is unavailable in minimal builds; validateConfig rejects ENABLE_POD_WATCH first
+func podWatchSelectors(config *DevicePluginConfig) (string, string, error) {
+	return "", "", errNoKubernetesAPI
+}
+
+// PodWatcher is not built into minimal builds
+type PodWatcher struct{}
+
+// NewPodWatcher always fails in minimal builds
+func NewPodWatcher(client *K8sClient, plugin *VideoDevicePlugin, config *DevicePluginConfig, logger *slog.Logger) (*PodWatcher, error) {
+	return nil, errNoKubernetesAPI
+}
+
+// Start is a no-op in minimal builds
+func (w *PodWatcher) Start(ctx context.Context) {}
+
+// Stop is a no-op in minimal builds
+func (w *PodWatcher) Stop() {}
+
+// ConfigMapWatcher is not built into minimal builds
+type ConfigMapWatcher struct{}
+
+// NewConfigMapWatcher returns an inert watcher in minimal builds
+func NewConfigMapWatcher(client *K8sClient, namespace, name string, settings *RuntimeSettings, logger *slog.Logger) *ConfigMapWatcher {
+	return &ConfigMapWatcher{}
+}
+
+// Start is a no-op in minimal builds
+func (w *ConfigMapWatcher) Start(ctx context.Context) {}
+
+// Stop is a no-op in minimal builds
+func (w *ConfigMapWatcher) Stop() {}
+
+// SchedulingExhaustionMonitor is not built into minimal builds
+type SchedulingExhaustionMonitor struct{}
+
+// NewSchedulingExhaustionMonitor returns an inert monitor in minimal builds
+func NewSchedulingExhaustionMonitor(client *K8sClient, plugin *VideoDevicePlugin, config *DevicePluginConfig, metrics *Metrics, logger *slog.Logger) *SchedulingExhaustionMonitor {
+	return &SchedulingExhaustionMonitor{}
+}
+
+// Start is a no-op in minimal builds
+func (m *SchedulingExhaustionMonitor) Start(ctx context.Context) {}
+
+// Stop is a no-op in minimal builds
+func (m *SchedulingExhaustionMonitor) Stop() {}
+
+// runAggregator needs the Kubernetes API and fails in minimal builds
+func runAggregator(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	return fmt.Errorf("aggregator mode: %w", errNoKubernetesAPI)
+}
+
+// runE2E needs the Kubernetes API and fails in minimal builds
+func runE2E(args []string) int {
+	fmt.Fprintf(os.Stderr, "e2e: %v\n", errNoKubernetesAPI)
+	return 1
+}
+
+// runFleetcheck needs the Kubernetes API and fails in minimal builds
+func runFleetcheck(args []string) int {
+	fmt.Fprintf(os.Stderr, "fleetcheck: %v\n", errNoKubernetesAPI)
+	return 1
+}
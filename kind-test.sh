@@ -0,0 +1,43 @@
+#!/bin/bash
+
+# End-to-end test in a kind cluster: builds the image for the host kernel, deploys the
+# plugin with the host /dev and /lib/modules, and runs "video-device-plugin e2e", which
+# schedules a pod requesting a device and checks that VIDEO_DEVICE is usable
+#
+# Requires docker, kind, kubectl and go on a Linux host that allows loading v4l2loopback.
+# Set KEEP_CLUSTER=true to keep the cluster for debugging.
+set -e
+
+CLUSTER_NAME="${CLUSTER_NAME:-video-device-plugin-e2e}"
+IMAGE="video-device-plugin:kind"
+KERNEL_VERSION="${KERNEL_VERSION:-$(uname -r)}"
+KUBECONFIG_PATH="$(mktemp)"
+
+cleanup() {
+    if [ "$KEEP_CLUSTER" != "true" ]; then
+        echo "Deleting kind cluster $CLUSTER_NAME..."
+        kind delete cluster --name "$CLUSTER_NAME" || true
+    fi
+    rm -f "$KUBECONFIG_PATH"
+}
+trap cleanup EXIT
+
+echo "Building $IMAGE for kernel $KERNEL_VERSION..."
+docker build --build-arg KERNEL_VERSION="$KERNEL_VERSION" --build-arg VERSION=kind --tag "$IMAGE" .
+
+echo "Creating kind cluster $CLUSTER_NAME..."
+kind create cluster --name "$CLUSTER_NAME" --config hack/kind/cluster.yaml --kubeconfig "$KUBECONFIG_PATH" --wait 120s
+kind load docker-image "$IMAGE" --name "$CLUSTER_NAME"
+
+echo "Deploying the plugin..."
+kubectl --kubeconfig "$KUBECONFIG_PATH" apply -f hack/kind/plugin.yaml
+kubectl --kubeconfig "$KUBECONFIG_PATH" -n kube-system rollout status daemonset/video-device-plugin --timeout 180s
+
+echo "Running the end-to-end check..."
+if ! go run . e2e -kubeconfig "$KUBECONFIG_PATH" -timeout 5m; then
+    echo "End-to-end check failed, plugin logs:"
+    kubectl --kubeconfig "$KUBECONFIG_PATH" -n kube-system logs daemonset/video-device-plugin --tail 200 || true
+    exit 1
+fi
+
+echo "End-to-end check passed"
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultKubeletRoot is the kubelet root directory of upstream Kubernetes, k3s and RKE2
+const defaultKubeletRoot = "/var/lib/kubelet"
+
+// kubeletRootCandidates are the kubelet root directories probed in order when KUBELET_SOCKET is unset
+var kubeletRootCandidates = []string{
+	defaultKubeletRoot,
+	"/var/snap/microk8s/common/var/lib/kubelet", // microk8s
+	"/var/lib/k0s/kubelet",                      // k0s
+}
+
+// kubeletRootPaths are the kubelet-relative paths rebased onto a discovered root unless set explicitly
+var kubeletRootPaths = []struct {
+	env      string
+	relative string
+	field    func(*DevicePluginConfig) *string
+}{
+	{"KUBELET_SOCKET", "device-plugins/kubelet.sock", func(c *DevicePluginConfig) *string { return &c.KubeletSocket }},
+	{"SOCKET_PATH", "device-plugins/video-device-plugin.sock", func(c *DevicePluginConfig) *string { return &c.SocketPath }},
+	{"PLUGIN_REGISTRY_DIR", "plugins_registry", func(c *DevicePluginConfig) *string { return &c.PluginRegistryDir }},
+	{"POD_RESOURCES_SOCKET", "pod-resources/kubelet.sock", func(c *DevicePluginConfig) *string { return &c.PodResourcesSocket }},
+	{"AV_BUNDLE_SOCKET_PATH", "device-plugins/video-device-plugin-av.sock", func(c *DevicePluginConfig) *string { return &c.AVBundleSocketPath }},
+	{"SHADOW_SOCKET_PATH", "device-plugins/video-device-plugin-shadow.sock", func(c *DevicePluginConfig) *string { return &c.ShadowSocketPath }},
+}
+
+// discoverKubeletRoot returns the first candidate root holding a kubelet device plugin socket
+// It returns defaultKubeletRoot when none does yet (kubelet may still be starting)
+func discoverKubeletRoot() string {
+	for _, root := range kubeletRootCandidates {
+		if checkDeviceExists(filepath.Join(root, "device-plugins", "kubelet.sock")) {
+			return root
+		}
+	}
+	return defaultKubeletRoot
+}
+
+// resolveKubeletRoot sets the kubelet paths of config from KUBELET_ROOT_DIR or auto-discovery
+// An explicit KUBELET_SOCKET disables it; other paths set explicitly are always kept
+func resolveKubeletRoot(config *DevicePluginConfig) {
+	if envSet("KUBELET_SOCKET") {
+		return
+	}
+
+	root := strings.TrimSpace(getEnv("KUBELET_ROOT_DIR", ""))
+	if root == "" {
+		root = discoverKubeletRoot()
+	}
+	config.KubeletRootDir = root
+
+	for _, path := range kubeletRootPaths {
+		if !envSet(path.env) {
+			*path.field(config) = filepath.Join(root, path.relative)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// kubeletEndpointCheckInterval is how often each additional kubelet's registration is checked
+const kubeletEndpointCheckInterval = 10 * time.Second
+
+// parseAdditionalKubeletSockets parses ADDITIONAL_KUBELET_SOCKETS, the kubelet registration
+// sockets of nested kubelets (e.g. a k3s agent next to the host kubelet)
+func parseAdditionalKubeletSockets(config *DevicePluginConfig) ([]string, error) {
+	dirs := map[string]bool{filepath.Dir(config.KubeletSocket): true}
+	var sockets []string
+	for _, entry := range strings.Split(config.AdditionalKubelets, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !filepath.IsAbs(entry) {
+			return nil, fmt.Errorf("ADDITIONAL_KUBELET_SOCKETS entry %q must be an absolute path", entry)
+		}
+		// Kubelet resolves the registered endpoint in its own socket directory
+		dir := filepath.Dir(filepath.Clean(entry))
+		if dirs[dir] {
+			return nil, fmt.Errorf("ADDITIONAL_KUBELET_SOCKETS entry %q shares its directory with another kubelet", entry)
+		}
+		dirs[dir] = true
+		sockets = append(sockets, filepath.Clean(entry))
+	}
+	return sockets, nil
+}
+
+// kubeletEndpoint is an additional kubelet the plugin registers its main resource with
+// Each one gets its own plugin socket next to its kubelet socket, gRPC server, registration
+// state and restart monitor; socket takeover and plugin watcher registration stay with the
+// primary kubelet
+type kubeletEndpoint struct {
+	KubeletSocket      string
+	SocketPath         string
+	PodResourcesSocket string // PodResources API socket of this kubelet
+
+	refreshCh chan struct{} // Wakes this kubelet's ListAndWatch stream for an immediate send
+
+	mu           sync.Mutex
+	server       *grpc.Server
+	listener     net.Listener
+	registered   bool
+	gaveUp       bool
+	registeredAt time.Time
+	lastError    string
+}
+
+// KubeletEndpointStatus is the registration state of one kubelet
+type KubeletEndpointStatus struct {
+	KubeletSocket string     `json:"kubelet_socket"`
+	SocketPath    string     `json:"socket_path"`
+	Primary       bool       `json:"primary"`
+	Registered    bool       `json:"registered"`
+	GaveUp        bool       `json:"gave_up,omitempty"`
+	RegisteredAt  *time.Time `json:"registered_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// newKubeletEndpoints builds the additional kubelet endpoints of a validated configuration
+func newKubeletEndpoints(config *DevicePluginConfig) []*kubeletEndpoint {
+	sockets, _ := parseAdditionalKubeletSockets(config)
+	endpoints := make([]*kubeletEndpoint, 0, len(sockets))
+	for _, socket := range sockets {
+		endpoints = append(endpoints, &kubeletEndpoint{
+			KubeletSocket:      socket,
+			SocketPath:         filepath.Join(filepath.Dir(socket), filepath.Base(config.SocketPath)),
+			PodResourcesSocket: kubeletPodResourcesSocket(socket),
+			refreshCh:          make(chan struct{}, 1),
+		})
+	}
+	return endpoints
+}
+
+// kubeletPodResourcesSocket derives a kubelet's PodResources socket from its registration socket
+// Kubelet serves both from its root directory: <root>/device-plugins and <root>/pod-resources
+func kubeletPodResourcesSocket(kubeletSocket string) string {
+	root := filepath.Dir(filepath.Dir(kubeletSocket))
+	return filepath.Join(root, "pod-resources", "kubelet.sock")
+}
+
+// allocatingKubeletKey carries the additional kubelet an Allocate call came through
+type allocatingKubeletKey struct{}
+
+// allocatingKubelet returns the additional kubelet socket an Allocate call came through,
+// empty for the primary kubelet
+func allocatingKubelet(ctx context.Context) string {
+	kubelet, _ := ctx.Value(allocatingKubeletKey{}).(string)
+	return kubelet
+}
+
+// kubeletEndpointServer serves the main resource to one additional kubelet
+// The device list and allocations are scoped to that kubelet: a device allocated through one
+// kubelet is Unhealthy for every other. The remaining methods are the plugin's own
+type kubeletEndpointServer struct {
+	*VideoDevicePlugin
+	endpoint *kubeletEndpoint
+}
+
+// ListAndWatch implements the ListAndWatch gRPC method for the additional kubelet
+func (s kubeletEndpointServer) ListAndWatch(req *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
+	return s.listAndWatch(stream, s.endpoint.KubeletSocket, s.endpoint.refreshCh)
+}
+
+// Allocate implements the Allocate gRPC method for the additional kubelet
+func (s kubeletEndpointServer) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	ctx = context.WithValue(ctx, allocatingKubeletKey{}, s.endpoint.KubeletSocket)
+	return s.allocateResource(ctx, s.config.ResourceName, req)
+}
+
+// connected reports whether the endpoint is registered and both sockets still exist
+func (e *kubeletEndpoint) connected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.registered && e.listener != nil && checkDeviceExists(e.KubeletSocket) && checkDeviceExists(e.SocketPath)
+}
+
+// setRegistered records the registration state; it returns whether the state changed
+func (e *kubeletEndpoint) setRegistered(registered bool, err error) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	changed := e.registered != registered
+	e.registered = registered
+	if registered {
+		e.registeredAt = time.Now()
+		e.lastError = ""
+	} else if err != nil {
+		e.lastError = err.Error()
+	}
+	return changed
+}
+
+// startKubeletEndpoints serves the plugin on every additional kubelet and registers with it
+// A kubelet that is not up yet is retried by its monitor and never fails the plugin start
+func (p *VideoDevicePlugin) startKubeletEndpoints() {
+	for _, endpoint := range p.endpoints {
+		logger := p.logger.With("kubelet_socket", endpoint.KubeletSocket)
+		if err := p.connectKubeletEndpoint(endpoint); err != nil {
+			endpoint.setRegistered(false, err)
+			logger.Warn("Additional kubelet not registered yet, retrying in the background", "error", err)
+		}
+		go p.monitorKubeletEndpoint(endpoint)
+	}
+}
+
+// connectKubeletEndpoint (re)creates the endpoint's plugin socket when needed and registers with its kubelet
+func (p *VideoDevicePlugin) connectKubeletEndpoint(e *kubeletEndpoint) error {
+	if !checkDeviceExists(e.KubeletSocket) {
+		return fmt.Errorf("kubelet socket %s not found", e.KubeletSocket)
+	}
+
+	// Kubelet wipes its plugin directory when it restarts
+	e.mu.Lock()
+	if e.listener == nil || !checkDeviceExists(e.SocketPath) {
+		if e.listener != nil {
+			_ = e.listener.Close()
+			e.listener = nil
+		}
+		if checkDeviceExists(e.SocketPath) {
+			if probeSocketAlive(e.SocketPath) {
+				e.mu.Unlock()
+				return fmt.Errorf("socket %s is served by another instance", e.SocketPath)
+			}
+			_ = cleanupSocket(e.SocketPath)
+		}
+		listener, err := net.Listen("unix", e.SocketPath)
+		if err != nil {
+			e.mu.Unlock()
+			return fmt.Errorf("failed to listen on %s: %w", e.SocketPath, err)
+		}
+		if e.server == nil {
+			e.server = grpc.NewServer(grpcServerOptions(p.config, p.settings, p.logger)...)
+			pluginapi.RegisterDevicePluginServer(e.server, kubeletEndpointServer{VideoDevicePlugin: p, endpoint: e})
+		}
+		e.listener = listener
+		go p.serveKubeletEndpoint(e.server, listener, e.KubeletSocket)
+	}
+	e.mu.Unlock()
+
+	if err := registerResourceWithKubelet(p.ctx, e.KubeletSocket, e.SocketPath, p.config.ResourceName, p.logger); err != nil {
+		return err
+	}
+	e.setRegistered(true, nil)
+	p.logger.Info("Registered with additional kubelet",
+		"kubelet_socket", e.KubeletSocket,
+		"socket_path", e.SocketPath,
+		"resource_name", p.config.ResourceName)
+	p.decisions.Publish(DecisionEvent{
+		Kind:     DecisionRegistration,
+		Action:   "registered",
+		Resource: p.config.ResourceName,
+		Fields:   map[string]string{"kubelet_socket": e.KubeletSocket},
+	})
+	return nil
+}
+
+// serveKubeletEndpoint runs an additional kubelet's gRPC server on its listener
+func (p *VideoDevicePlugin) serveKubeletEndpoint(server *grpc.Server, listener net.Listener, kubeletSocket string) {
+	if err := server.Serve(listener); err != nil && !p.takingOver.Load() {
+		p.logger.Error("gRPC server failed", "kubelet_socket", kubeletSocket, "error", err)
+	}
+}
+
+// monitorKubeletEndpoint re-registers with an additional kubelet whenever it restarts,
+// independently of the primary kubelet and every other endpoint
+func (p *VideoDevicePlugin) monitorKubeletEndpoint(e *kubeletEndpoint) {
+	ticker := p.clock.NewTicker(kubeletEndpointCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		if e.connected() {
+			continue
+		}
+
+		if e.setRegistered(false, fmt.Errorf("kubelet or plugin socket disappeared")) {
+			p.logger.Warn("Additional kubelet lost the plugin registration, kubelet may have restarted", "kubelet_socket", e.KubeletSocket)
+			p.decisions.Publish(DecisionEvent{
+				Kind:     DecisionRegistration,
+				Action:   "unregistered",
+				Resource: p.config.ResourceName,
+				Fields:   map[string]string{"kubelet_socket": e.KubeletSocket},
+			})
+		}
+		if !p.reconnectKubeletEndpoint(e) {
+			return
+		}
+	}
+}
+
+// reconnectKubeletEndpoint retries an additional kubelet with exponential backoff
+// It returns false when the plugin stops or the retry policy gives up on this endpoint
+func (p *VideoDevicePlugin) reconnectKubeletEndpoint(e *kubeletEndpoint) bool {
+	logger := p.logger.With("kubelet_socket", e.KubeletSocket)
+	backoff := time.Duration(p.config.ReregisterInitialBackoff) * time.Second
+	maxBackoff := time.Duration(p.config.ReregisterMaxBackoff) * time.Second
+
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.ctx.Done():
+			return false
+		case <-p.clock.After(backoff):
+		}
+
+		err := p.connectKubeletEndpoint(e)
+		if err == nil {
+			logger.Info("Re-registered with additional kubelet", "attempts", attempt)
+			return true
+		}
+		e.setRegistered(false, err)
+		logger.Debug("Additional kubelet registration failed", "attempt", attempt, "error", err)
+
+		if p.config.ReregisterMaxAttempts > 0 && attempt >= p.config.ReregisterMaxAttempts {
+			e.mu.Lock()
+			e.gaveUp = true
+			e.mu.Unlock()
+			message := fmt.Sprintf("Gave up registering with kubelet %s after %d attempts; restart the plugin pod", e.KubeletSocket, attempt)
+			p.decisions.Publish(DecisionEvent{
+				Kind:     DecisionRegistration,
+				Action:   "gave_up",
+				Resource: p.config.ResourceName,
+				Message:  message,
+				Fields:   map[string]string{"kubelet_socket": e.KubeletSocket, "attempts": strconv.Itoa(attempt)},
+			})
+			logger.Error("Giving up additional kubelet registration", "attempts", attempt, "error", err)
+			p.recordEvent(corev1.EventTypeWarning, "KubeletRegistrationGaveUp", message)
+			return false
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// stopKubeletEndpointServers stops serving the additional kubelets
+func (p *VideoDevicePlugin) stopKubeletEndpointServers() {
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.server != nil {
+			endpoint.server.Stop()
+			endpoint.server = nil
+		}
+		endpoint.mu.Unlock()
+	}
+}
+
+// stopKubeletEndpoints stops serving the additional kubelets and removes their plugin sockets
+func (p *VideoDevicePlugin) stopKubeletEndpoints() {
+	p.stopKubeletEndpointServers()
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.listener != nil {
+			_ = endpoint.listener.Close()
+			endpoint.listener = nil
+		}
+		endpoint.mu.Unlock()
+		if err := cleanupSocket(endpoint.SocketPath); err != nil {
+			p.logger.Warn("Failed to cleanup additional kubelet socket", "socket", endpoint.SocketPath, "error", err)
+		}
+	}
+}
+
+// kubeletEndpointStatuses returns the registration state of the primary and every additional
+// kubelet, nil when the plugin only serves the primary one
+func (p *VideoDevicePlugin) kubeletEndpointStatuses() []KubeletEndpointStatus {
+	if len(p.endpoints) == 0 {
+		return nil
+	}
+
+	p.mu.RLock()
+	primary := KubeletEndpointStatus{
+		KubeletSocket: p.config.KubeletSocket,
+		SocketPath:    p.config.SocketPath,
+		Primary:       true,
+		Registered:    p.registered,
+		GaveUp:        p.registrationGaveUp,
+	}
+	p.mu.RUnlock()
+
+	statuses := []KubeletEndpointStatus{primary}
+	for _, endpoint := range p.endpoints {
+		endpoint.mu.Lock()
+		status := KubeletEndpointStatus{
+			KubeletSocket: endpoint.KubeletSocket,
+			SocketPath:    endpoint.SocketPath,
+			Registered:    endpoint.registered,
+			GaveUp:        endpoint.gaveUp,
+			LastError:     endpoint.lastError,
+		}
+		if !endpoint.registeredAt.IsZero() {
+			registeredAt := endpoint.registeredAt
+			status.RegisteredAt = &registeredAt
+		}
+		endpoint.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	sort.SliceStable(statuses[1:], func(i, j int) bool {
+		return statuses[i+1].KubeletSocket < statuses[j+1].KubeletSocket
+	})
+	return statuses
+}
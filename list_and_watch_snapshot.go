@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// kubeletAllocatableTimeout bounds the PodResources lookup of the snapshot endpoint
+const kubeletAllocatableTimeout = 2 * time.Second
+
+// listAndWatchSlowSend is the send latency above which a device list send is logged as slow
+// Send blocks once kubelet stops reading and the stream's flow-control window is full
+const listAndWatchSlowSend = time.Second
+
+// ListAndWatchSnapshot is the last device list sent to kubelet for one resource, as sent
+type ListAndWatchSnapshot struct {
+	Resource       string              `json:"resource"`
+	Sequence       uint64              `json:"sequence"` // Device list updates of this resource since the plugin started, from 1; gaps are failed sends
+	SentAt         time.Time           `json:"sent_at"`
+	SendSeconds    float64             `json:"send_seconds"`     // How long stream.Send took
+	StreamOpenedAt time.Time           `json:"stream_opened_at"` // When kubelet opened the stream carrying this send
+	Initial        bool                `json:"initial"`          // First send of a stream, i.e. kubelet (re)connected
+	DeviceCount    int                 `json:"device_count"`
+	HealthyCount   int                 `json:"healthy_count"`
+	Devices        []*pluginapi.Device `json:"devices"`
+}
+
+// ListAndWatchRecorder keeps the last ListAndWatchResponse sent per resource
+// When kubelet's allocatable count disagrees with ours, the snapshot shows exactly what it was told
+type ListAndWatchRecorder struct {
+	mu        sync.Mutex
+	snapshots map[string]ListAndWatchSnapshot
+	sequences map[string]uint64 // Last sequence number handed out per resource, sent or not
+	heartbeat time.Duration     // Interval of the stream heartbeat log, 0 disables it
+	metrics   *Metrics
+}
+
+// NewListAndWatchRecorder creates an empty recorder
+func NewListAndWatchRecorder(config *DevicePluginConfig, metrics *Metrics) *ListAndWatchRecorder {
+	return &ListAndWatchRecorder{
+		snapshots: make(map[string]ListAndWatchSnapshot),
+		sequences: make(map[string]uint64),
+		heartbeat: time.Duration(config.ListAndWatchHeartbeat) * time.Second,
+		metrics:   metrics,
+	}
+}
+
+// ListAndWatchStream is one kubelet ListAndWatch stream of a resource
+// It numbers every device list update, times each send and logs the stream's lifetime
+type ListAndWatchStream struct {
+	recorder      *ListAndWatchRecorder
+	server        pluginapi.DevicePlugin_ListAndWatchServer
+	resource      string
+	logger        *slog.Logger
+	openedAt      time.Time
+	sends         int
+	lastSequence  uint64
+	lastSendAt    time.Time
+	lastHeartbeat time.Time
+}
+
+// OpenStream starts tracking a ListAndWatch stream kubelet opened for resource
+// logger is expected to name the resource already
+func (r *ListAndWatchRecorder) OpenStream(resource string, server pluginapi.DevicePlugin_ListAndWatchServer, logger *slog.Logger) *ListAndWatchStream {
+	now := time.Now()
+	logger.Info("Kubelet opened ListAndWatch stream")
+	return &ListAndWatchStream{
+		recorder:      r,
+		server:        server,
+		resource:      resource,
+		logger:        logger,
+		openedAt:      now,
+		lastHeartbeat: now,
+	}
+}
+
+// Send numbers a device list update, sends it and records it once kubelet accepted it
+func (s *ListAndWatchStream) Send(response *pluginapi.ListAndWatchResponse) error {
+	sequence := s.recorder.nextSequence(s.resource)
+	start := time.Now()
+	err := s.server.Send(response)
+	latency := time.Since(start)
+	s.recorder.observeSend(s.resource, latency, err)
+	if err != nil {
+		s.logger.Error("Failed to send device list",
+			"sequence", sequence,
+			"send_latency", latency.String(),
+			"last_sent_sequence", s.lastSequence,
+			"error", err)
+		return err
+	}
+
+	initial := s.sends == 0
+	s.sends++
+	s.lastSequence, s.lastSendAt = sequence, time.Now()
+	s.recorder.record(s.resource, sequence, initial, latency, s.openedAt, response)
+
+	switch {
+	case latency > listAndWatchSlowSend:
+		s.logger.Warn("Slow device list send, kubelet may not be reading the stream",
+			"sequence", sequence,
+			"send_latency", latency.String())
+	case initial:
+		s.logger.Info("Sent initial device list", "sequence", sequence, "send_latency", latency.String())
+	default:
+		s.logger.Debug("Sent device list", "sequence", sequence, "send_latency", latency.String())
+	}
+	if heartbeat := s.recorder.heartbeatInterval(); heartbeat > 0 && s.lastSendAt.Sub(s.lastHeartbeat) >= heartbeat {
+		s.lastHeartbeat = s.lastSendAt
+		s.logger.Info("ListAndWatch stream heartbeat",
+			"sequence", sequence,
+			"stream_sends", s.sends,
+			"stream_age", s.lastSendAt.Sub(s.openedAt).Round(time.Second).String(),
+			"send_latency", latency.String())
+	}
+	return nil
+}
+
+// Close logs the lifetime of a stream that ended, err being why (nil on plugin shutdown)
+func (s *ListAndWatchStream) Close(err error) {
+	args := []any{
+		"lifetime", time.Since(s.openedAt).Round(time.Millisecond).String(),
+		"stream_sends", s.sends,
+		"last_sent_sequence", s.lastSequence,
+	}
+	if !s.lastSendAt.IsZero() {
+		args = append(args, "since_last_send", time.Since(s.lastSendAt).Round(time.Millisecond).String())
+	}
+	if err != nil {
+		s.logger.Warn("ListAndWatch stream ended", append(args, "error", err)...)
+		return
+	}
+	s.logger.Info("ListAndWatch stream closed", args...)
+}
+
+// nextSequence hands out the sequence number of the next device list update of a resource
+func (r *ListAndWatchRecorder) nextSequence(resource string) uint64 {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sequences[resource]++
+	return r.sequences[resource]
+}
+
+// observeSend exports the latency and outcome of a send
+func (r *ListAndWatchRecorder) observeSend(resource string, latency time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	r.metrics.ObserveListAndWatchSend(resource, latency, err)
+}
+
+// heartbeatInterval returns the interval of the stream heartbeat log
+func (r *ListAndWatchRecorder) heartbeatInterval() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.heartbeat
+}
+
+// record stores a response that was sent successfully; initial marks the first send of a stream
+func (r *ListAndWatchRecorder) record(resource string, sequence uint64, initial bool, latency time.Duration, openedAt time.Time, response *pluginapi.ListAndWatchResponse) {
+	if r == nil {
+		return
+	}
+
+	// Responses are built per send and not touched afterwards, but the device entries are
+	// copied so the snapshot stays exact whatever the caller does with them
+	devices := make([]*pluginapi.Device, 0, len(response.Devices))
+	healthy := 0
+	for _, device := range response.Devices {
+		copied := *device
+		devices = append(devices, &copied)
+		if device.Health == pluginapi.Healthy {
+			healthy++
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshots[resource] = ListAndWatchSnapshot{
+		Resource:       resource,
+		Sequence:       sequence,
+		SentAt:         time.Now(),
+		SendSeconds:    latency.Seconds(),
+		StreamOpenedAt: openedAt,
+		Initial:        initial,
+		DeviceCount:    len(devices),
+		HealthyCount:   healthy,
+		Devices:        devices,
+	}
+}
+
+// Snapshots returns the last send of every resource, sorted by resource name
+func (r *ListAndWatchRecorder) Snapshots() []ListAndWatchSnapshot {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshots := make([]ListAndWatchSnapshot, 0, len(r.snapshots))
+	for _, snapshot := range r.snapshots {
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Resource < snapshots[j].Resource })
+	return snapshots
+}
+
+// listAndWatchReport is the response of GET /v1/listandwatch
+type listAndWatchReport struct {
+	Snapshots          []ListAndWatchSnapshot `json:"snapshots"`
+	KubeletAllocatable map[string][]string    `json:"kubelet_allocatable,omitempty"` // Device IDs kubelet considers allocatable, by resource
+	KubeletError       string                 `json:"kubelet_error,omitempty"`
+}
+
+// handleListAndWatch returns the last device lists sent to kubelet next to kubelet's allocatable view
+// ?resource= limits the report to one resource
+func (a *AdminServer) handleListAndWatch(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	report := listAndWatchReport{Snapshots: []ListAndWatchSnapshot{}}
+	for _, snapshot := range a.plugin.sent.Snapshots() {
+		if resource == "" || snapshot.Resource == resource {
+			report.Snapshots = append(report.Snapshots, snapshot)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), kubeletAllocatableTimeout)
+	defer cancel()
+	allocatable, err := listAllocatableDevices(ctx, a.config.PodResourcesSocket)
+	if err != nil {
+		report.KubeletError = err.Error()
+	} else {
+		report.KubeletAllocatable = make(map[string][]string)
+		for _, snapshot := range report.Snapshots {
+			ids := make([]string, 0, len(allocatable[snapshot.Resource]))
+			for id := range allocatable[snapshot.Resource] {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			report.KubeletAllocatable[snapshot.Resource] = ids
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
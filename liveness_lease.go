@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// livenessLeasePrefix names the per-node liveness Lease (video-device-plugin-<node>)
+const livenessLeasePrefix = "video-device-plugin-"
+
+// LivenessLease renews a coordination.k8s.io Lease after every successful health cycle
+// A plugin whose pod is Running but whose ListAndWatch loop stopped sending lets the Lease
+// expire, which external controllers detect without access to the node
+type LivenessLease struct {
+	client    *K8sClient
+	namespace string
+	name      string
+	holder    string
+	duration  time.Duration
+	logger    *slog.Logger
+	beats     chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewLivenessLease creates the liveness Lease of this node's plugin
+func NewLivenessLease(client *K8sClient, config *DevicePluginConfig, logger *slog.Logger) *LivenessLease {
+	holder, err := os.Hostname()
+	if err != nil || holder == "" {
+		holder = config.NodeName
+	}
+	return &LivenessLease{
+		client:    client,
+		namespace: config.LivenessLeaseNamespace,
+		name:      livenessLeasePrefix + config.NodeName,
+		holder:    holder,
+		duration:  time.Duration(config.LivenessLeaseDuration) * time.Second,
+		logger:    logger.With("component", "liveness-lease"),
+		beats:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// Beat records a successful health cycle; the Lease is renewed in the background
+func (l *LivenessLease) Beat() {
+	if l == nil {
+		return
+	}
+	select {
+	case l.beats <- struct{}{}:
+	default:
+	}
+}
+
+// Start renews the Lease on every beat until ctx is canceled or Stop is called
+func (l *LivenessLease) Start(ctx context.Context) {
+	if l == nil {
+		return
+	}
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.logger.Info("Renewing liveness lease on every health cycle",
+		"namespace", l.namespace,
+		"lease", l.name,
+		"holder", l.holder,
+		"duration", l.duration.String())
+
+	go func() {
+		defer close(l.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.beats:
+				renewCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				if err := l.client.RenewLease(renewCtx, l.namespace, l.name, l.holder, l.duration); err != nil {
+					l.logger.Warn("Failed to renew liveness lease", "lease", l.name, "error", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop ends renewals; the Lease is left to expire so watchers see the plugin is gone
+func (l *LivenessLease) Stop() {
+	if l == nil || l.cancel == nil {
+		return
+	}
+	l.stopOnce.Do(func() {
+		l.cancel()
+		<-l.done
+	})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
@@ -10,8 +12,28 @@ import (
 )
 
 func main() {
-	// Load configuration
+	// Subcommands run standalone and never touch the node
+	if len(os.Args) > 1 && os.Args[1] == "soak" {
+		os.Exit(runSoak(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "e2e" {
+		os.Exit(runE2E(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fleetcheck" {
+		os.Exit(runFleetcheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfig(os.Args[2:]))
+	}
+
+	// Load configuration; --mode and the feature flags override the environment
 	config := loadConfig()
+	flag.StringVar(&config.Mode, "mode", config.Mode, "run mode: plugin, aggregator or shadow")
+	registerFeatureFlags(flag.CommandLine, config)
+	flag.Parse()
+	if flagSet("mode") {
+		flagKeys["MODE"] = true
+	}
 
 	// Validate configuration
 	if err := validateConfig(config); err != nil {
@@ -21,7 +43,44 @@ func main() {
 
 	// Initialize structured logging
 	logger := setupLogger(config.LogLevel)
-	logger.Info("Starting Video Device Plugin initialization...")
+
+	// Every mode runs until SIGINT/SIGTERM cancels the root context
+	ctx, stop := signalContext(context.Background(), logger)
+	defer stop()
+
+	if config.Mode == "aggregator" {
+		if err := runAggregator(ctx, config, logger); err != nil {
+			logger.Error("Aggregator failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if config.Mode == "shadow" {
+		if err := runShadow(ctx, config, logger); err != nil {
+			logger.Error("Shadow instance failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger.Info("Starting Video Device Plugin initialization...", "version", pluginVersion)
+
+	// Fatal exits from here on leave a diagnostics bundle on the host
+	fatal := NewFatalDiagnostics(config, logger)
+	if config.KubeletRootDir != "" {
+		logger.Info("Using kubelet root directory", "kubelet_root_dir", config.KubeletRootDir, "kubelet_socket", config.KubeletSocket)
+	}
+
+	// Record every privileged host operation from here on
+	if config.AuditLogPath != "" {
+		audit, err := openAuditLog(config.AuditLogPath, config.NodeName)
+		if err != nil {
+			fatal.Exit("Failed to open audit log", err)
+		}
+		auditLog = audit
+		defer auditLog.Close()
+		logger.Info("Auditing privileged host operations", "audit_log_path", config.AuditLogPath)
+	}
 
 	// Debug: Show loaded configuration
 	if config.Debug {
@@ -35,7 +94,9 @@ func main() {
 			"v4l2_exclusive_caps", config.V4L2ExclusiveCaps,
 			"resource_name", config.ResourceName,
 			"kubelet_socket", config.KubeletSocket,
+			"kubelet_root_dir", config.KubeletRootDir,
 			"socket_path", config.SocketPath,
+			"manage_module", config.ManageModule,
 			"cleanup_timeout", config.CleanupTimeout)
 	}
 
@@ -46,8 +107,7 @@ func main() {
 
 	// Check if running as root
 	if err := checkRoot(logger); err != nil {
-		logger.Error("Root check failed", "error", err)
-		os.Exit(1)
+		fatal.Exit("Root check failed", err)
 	}
 
 	// Display system information
@@ -66,29 +126,89 @@ func main() {
 			logger.Info("Using default fallback device prefix", "fallback_prefix", fallbackPrefix)
 		}
 	}
-	v4l2Manager := NewV4L2Manager(logger, config.V4L2DevicePerm, fallbackPrefix)
+	// Move the device range past foreign /dev/video nodes instead of failing verification
+	start, err := selectDeviceRange(config, logger)
+	if err != nil {
+		fatal.Exit("Failed to select video device range", err)
+	}
+	config.VideoDeviceStart = start
+	if _, err := parseExcludedDevices(config); err != nil {
+		logger.Warn("Excluded devices no longer match the relocated device range", "error", err)
+	}
+
+	// Remove placeholders left behind by a crashed previous instance before discovery
+	cleanupOrphanedFallbackDevices(fallbackPrefix, logger)
+
+	v4l2Manager := NewV4L2Manager(logger, config.V4L2DevicePerm, config.V4L2DeviceGID, config.VideoDeviceStart, fallbackPrefix)
+	fatal.SetDeviceManager(v4l2Manager)
+	slotFallback := config.EnableFallbackMode && config.FallbackGranularity == FallbackGranularityDevice
+	v4l2Manager.SetSlotFallback(slotFallback)
+
+	// Create the metrics registry before the module is touched so module operations are recorded
+	var metrics *Metrics
+	if config.EnableMetrics {
+		metrics = NewMetrics()
+		moduleMetrics = metrics
+	}
+
+	// Decide whether module commands can use the container's /lib/modules or must run on the host
+	if config.ManageModule {
+		mode, err := resolveModuleExecMode(config, logger)
+		if err != nil {
+			fatal.Exit("Module execution preflight failed", err)
+		}
+		moduleExecMode = mode
+		logger.Info("Module commands execution mode", "configured", config.ModuleExecMode, "resolved", mode)
+	}
+
+	// Install udev rules before devices appear so udev re-triggers keep our attributes
+	if config.EnableUdevRules {
+		if err := installUdevRules(config, logger); err != nil {
+			logger.Warn("Failed to install udev rules, relying on chmod only", "error", err)
+		}
+	}
 
 	// Try to load v4l2loopback module
-	if err := loadV4L2LoopbackModule(config, logger); err != nil {
+	// A busy module with the wrong configuration keeps serving its devices until they are free
+	// Without the host /dev the module's devices would never appear, so loading is skipped
+	if reason := checkHostDev(); config.EnableDevCheck && reason != "" {
+		err = &ModuleLoadError{
+			Module:               "v4l2loopback",
+			Reason:               "host /dev not shared",
+			Original:             errors.New(reason),
+			OriginalErrorMessage: reason,
+			CanFallback:          true,
+		}
+	} else {
+		err = withModuleLock(ctx, config, "load", logger, func() error {
+			return loadV4L2LoopbackModule(ctx, config, logger)
+		})
+	}
+	reloadDeferred := errors.Is(err, ErrModuleInUse)
+	if err != nil && !reloadDeferred {
 		// Check if this is a module load error that supports fallback
 		var moduleErr *ModuleLoadError
+		if errors.As(err, &moduleErr) {
+			recordModuleLoadFailure(ctx, config, moduleErr, logger)
+		}
 		if errors.As(err, &moduleErr) && moduleErr.CanFallback && config.EnableFallbackMode {
 			logger.Warn("Kernel module loading failed, enabling fallback mode",
 				"module", moduleErr.Module,
 				"reason", moduleErr.Reason,
-				"original_error", moduleErr.OriginalErrorMessage)
+				"original_error", moduleErr.OriginalErrorMessage,
+				"diagnostics", moduleErr.Diagnostics)
 
 			// Enable fallback mode with the structured error information
-			if fallbackErr := v4l2Manager.EnableFallbackMode(moduleErr.Reason, config.MaxDevices); fallbackErr != nil {
-				logger.Error("Failed to enable fallback mode", "error", fallbackErr)
-				os.Exit(1)
+			fallbackReason := moduleErr.FallbackReason()
+			if fallbackErr := v4l2Manager.EnableFallbackMode(fallbackReason, config.MaxDevices); fallbackErr != nil {
+				fatal.Exit("Failed to enable fallback mode", fallbackErr)
 			}
 
 			// Set the fallback reason in config for logging
-			config.FallbackModeReason = moduleErr.Reason
+			config.FallbackModeReason = fallbackReason
 
 			logger.Warn("Video device plugin running in fallback mode",
-				"reason", moduleErr.Reason,
+				"reason", fallbackReason,
 				"dummy_devices", config.MaxDevices,
 				"fallback_prefix", fallbackPrefix)
 		} else {
@@ -98,78 +218,308 @@ func main() {
 					"module", moduleErr.Module,
 					"reason", moduleErr.Reason,
 					"error", err,
+					"diagnostics", moduleErr.Diagnostics,
 					"note", "Set ENABLE_FALLBACK_MODE=true to enable fallback mode")
+			} else if errors.As(err, &moduleErr) {
+				logger.Error("Failed to load v4l2loopback module", "error", err, "diagnostics", moduleErr.Diagnostics)
 			} else {
 				logger.Error("Failed to load v4l2loopback module", "error", err)
 			}
+			fatal.Bundle("Failed to load v4l2loopback module", err)
 			os.Exit(1)
 		}
 	} else {
 		// Normal mode - verify devices were created and populate the V4L2 manager
 		if err := verifyVideoDevices(config, logger); err != nil {
-			logger.Error("Failed to verify video devices", "error", err)
-			os.Exit(1)
+			fatal.Exit("Failed to verify video devices", err)
 		}
 
 		// Ensure device count and types match config exactly
-		if err := verifyV4L2Configuration(config, logger); err != nil {
-			logger.Error("v4l2 configuration verification failed", "error", err)
-			os.Exit(1)
+		if reloadDeferred {
+			logger.Warn("Serving existing v4l2loopback devices until the deferred reload completes", "reason", err)
+		} else if err := withModuleLock(ctx, config, "verify", logger, func() error {
+			return verifyV4L2Configuration(config, logger)
+		}); err != nil && slotFallback {
+			logger.Warn("v4l2 configuration verification failed, continuing with per-device fallback", "error", err)
+		} else if err != nil {
+			fatal.Exit("v4l2 configuration verification failed", err)
 		}
 
+		// Check limits only the loaded module knows about
+		logConfigFindings(checkLoadedModuleRules(config), logger)
+
 		// Populate the V4L2 manager with real devices
 		if err := v4l2Manager.CreateDevices(config.MaxDevices); err != nil {
-			logger.Error("Failed to populate V4L2 manager with devices", "error", err)
-			os.Exit(1)
+			fatal.Exit("Failed to populate V4L2 manager with devices", err)
 		}
 	}
 
+	// Initialize Kubernetes API client when an API-backed feature is enabled
+	var k8sClient *K8sClient
+	if config.EnableKubernetesAPI && (len(kubernetesAPIFeatures(config)) > 0 || (config.EnableLabelStamping && config.LabelStampAnnotation != "")) {
+		client, err := NewK8sClient(config, logger)
+		if err != nil {
+			logger.Warn("Kubernetes API client unavailable, node condition, dynamic settings, events, pod watch, device affinity and liveness lease disabled", "error", err)
+		} else {
+			k8sClient = client
+		}
+	}
+
+	// Re-read settings given as mounted files, e.g. rotated Secret volumes
+	secretFiles := NewSecretFileWatcher(config, logger)
+
+	// Start metrics endpoint
+	if config.EnableMetrics {
+		if hasMetricsBackend(config, MetricsBackendPrometheus) {
+			metricsServer := startMetricsServer(config.MetricsPort, metrics, logger)
+			defer func() {
+				_ = metricsServer.Close()
+			}()
+		}
+		if pusher := NewMetricsPusher(config, metrics, logger); pusher != nil {
+			secretFiles.Handle("OTLP_METRICS_ENDPOINT", pusher.SetOTLPEndpoint)
+			secretFiles.Handle("OTLP_METRICS_HEADERS", pusher.SetOTLPHeaders)
+			pusher.Start(ctx)
+			defer pusher.Stop()
+		}
+	}
+	secretFiles.Start(ctx)
+	defer secretFiles.Stop()
+
 	// Initialize device plugin
-	plugin := NewVideoDevicePlugin(config, v4l2Manager, logger)
+	plugin := NewVideoDevicePlugin(config, v4l2Manager, k8sClient, metrics, logger)
+
+	// Load operator device preparation hooks and prepare the devices found at startup
+	if config.DeviceHooksFile != "" {
+		hooks, err := LoadDeviceHooks(config.DeviceHooksFile, logger)
+		if err != nil {
+			fatal.Exit("Failed to load device hooks", err)
+		}
+		plugin.hooks = hooks
+		plugin.prepareDevices()
+	}
 
-	// Set up signal handling for graceful shutdown
-	sigChan := setupSignalHandling()
+	// Restore bookkeeping of the previous instance before kubelet sees any device
+	plugin.RestoreCheckpoint(ctx)
 
-	// Start the device plugin in a goroutine
-	startErrCh := make(chan error, 1)
-	go func() {
-		startErrCh <- plugin.Start()
-	}()
+	// Give device tiers their own buffer counts
+	plugin.applyTierParameters()
 
-	// Wait for plugin to start or fail
-	if err := <-startErrCh; err != nil {
-		logger.Error("Failed to start device plugin", "error", err)
-		os.Exit(1)
+	// Publish the plugin version so companion components can detect skew before relying on newer layouts
+	if k8sClient != nil {
+		annotateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := k8sClient.AnnotateNodeVersions(annotateCtx); err != nil {
+			logger.Warn("Failed to publish plugin version on the node", "error", err)
+		}
+		cancel()
+	}
+
+	// Apply cluster-wide dynamic settings from the ConfigMap
+	if k8sClient != nil && config.ConfigMapName != "" {
+		watcher := NewConfigMapWatcher(k8sClient, config.KubernetesNamespace, config.ConfigMapName, plugin.settings, logger)
+		watcher.Start(ctx)
+		defer watcher.Stop()
+		plugin.OnTakeover(watcher.Stop)
+	}
+
+	// Release allocations of terminated pods on this node
+	if k8sClient != nil && config.EnablePodWatch {
+		podWatcher, err := NewPodWatcher(k8sClient, plugin, config, logger)
+		if err != nil {
+			fatal.Exit("Failed to create pod watcher", err)
+		}
+		podWatcher.Start(ctx)
+		defer podWatcher.Stop()
+		plugin.OnTakeover(podWatcher.Stop)
+	}
+
+	// Let external controllers detect a wedged plugin through an expiring Lease
+	if k8sClient != nil && config.EnableLivenessLease {
+		plugin.liveness = NewLivenessLease(k8sClient, config, logger)
+		plugin.liveness.Start(ctx)
+		defer plugin.liveness.Stop()
+		plugin.OnTakeover(plugin.liveness.Stop)
+	}
+
+	// Count pods that could not be scheduled while this node was out of devices
+	if k8sClient != nil && config.EnableExhaustionWatch {
+		exhaustion := NewSchedulingExhaustionMonitor(k8sClient, plugin, config, metrics, logger)
+		exhaustion.Start(ctx)
+		defer exhaustion.Stop()
+		plugin.OnTakeover(exhaustion.Stop)
 	}
 
+	// Serve liveness/readiness probes if configured
+	if config.ProbePort > 0 {
+		probeServer := startProbeServer(config.ProbePort, plugin, logger)
+		defer func() {
+			_ = probeServer.Close()
+		}()
+	}
+
+	// Start the device plugin; its loops run until ctx is canceled or Stop is called
+	if err := plugin.Start(ctx); err != nil {
+		// A signal during startup is a shutdown, not a failure worth a diagnostics bundle
+		if ctx.Err() != nil {
+			logger.Info("Shutdown requested during startup", "reason", context.Cause(ctx))
+			return
+		}
+		fatal.Exit("Failed to start device plugin", err)
+	}
+
+	// Apply permissions and formats before kubelet sees a Healthy device; its initial list waits for this
+	plugin.prepareForAdvertisement()
+
 	// Wait for devices to be ready
-	if err := waitForDevicesReady(v4l2Manager, config, logger); err != nil {
-		logger.Error("Devices not ready", "error", err)
-		os.Exit(1)
+	if err := waitForDevicesReady(ctx, v4l2Manager, config, logger); err != nil && ctx.Err() == nil {
+		fatal.Exit("Devices not ready", err)
+	}
+
+	// Reload the module with the right configuration once no pod holds a device
+	if reloadDeferred {
+		var eventClient *K8sClient
+		if config.EnableEvents {
+			eventClient = k8sClient
+		}
+		reloader := NewDeferredModuleReload(config, v4l2Manager, plugin, eventClient, logger)
+		plugin.setPendingModuleChange(pendingModuleChange(config, logger))
+		if config.ModuleChangePolicy == ModuleChangeRefuse {
+			reloader.Refuse(plugin.PendingModuleChange())
+		} else {
+			reloader.Start(ctx)
+			defer reloader.Stop()
+			plugin.OnTakeover(reloader.Stop)
+		}
+	}
+
+	// Serve paired video+audio bundles as a separate resource
+	var bundlePlugin *AVBundlePlugin
+	if config.AVBundleCount > 0 {
+		if err := withModuleLock(ctx, config, "load-alsa", logger, func() error {
+			return loadALSALoopbackModule(ctx, config, logger)
+		}); err != nil {
+			logger.Error("Failed to load ALSA loopback module, bundles will be unhealthy", "error", err)
+		}
+		bundlePlugin = NewAVBundlePlugin(config, v4l2Manager, plugin.allocations, plugin.settings, plugin.sent, logger)
+		if err := bundlePlugin.Start(ctx); err != nil {
+			logger.Error("Failed to start av-bundle device plugin", "error", err)
+			bundlePlugin = nil
+		} else {
+			plugin.OnTakeover(bundlePlugin.handOver)
+		}
+	}
+
+	// Serve each device tier as its own resource
+	var tierPlugins []*DeviceTierPlugin
+	for _, tier := range buildDeviceTiers(config) {
+		if tier.GeneratedName != "" {
+			logger.Warn("Generated tier resource name does not follow kubelet naming rules, registering a sanitized name",
+				"tier", tier.Name,
+				"generated", tier.GeneratedName,
+				"resource_name", tier.ResourceName)
+		}
+		tierPlugin := NewDeviceTierPlugin(tier, plugin, logger)
+		if err := tierPlugin.Start(ctx); err != nil {
+			logger.Error("Failed to start device tier plugin", "tier", tier.Name, "error", err)
+			continue
+		}
+		tierPlugins = append(tierPlugins, tierPlugin)
+		plugin.OnTakeover(tierPlugin.handOver)
+	}
+
+	// Publish readiness for dependent workloads
+	plugin.refreshReadiness()
+
+	// Start the local admin API
+	var adminServer *AdminServer
+	if config.EnableAdminAPI {
+		adminServer = NewAdminServer(config, plugin, logger)
+		if err := adminServer.Start(); err != nil {
+			logger.Error("Failed to start admin API", "error", err)
+			adminServer = nil
+		} else {
+			plugin.OnTakeover(adminServer.handOver)
+		}
+	}
+
+	// Report readiness and health to systemd when run as a host service
+	var notifier *SystemdNotifier
+	if config.EnableSystemdNotify {
+		notifier = NewSystemdNotifier(plugin, logger)
+		notifier.Start(ctx)
 	}
 
 	logger.Info("Video device plugin is ready and running")
 
 	// Wait for shutdown signal
-	waitForSignal(sigChan, logger)
+	<-ctx.Done()
 
-	// Graceful shutdown
-	logger.Info("Shutting down video device plugin")
+	// Graceful shutdown; the remaining steps are bounded by their own deadlines, not the canceled root context
+	logger.Info("Shutting down video device plugin", "reason", context.Cause(ctx))
+	shutdownCtx := context.WithoutCancel(ctx)
+	notifier.Stop()
+	if adminServer != nil {
+		adminServer.Stop()
+	}
+	if bundlePlugin != nil {
+		bundlePlugin.Stop()
+	}
+	for _, tierPlugin := range tierPlugins {
+		tierPlugin.Stop()
+	}
 	if err := plugin.Stop(); err != nil {
 		logger.Error("Error during shutdown", "error", err)
 	}
 
+	// The devices and the module belong to the instance that took over the socket
+	if plugin.IsTakenOver() {
+		logger.Info("Video device plugin shutdown complete, devices left to the new instance")
+		return
+	}
+
 	// Cleanup fallback devices if in fallback mode
 	v4l2Manager.CleanupFallbackDevices()
 
+	// Pods keep streaming after the plugin deregistered; wait for them before pulling the module
+	if config.ManageModule && config.ModuleUnloadMode == ModuleUnloadDeferred && !v4l2Manager.IsFallbackMode() {
+		// A kept module only loses the devices the plugin added itself
+		paths := plugin.CtlAddedDevices()
+		if !config.KeepModuleOnExit {
+			paths = nil
+			for _, device := range v4l2Manager.ListAllDevices() {
+				paths = append(paths, device.Path)
+			}
+		}
+		if busy := waitForDevicesReleased(shutdownCtx, paths, time.Duration(config.ModuleUnloadDeadline)*time.Second, logger); len(busy) > 0 {
+			plugin.logDeviceHolders(shutdownCtx, "module unload deadline")
+		}
+	}
+
 	// Cleanup v4l2loopback module
-	cleanupV4L2Module(config, logger)
+	var unloadErr error
+	if err := withModuleLock(shutdownCtx, config, "unload", logger, func() error {
+		unloadErr = cleanupV4L2Module(shutdownCtx, config, plugin.CtlAddedDevices(), logger)
+		if config.AVBundleCount > 0 {
+			cleanupALSALoopbackModule(shutdownCtx, config, logger)
+		}
+		return nil
+	}); err != nil {
+		logger.Warn("Skipping module cleanup", "error", err)
+	}
+	if errors.Is(unloadErr, ErrModuleInUse) {
+		plugin.logDeviceHolders(shutdownCtx, "module unload at shutdown")
+	}
+
+	// Remove generated udev rules
+	if config.EnableUdevRules {
+		removeUdevRules(config, logger)
+	}
 
 	logger.Info("Video device plugin shutdown complete")
 }
 
-// waitForDevicesReady waits for devices to be created and ready
-func waitForDevicesReady(v4l2Manager V4L2Manager, config *DevicePluginConfig, logger *slog.Logger) error {
+// waitForDevicesReady waits for devices to be created and ready, or until ctx is canceled
+func waitForDevicesReady(ctx context.Context, v4l2Manager V4L2Manager, config *DevicePluginConfig, logger *slog.Logger) error {
 	logger.Info("Waiting for devices to be ready...")
 
 	// Wait for devices to be available
@@ -189,7 +539,11 @@ func waitForDevicesReady(v4l2Manager V4L2Manager, config *DevicePluginConfig, lo
 		}
 
 		logger.Debug("Waiting for devices to be ready...")
-		time.Sleep(checkInterval)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for devices: %w", context.Cause(ctx))
+		case <-time.After(checkInterval):
+		}
 	}
 
 	return fmt.Errorf("devices not ready after %v", maxWait)
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes every exported metric
+const metricsNamespace = "video_device_plugin"
+
+// Metrics holds the Prometheus collectors exported by the device plugin
+// All methods are safe to call on a nil *Metrics, which disables recording
+type Metrics struct {
+	registry              *prometheus.Registry
+	permissionCorrections *prometheus.CounterVec
+	allocationReplays     prometheus.Counter
+	bufferRecoveries      *prometheus.CounterVec
+	allocationRejections  *prometheus.CounterVec
+	schedulingExhaustion  *prometheus.CounterVec
+	kubeletDivergences    *prometheus.GaugeVec
+	healthTransitions     *prometheus.CounterVec
+	deviceFlapping        *prometheus.GaugeVec
+	moduleOpDuration      *prometheus.HistogramVec
+	moduleOpLastSuccess   *prometheus.GaugeVec
+	allocateQueueDepth    prometheus.Gauge
+	allocateQueueWait     prometheus.Histogram
+	allocationsInFlight   prometheus.Gauge
+	namespaceDevices      *prometheus.GaugeVec
+	namespaceQuota        *prometheus.GaugeVec
+	namespaceDenials      *prometheus.CounterVec
+	listAndWatchSend      *prometheus.HistogramVec
+}
+
+// NewMetrics creates and registers the device plugin collectors
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		permissionCorrections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "permission_corrections_total",
+			Help:      "Number of times device permissions or ownership were re-applied after drifting.",
+		}, []string{"device"}),
+		allocationReplays: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocation_replays_total",
+			Help:      "Number of duplicate Allocate requests answered from the replay cache.",
+		}),
+		bufferRecoveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "buffer_recoveries_total",
+			Help:      "Number of times a device was recreated with fewer buffers after a kernel buffer allocation failure.",
+		}, []string{"device"}),
+		allocationRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocation_rejections_total",
+			Help:      "Number of Allocate requests rejected for unknown or unadvertised device IDs, by gRPC code.",
+		}, []string{"code"}),
+		schedulingExhaustion: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "scheduling_exhaustion_total",
+			Help:      "Number of FailedScheduling occurrences for lack of devices while this node had none free.",
+		}, []string{"resource"}),
+		kubeletDivergences: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "kubelet_view_divergences",
+			Help:      "Device IDs on which kubelet's view disagrees with the node in the last conformance check, by kind.",
+		}, []string{"resource", "kind"}),
+		healthTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "device_health_transitions_total",
+			Help:      "Number of observed device health changes, by the state the device changed to.",
+		}, []string{"device", "state"}),
+		deviceFlapping: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "device_flapping",
+			Help:      "Whether a device is held Unhealthy after flapping (1) or not (0).",
+		}, []string{"device"}),
+		moduleOpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "module_operation_duration_seconds",
+			Help:      "Duration of kernel module load, unload, verify and v4l2loopback-ctl operations, by result.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 11),
+		}, []string{"operation", "module", "result"}),
+		moduleOpLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "module_operation_last_success_timestamp_seconds",
+			Help:      "Unix time of the last successful kernel module operation.",
+		}, []string{"operation", "module"}),
+		allocateQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocate_queue_depth",
+			Help:      "Allocate calls waiting for a free slot under MAX_CONCURRENT_ALLOCATIONS.",
+		}),
+		allocateQueueWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocate_queue_wait_seconds",
+			Help:      "Time Allocate calls waited for a free slot before preparing devices.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}),
+		allocationsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocations_in_flight",
+			Help:      "Allocate calls currently preparing devices.",
+		}),
+		namespaceDevices: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "namespace_devices_allocated",
+			Help:      "Video devices kubelet assigns to each namespace's pods on this node.",
+		}, []string{"namespace"}),
+		namespaceQuota: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "namespace_device_quota",
+			Help:      "Configured video device quota of each namespace on this node.",
+		}, []string{"namespace"}),
+		namespaceDenials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "namespace_quota_denials_total",
+			Help:      "Containers refused at PreStartContainer because their namespace was over its quota.",
+		}, []string{"namespace"}),
+		listAndWatchSend: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "list_and_watch_send_duration_seconds",
+			Help:      "Duration of ListAndWatch device list sends to kubelet, by result.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+		}, []string{"resource", "result"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.permissionCorrections,
+		m.allocationReplays,
+		m.bufferRecoveries,
+		m.allocationRejections,
+		m.schedulingExhaustion,
+		m.kubeletDivergences,
+		m.healthTransitions,
+		m.deviceFlapping,
+		m.moduleOpDuration,
+		m.moduleOpLastSuccess,
+		m.allocateQueueDepth,
+		m.allocateQueueWait,
+		m.allocationsInFlight,
+		m.namespaceDevices,
+		m.namespaceQuota,
+		m.namespaceDenials,
+		m.listAndWatchSend,
+	)
+
+	return m
+}
+
+// IncPermissionCorrections counts a permission/ownership correction on a device
+func (m *Metrics) IncPermissionCorrections(deviceID string) {
+	if m == nil {
+		return
+	}
+	m.permissionCorrections.WithLabelValues(deviceID).Inc()
+}
+
+// IncAllocationReplays counts an Allocate request answered from the replay cache
+func (m *Metrics) IncAllocationReplays() {
+	if m == nil {
+		return
+	}
+	m.allocationReplays.Inc()
+}
+
+// IncBufferRecoveries counts a device recreated with fewer buffers
+func (m *Metrics) IncBufferRecoveries(deviceID string) {
+	if m == nil {
+		return
+	}
+	m.bufferRecoveries.WithLabelValues(deviceID).Inc()
+}
+
+// IncAllocationRejections counts an Allocate request rejected by device ID validation
+func (m *Metrics) IncAllocationRejections(code string) {
+	if m == nil {
+		return
+	}
+	m.allocationRejections.WithLabelValues(code).Inc()
+}
+
+// AddSchedulingExhaustion counts FailedScheduling occurrences attributed to device exhaustion
+func (m *Metrics) AddSchedulingExhaustion(resource string, occurrences int) {
+	if m == nil {
+		return
+	}
+	m.schedulingExhaustion.WithLabelValues(resource).Add(float64(occurrences))
+}
+
+// SetKubeletViewDivergences records the divergences of one kind found by the conformance check
+func (m *Metrics) SetKubeletViewDivergences(resource, kind string, count int) {
+	if m == nil {
+		return
+	}
+	m.kubeletDivergences.WithLabelValues(resource, kind).Set(float64(count))
+}
+
+// IncHealthTransitions counts a device health change
+func (m *Metrics) IncHealthTransitions(deviceID, state string) {
+	if m == nil {
+		return
+	}
+	m.healthTransitions.WithLabelValues(deviceID, state).Inc()
+}
+
+// SetDeviceFlapping records whether a device is damped after flapping
+func (m *Metrics) SetDeviceFlapping(deviceID string, flapping bool) {
+	if m == nil {
+		return
+	}
+	value := 0.0
+	if flapping {
+		value = 1
+	}
+	m.deviceFlapping.WithLabelValues(deviceID).Set(value)
+}
+
+// ObserveModuleOperation records the duration and outcome of a kernel module operation
+func (m *Metrics) ObserveModuleOperation(operation, module string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.moduleOpDuration.WithLabelValues(operation, module, result).Observe(duration.Seconds())
+	if err == nil {
+		m.moduleOpLastSuccess.WithLabelValues(operation, module).SetToCurrentTime()
+	}
+}
+
+// SetAllocateQueueDepth records the Allocate calls waiting for a slot
+func (m *Metrics) SetAllocateQueueDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.allocateQueueDepth.Set(float64(depth))
+}
+
+// ObserveAllocateQueueWait records how long an Allocate call waited for a slot
+func (m *Metrics) ObserveAllocateQueueWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.allocateQueueWait.Observe(wait.Seconds())
+}
+
+// SetAllocationsInFlight records the Allocate calls holding a slot
+func (m *Metrics) SetAllocationsInFlight(count int) {
+	if m == nil {
+		return
+	}
+	m.allocationsInFlight.Set(float64(count))
+}
+
+// SetNamespaceDevices records the devices and quota of every namespace
+// Namespaces that no longer hold devices are dropped
+func (m *Metrics) SetNamespaceDevices(usage []NamespaceUsage) {
+	if m == nil {
+		return
+	}
+	m.namespaceDevices.Reset()
+	m.namespaceQuota.Reset()
+	for _, entry := range usage {
+		m.namespaceDevices.WithLabelValues(entry.Namespace).Set(float64(len(entry.Devices)))
+		if entry.Quota != nil {
+			m.namespaceQuota.WithLabelValues(entry.Namespace).Set(float64(*entry.Quota))
+		}
+	}
+}
+
+// IncNamespaceQuotaDenials counts a container refused over its namespace quota
+func (m *Metrics) IncNamespaceQuotaDenials(namespace string) {
+	if m == nil {
+		return
+	}
+	m.namespaceDenials.WithLabelValues(namespace).Inc()
+}
+
+// ObserveListAndWatchSend records the duration and outcome of a device list send
+func (m *Metrics) ObserveListAndWatchSend(resource string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.listAndWatchSend.WithLabelValues(resource, result).Observe(duration.Seconds())
+}
+
+// WatchListAndWatchSends exports the time since the last successful send of every resource
+// It grows while a stream is half-dead: kubelet stopped reading and Send blocks or fails
+func (m *Metrics) WatchListAndWatchSends(sent *ListAndWatchRecorder) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&sinceLastSendCollector{
+		sent: sent,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "list_and_watch_seconds_since_last_send"),
+			"Seconds since the last device list kubelet accepted on ListAndWatch, by resource.",
+			[]string{"resource"}, nil),
+	})
+}
+
+// sinceLastSendCollector computes the age of the last successful send at scrape time
+type sinceLastSendCollector struct {
+	sent *ListAndWatchRecorder
+	desc *prometheus.Desc
+}
+
+func (c *sinceLastSendCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *sinceLastSendCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	for _, snapshot := range c.sent.Snapshots() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(snapshot.SentAt).Seconds(), snapshot.Resource)
+	}
+}
+
+// Handler returns the HTTP handler serving the metrics registry
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// startMetricsServer serves /metrics on the configured port in the background
+func startMetricsServer(port int, metrics *Metrics, logger *slog.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Starting metrics server", "port", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", "error", err)
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metrics backends selectable through METRICS_BACKENDS
+const (
+	MetricsBackendPrometheus = "prometheus" // Serve /metrics on METRICS_PORT for scraping
+	MetricsBackendStatsD     = "statsd"     // Push to a (Dog)StatsD agent over UDP
+	MetricsBackendOTLP       = "otlp"       // Push to an OTLP/HTTP metrics endpoint as JSON
+)
+
+// statsdMaxPacket keeps StatsD datagrams below common MTU-safe sizes
+const statsdMaxPacket = 1400
+
+// metricsBackend receives the gathered metric families on every push interval
+// Instrumentation only talks to Metrics; backends read the same registry Prometheus serves
+type metricsBackend interface {
+	Name() string
+	Push(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+// parseMetricsBackends splits METRICS_BACKENDS into its validated backend names
+func parseMetricsBackends(value string) ([]string, error) {
+	var backends []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		switch name {
+		case MetricsBackendPrometheus, MetricsBackendStatsD, MetricsBackendOTLP:
+		default:
+			return nil, fmt.Errorf("METRICS_BACKENDS entry %q must be prometheus, statsd or otlp", name)
+		}
+		seen[name] = true
+		backends = append(backends, name)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("METRICS_BACKENDS must name at least one backend")
+	}
+	return backends, nil
+}
+
+// hasMetricsBackend reports whether METRICS_BACKENDS selects backend
+func hasMetricsBackend(config *DevicePluginConfig, backend string) bool {
+	backends, _ := parseMetricsBackends(config.MetricsBackends)
+	for _, name := range backends {
+		if name == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsPusher periodically pushes the metrics registry to the configured push backends
+type MetricsPusher struct {
+	metrics  *Metrics
+	backends []metricsBackend
+	otlp     *otlpBackend // Nil unless the otlp backend is selected
+	interval time.Duration
+	logger   *slog.Logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMetricsPusher creates a pusher for the push backends named in METRICS_BACKENDS
+// It returns nil when only Prometheus is selected
+func NewMetricsPusher(config *DevicePluginConfig, metrics *Metrics, logger *slog.Logger) *MetricsPusher {
+	var backends []metricsBackend
+	var otlp *otlpBackend
+	if hasMetricsBackend(config, MetricsBackendStatsD) {
+		backends = append(backends, newStatsDBackend(config.StatsDAddress, config.NodeName))
+	}
+	if hasMetricsBackend(config, MetricsBackendOTLP) {
+		headers, _ := parseOTLPHeaders(config.OTLPMetricsHeaders)
+		otlp = newOTLPBackend(config.OTLPMetricsEndpoint, headers, config.NodeName)
+		backends = append(backends, otlp)
+	}
+	if len(backends) == 0 {
+		return nil
+	}
+	return &MetricsPusher{
+		metrics:  metrics,
+		backends: backends,
+		otlp:     otlp,
+		interval: time.Duration(config.MetricsPushInterval) * time.Second,
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start pushes metrics every interval in the background until ctx is canceled or Stop is called
+func (m *MetricsPusher) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	names := make([]string, 0, len(m.backends))
+	for _, backend := range m.backends {
+		names = append(names, backend.Name())
+	}
+	m.logger.Info("Starting metrics push", "backends", names, "interval", m.interval.String())
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// Deliver what changed since the last interval before exiting, past the cancellation
+				m.push(context.WithoutCancel(ctx))
+				return
+			case <-ticker.C:
+				m.push(ctx)
+			}
+		}
+	}()
+}
+
+// Stop pushes a final time and stops the pusher
+func (m *MetricsPusher) Stop() {
+	m.stopOnce.Do(func() { m.cancel() })
+	<-m.done
+}
+
+// SetOTLPEndpoint changes the OTLP endpoint of the next pushes (e.g. a rotated mounted URL)
+func (m *MetricsPusher) SetOTLPEndpoint(endpoint string) error {
+	if m.otlp == nil {
+		return nil
+	}
+	if err := validateOTLPEndpoint(endpoint); err != nil {
+		return err
+	}
+	m.otlp.mu.Lock()
+	defer m.otlp.mu.Unlock()
+	m.otlp.endpoint = endpoint
+	return nil
+}
+
+// SetOTLPHeaders changes the headers of the next OTLP pushes (e.g. a rotated mounted token)
+func (m *MetricsPusher) SetOTLPHeaders(value string) error {
+	if m.otlp == nil {
+		return nil
+	}
+	headers, err := parseOTLPHeaders(value)
+	if err != nil {
+		return err
+	}
+	m.otlp.mu.Lock()
+	defer m.otlp.mu.Unlock()
+	m.otlp.headers = headers
+	return nil
+}
+
+// push gathers the registry once and hands it to every backend, each bounded by the interval
+func (m *MetricsPusher) push(ctx context.Context) {
+	families, err := m.metrics.registry.Gather()
+	if err != nil {
+		m.logger.Warn("Failed to gather metrics for push", "error", err)
+		return
+	}
+	for _, backend := range m.backends {
+		pushCtx, cancel := context.WithTimeout(ctx, m.interval)
+		if err := backend.Push(pushCtx, families); err != nil {
+			m.logger.Warn("Failed to push metrics", "backend", backend.Name(), "error", err)
+		}
+		cancel()
+	}
+}
+
+// statsdBackend sends gauges as gauges and counters as deltas in DogStatsD format
+type statsdBackend struct {
+	address  string
+	node     string
+	previous map[string]float64 // Last cumulative value per counter series
+}
+
+// newStatsDBackend creates a StatsD backend sending to address (host:port)
+func newStatsDBackend(address, node string) *statsdBackend {
+	return &statsdBackend{address: address, node: node, previous: make(map[string]float64)}
+}
+
+// Name implements metricsBackend
+func (s *statsdBackend) Name() string { return MetricsBackendStatsD }
+
+// Push implements metricsBackend
+func (s *statsdBackend) Push(ctx context.Context, families []*dto.MetricFamily) error {
+	var lines []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			tags := s.tags(metric.GetLabel())
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				lines = s.appendCounter(lines, family.GetName(), tags, metric.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = append(lines, statsdLine(family.GetName(), metric.GetGauge().GetValue(), "g", tags))
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				lines = s.appendCounter(lines, family.GetName()+"_count", tags, float64(histogram.GetSampleCount()))
+				lines = s.appendCounter(lines, family.GetName()+"_sum", tags, histogram.GetSampleSum())
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %w", s.address, err)
+	}
+	defer conn.Close()
+
+	// Pack lines into datagrams below statsdMaxPacket
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return fmt.Errorf("failed to send to %s: %w", s.address, err)
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return fmt.Errorf("failed to send to %s: %w", s.address, err)
+	}
+	return nil
+}
+
+// appendCounter appends the increase of a cumulative counter since the previous push
+func (s *statsdBackend) appendCounter(lines []string, name string, tags []string, value float64) []string {
+	key := name + "|" + strings.Join(tags, ",")
+	delta := value - s.previous[key]
+	s.previous[key] = value
+	if delta < 0 {
+		// The registry was recreated (module reload or restart); the value is all new
+		delta = value
+	}
+	if delta == 0 {
+		return lines
+	}
+	return append(lines, statsdLine(name, delta, "c", tags))
+}
+
+// tags renders labels as sorted DogStatsD tags, including the node
+func (s *statsdBackend) tags(labels []*dto.LabelPair) []string {
+	tags := make([]string, 0, len(labels)+1)
+	if s.node != "" {
+		tags = append(tags, "node:"+s.node)
+	}
+	for _, label := range labels {
+		tags = append(tags, label.GetName()+":"+label.GetValue())
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// statsdLine formats one DogStatsD metric line
+func statsdLine(name string, value float64, kind string, tags []string) string {
+	line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
+
+// otlpBackend posts cumulative metrics to an OTLP/HTTP endpoint using the JSON encoding
+type otlpBackend struct {
+	mu       sync.RWMutex
+	endpoint string
+	headers  map[string]string // Extra request headers (OTLP_METRICS_HEADERS), e.g. authorization
+	node     string
+	start    time.Time
+	client   *http.Client
+}
+
+// newOTLPBackend creates an OTLP backend posting to endpoint (e.g. http://localhost:4318/v1/metrics)
+func newOTLPBackend(endpoint string, headers map[string]string, node string) *otlpBackend {
+	return &otlpBackend{endpoint: endpoint, headers: headers, node: node, start: time.Now(), client: &http.Client{}}
+}
+
+// validateOTLPEndpoint checks OTLP_METRICS_ENDPOINT is an http(s) URL
+// The value is left out of the error since it may carry credentials
+func validateOTLPEndpoint(endpoint string) error {
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("OTLP_METRICS_ENDPOINT must be an http(s) URL")
+	}
+	return nil
+}
+
+// parseOTLPHeaders parses OTLP_METRICS_HEADERS ("Name=value,Name=value"); values may contain '='
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, headerValue, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("OTLP_METRICS_HEADERS entries must be Name=value")
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// Name implements metricsBackend
+func (o *otlpBackend) Name() string { return MetricsBackendOTLP }
+
+// OTLP JSON payload, limited to the fields this plugin produces
+type (
+	otlpAttribute struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsDouble          *float64        `json:"asDouble,omitempty"`
+		Count             string          `json:"count,omitempty"`
+		Sum               *float64        `json:"sum,omitempty"`
+		BucketCounts      []string        `json:"bucketCounts,omitempty"`
+		ExplicitBounds    []float64       `json:"explicitBounds,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpHistogram struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+	}
+	otlpMetric struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description,omitempty"`
+		Sum         *otlpSum       `json:"sum,omitempty"`
+		Gauge       *otlpGauge     `json:"gauge,omitempty"`
+		Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	}
+)
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+// Push implements metricsBackend
+func (o *otlpBackend) Push(ctx context.Context, families []*dto.MetricFamily) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	start := strconv.FormatInt(o.start.UnixNano(), 10)
+
+	var metrics []otlpMetric
+	for _, family := range families {
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		for _, m := range family.GetMetric() {
+			point := otlpDataPoint{Attributes: otlpAttributes(m.GetLabel()), StartTimeUnixNano: start, TimeUnixNano: now}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				if metric.Sum == nil {
+					metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+				}
+				value := m.GetCounter().GetValue()
+				point.AsDouble = &value
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+			case dto.MetricType_GAUGE:
+				if metric.Gauge == nil {
+					metric.Gauge = &otlpGauge{}
+				}
+				value := m.GetGauge().GetValue()
+				point.AsDouble, point.StartTimeUnixNano = &value, ""
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+			case dto.MetricType_HISTOGRAM:
+				if metric.Histogram == nil {
+					metric.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+				}
+				histogram := m.GetHistogram()
+				sum := histogram.GetSampleSum()
+				point.Count = strconv.FormatUint(histogram.GetSampleCount(), 10)
+				point.Sum = &sum
+				// Prometheus buckets are cumulative, OTLP bucket counts are per bucket
+				var previous uint64
+				for _, bucket := range histogram.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), 1) {
+						continue
+					}
+					point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+					point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+					previous = bucket.GetCumulativeCount()
+				}
+				point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(histogram.GetSampleCount()-previous, 10))
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, point)
+			}
+		}
+		if metric.Sum != nil || metric.Gauge != nil || metric.Histogram != nil {
+			metrics = append(metrics, metric)
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	hostname, _ := os.Hostname()
+	payload := map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttribute{
+				{Key: "service.name", Value: map[string]string{"stringValue": "video-device-plugin"}},
+				{Key: "service.version", Value: map[string]string{"stringValue": pluginVersion}},
+				{Key: "k8s.node.name", Value: map[string]string{"stringValue": o.node}},
+				{Key: "host.name", Value: map[string]string{"stringValue": hostname}},
+			}},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   map[string]string{"name": "github.com/Meeting-BaaS/video-device-plugin"},
+				"metrics": metrics,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP payload: %w", err)
+	}
+
+	o.mu.RLock()
+	endpoint, headers := o.endpoint, o.headers
+	o.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// otlpAttributes converts Prometheus labels to OTLP string attributes
+func otlpAttributes(labels []*dto.LabelPair) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for _, label := range labels {
+		attributes = append(attributes, otlpAttribute{Key: label.GetName(), Value: map[string]string{"stringValue": label.GetValue()}})
+	}
+	return attributes
+}
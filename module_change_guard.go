@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Policies for a module reload that would recreate devices kubelet assigned to pods (MODULE_CHANGE_POLICY)
+const (
+	ModuleChangeDefer  = "defer"  // Serve the loaded module and reload once every device is released
+	ModuleChangeRefuse = "refuse" // Serve the loaded module until the plugin is restarted on a drained node
+)
+
+// moduleChangeQueryTimeout bounds the PodResources query guarding a reload
+const moduleChangeQueryTimeout = 5 * time.Second
+
+// moduleParameterChanges lists the parameters of the loaded module that differ from the configuration
+// exclusive_caps is compared per device (tiers carry their own), max_buffers module-wide.
+// Parameters the loaded build does not expose are not compared.
+func moduleParameterChanges(config *DevicePluginConfig) []string {
+	var changes []string
+
+	if data, err := os.ReadFile(filepath.Join(v4l2loopbackParamsDir, "exclusive_caps")); err == nil {
+		loaded := strings.Split(strings.TrimSpace(string(data)), ",")
+		tiers := tierVideoIDs(config)
+		var differing []string
+		for i := 0; i < config.MaxDevices && i < len(loaded); i++ {
+			deviceID := fmt.Sprintf("video%d", config.VideoDeviceStart+i)
+			expected := config.V4L2ExclusiveCaps
+			if tier, ok := tiers[deviceID]; ok {
+				expected = tier.ExclusiveCaps
+			}
+			// Bool array parameters read back as Y/N, int arrays as 1/0
+			actual := 0
+			if value := strings.TrimSpace(loaded[i]); value == "Y" || value == "1" {
+				actual = 1
+			}
+			if actual != expected {
+				differing = append(differing, fmt.Sprintf("%s %d->%d", deviceID, actual, expected))
+			}
+		}
+		if len(differing) > 0 {
+			changes = append(changes, "exclusive_caps "+strings.Join(differing, ","))
+		}
+	}
+
+	if maxBuffers, ok := readModuleParamInt("max_buffers"); ok && maxBuffers != config.V4L2MaxBuffers {
+		changes = append(changes, fmt.Sprintf("max_buffers %d->%d", maxBuffers, config.V4L2MaxBuffers))
+	}
+	return changes
+}
+
+// kubeletAssignedDevices returns the device IDs of the plugin's resources kubelet assigns to pods
+// It only needs kubelet, so it works before this instance registered
+func kubeletAssignedDevices(ctx context.Context, config *DevicePluginConfig) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, moduleChangeQueryTimeout)
+	defer cancel()
+	assigned, err := listAssignedDevices(ctx, config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []string{config.ResourceName, config.AVBundleResourceName}
+	for _, tier := range buildDeviceTiers(config) {
+		resources = append(resources, tier.ResourceName)
+	}
+	var deviceIDs []string
+	for _, resource := range resources {
+		for deviceID := range assigned[resource] {
+			deviceIDs = append(deviceIDs, deviceID)
+		}
+	}
+	sort.Strings(deviceIDs)
+	return deviceIDs, nil
+}
+
+// guardModuleReload refuses a reload while kubelet assigns devices to pods
+// Pods keep an assigned device between streams without holding it open, so an unload that
+// succeeds would still pull the device from under them. The returned error wraps ErrModuleInUse
+// so the caller keeps serving the loaded module.
+func guardModuleReload(ctx context.Context, config *DevicePluginConfig, changes []string, logger *slog.Logger) error {
+	assigned, err := kubeletAssignedDevices(ctx, config)
+	if err != nil {
+		// Without kubelet's view only devices held open keep the module loaded
+		logger.Warn("Cannot read kubelet device assignments before reloading v4l2loopback", "error", err)
+		return nil
+	}
+	if len(assigned) == 0 {
+		return nil
+	}
+	logger.Warn("Refusing to reload v4l2loopback while devices are allocated",
+		"allocated_devices", assigned,
+		"pending_changes", changes,
+		"policy", config.ModuleChangePolicy)
+	return fmt.Errorf("%w: %d device(s) allocated to pods (%s)", ErrModuleInUse, len(assigned), strings.Join(assigned, ", "))
+}
+
+// pendingModuleChange summarizes why the loaded module does not match the configuration
+func pendingModuleChange(config *DevicePluginConfig, logger *slog.Logger) string {
+	changes := moduleParameterChanges(config)
+	if err := verifyV4L2Configuration(config, logger); err != nil {
+		changes = append([]string{err.Error()}, changes...)
+	}
+	return strings.Join(changes, "; ")
+}
+
+// setPendingModuleChange records the module change waiting for a reload ("" once applied)
+func (p *VideoDevicePlugin) setPendingModuleChange(change string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pendingModuleChange = change
+}
+
+// PendingModuleChange returns the module change waiting for a reload, "" when none is
+func (p *VideoDevicePlugin) PendingModuleChange() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pendingModuleChange
+}
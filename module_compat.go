@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moduleParam is a single v4l2loopback module parameter
+type moduleParam struct {
+	Name  string
+	Value string
+}
+
+// moduleCompatProfile describes parameter adjustments for a kernel/architecture combination
+type moduleCompatProfile struct {
+	Name           string            // Profile name, logged when selected
+	Arch           string            // Machine architecture (uname -m), empty matches any
+	MinKernelMajor int               // Minimum kernel major version, 0 matches any
+	MaxKernelMajor int               // Maximum kernel major version, 0 matches any
+	ParamRenames   map[string]string // Canonical parameter name -> name used by this build
+	DropParams     []string          // Parameters unsupported by this build
+	ExtraParams    []moduleParam     // Parameters pinned explicitly because build defaults differ
+}
+
+// moduleCompatProfiles is the compatibility table; every matching profile applies, in order,
+// so an architecture entry never hides a kernel version entry. Only quirks of released
+// v4l2loopback builds belong here.
+var moduleCompatProfiles = []moduleCompatProfile{
+	{
+		// Pre-5.x kernels can only carry old v4l2loopback releases without exclusive_caps
+		Name:           "legacy-4.x",
+		MaxKernelMajor: 4,
+		DropParams:     []string{"exclusive_caps"},
+	},
+}
+
+// matches reports whether the profile applies to the given architecture and kernel major version
+// An unknown kernel version (0) only matches profiles without version bounds
+func (p moduleCompatProfile) matches(arch string, kernelMajor int) bool {
+	if p.Arch != "" && p.Arch != arch {
+		return false
+	}
+	if kernelMajor == 0 && (p.MinKernelMajor > 0 || p.MaxKernelMajor > 0) {
+		return false
+	}
+	if p.MinKernelMajor > 0 && kernelMajor < p.MinKernelMajor {
+		return false
+	}
+	if p.MaxKernelMajor > 0 && kernelMajor > p.MaxKernelMajor {
+		return false
+	}
+	return true
+}
+
+// apply renames, drops and extends parameters according to the profile
+func (p moduleCompatProfile) apply(params []moduleParam) []moduleParam {
+	dropped := make(map[string]bool, len(p.DropParams))
+	for _, name := range p.DropParams {
+		dropped[name] = true
+	}
+
+	result := make([]moduleParam, 0, len(params)+len(p.ExtraParams))
+	for _, param := range params {
+		if dropped[param.Name] {
+			continue
+		}
+		if renamed, ok := p.ParamRenames[param.Name]; ok {
+			param.Name = renamed
+		}
+		result = append(result, param)
+	}
+
+	return append(result, p.ExtraParams...)
+}
+
+// selectModuleCompatProfile merges the compatibility profiles matching the running system in
+// table order; later profiles add to the renames, drops and pinned parameters of earlier ones
+func selectModuleCompatProfile(arch, kernelVersion string) moduleCompatProfile {
+	major := parseKernelMajor(kernelVersion)
+	merged := moduleCompatProfile{ParamRenames: make(map[string]string)}
+	var names []string
+	for _, profile := range moduleCompatProfiles {
+		if !profile.matches(arch, major) {
+			continue
+		}
+		names = append(names, profile.Name)
+		for from, to := range profile.ParamRenames {
+			merged.ParamRenames[from] = to
+		}
+		merged.DropParams = append(merged.DropParams, profile.DropParams...)
+		merged.ExtraParams = append(merged.ExtraParams, profile.ExtraParams...)
+	}
+	merged.Name = "default"
+	if len(names) > 0 {
+		merged.Name = strings.Join(names, "+")
+	}
+	return merged
+}
+
+// parseKernelMajor extracts the major version from a kernel release string (e.g., "6.8.0-90-generic")
+func parseKernelMajor(kernelVersion string) int {
+	majorStr, _, _ := strings.Cut(kernelVersion, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// supportedModuleParams returns the parameter names the module file accepts, or nil if unknown
+func supportedModuleParams(modulePath string) map[string]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	name, args := moduleCommandLine("modinfo", []string{"-F", "parm", modulePath})
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return nil
+	}
+
+	supported := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, _, ok := strings.Cut(line, ":"); ok {
+			supported[strings.TrimSpace(name)] = true
+		}
+	}
+	if len(supported) == 0 {
+		return nil
+	}
+	return supported
+}
+
+// buildModuleArgs applies the compatibility profile and drops parameters the module rejects
+func buildModuleArgs(params []moduleParam, modulePath, kernelVersion string, logger *slog.Logger) []string {
+	arch := ""
+	if out, err := exec.Command("uname", "-m").Output(); err == nil {
+		arch = strings.TrimSpace(string(out))
+	}
+
+	profile := selectModuleCompatProfile(arch, kernelVersion)
+	adjusted := profile.apply(params)
+	logger.Info("Selected module compatibility profile",
+		"profile", profile.Name,
+		"arch", arch,
+		"kernel_version", kernelVersion)
+
+	supported := supportedModuleParams(modulePath)
+	args := make([]string, 0, len(adjusted))
+	for _, param := range adjusted {
+		// A rename only applies when the build really uses the other name
+		if supported != nil && !supported[param.Name] {
+			for canonical, renamed := range profile.ParamRenames {
+				if renamed == param.Name && supported[canonical] {
+					param.Name = canonical
+				}
+			}
+		}
+		if supported != nil && !supported[param.Name] {
+			logger.Warn("Dropping module parameter unsupported by this v4l2loopback build",
+				"param", param.Name,
+				"module_path", modulePath)
+			continue
+		}
+		args = append(args, fmt.Sprintf("%s=%s", param.Name, param.Value))
+	}
+
+	return args
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectModuleCompatProfile(t *testing.T) {
+	params := []moduleParam{
+		{Name: "devices", Value: "8"},
+		{Name: "exclusive_caps", Value: "1,1"},
+	}
+
+	tests := []struct {
+		name          string
+		arch          string
+		kernelVersion string
+		wantProfile   string
+		wantParams    []string
+	}{
+		{"arm64 legacy kernel", "aarch64", "4.19.0-26-arm64", "legacy-4.x", []string{"devices"}},
+		{"x86_64 legacy kernel", "x86_64", "4.15.0-213-generic", "legacy-4.x", []string{"devices"}},
+		{"arm64 current kernel", "aarch64", "6.8.0-1012-aws", "default", []string{"devices", "exclusive_caps"}},
+		{"unparsable kernel", "x86_64", "unknown", "default", []string{"devices", "exclusive_caps"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := selectModuleCompatProfile(tt.arch, tt.kernelVersion)
+			if profile.Name != tt.wantProfile {
+				t.Errorf("profile = %q, want %q", profile.Name, tt.wantProfile)
+			}
+			var names []string
+			for _, param := range profile.apply(params) {
+				names = append(names, param.Name)
+			}
+			if !slices.Equal(names, tt.wantParams) {
+				t.Errorf("params = %v, want %v", names, tt.wantParams)
+			}
+		})
+	}
+}
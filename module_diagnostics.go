@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxKernelLogLines bounds how many matching kernel log lines are kept in diagnostics
+const maxKernelLogLines = 20
+
+// Known kernel log failure signatures for module loading
+const (
+	SignatureUnknownSymbol    = "unknown_symbol"
+	SignatureSymbolVersion    = "symbol_version_mismatch"
+	SignatureVersionMagic     = "version_magic"
+	SignatureModuleSignature  = "module_signature_rejected"
+	SignatureTaint            = "kernel_taint"
+	SignatureInvalidParameter = "invalid_parameter"
+)
+
+// moduleFailurePatterns maps kernel log substrings (lowercase) to failure signatures
+var moduleFailurePatterns = []struct {
+	pattern   string
+	signature string
+}{
+	{"unknown symbol", SignatureUnknownSymbol},
+	{"disagrees about version of symbol", SignatureSymbolVersion},
+	{"version magic", SignatureVersionMagic},
+	{"module verification failed", SignatureModuleSignature},
+	{"key was rejected", SignatureModuleSignature},
+	{"required key not available", SignatureModuleSignature},
+	{"taints kernel", SignatureTaint},
+	{"tainting kernel", SignatureTaint},
+	{"unknown parameter", SignatureInvalidParameter},
+	{"invalid parameter", SignatureInvalidParameter},
+}
+
+// bufferExhaustionPatterns are kernel log substrings (lowercase) of loopback buffer allocation failures
+var bufferExhaustionPatterns = []string{
+	"failed to allocate",
+	"allocation failure",
+	"out of memory",
+	"cannot allocate memory",
+}
+
+// moduleDiagnosticsRemediation gives operators a next step for each signature
+var moduleDiagnosticsRemediation = map[string]string{
+	SignatureUnknownSymbol:    "load videodev first or rebuild v4l2loopback against the running kernel",
+	SignatureSymbolVersion:    "rebuild v4l2loopback for the exact running kernel version",
+	SignatureVersionMagic:     "KERNEL_VERSION of the image does not match the node kernel; rebuild the image",
+	SignatureModuleSignature:  "sign the module or disable module signature enforcement on the node",
+	SignatureTaint:            "module is out-of-tree or unsigned; informational unless enforcement is enabled",
+	SignatureInvalidParameter: "module parameters are not supported by this v4l2loopback build",
+}
+
+// ModuleDiagnostics holds structured kernel log findings for a module load failure
+type ModuleDiagnostics struct {
+	KernelLogLines []string `json:"kernel_log_lines,omitempty"` // Kernel log lines mentioning the modules
+	Signatures     []string `json:"signatures,omitempty"`       // Classified failure signatures
+	Remediation    []string `json:"remediation,omitempty"`      // Suggested next steps per signature
+	Unavailable    string   `json:"unavailable,omitempty"`      // Why the kernel log could not be read
+}
+
+// collectModuleDiagnostics scrapes the kernel log for v4l2loopback/videodev lines and classifies them
+func collectModuleDiagnostics(logger *slog.Logger) *ModuleDiagnostics {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "dmesg").Output()
+	if err != nil {
+		logger.Debug("dmesg not available or restricted", "error", err)
+		return &ModuleDiagnostics{Unavailable: err.Error()}
+	}
+
+	return parseModuleDiagnostics(string(output))
+}
+
+// recordModuleLoadFailure emits a Warning node event carrying the classified kernel log signatures
+// Module loading runs before the plugin's Kubernetes client exists, so a short-lived one is created
+func recordModuleLoadFailure(ctx context.Context, config *DevicePluginConfig, moduleErr *ModuleLoadError, logger *slog.Logger) {
+	if !config.EnableEvents || !config.EnableKubernetesAPI {
+		return
+	}
+	client, err := NewK8sClient(config, logger)
+	if err != nil {
+		logger.Debug("Cannot record the module load failure event", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.RecordNodeEvent(ctx, corev1.EventTypeWarning, "ModuleLoadFailed", moduleErr.EventMessage()); err != nil {
+		logger.Warn("Failed to record the module load failure event", "error", err)
+	}
+}
+
+// parseModuleDiagnostics filters and classifies kernel log output
+func parseModuleDiagnostics(kernelLog string) *ModuleDiagnostics {
+	diagnostics := &ModuleDiagnostics{}
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(kernelLog, "\n") {
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "v4l2loopback") && !strings.Contains(lower, "videodev") {
+			continue
+		}
+
+		diagnostics.KernelLogLines = append(diagnostics.KernelLogLines, strings.TrimSpace(line))
+
+		for _, p := range moduleFailurePatterns {
+			if strings.Contains(lower, p.pattern) && !seen[p.signature] {
+				seen[p.signature] = true
+				diagnostics.Signatures = append(diagnostics.Signatures, p.signature)
+				diagnostics.Remediation = append(diagnostics.Remediation, moduleDiagnosticsRemediation[p.signature])
+			}
+		}
+	}
+
+	// Keep only the most recent lines
+	if len(diagnostics.KernelLogLines) > maxKernelLogLines {
+		diagnostics.KernelLogLines = diagnostics.KernelLogLines[len(diagnostics.KernelLogLines)-maxKernelLogLines:]
+	}
+
+	return diagnostics
+}
+
+// parseBufferExhaustion returns v4l2loopback kernel log lines reporting buffer allocation failures
+func parseBufferExhaustion(kernelLog string) []string {
+	var lines []string
+	for _, line := range strings.Split(kernelLog, "\n") {
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "v4l2loopback") && !strings.Contains(lower, "v4l2-loopback") {
+			continue
+		}
+		for _, pattern := range bufferExhaustionPatterns {
+			if strings.Contains(lower, pattern) {
+				lines = append(lines, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Module command execution modes (MODULE_EXEC_MODE)
+const (
+	ModuleExecAuto      = "auto"      // Preflight decides between container and nsenter
+	ModuleExecContainer = "container" // Run modprobe/insmod with the container's /lib/modules
+	ModuleExecNsenter   = "nsenter"   // Run them in the host's mount and pid namespaces
+)
+
+// hostRoot is the host filesystem as seen through PID 1 (requires hostPID)
+const hostRoot = "/proc/1/root"
+
+// moduleExecMode is the resolved execution mode of module commands
+var moduleExecMode = ModuleExecContainer
+
+// moduleCommand runs a module management command (modprobe, insmod, modinfo) in the resolved mode
+func moduleCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	name, args = moduleCommandLine(name, args)
+	return privilegedCommand(ctx, name, args...)
+}
+
+// moduleCommandLine wraps a command in nsenter when module commands run on the host
+func moduleCommandLine(name string, args []string) (string, []string) {
+	if moduleExecMode != ModuleExecNsenter {
+		return name, args
+	}
+	return "nsenter", append([]string{"--target", "1", "--mount", "--pid", "--", name}, args...)
+}
+
+// moduleFilePath returns where this process can stat a module file that module commands will read
+func moduleFilePath(path string) string {
+	if moduleExecMode == ModuleExecNsenter {
+		return filepath.Join(hostRoot, path)
+	}
+	return path
+}
+
+// resolveModuleExecMode runs the preflight deciding how module commands are executed
+// The container path needs modules.dep of the running kernel under /lib/modules; nsenter needs
+// hostPID, CAP_SYS_ADMIN and the nsenter binary
+func resolveModuleExecMode(config *DevicePluginConfig, logger *slog.Logger) (string, error) {
+	if config.ModuleExecMode == ModuleExecContainer {
+		return ModuleExecContainer, nil
+	}
+
+	kernelVersion, err := kernelRelease()
+	if err != nil {
+		return "", err
+	}
+	containerReason := containerModulesUsable(kernelVersion)
+	nsenterReason := nsenterUsable()
+
+	switch config.ModuleExecMode {
+	case ModuleExecNsenter:
+		if nsenterReason != "" {
+			return "", fmt.Errorf("MODULE_EXEC_MODE=nsenter is unusable: %s", nsenterReason)
+		}
+		return ModuleExecNsenter, nil
+	default:
+		if containerReason == "" {
+			return ModuleExecContainer, nil
+		}
+		if nsenterReason == "" {
+			logger.Warn("Container module tree is incomplete, running module commands in the host mount namespace",
+				"reason", containerReason)
+			return ModuleExecNsenter, nil
+		}
+		logger.Warn("Container module tree is incomplete and nsenter is unusable, module loads will likely fail",
+			"container_reason", containerReason,
+			"nsenter_reason", nsenterReason)
+		return ModuleExecContainer, nil
+	}
+}
+
+// kernelRelease returns the running kernel release (uname -r)
+func kernelRelease() (string, error) {
+	out, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get kernel version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// containerModulesUsable returns why the container's module tree cannot serve modprobe, or ""
+func containerModulesUsable(kernelVersion string) string {
+	depPath := filepath.Join("/lib/modules", kernelVersion, "modules.dep")
+	if _, err := os.Stat(depPath); err != nil {
+		return fmt.Sprintf("%s is missing", depPath)
+	}
+	return ""
+}
+
+// nsenterUsable returns why module commands cannot run in the host namespaces, or ""
+func nsenterUsable() string {
+	if _, err := exec.LookPath("nsenter"); err != nil {
+		return "nsenter binary not found"
+	}
+	hostNS, err := os.Readlink("/proc/1/ns/mnt")
+	if err != nil {
+		return fmt.Sprintf("cannot inspect PID 1 mount namespace (hostPID required): %v", err)
+	}
+	selfNS, err := os.Readlink("/proc/self/ns/mnt")
+	if err == nil && selfNS == hostNS {
+		return "already running in PID 1 mount namespace (no hostPID or running on the host)"
+	}
+	return ""
+}
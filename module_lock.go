@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// moduleLockPollInterval is how often a contended module lock is retried
+const moduleLockPollInterval = 200 * time.Millisecond
+
+// withModuleLock runs fn while holding the host-wide module lock (flock on MODULE_LOCK_PATH)
+// Init containers and host scripts that load v4l2loopback/snd-aloop can take the same lock
+// (e.g. `flock /var/lib/video-device-plugin/module.lock modprobe ...`) so loads never interleave.
+// The holder writes an owner record into the lock file so waiters can report who holds it.
+func withModuleLock(ctx context.Context, config *DevicePluginConfig, operation string, logger *slog.Logger, fn func() error) error {
+	if config.ModuleLockPath == "" {
+		return fn()
+	}
+
+	file, err := acquireModuleLock(ctx, config, operation, logger)
+	if err != nil {
+		return err
+	}
+	defer releaseModuleLock(file, operation, logger)
+
+	return fn()
+}
+
+// acquireModuleLock takes an exclusive flock, waiting up to MODULE_LOCK_TIMEOUT or until ctx ends
+func acquireModuleLock(ctx context.Context, config *DevicePluginConfig, operation string, logger *slog.Logger) (*os.File, error) {
+	if err := ensureDirectory(filepath.Dir(config.ModuleLockPath)); err != nil {
+		return nil, fmt.Errorf("failed to create module lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(config.ModuleLockPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open module lock %s: %w", config.ModuleLockPath, err)
+	}
+
+	start := time.Now()
+	deadline := start.Add(time.Duration(config.ModuleLockTimeout) * time.Second)
+	waiting := false
+	for {
+		busy, err := tryLockFile(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", config.ModuleLockPath, err)
+		}
+		if !busy {
+			break
+		}
+
+		owner := readModuleLockOwner(file)
+		if time.Now().After(deadline) {
+			_ = file.Close()
+			return nil, fmt.Errorf("timed out after %ds waiting for module lock %s held by %s", config.ModuleLockTimeout, config.ModuleLockPath, owner)
+		}
+		if !waiting {
+			logger.Info("Waiting for module lock", "operation", operation, "lock_path", config.ModuleLockPath, "owner", owner)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, fmt.Errorf("stopped waiting for module lock %s held by %s: %w", config.ModuleLockPath, owner, context.Cause(ctx))
+		case <-time.After(moduleLockPollInterval):
+		}
+	}
+
+	if waiting {
+		logger.Info("Acquired module lock", "operation", operation, "waited", time.Since(start).Round(time.Millisecond).String())
+	} else {
+		logger.Debug("Acquired module lock", "operation", operation)
+	}
+	writeModuleLockOwner(file, operation)
+	return file, nil
+}
+
+// releaseModuleLock clears the owner record and drops the lock
+func releaseModuleLock(file *os.File, operation string, logger *slog.Logger) {
+	_ = file.Truncate(0)
+	if err := unlockFile(file); err != nil {
+		logger.Warn("Failed to release module lock", "operation", operation, "error", err)
+	}
+	_ = file.Close()
+	logger.Debug("Released module lock", "operation", operation)
+}
+
+// writeModuleLockOwner records this process as the lock holder
+func writeModuleLockOwner(file *os.File, operation string) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("video-device-plugin pid=%d host=%s operation=%s since=%s\n",
+		os.Getpid(), hostname, operation, time.Now().UTC().Format(time.RFC3339))
+
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt([]byte(owner), 0)
+}
+
+// readModuleLockOwner returns the holder's owner record; holders that do not write one
+// (plain `flock` in a host script) are reported as unknown
+func readModuleLockOwner(file *os.File) string {
+	buf := make([]byte, 256)
+	n, _ := file.ReadAt(buf, 0)
+	if owner := strings.TrimSpace(string(buf[:n])); owner != "" {
+		return owner
+	}
+	return "unknown (no owner record)"
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -11,23 +12,62 @@ import (
 )
 
 // loadV4L2LoopbackModule loads the v4l2loopback kernel module
-func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) error {
+// Every modprobe and v4l2loopback-ctl call is bounded by DEVICE_CREATION_TIMEOUT and canceled with ctx
+func loadV4L2LoopbackModule(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	// External-module mode: the host owns the module lifecycle, never call modprobe
+	if !config.ManageModule {
+		logger.Info("Module management disabled, expecting host-managed v4l2loopback", "manage_module", config.ManageModule)
+		if loaded, err := isModuleLoaded("v4l2loopback"); err != nil {
+			logger.Warn("Failed to check v4l2loopback module status", "error", err)
+		} else if !loaded {
+			logger.Warn("v4l2loopback module is not loaded; the host is expected to load it")
+		}
+		return nil
+	}
+
 	logger.Info("Loading v4l2loopback kernel module...")
 
 	// Check if module is already loaded and verify configuration
 	if loaded, err := isModuleLoaded("v4l2loopback"); err == nil && loaded {
 		logger.Info("v4l2loopback module already loaded, verifying configuration...")
 
-		// Check if the current device configuration matches our requirements
-		if err := verifyV4L2Configuration(config, logger); err != nil {
-			logger.Warn("v4l2loopback configuration mismatch detected", "error", err)
+		// Check if the current device configuration and parameters match our requirements
+		mismatch := verifyV4L2Configuration(config, logger)
+		changes := moduleParameterChanges(config)
+		if mismatch != nil || len(changes) > 0 {
+			if mismatch != nil {
+				logger.Warn("v4l2loopback configuration mismatch detected", "error", mismatch)
+
+				// A partially created set is repaired in place so pods on the other devices keep streaming
+				convergeErr := convergeDeviceSet(ctx, config, logger)
+				if convergeErr == nil && len(changes) == 0 {
+					logger.Info("v4l2loopback device set converged without a reload")
+					return nil
+				}
+				if convergeErr != nil {
+					logger.Warn("Could not converge device set in place", "error", convergeErr)
+				}
+			}
+			if len(changes) > 0 {
+				logger.Warn("v4l2loopback runs with parameters that differ from the configuration", "changes", changes)
+			}
+
+			// Devices assigned to pods would be recreated underneath them
+			if err := guardModuleReload(ctx, config, changes, logger); err != nil {
+				return err
+			}
 			logger.Info("Reloading v4l2loopback module with correct configuration...")
 
 			// Unload the module first (time-bounded)
-			unloadCtx, unloadCancel := context.WithTimeout(context.Background(), time.Duration(config.DeviceCreationTimeout)*time.Second)
+			unloadCtx, unloadCancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
 			defer unloadCancel()
-			if unloadErr := exec.CommandContext(unloadCtx, "modprobe", "-r", "v4l2loopback").Run(); unloadErr != nil {
-				logger.Warn("Failed to unload existing v4l2loopback module", "error", unloadErr)
+			if out, unloadErr := moduleCommand(unloadCtx, "modprobe", "-r", "v4l2loopback"); unloadErr != nil {
+				// Pods are still streaming; the caller keeps the current devices and reloads later
+				if isModuleInUseOutput(string(out)) {
+					logger.Warn("v4l2loopback is in use, deferring reload", "output", strings.TrimSpace(string(out)))
+					return fmt.Errorf("%w: %s", ErrModuleInUse, strings.TrimSpace(string(out)))
+				}
+				logger.Warn("Failed to unload existing v4l2loopback module", "error", unloadErr, "output", strings.TrimSpace(string(out)))
 				// Continue anyway, modprobe might handle the reload
 			}
 		} else {
@@ -38,10 +78,14 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 
 	// CRITICAL: Load videodev module first (required for v4l2loopback)
 	logger.Info("Loading videodev module (required for v4l2loopback)...")
-	vctx, vcancel := context.WithTimeout(context.Background(), time.Duration(config.DeviceCreationTimeout)*time.Second)
+	vctx, vcancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
 	defer vcancel()
-	if out, err := exec.CommandContext(vctx, "modprobe", "videodev").CombinedOutput(); err != nil {
-		logger.Error("Failed to load videodev module - this is required for v4l2loopback", "error", err, "output", strings.TrimSpace(string(out)))
+	if out, err := moduleCommand(vctx, "modprobe", "videodev"); err != nil {
+		diagnostics := collectModuleDiagnostics(logger)
+		logger.Error("Failed to load videodev module - this is required for v4l2loopback",
+			"error", err,
+			"output", strings.TrimSpace(string(out)),
+			"signatures", diagnostics.Signatures)
 		logger.Info("Make sure linux-modules-extra-$(uname -r) is installed")
 
 		// Create a structured error that can be handled by the caller
@@ -51,6 +95,7 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 			Original:             err,
 			OriginalErrorMessage: err.Error(),
 			CanFallback:          config.EnableFallbackMode,
+			Diagnostics:          diagnostics,
 		}
 	}
 
@@ -66,18 +111,25 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 	}
 
 	// Load the v4l2loopback module with our specific parameters
-	// Using video_nr=VideoDeviceStartNumber-{VideoDeviceStartNumber+max_devices-1} to avoid conflicts with system video devices
+	// Using video_nr={VideoDeviceStart}-{VideoDeviceStart+max_devices-1} to avoid conflicts with system video devices
+	// Device tiers get their own card label and exclusive_caps; max_buffers is module-wide
+	// and applied to tier devices through v4l2loopback-ctl once the plugin runs
+	tiers := tierVideoIDs(config)
 	videoNumbers := make([]string, config.MaxDevices)
 	cardLabels := make([]string, config.MaxDevices)
 	exclusiveCaps := make([]string, config.MaxDevices)
 	for i := 0; i < config.MaxDevices; i++ {
-		videoNumbers[i] = fmt.Sprintf("%d", VideoDeviceStartNumber+i)
+		videoNumbers[i] = fmt.Sprintf("%d", config.VideoDeviceStart+i)
 		cardLabels[i] = fmt.Sprintf(`"%s"`, config.V4L2CardLabel)
 		exclusiveCaps[i] = fmt.Sprintf("%d", config.V4L2ExclusiveCaps)
+		if tier, ok := tiers[fmt.Sprintf("video%d", config.VideoDeviceStart+i)]; ok {
+			cardLabels[i] = fmt.Sprintf(`"%s"`, tier.CardLabel)
+			exclusiveCaps[i] = fmt.Sprintf("%d", tier.ExclusiveCaps)
+		}
 	}
 
 	// Create context with timeout for insmod command
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.DeviceCreationTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.DeviceCreationTimeout)*time.Second)
 	defer cancel()
 
 	// Load v4l2loopback using insmod to ensure we get the newer version with device control features
@@ -105,7 +157,7 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 
 	var modulePath string
 	for _, candidate := range candidates {
-		if _, statErr := os.Stat(candidate); statErr == nil {
+		if _, statErr := os.Stat(moduleFilePath(candidate)); statErr == nil {
 			modulePath = candidate
 			logger.Info("Found v4l2loopback module", "path", modulePath, "kernel_version", kv)
 			break
@@ -125,14 +177,17 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, "insmod", modulePath,
-		fmt.Sprintf("video_nr=%s", strings.Join(videoNumbers, ",")),
-		fmt.Sprintf("max_buffers=%d", config.V4L2MaxBuffers),
-		fmt.Sprintf("exclusive_caps=%s", strings.Join(exclusiveCaps, ",")),
-		fmt.Sprintf("card_label=%s", strings.Join(cardLabels, ",")),
-		fmt.Sprintf("devices=%d", config.MaxDevices))
+	// Adjust parameters for kernel/architecture quirks of the installed build
+	params := []moduleParam{
+		{Name: "video_nr", Value: strings.Join(videoNumbers, ",")},
+		{Name: "max_buffers", Value: fmt.Sprintf("%d", config.V4L2MaxBuffers)},
+		{Name: "exclusive_caps", Value: strings.Join(exclusiveCaps, ",")},
+		{Name: "card_label", Value: strings.Join(cardLabels, ",")},
+		{Name: "devices", Value: fmt.Sprintf("%d", config.MaxDevices)},
+	}
+	args := append([]string{modulePath}, buildModuleArgs(params, modulePath, kv, logger)...)
 
-	if out, err := cmd.CombinedOutput(); err != nil {
+	if out, err := moduleCommand(ctx, "insmod", args...); err != nil {
 		// Check if the error is due to timeout
 		if ctx.Err() == context.DeadlineExceeded {
 			logger.Error("Failed to load v4l2loopback module - operation timed out",
@@ -147,21 +202,12 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 			}
 		}
 
-		logger.Error("Failed to load v4l2loopback module")
-		logger.Info("modprobe output", "output", strings.TrimSpace(string(out)))
-
-		// dmesg fallback for additional debugging
-		logger.Info("Checking dmesg for additional error details:")
-		if dmesgOutput, dmesgErr := exec.Command("dmesg").Output(); dmesgErr == nil {
-			lines := strings.Split(string(dmesgOutput), "\n")
-			for i := len(lines) - 10; i < len(lines); i++ {
-				if i >= 0 {
-					logger.Info("   " + lines[i])
-				}
-			}
-		} else {
-			logger.Debug("dmesg not available or restricted", "error", dmesgErr)
-		}
+		// Collect structured kernel log diagnostics for the failure
+		diagnostics := collectModuleDiagnostics(logger)
+		logger.Error("Failed to load v4l2loopback module",
+			"output", strings.TrimSpace(string(out)),
+			"signatures", diagnostics.Signatures,
+			"kernel_log_lines", diagnostics.KernelLogLines)
 
 		return &ModuleLoadError{
 			Module:               "v4l2loopback",
@@ -169,6 +215,7 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 			Original:             err,
 			OriginalErrorMessage: err.Error(),
 			CanFallback:          config.EnableFallbackMode,
+			Diagnostics:          diagnostics,
 		}
 	}
 
@@ -177,7 +224,28 @@ func loadV4L2LoopbackModule(config *DevicePluginConfig, logger *slog.Logger) err
 }
 
 // cleanupV4L2Module unloads the v4l2loopback module on shutdown
-func cleanupV4L2Module(config *DevicePluginConfig, logger *slog.Logger) {
+// With KEEP_MODULE_ON_EXIT only the devices we added through v4l2loopback-ctl are removed
+// It returns ErrModuleInUse when devices held open kept the module loaded
+func cleanupV4L2Module(ctx context.Context, config *DevicePluginConfig, ctlAdded []string, logger *slog.Logger) error {
+	if !config.ManageModule {
+		logger.Info("Module management disabled, leaving v4l2loopback module to the host")
+		return nil
+	}
+
+	if config.KeepModuleOnExit {
+		logger.Info("Keeping v4l2loopback module loaded for other workloads", "ctl_added_devices", len(ctlAdded))
+		for _, devicePath := range ctlAdded {
+			ctx, cancel := context.WithTimeout(ctx, time.Duration(config.CleanupTimeout)*time.Second)
+			if out, err := privilegedCommand(ctx, "v4l2loopback-ctl", "delete", devicePath); err != nil {
+				logger.Warn("Failed to remove device added by the plugin", "device_path", devicePath, "error", err, "output", strings.TrimSpace(string(out)))
+			} else {
+				logger.Info("Removed device added by the plugin", "device_path", devicePath)
+			}
+			cancel()
+		}
+		return nil
+	}
+
 	logger.Info("Cleaning up v4l2loopback module")
 
 	// Check if v4l2loopback module is loaded
@@ -185,22 +253,25 @@ func cleanupV4L2Module(config *DevicePluginConfig, logger *slog.Logger) {
 	output, err := cmd.Output()
 	if err != nil {
 		logger.Warn("Failed to check loaded modules", "error", err)
-		return
+		return nil
 	}
 
 	if !strings.Contains(string(output), "v4l2loopback") {
 		logger.Info("v4l2loopback module not loaded, nothing to cleanup")
-		return
+		return nil
 	}
 
 	// Unload v4l2loopback module
 	logger.Info("Unloading v4l2loopback module...")
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.CleanupTimeout)*time.Second)
+	unloadCtx, cancel := context.WithTimeout(ctx, time.Duration(config.CleanupTimeout)*time.Second)
 	defer cancel()
-	unloadCmd := exec.CommandContext(ctx, "modprobe", "-r", "v4l2loopback")
-	if out, err := unloadCmd.CombinedOutput(); err != nil {
+	var unloadErr error
+	if out, err := moduleCommand(unloadCtx, "modprobe", "-r", "v4l2loopback"); err != nil {
 		logger.Warn("Failed to unload v4l2loopback module", "error", err, "output", strings.TrimSpace(string(out)))
 		logger.Info("Module may be in use by other processes")
+		if isModuleInUseOutput(string(out)) {
+			unloadErr = fmt.Errorf("%w: %s", ErrModuleInUse, strings.TrimSpace(string(out)))
+		}
 	} else {
 		logger.Info("v4l2loopback module unloaded successfully")
 	}
@@ -211,10 +282,9 @@ func cleanupV4L2Module(config *DevicePluginConfig, logger *slog.Logger) {
 		// Check if any other video modules are using videodev
 		if loaded, err := isModuleLoaded("v4l2loopback"); err == nil && !loaded {
 			// No other modules using videodev, try to unload it
-			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.CleanupTimeout)*time.Second)
+			ctx, cancel := context.WithTimeout(ctx, time.Duration(config.CleanupTimeout)*time.Second)
 			defer cancel()
-			unloadVideodevCmd := exec.CommandContext(ctx, "modprobe", "-r", "videodev")
-			if out, err := unloadVideodevCmd.CombinedOutput(); err != nil {
+			if out, err := moduleCommand(ctx, "modprobe", "-r", "videodev"); err != nil {
 				logger.Info("videodev module still needed by other modules, keeping loaded", "output", strings.TrimSpace(string(out)))
 			} else {
 				logger.Info("videodev module unloaded successfully")
@@ -223,6 +293,7 @@ func cleanupV4L2Module(config *DevicePluginConfig, logger *slog.Logger) {
 	}
 
 	logger.Info("Cleanup completed")
+	return unloadErr
 }
 
 // isModuleLoaded checks if a specific kernel module is loaded by parsing lsmod output
@@ -242,13 +313,29 @@ func isModuleLoaded(moduleName string) (bool, error) {
 	return false, nil
 }
 
+// ErrModuleInUse reports that v4l2loopback could not be unloaded because devices are open
+var ErrModuleInUse = errors.New("v4l2loopback module is in use")
+
+// isModuleInUseOutput detects the busy condition in modprobe/rmmod output
+func isModuleInUseOutput(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "is in use") ||
+		strings.Contains(lower, "resource busy") ||
+		strings.Contains(lower, "ebusy")
+}
+
 // verifyV4L2Configuration checks if the current v4l2loopback configuration matches requirements
-func verifyV4L2Configuration(config *DevicePluginConfig, logger *slog.Logger) error {
+func verifyV4L2Configuration(config *DevicePluginConfig, logger *slog.Logger) (err error) {
+	start := time.Now()
+	defer func() {
+		moduleMetrics.ObserveModuleOperation(ModuleOpVerify, "v4l2loopback", time.Since(start), err)
+	}()
+
 	// Check if the expected number of devices exist
 	expectedDevices := config.MaxDevices
 	actualDevices := 0
 
-	for i := VideoDeviceStartNumber; i < VideoDeviceStartNumber+expectedDevices; i++ {
+	for i := config.VideoDeviceStart; i < config.VideoDeviceStart+expectedDevices; i++ {
 		devicePath := fmt.Sprintf("/dev/video%d", i)
 		if _, err := os.Stat(devicePath); err == nil {
 			actualDevices++
@@ -258,14 +345,14 @@ func verifyV4L2Configuration(config *DevicePluginConfig, logger *slog.Logger) er
 	logger.Info("v4l2loopback configuration check",
 		"expected_devices", expectedDevices,
 		"actual_devices", actualDevices,
-		"device_range", fmt.Sprintf("/dev/video%d-%d", VideoDeviceStartNumber, VideoDeviceStartNumber+expectedDevices-1))
+		"device_range", fmt.Sprintf("/dev/video%d-%d", config.VideoDeviceStart, config.VideoDeviceStart+expectedDevices-1))
 
 	if actualDevices != expectedDevices {
 		return fmt.Errorf("device count mismatch: expected %d devices, found %d", expectedDevices, actualDevices)
 	}
 
 	// Check if devices are character devices and have correct permissions
-	for i := VideoDeviceStartNumber; i < VideoDeviceStartNumber+expectedDevices; i++ {
+	for i := config.VideoDeviceStart; i < config.VideoDeviceStart+expectedDevices; i++ {
 		devicePath := fmt.Sprintf("/dev/video%d", i)
 		if stat, err := os.Stat(devicePath); err == nil {
 			// Check if it's a character device
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Kernel module operations timed by module_operation_duration_seconds
+const (
+	ModuleOpLoad   = "load"   // modprobe and insmod
+	ModuleOpUnload = "unload" // modprobe -r and rmmod
+	ModuleOpVerify = "verify" // Checking the loaded module's devices against the configuration
+	ModuleOpCtl    = "ctl"    // v4l2loopback-ctl add/delete/set-fps...
+)
+
+// moduleMetrics records module operations process-wide, nil unless ENABLE_METRICS is set
+// Module commands run before the plugin exists, so they cannot reach it through the plugin
+var moduleMetrics *Metrics
+
+// moduleOperation classifies a privileged command as a module operation and names its module
+// Commands wrapped in nsenter are classified by the command they run; others return ""
+func moduleOperation(name string, args []string) (string, string) {
+	if name == "nsenter" {
+		if i := slices.Index(args, "--"); i >= 0 && i+1 < len(args) {
+			return moduleOperation(args[i+1], args[i+2:])
+		}
+		return "", ""
+	}
+
+	switch filepath.Base(name) {
+	case "modprobe":
+		unload := slices.Contains(args, "-r")
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			if unload {
+				return ModuleOpUnload, arg
+			}
+			return ModuleOpLoad, arg
+		}
+	case "insmod":
+		if len(args) > 0 {
+			return ModuleOpLoad, strings.TrimSuffix(filepath.Base(args[0]), ".ko")
+		}
+	case "rmmod":
+		if len(args) > 0 {
+			return ModuleOpUnload, args[len(args)-1]
+		}
+	case "v4l2loopback-ctl":
+		return ModuleOpCtl, "v4l2loopback"
+	}
+	return "", ""
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// deviceListSyncTimeout bounds the wait for kubelet to receive the withdrawn device list
+const deviceListSyncTimeout = 10 * time.Second
+
+// DeferredModuleReload reloads v4l2loopback with the configured parameters once no device is in use
+// It is started when the module had to be kept loaded with a mismatched configuration at startup
+type DeferredModuleReload struct {
+	config      *DevicePluginConfig
+	v4l2Manager V4L2Manager
+	plugin      *VideoDevicePlugin
+	k8sClient   *K8sClient
+	logger      *slog.Logger
+	cancel      context.CancelFunc
+}
+
+// NewDeferredModuleReload creates a new DeferredModuleReload instance
+// k8sClient may be nil, in which case progress is only logged
+func NewDeferredModuleReload(config *DevicePluginConfig, v4l2Manager V4L2Manager, plugin *VideoDevicePlugin, k8sClient *K8sClient, logger *slog.Logger) *DeferredModuleReload {
+	return &DeferredModuleReload{
+		config:      config,
+		v4l2Manager: v4l2Manager,
+		plugin:      plugin,
+		k8sClient:   k8sClient,
+		logger:      logger,
+	}
+}
+
+// Start waits for the devices to be free in the background and then reloads the module
+// Canceling ctx abandons the pending reload like Stop does
+func (r *DeferredModuleReload) Start(ctx context.Context) {
+	message := "v4l2loopback configuration mismatch; reload deferred until all devices are free"
+	if change := r.plugin.PendingModuleChange(); change != "" {
+		message += ": " + change
+	}
+	r.event(corev1.EventTypeWarning, "ModuleReloadDeferred", message)
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.run(ctx)
+}
+
+// Refuse reports a module change that is never applied while this instance runs (MODULE_CHANGE_POLICY=refuse)
+func (r *DeferredModuleReload) Refuse(change string) {
+	r.logger.Error("v4l2loopback configuration change refused while devices are allocated; restart the plugin on a drained node to apply it",
+		"pending_change", change)
+	r.event(corev1.EventTypeWarning, "ModuleChangeRefused", "v4l2loopback change refused while devices are allocated: "+change)
+}
+
+// Stop abandons a pending reload
+func (r *DeferredModuleReload) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// run polls device usage every health interval until the reload succeeds
+func (r *DeferredModuleReload) run(ctx context.Context) {
+	ticker := r.plugin.clock.NewTicker(time.Duration(r.config.HealthCheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	lastBusy := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		busy := r.busyDevices(ctx)
+		if len(busy) > 0 {
+			if len(busy) != lastBusy {
+				r.logger.Info("Deferred module reload waiting for devices to be released", "busy_devices", busy)
+				r.plugin.logDeviceHolders(ctx, "deferred module reload")
+				r.event(corev1.EventTypeNormal, "ModuleReloadWaiting", fmt.Sprintf("Waiting for %d device(s) to be released: %s", len(busy), strings.Join(busy, ", ")))
+				lastBusy = len(busy)
+			}
+			continue
+		}
+
+		done, err := r.reload(ctx)
+		if err != nil {
+			r.logger.Warn("Deferred module reload attempt failed", "error", err)
+			r.event(corev1.EventTypeWarning, "ModuleReloadFailed", err.Error())
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// busyDevices returns the paths of devices held open by other processes, assigned to pods by
+// kubelet or held by local leases
+func (r *DeferredModuleReload) busyDevices(ctx context.Context) []string {
+	devices := r.v4l2Manager.ListAllDevices()
+	paths := make([]string, 0, len(devices))
+	for _, device := range devices {
+		paths = append(paths, device.Path)
+	}
+	holders := findHolders(paths)
+
+	// A pod keeps its device between streams without holding it open
+	ctx, cancel := context.WithTimeout(ctx, moduleChangeQueryTimeout)
+	assigned, err := r.plugin.assignedVideoDevices(ctx)
+	cancel()
+	if err != nil {
+		r.logger.Debug("Cannot read kubelet device assignments, only open devices delay the reload", "error", err)
+	}
+
+	var busy []string
+	for _, device := range devices {
+		if r.plugin.allocations.IsLocallyLeased(device.ID) || len(holders[device.Path]) > 0 || assigned[device.ID] {
+			busy = append(busy, device.Path)
+		}
+	}
+	return busy
+}
+
+// reload unloads and reloads the module under the module lock
+func (r *DeferredModuleReload) reload(ctx context.Context) (bool, error) {
+	var done bool
+	err := withModuleLock(ctx, r.config, "reload", r.logger, func() error {
+		var err error
+		done, err = r.reloadLocked(ctx)
+		return err
+	})
+	return done, err
+}
+
+// reloadLocked unloads and reloads the module and rediscovers devices
+// It reports done=true once the module runs with the configured parameters
+func (r *DeferredModuleReload) reloadLocked(ctx context.Context) (bool, error) {
+	r.logger.Info("Devices are free, reloading v4l2loopback")
+	r.event(corev1.EventTypeNormal, "ModuleReloadStarted", "All devices free, reloading v4l2loopback")
+
+	// Placeholder producers hold devices from this process and would keep the module busy
+	if r.plugin.warmup != nil {
+		r.plugin.warmup.StopAll()
+	}
+
+	// Withdraw every device from kubelet before the nodes disappear so no defunct ID can be allocated
+	generation := r.v4l2Manager.InvalidateDevices()
+	r.plugin.replays.Clear()
+	if !r.plugin.syncDeviceList(deviceListSyncTimeout) {
+		r.logger.Warn("Could not confirm kubelet saw the withdrawn devices, continuing reload", "module_generation", generation)
+	}
+	done := false
+	defer func() {
+		// Re-advertise whatever the module serves now when the reload did not complete
+		if !done {
+			if err := r.v4l2Manager.CreateDevices(r.config.MaxDevices); err != nil {
+				r.logger.Warn("No devices to re-advertise after incomplete reload", "error", err)
+			}
+			r.plugin.requestListAndWatchRefresh()
+		}
+	}()
+
+	if loaded, _ := isModuleLoaded("v4l2loopback"); loaded {
+		unloadCtx, cancel := context.WithTimeout(ctx, time.Duration(r.config.DeviceCreationTimeout)*time.Second)
+		out, err := moduleCommand(unloadCtx, "modprobe", "-r", "v4l2loopback")
+		cancel()
+		if err != nil {
+			if isModuleInUseOutput(string(out)) {
+				// A pod opened a device between the check and the unload; wait for the next round
+				r.logger.Info("v4l2loopback became busy again, postponing reload")
+				r.plugin.logDeviceHolders(ctx, "module unload for reload")
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to unload v4l2loopback: %w (output: %s)", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if err := loadV4L2LoopbackModule(ctx, r.config, r.logger); err != nil {
+		return false, err
+	}
+	if err := verifyV4L2Configuration(r.config, r.logger); err != nil {
+		return false, err
+	}
+	if err := r.v4l2Manager.CreateDevices(r.config.MaxDevices); err != nil {
+		return false, err
+	}
+	r.plugin.applyTierParameters()
+	r.plugin.preformatDevices()
+	done = true
+
+	// Allocations computed against the withdrawn list must not reach the recreated nodes
+	var deviceIDs []string
+	for deviceID := range r.v4l2Manager.ListAllDevices() {
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	r.plugin.RotateDeviceIDs("module reloaded", deviceIDs)
+
+	// Advertise the new generation right away instead of on the next health tick
+	r.plugin.requestListAndWatchRefresh()
+	r.plugin.refreshReadiness()
+	r.plugin.setPendingModuleChange("")
+	r.logger.Info("Deferred v4l2loopback reload completed", "module_generation", generation)
+	r.event(corev1.EventTypeNormal, "ModuleReloaded", fmt.Sprintf("v4l2loopback reloaded with %d devices", r.config.MaxDevices))
+	return true, nil
+}
+
+// event records a node event when the Kubernetes client is available
+func (r *DeferredModuleReload) event(eventType, reason, message string) {
+	if r.k8sClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.k8sClient.RecordNodeEvent(ctx, eventType, reason, message); err != nil {
+		r.logger.Debug("Failed to record node event", "reason", reason, "error", err)
+	}
+}
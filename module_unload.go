@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Module unload modes on shutdown (MODULE_UNLOAD_MODE)
+const (
+	ModuleUnloadImmediate = "immediate" // Unload right after deregistering, even while pods stream
+	ModuleUnloadDeferred  = "deferred"  // Wait until no process holds a device open or the deadline passes
+)
+
+// moduleUnloadPollInterval is how often a deferred unload rescans /proc for open device handles
+const moduleUnloadPollInterval = 2 * time.Second
+
+// waitForDevicesReleased blocks until no other process holds any of devicePaths open or deadline passes
+// The plugin has withdrawn its devices from kubelet by then, so no new consumer can appear;
+// it returns the paths still held when the deadline passed or ctx ended
+func waitForDevicesReleased(ctx context.Context, devicePaths []string, deadline time.Duration, logger *slog.Logger) []string {
+	expires := time.Now().Add(deadline)
+	var lastBusy []string
+	for {
+		holders := findHolders(devicePaths)
+		busy := make([]string, 0, len(holders))
+		for path, held := range holders {
+			if len(held) > 0 {
+				busy = append(busy, path)
+			}
+		}
+		sort.Strings(busy)
+		if len(busy) == 0 {
+			logger.Info("All video devices released, unloading module")
+			return nil
+		}
+		if time.Now().After(expires) {
+			logger.Warn("Module unload deadline passed with devices still open, unloading anyway",
+				"deadline_seconds", int(deadline.Seconds()),
+				"busy_devices", busy)
+			return busy
+		}
+		if !slices.Equal(busy, lastBusy) {
+			logger.Info("Deferring module unload until devices are released",
+				"busy_devices", busy,
+				"remaining_seconds", int(time.Until(expires).Seconds()))
+			lastBusy = busy
+		}
+		select {
+		case <-ctx.Done():
+			logger.Warn("Stopped deferring module unload with devices still open",
+				"busy_devices", busy,
+				"reason", context.Cause(ctx))
+			return busy
+		case <-time.After(moduleUnloadPollInterval):
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// namespaceQuotaTimeout bounds each PodResources query and webhook call of the quota check
+	namespaceQuotaTimeout = 5 * time.Second
+	// namespaceQuotaWildcard sets the quota of every namespace not listed in NAMESPACE_QUOTAS
+	namespaceQuotaWildcard = "*"
+)
+
+// parseNamespaceQuotas parses NAMESPACE_QUOTAS ("namespace=devices,...", "*" for the rest)
+// A namespace without a quota is only tracked
+func parseNamespaceQuotas(config *DevicePluginConfig) (map[string]int, error) {
+	quotas := make(map[string]int)
+	for _, entry := range strings.Split(config.NamespaceQuotas, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		namespace, value, ok := strings.Cut(entry, "=")
+		namespace = strings.TrimSpace(namespace)
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || namespace == "" || err != nil || limit < 0 {
+			return nil, fmt.Errorf("NAMESPACE_QUOTAS entry %q must be namespace=devices", entry)
+		}
+		if _, duplicate := quotas[namespace]; duplicate {
+			return nil, fmt.Errorf("NAMESPACE_QUOTAS lists namespace %s twice", namespace)
+		}
+		quotas[namespace] = limit
+	}
+	return quotas, nil
+}
+
+// namespaceQuota returns the quota of a namespace and whether it has one
+func namespaceQuota(quotas map[string]int, namespace string) (int, bool) {
+	if limit, ok := quotas[namespace]; ok {
+		return limit, true
+	}
+	limit, ok := quotas[namespaceQuotaWildcard]
+	return limit, ok
+}
+
+// NamespaceUsage is one namespace's video devices on this node against its quota
+type NamespaceUsage struct {
+	Namespace string   `json:"namespace"`
+	Devices   []string `json:"devices"`
+	Quota     *int     `json:"quota,omitempty"` // nil when the namespace has no quota
+	Exceeded  bool     `json:"exceeded"`
+}
+
+// namespaceDevices returns the video devices kubelet assigns to each namespace, through the
+// plain resource, a device tier or an av-bundle
+func (p *VideoDevicePlugin) namespaceDevices(ctx context.Context) (map[string][]string, error) {
+	pods, err := listDevicePods(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]string) // video device ID -> namespace
+	for kubeletID, pod := range pods[p.config.ResourceName] {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		owners[deviceID] = pod.Namespace
+	}
+	for _, tier := range buildDeviceTiers(p.config) {
+		for kubeletID, pod := range pods[tier.ResourceName] {
+			if deviceID, _ := splitKubeletDeviceID(kubeletID); p.tiers[deviceID].ResourceName == tier.ResourceName {
+				owners[deviceID] = pod.Namespace
+			}
+		}
+	}
+	for _, bundle := range buildAVBundles(p.config) {
+		if pod, ok := pods[p.config.AVBundleResourceName][bundle.ID]; ok {
+			owners[bundle.VideoDeviceID] = pod.Namespace
+		}
+	}
+
+	devices := make(map[string][]string)
+	for deviceID, namespace := range owners {
+		devices[namespace] = append(devices[namespace], deviceID)
+	}
+	for namespace := range devices {
+		sort.Strings(devices[namespace])
+	}
+	return devices, nil
+}
+
+// namespaceUsage reports every namespace holding devices or having an explicit quota
+func (p *VideoDevicePlugin) namespaceUsage(ctx context.Context) ([]NamespaceUsage, error) {
+	quotas, err := parseNamespaceQuotas(p.config)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := p.namespaceDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for namespace := range quotas {
+		if _, ok := devices[namespace]; !ok && namespace != namespaceQuotaWildcard {
+			devices[namespace] = []string{}
+		}
+	}
+
+	usage := make([]NamespaceUsage, 0, len(devices))
+	for namespace, held := range devices {
+		entry := NamespaceUsage{Namespace: namespace, Devices: held}
+		if limit, ok := namespaceQuota(quotas, namespace); ok {
+			entry.Quota = &limit
+			entry.Exceeded = len(held) > limit
+		}
+		usage = append(usage, entry)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Namespace < usage[j].Namespace })
+	return usage, nil
+}
+
+// monitorNamespaceQuotas periodically exports the devices each namespace holds
+func (p *VideoDevicePlugin) monitorNamespaceQuotas() {
+	if p.config.NamespaceQuotaInterval <= 0 {
+		return
+	}
+
+	ticker := p.clock.NewTicker(time.Duration(p.config.NamespaceQuotaInterval) * time.Second)
+	defer ticker.Stop()
+
+	exceeded := make(map[string]bool)
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		ctx, cancel := context.WithTimeout(p.ctx, namespaceQuotaTimeout)
+		usage, err := p.namespaceUsage(ctx)
+		cancel()
+		if err != nil {
+			p.logger.Warn("Namespace quota check failed", "error", err)
+			continue
+		}
+
+		p.metrics.SetNamespaceDevices(usage)
+		for _, entry := range usage {
+			// Report each namespace once when it goes over its quota
+			if entry.Exceeded && !exceeded[entry.Namespace] {
+				p.logger.Warn("Namespace holds more devices than its quota",
+					"namespace", entry.Namespace,
+					"devices", entry.Devices,
+					"quota", *entry.Quota)
+			}
+			exceeded[entry.Namespace] = entry.Exceeded
+		}
+	}
+}
+
+// quotaReview is POSTed to NAMESPACE_QUOTA_WEBHOOK before a container with devices starts
+type quotaReview struct {
+	Node      string   `json:"node"`
+	Namespace string   `json:"namespace"`
+	Pod       string   `json:"pod"`
+	Devices   []string `json:"devices"`   // Devices of the starting container
+	InUse     int      `json:"in_use"`    // Devices the namespace holds on the node, including these
+	Quota     *int     `json:"quota"`     // Configured quota of the namespace, null without one
+	Exceeded  bool     `json:"exceeded"`  // Whether InUse is over Quota
+	Resource  string   `json:"resource"`  // Resource the devices were requested through
+	Timestamp string   `json:"timestamp"` // RFC 3339
+}
+
+// quotaReviewResponse is the webhook's answer
+type quotaReviewResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// enforceNamespaceQuota refuses to start a container whose namespace is over its quota
+// The webhook, when configured, decides instead of the static quota; it fails open so an
+// unreachable webhook never blocks every camera on the node
+func (p *VideoDevicePlugin) enforceNamespaceQuota(ctx context.Context, deviceIDs []string) error {
+	if (!p.config.EnforceNamespaceQuotas && p.config.NamespaceQuotaWebhook == "") || len(deviceIDs) == 0 {
+		return nil
+	}
+	logger := p.logger.With("device_id", deviceIDs[0])
+
+	ctx, cancel := context.WithTimeout(ctx, namespaceQuotaTimeout)
+	defer cancel()
+	pod, err := devicePod(ctx, p.config.PodResourcesSocket, p.deviceResource(deviceIDs[0]), deviceIDs[0])
+	if err != nil || pod.Name == "" {
+		logger.Warn("Cannot resolve the pod of a starting device, namespace quota not checked", "error", err)
+		return nil
+	}
+	usage, err := p.namespaceUsage(ctx)
+	if err != nil {
+		logger.Warn("Cannot read namespace usage, namespace quota not checked", "pod", pod.String(), "error", err)
+		return nil
+	}
+	review := quotaReview{
+		Node:      p.config.NodeName,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Devices:   deviceIDs,
+		Resource:  p.deviceResource(deviceIDs[0]),
+		Timestamp: p.clock.Now().UTC().Format(time.RFC3339),
+	}
+	for _, entry := range usage {
+		if entry.Namespace == pod.Namespace {
+			review.InUse, review.Quota, review.Exceeded = len(entry.Devices), entry.Quota, entry.Exceeded
+		}
+	}
+
+	allowed, reason := !review.Exceeded, ""
+	if review.Exceeded {
+		reason = fmt.Sprintf("namespace %s holds %d video devices on node %s, quota is %d", pod.Namespace, review.InUse, p.config.NodeName, *review.Quota)
+	}
+	if p.config.NamespaceQuotaWebhook != "" {
+		answer, err := p.reviewNamespaceQuota(ctx, review)
+		if err != nil {
+			logger.Warn("Namespace quota webhook failed, allowing the container", "pod", pod.String(), "error", err)
+			return nil
+		}
+		allowed, reason = answer.Allowed, answer.Reason
+	} else if !p.config.EnforceNamespaceQuotas {
+		allowed = true
+	}
+	if allowed {
+		return nil
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("namespace %s is over its video device quota", pod.Namespace)
+	}
+	p.metrics.IncNamespaceQuotaDenials(pod.Namespace)
+	p.decisions.Publish(DecisionEvent{
+		Kind:          DecisionAllocation,
+		Action:        "quota_denied",
+		DeviceID:      deviceIDs[0],
+		Resource:      review.Resource,
+		CorrelationID: p.allocations.CorrelationID(deviceIDs[0]),
+		Message:       reason,
+		Fields: map[string]string{
+			"pod":    pod.String(),
+			"in_use": strconv.Itoa(review.InUse),
+		},
+	})
+	logger.Warn("Refusing to start container over its namespace quota", "pod", pod.String(), "reason", reason)
+	return status.Error(codes.ResourceExhausted, reason)
+}
+
+// reviewNamespaceQuota asks NAMESPACE_QUOTA_WEBHOOK whether a container may start
+func (p *VideoDevicePlugin) reviewNamespaceQuota(ctx context.Context, review quotaReview) (*quotaReviewResponse, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quota review: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.NamespaceQuotaWebhook, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	var answer quotaReviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook response: %w", err)
+	}
+	return &answer, nil
+}
+
+// handleNamespaceQuotas returns the devices each namespace holds on the node against its quota
+func (a *AdminServer) handleNamespaceQuotas(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), namespaceQuotaTimeout)
+	defer cancel()
+	usage, err := a.plugin.namespaceUsage(ctx)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,265 @@
+// Package client is a Go client for the video device plugin admin API
+//
+// It lets other services on a node query the camera inventory, manage local device
+// leases and subscribe to plugin decisions without hand-rolled HTTP calls.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultSocketPath is the admin socket the plugin listens on unless ADMIN_SOCKET_PATH is set
+const DefaultSocketPath = "/var/lib/video-device-plugin/admin.sock"
+
+// APIError is a non-2xx admin API response
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("admin API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client talks to the admin API over its unix socket
+type Client struct {
+	http *http.Client
+}
+
+// New creates a client for the admin socket at socketPath (DefaultSocketPath when empty)
+func New(socketPath string) *Client {
+	if socketPath == "" {
+		socketPath = DefaultSocketPath
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}
+}
+
+// NewTLS creates a client for an admin socket served with ADMIN_AUTH_MODE=tls
+// tlsConfig carries the client certificate (an X.509 SVID) and the roots to verify the plugin with
+func NewTLS(socketPath string, tlsConfig *tls.Config) *Client {
+	if socketPath == "" {
+		socketPath = DefaultSocketPath
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "unix", socketPath)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}
+}
+
+// Devices returns every device of the node with its role and health
+func (c *Client) Devices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	return devices, c.do(ctx, http.MethodGet, "/v1/devices", nil, &devices)
+}
+
+// System returns kernel, module and runtime facts of the node
+func (c *Client) System(ctx context.Context) (*SystemInfo, error) {
+	var info SystemInfo
+	if err := c.do(ctx, http.MethodGet, "/v1/system", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Holders returns the processes holding video devices open
+func (c *Client) Holders(ctx context.Context) (*DeviceHolders, error) {
+	var holders DeviceHolders
+	if err := c.do(ctx, http.MethodGet, "/v1/holders", nil, &holders); err != nil {
+		return nil, err
+	}
+	return &holders, nil
+}
+
+// Capacity returns the runtime size of the main resource pool
+func (c *Client) Capacity(ctx context.Context) (*Capacity, error) {
+	var capacity Capacity
+	if err := c.do(ctx, http.MethodGet, "/v1/capacity", nil, &capacity); err != nil {
+		return nil, err
+	}
+	return &capacity, nil
+}
+
+// SetCapacity grows or shrinks the number of devices advertised to kubelet
+// Shrinking below the allocated devices fails with an APIError of status 409
+func (c *Client) SetCapacity(ctx context.Context, advertised int) (*Capacity, error) {
+	var capacity Capacity
+	if err := c.do(ctx, http.MethodPut, "/v1/capacity", map[string]int{"advertised": advertised}, &capacity); err != nil {
+		return nil, err
+	}
+	return &capacity, nil
+}
+
+// Config returns the plugin's effective configuration, including runtime overrides
+func (c *Client) Config(ctx context.Context) ([]ConfigSetting, error) {
+	var settings []ConfigSetting
+	return settings, c.do(ctx, http.MethodGet, "/v1/config", nil, &settings)
+}
+
+// Allocations returns all current allocations, kubelet and local
+func (c *Client) Allocations(ctx context.Context) ([]Allocation, error) {
+	var allocations []Allocation
+	return allocations, c.do(ctx, http.MethodGet, "/v1/leases", nil, &allocations)
+}
+
+// Lease leases a free healthy device to owner; a zero ttl uses the plugin default
+func (c *Client) Lease(ctx context.Context, owner string, ttl time.Duration) (*Lease, error) {
+	var lease Lease
+	body := map[string]any{"owner": owner, "ttl_seconds": int(ttl / time.Second)}
+	if err := c.do(ctx, http.MethodPost, "/v1/leases", body, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// Renew extends a local lease; a zero ttl uses the plugin default
+func (c *Client) Renew(ctx context.Context, leaseID string, ttl time.Duration) (*Lease, error) {
+	var lease Lease
+	body := map[string]any{"ttl_seconds": int(ttl / time.Second)}
+	if err := c.do(ctx, http.MethodPost, "/v1/leases/"+url.PathEscape(leaseID)+"/renew", body, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// Release ends a local lease, returning the device to kubelet
+func (c *Client) Release(ctx context.Context, leaseID string) (*Lease, error) {
+	var lease Lease
+	if err := c.do(ctx, http.MethodDelete, "/v1/leases/"+url.PathEscape(leaseID), nil, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// Watch streams plugin decisions of the given kinds (all kinds when empty) until ctx is done
+// The returned channel is closed when the stream ends; the error channel then carries why,
+// nil when ctx was cancelled
+func (c *Client) Watch(ctx context.Context, kinds ...string) (<-chan Event, <-chan error, error) {
+	path := "/v1/watch"
+	if len(kinds) > 0 {
+		path += "?kinds=" + url.QueryEscape(strings.Join(kinds, ","))
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		errs <- readEvents(ctx, resp.Body, events)
+	}()
+	return events, errs, nil
+}
+
+// readEvents decodes server-sent events into events until the stream or ctx ends
+func readEvents(ctx context.Context, body io.Reader, events chan<- Event) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			// ids, event names and heartbeat comments carry nothing the data line lacks
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("watch stream failed: %w", err)
+	}
+	return io.EOF
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send issues a request and turns non-2xx responses into an APIError
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored, every request goes to the admin socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://admin"+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var payload struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err == nil {
+			apiErr.Message = payload.Error
+		} else {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
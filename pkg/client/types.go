@@ -0,0 +1,131 @@
+package client
+
+import "time"
+
+// Wire types of the admin API; they mirror the plugin's JSON responses field for field
+
+// Allocation is a device handed to a kubelet container or a local lease holder
+type Allocation struct {
+	DeviceID      string    `json:"device_id"`
+	DevicePath    string    `json:"device_path"`
+	Source        string    `json:"source"`                   // kubelet or local
+	LeaseID       string    `json:"lease_id,omitempty"`       // Local leases only
+	Owner         string    `json:"owner,omitempty"`          // Free-form holder description for local leases
+	CorrelationID string    `json:"correlation_id,omitempty"` // Allocate call correlation ID (kubelet allocations)
+	AllocatedAt   time.Time `json:"allocated_at"`             // When the allocation was made
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`     // Local lease expiry (zero for kubelet allocations)
+	RenewedAt     time.Time `json:"renewed_at,omitempty"`     // Last lease renewal
+}
+
+// Lease is a local lease together with the environment its consumer should use
+type Lease struct {
+	Allocation
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// Device is one video device of the node with its role and health
+type Device struct {
+	ID         string         `json:"id"`                    // Device ID (e.g., "video0")
+	Path       string         `json:"path"`                  // Device path (e.g., "/dev/video0")
+	CardLabel  string         `json:"card_label,omitempty"`  // Card label reported by the driver
+	Driver     string         `json:"driver,omitempty"`      // Driver name from VIDIOC_QUERYCAP
+	SysfsPath  string         `json:"sysfs_path,omitempty"`  // Resolved /sys/devices path
+	Major      uint32         `json:"major"`                 // Device node major number
+	Minor      uint32         `json:"minor"`                 // Device node minor number
+	Generation int            `json:"generation"`            // Incremented every time the device is recreated
+	MaxBuffers int            `json:"max_buffers,omitempty"` // Reduced buffer count after a buffer exhaustion recovery
+	CreatedAt  time.Time      `json:"created_at"`            // When the current generation was discovered or created
+	Fallback   bool           `json:"fallback,omitempty"`    // Placeholder standing in for a device the module could not create
+	Healthy    bool           `json:"healthy"`
+	Role       string         `json:"role"` // advertised, spare, repairing, bundle, tier, excluded or parked
+	SkipReason string         `json:"skip_reason,omitempty"`
+	Labels     *DeviceLabels  `json:"labels,omitempty"`
+	History    *HealthHistory `json:"health_history,omitempty"`
+}
+
+// DeviceLabels are the scheduling labels of a device
+type DeviceLabels struct {
+	FormatProfile string    `json:"format_profile"`           // v4l2loopback parameters the device was created with
+	Generation    int       `json:"generation"`               // Device generation
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"` // Freshly (re)created devices settle until this time
+}
+
+// HealthHistory is the recent health of a device and its flap damping state
+type HealthHistory struct {
+	Transitions []HealthTransition `json:"transitions"`
+	Flapping    bool               `json:"flapping"`
+	DampedUntil *time.Time         `json:"damped_until,omitempty"`
+}
+
+// HealthTransition is one observed health change
+type HealthTransition struct {
+	Healthy bool      `json:"healthy"`
+	At      time.Time `json:"at"`
+}
+
+// SystemInfo is a snapshot of node facts relevant to triaging module and device failures
+type SystemInfo struct {
+	KernelVersion    string   `json:"kernel_version"`
+	Architecture     string   `json:"architecture"`
+	TotalMemory      string   `json:"total_memory,omitempty"`
+	V4L2ModuleCount  int      `json:"v4l2_module_count"`
+	TaintValue       int      `json:"taint_value"`
+	TaintFlags       []string `json:"taint_flags,omitempty"`
+	ModuleSigEnforce string   `json:"module_sig_enforce"` // "enforced", "permissive" or "unknown"
+	KernelLockdown   string   `json:"kernel_lockdown,omitempty"`
+	CgroupVersion    string   `json:"cgroup_version"`
+	ContainerRuntime string   `json:"container_runtime"`
+	DevFilesystem    string   `json:"dev_filesystem"` // Filesystem type mounted on /dev
+	DevIsDevtmpfs    bool     `json:"dev_is_devtmpfs"`
+}
+
+// DeviceHolder is a process holding a video device open
+type DeviceHolder struct {
+	PID         int    `json:"pid"`
+	Comm        string `json:"comm"`
+	PodUID      string `json:"pod_uid,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	Pod         string `json:"pod,omitempty"` // namespace/name when the plugin could resolve it
+}
+
+// DeviceHolders lists the processes holding each video device open, by device ID
+type DeviceHolders struct {
+	HostPIDNamespace bool                      `json:"host_pid_namespace"` // False: holders in other pods are invisible
+	Devices          map[string][]DeviceHolder `json:"devices"`
+}
+
+// Capacity is the runtime size of the node's main resource pool
+type Capacity struct {
+	Advertised int      `json:"advertised"` // Devices currently advertised to kubelet
+	Max        int      `json:"max"`        // Devices the pool can advertise (created slots minus spares)
+	Parked     []string `json:"parked"`     // Devices withdrawn by capacity changes
+}
+
+// ConfigSetting is one resolved configuration value of the plugin and where it came from
+type ConfigSetting struct {
+	Key    string `json:"key"`    // Environment variable name
+	Value  any    `json:"value"`  // Secrets are returned as "<redacted>"
+	Source string `json:"source"` // default, file, env, runtime or status
+}
+
+// Kinds of plugin decisions published on the watch stream
+const (
+	EventAllocation   = "allocation"
+	EventRelease      = "release"
+	EventHealth       = "health"
+	EventRegistration = "registration"
+	EventReconcile    = "reconcile"
+)
+
+// Event is one structured plugin decision from the watch stream
+type Event struct {
+	Seq           uint64            `json:"seq"`
+	Time          time.Time         `json:"time"`
+	Kind          string            `json:"kind"`
+	Action        string            `json:"action"`
+	DeviceID      string            `json:"device_id,omitempty"`
+	Resource      string            `json:"resource,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Message       string            `json:"message,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"`
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openVideoOutput opens a loopback device for writing frames without blocking
+func openVideoOutput(devicePath string) (int, error) {
+	return unix.Open(devicePath, unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+}
+
+// closeVideoOutput closes a descriptor returned by openVideoOutput
+func closeVideoOutput(fd int) {
+	_ = unix.Close(fd)
+}
+
+// writeVideoFrame writes one frame; a full queue (EAGAIN) drops the frame without error
+func writeVideoFrame(fd int, frame []byte) error {
+	if _, err := unix.Write(fd, frame); err != nil && err != unix.EAGAIN {
+		return err
+	}
+	return nil
+}
+
+// createFileNoFollow creates a new regular file without following a symlink planted at path
+func createFileNoFollow(path string, perm os.FileMode) error {
+	fd, err := unix.Open(path, unix.O_CREAT|unix.O_EXCL|unix.O_WRONLY|unix.O_NOFOLLOW, uint32(perm))
+	if err != nil {
+		return err
+	}
+	_ = unix.Close(fd)
+	return nil
+}
+
+// fileDeviceNumbers returns the major/minor numbers of a device node
+func fileDeviceNumbers(info os.FileInfo) (uint32, uint32, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev)), true
+}
+
+// fileOwner returns the owning user and group IDs of a file
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// tryLockFile takes an exclusive flock without waiting; busy reports another holder
+func tryLockFile(file *os.File) (bool, error) {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return true, nil
+	}
+	return false, err
+}
+
+// unlockFile drops a lock taken by tryLockFile
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
+
+// peerCredentials returns the pid, uid and gid the kernel recorded for the process on the
+// other end of a unix socket connection
+func peerCredentials(conn *net.UnixConn) (int, int, int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, 0, credErr
+	}
+	return int(cred.Pid), int(cred.Uid), int(cred.Gid), nil
+}
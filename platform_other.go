@@ -0,0 +1,60 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// errUnsupportedPlatform is returned by device operations that only exist on Linux
+// The stubs let the package build and its pure logic run on developer machines
+var errUnsupportedPlatform = errors.New("video devices are only supported on linux")
+
+// openVideoOutput is not supported off Linux
+func openVideoOutput(devicePath string) (int, error) {
+	return -1, errUnsupportedPlatform
+}
+
+// closeVideoOutput is a no-op off Linux
+func closeVideoOutput(fd int) {}
+
+// writeVideoFrame is not supported off Linux
+func writeVideoFrame(fd int, frame []byte) error {
+	return errUnsupportedPlatform
+}
+
+// createFileNoFollow creates a new regular file; O_EXCL already refuses existing symlinks
+func createFileNoFollow(path string, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// fileDeviceNumbers is not available off Linux
+func fileDeviceNumbers(info os.FileInfo) (uint32, uint32, bool) {
+	return 0, 0, false
+}
+
+// fileOwner is not available off Linux
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
+
+// tryLockFile always succeeds off Linux; there is no module to serialize
+func tryLockFile(file *os.File) (bool, error) {
+	return false, nil
+}
+
+// unlockFile is a no-op off Linux
+func unlockFile(file *os.File) error {
+	return nil
+}
+
+// peerCredentials is not available off Linux
+func peerCredentials(conn *net.UnixConn) (int, int, int, error) {
+	return 0, 0, 0, errors.New("unix socket peer credentials are only supported on linux")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pluginStateFile is the file name of the persisted state inside StateDir
+const pluginStateFile = "state.json"
+
+// PluginState is node-local state that must survive plugin restarts
+type PluginState struct {
+	VideoDeviceStart int       `json:"video_device_start"` // First device number of the chosen range
+	MaxDevices       int       `json:"max_devices"`        // Range length when it was chosen
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// loadPluginState reads the persisted state; a missing file returns nil without error
+func loadPluginState(stateDir string) (*PluginState, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, pluginStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin state: %w", err)
+	}
+
+	state := &PluginState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin state: %w", err)
+	}
+	return state, nil
+}
+
+// savePluginState writes the state atomically
+func savePluginState(stateDir string, state *PluginState) error {
+	if err := ensureDirectory(stateDir); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	state.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin state: %w", err)
+	}
+
+	path := filepath.Join(stateDir, pluginStateFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write plugin state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to install plugin state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+)
+
+// Registration modes
+const (
+	RegistrationModeDirect  = "direct"  // Call kubelet's Registration service on KUBELET_SOCKET
+	RegistrationModeWatcher = "watcher" // Publish a socket under the kubelet plugins_registry directory
+	RegistrationModeBoth    = "both"    // Do both; whichever kubelet honours wins
+)
+
+// usesDirectRegistration reports whether the plugin calls kubelet's Register RPC
+func usesDirectRegistration(config *DevicePluginConfig) bool {
+	return config.RegistrationMode == RegistrationModeDirect || config.RegistrationMode == RegistrationModeBoth
+}
+
+// usesWatcherRegistration reports whether the plugin publishes a plugin watcher socket
+func usesWatcherRegistration(config *DevicePluginConfig) bool {
+	return config.RegistrationMode == RegistrationModeWatcher || config.RegistrationMode == RegistrationModeBoth
+}
+
+// PluginWatcherServer implements the kubelet plugin registration service
+// Kubelet discovers the socket in its plugins_registry directory, calls GetInfo to learn the
+// device plugin endpoint, connects to it and reports the outcome through NotifyRegistrationStatus
+type PluginWatcherServer struct {
+	resourceName string
+	endpoint     string // Device plugin socket kubelet should connect to
+	socketPath   string // Registration socket under the plugins_registry directory
+	onStatus     func(registered bool, reason string)
+	logger       *slog.Logger
+
+	mu       sync.Mutex
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// NewPluginWatcherServer creates a registration server for resourceName served on endpoint
+// onStatus is called with every registration status kubelet reports
+func NewPluginWatcherServer(registryDir, resourceName, endpoint string, onStatus func(bool, string), logger *slog.Logger) *PluginWatcherServer {
+	return &PluginWatcherServer{
+		resourceName: resourceName,
+		endpoint:     endpoint,
+		socketPath:   filepath.Join(registryDir, pluginWatcherSocketName(resourceName)),
+		onStatus:     onStatus,
+		logger:       logger,
+	}
+}
+
+// pluginWatcherSocketName derives a registration socket name from the resource name
+func pluginWatcherSocketName(resourceName string) string {
+	return strings.ReplaceAll(resourceName, "/", "_") + "-reg.sock"
+}
+
+// Start serves the registration service on the plugins_registry socket
+func (w *PluginWatcherServer) Start() error {
+	if err := ensureDirectory(filepath.Dir(w.socketPath)); err != nil {
+		return fmt.Errorf("failed to create plugin registry directory: %w", err)
+	}
+	if probeRegistrationSocketAlive(w.socketPath) {
+		return fmt.Errorf("registration socket %s is still served by another instance", w.socketPath)
+	}
+	if err := cleanupSocket(w.socketPath); err != nil {
+		w.logger.Warn("Failed to cleanup existing registration socket", "error", err)
+	}
+
+	listener, err := net.Listen("unix", w.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on registration socket: %w", err)
+	}
+
+	server := grpc.NewServer()
+	registerapi.RegisterRegistrationServer(server, w)
+
+	w.mu.Lock()
+	w.server = server
+	w.listener = listener
+	w.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			w.logger.Error("Registration gRPC server failed", "error", err)
+		}
+	}()
+
+	w.logger.Info("Published plugin watcher registration socket",
+		"registration_socket", w.socketPath,
+		"endpoint", w.endpoint)
+	return nil
+}
+
+// Stop removes the registration socket so kubelet deregisters the plugin
+func (w *PluginWatcherServer) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.server != nil {
+		w.server.Stop()
+		w.server = nil
+	}
+	if w.listener != nil {
+		_ = w.listener.Close()
+		w.listener = nil
+	}
+	if err := cleanupSocket(w.socketPath); err != nil {
+		w.logger.Warn("Failed to cleanup registration socket", "error", err)
+	}
+}
+
+// GetInfo implements the GetInfo gRPC method of the registration service
+func (w *PluginWatcherServer) GetInfo(ctx context.Context, req *registerapi.InfoRequest) (*registerapi.PluginInfo, error) {
+	w.logger.Debug("Plugin watcher GetInfo called")
+	return &registerapi.PluginInfo{
+		Type:              registerapi.DevicePlugin,
+		Name:              w.resourceName,
+		Endpoint:          w.endpoint,
+		SupportedVersions: []string{pluginapi.Version},
+	}, nil
+}
+
+// NotifyRegistrationStatus implements the NotifyRegistrationStatus gRPC method of the registration service
+func (w *PluginWatcherServer) NotifyRegistrationStatus(ctx context.Context, status *registerapi.RegistrationStatus) (*registerapi.RegistrationStatusResponse, error) {
+	if status.PluginRegistered {
+		w.logger.Info("Kubelet plugin watcher registered the plugin")
+	} else {
+		w.logger.Error("Kubelet plugin watcher rejected the plugin", "error", status.Error)
+	}
+
+	if w.onStatus != nil {
+		w.onStatus(status.PluginRegistered, status.Error)
+	}
+	return &registerapi.RegistrationStatusResponse{}, nil
+}
+
+// probeRegistrationSocketAlive reports whether a registration server answers on socketPath
+func probeRegistrationSocketAlive(socketPath string) bool {
+	if !checkDeviceExists(socketPath) {
+		return false
+	}
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = registerapi.NewRegistrationClient(conn).GetInfo(ctx, &registerapi.InfoRequest{})
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// podReadinessResolveAttempts bounds the PodResources lookups of the pod a started device belongs to
+	podReadinessResolveAttempts = 5
+	// podReadinessRetryDelay separates the lookups; kubelet may list the pod's devices a moment later
+	podReadinessRetryDelay = 2 * time.Second
+	// podReadinessTimeout bounds each lookup and the condition patch
+	podReadinessTimeout = 10 * time.Second
+)
+
+// reportPodReadiness sets the device readiness gate condition on the pod holding deviceIDs
+// It runs in the background so PreStartContainer never waits on the API server
+func (p *VideoDevicePlugin) reportPodReadiness(deviceIDs []string, ready bool, reason, message string) {
+	if p.k8sClient == nil || !p.config.EnablePodReadinessGate || len(deviceIDs) == 0 {
+		return
+	}
+	go p.setPodReadiness(deviceIDs[0], ready, reason, message)
+}
+
+// setPodReadiness resolves the pod of deviceID and patches its readiness gate condition
+// Pods that do not declare the readiness gate are left untouched
+func (p *VideoDevicePlugin) setPodReadiness(deviceID string, ready bool, reason, message string) {
+	conditionType := p.config.PodReadinessConditionType
+	logger := p.logger.With("device_id", deviceID, "condition", conditionType)
+
+	var owner podRef
+	for attempt := 1; owner.Name == ""; attempt++ {
+		ctx, cancel := context.WithTimeout(p.ctx, podReadinessTimeout)
+		pod, err := devicePod(ctx, p.config.PodResourcesSocket, p.deviceResource(deviceID), deviceID)
+		cancel()
+		if err == nil && pod.Name != "" {
+			owner = pod
+			break
+		}
+		if attempt == podReadinessResolveAttempts {
+			logger.Warn("Cannot resolve the pod of a started device, readiness gate not updated", "error", err)
+			return
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(podReadinessRetryDelay):
+		}
+	}
+	logger = logger.With("pod", owner.String())
+
+	ctx, cancel := context.WithTimeout(p.ctx, podReadinessTimeout)
+	defer cancel()
+	pod, err := p.k8sClient.GetPod(ctx, owner.Namespace, owner.Name)
+	if err != nil {
+		logger.Warn("Failed to read pod for its readiness gate", "error", err)
+		return
+	}
+	if !hasReadinessGate(pod, conditionType) {
+		logger.Debug("Pod declares no device readiness gate, not setting the condition")
+		return
+	}
+	if err := p.k8sClient.SetPodCondition(ctx, owner.Namespace, owner.Name, conditionType, ready, reason, message); err != nil {
+		logger.Warn("Failed to set the device readiness condition", "error", err)
+		return
+	}
+
+	status := string(corev1.ConditionFalse)
+	if ready {
+		status = string(corev1.ConditionTrue)
+	}
+	p.decisions.Publish(DecisionEvent{
+		Kind:          DecisionAllocation,
+		Action:        "pod_readiness_set",
+		DeviceID:      deviceID,
+		CorrelationID: p.allocations.CorrelationID(deviceID),
+		Message:       message,
+		Fields:        map[string]string{"pod": owner.String(), "condition": conditionType, "status": status, "reason": reason},
+	})
+}
+
+// hasReadinessGate reports whether a pod waits for conditionType before it becomes Ready
+func hasReadinessGate(pod *corev1.Pod, conditionType string) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if string(gate.ConditionType) == conditionType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+// listAssignedDevices returns the device IDs kubelet currently assigns to containers, by resource name
+// It queries kubelet's PodResources API, the source of truth for which pod holds which device
+func listAssignedDevices(ctx context.Context, socket string) (map[string]map[string]bool, error) {
+	conn, err := dialPodResources(socket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	resp, err := podresourcesapi.NewPodResourcesListerClient(conn).List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+
+	assigned := make(map[string]map[string]bool)
+	for _, pod := range resp.PodResources {
+		for _, container := range pod.Containers {
+			for _, devices := range container.Devices {
+				if assigned[devices.ResourceName] == nil {
+					assigned[devices.ResourceName] = make(map[string]bool)
+				}
+				for _, deviceID := range devices.DeviceIds {
+					assigned[devices.ResourceName][deviceID] = true
+				}
+			}
+		}
+	}
+	return assigned, nil
+}
+
+// listAllocatableDevices returns the device IDs kubelet considers allocatable on the node, by resource name
+func listAllocatableDevices(ctx context.Context, socket string) (map[string]map[string]bool, error) {
+	conn, err := dialPodResources(socket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	resp, err := podresourcesapi.NewPodResourcesListerClient(conn).GetAllocatableResources(ctx, &podresourcesapi.AllocatableResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allocatable resources: %w", err)
+	}
+
+	allocatable := make(map[string]map[string]bool)
+	for _, devices := range resp.Devices {
+		if allocatable[devices.ResourceName] == nil {
+			allocatable[devices.ResourceName] = make(map[string]bool)
+		}
+		for _, deviceID := range devices.DeviceIds {
+			allocatable[devices.ResourceName][deviceID] = true
+		}
+	}
+	return allocatable, nil
+}
+
+// dialPodResources connects to kubelet's PodResources socket
+func dialPodResources(socket string) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pod resources socket: %w", err)
+	}
+	return conn, nil
+}
+
+// assignedVideoDevices returns the video device IDs kubelet assigns to containers,
+// directly, through an av-bundle or through a device tier
+// With ADDITIONAL_KUBELET_SOCKETS every kubelet is asked; one failing fails the lookup
+func (p *VideoDevicePlugin) assignedVideoDevices(ctx context.Context) (map[string]bool, error) {
+	assigned, err := listAssignedDevices(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	videoIDs, err := p.endpointAssignedDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for kubeletID := range assigned[p.config.ResourceName] {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		videoIDs[deviceID] = true
+	}
+	for _, tier := range buildDeviceTiers(p.config) {
+		for kubeletID := range assigned[tier.ResourceName] {
+			if deviceID, _ := splitKubeletDeviceID(kubeletID); p.tiers[deviceID].ResourceName == tier.ResourceName {
+				videoIDs[deviceID] = true
+			}
+		}
+	}
+	for _, bundle := range buildAVBundles(p.config) {
+		if assigned[p.config.AVBundleResourceName][bundle.ID] {
+			videoIDs[bundle.VideoDeviceID] = true
+		}
+	}
+	return videoIDs, nil
+}
+
+// endpointAssignedDevices returns the video device IDs the additional kubelets assign to containers
+// Only the main resource is served to them
+func (p *VideoDevicePlugin) endpointAssignedDevices(ctx context.Context) (map[string]bool, error) {
+	videoIDs := make(map[string]bool)
+	for _, endpoint := range p.endpoints {
+		assigned, err := listAssignedDevices(ctx, endpoint.PodResourcesSocket)
+		if err != nil {
+			return nil, fmt.Errorf("kubelet %s: %w", endpoint.KubeletSocket, err)
+		}
+		for kubeletID := range assigned[p.config.ResourceName] {
+			deviceID, _ := splitKubeletDeviceID(kubeletID)
+			videoIDs[deviceID] = true
+		}
+	}
+	return videoIDs, nil
+}
+
+// podVideoDevices returns the video device IDs kubelet assigns to one pod's containers
+// Kubelet keeps reporting the devices of a Failed pod until the pod object is deleted
+func (p *VideoDevicePlugin) podVideoDevices(ctx context.Context, pod podRef) (map[string]bool, error) {
+	pods, err := listDevicePods(ctx, p.config.PodResourcesSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	videoIDs := make(map[string]bool)
+	for kubeletID, holder := range pods[p.config.ResourceName] {
+		if holder == pod {
+			deviceID, _ := splitKubeletDeviceID(kubeletID)
+			videoIDs[deviceID] = true
+		}
+	}
+	for _, tier := range buildDeviceTiers(p.config) {
+		for kubeletID, holder := range pods[tier.ResourceName] {
+			if deviceID, _ := splitKubeletDeviceID(kubeletID); holder == pod && p.tiers[deviceID].ResourceName == tier.ResourceName {
+				videoIDs[deviceID] = true
+			}
+		}
+	}
+	for _, bundle := range buildAVBundles(p.config) {
+		if holder, ok := pods[p.config.AVBundleResourceName][bundle.ID]; ok && holder == pod {
+			videoIDs[bundle.VideoDeviceID] = true
+		}
+	}
+	return videoIDs, nil
+}
+
+// podDeviceRequest returns how many devices of resource a pod holds
+// Like the scheduler, it takes the max of init containers and the sum of regular containers
+func podDeviceRequest(pod *corev1.Pod, resource string) int64 {
+	name := corev1.ResourceName(resource)
+
+	var sum int64
+	for _, container := range pod.Spec.Containers {
+		if quantity, ok := container.Resources.Limits[name]; ok {
+			sum += quantity.Value()
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if quantity, ok := container.Resources.Limits[name]; ok && quantity.Value() > sum {
+			sum = quantity.Value()
+		}
+	}
+	return sum
+}
+
+// podRef names a pod
+type podRef struct {
+	Namespace string
+	Name      string
+}
+
+// listDevicePods returns the pod holding each assigned device ID, by resource name
+func listDevicePods(ctx context.Context, socket string) (map[string]map[string]podRef, error) {
+	conn, err := dialPodResources(socket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	resp, err := podresourcesapi.NewPodResourcesListerClient(conn).List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+
+	pods := make(map[string]map[string]podRef)
+	for _, pod := range resp.PodResources {
+		for _, container := range pod.Containers {
+			for _, devices := range container.Devices {
+				if pods[devices.ResourceName] == nil {
+					pods[devices.ResourceName] = make(map[string]podRef)
+				}
+				for _, deviceID := range devices.DeviceIds {
+					pods[devices.ResourceName][deviceID] = podRef{Namespace: pod.Namespace, Name: pod.Name}
+				}
+			}
+		}
+	}
+	return pods, nil
+}
+
+// devicePod returns the pod kubelet assigned a device of resourceName to, empty when none
+// deviceID is matched under any rotation of its kubelet ID
+func devicePod(ctx context.Context, socket, resourceName, deviceID string) (podRef, error) {
+	pods, err := listDevicePods(ctx, socket)
+	if err != nil {
+		return podRef{}, err
+	}
+	for kubeletID, pod := range pods[resourceName] {
+		if id, _ := splitKubeletDeviceID(kubeletID); id == deviceID {
+			return pod, nil
+		}
+	}
+	return podRef{}, nil
+}
+
+// String returns namespace/name
+func (r podRef) String() string {
+	return r.Namespace + "/" + r.Name
+}
@@ -0,0 +1,445 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// podReleaseGrace keeps fresh kubelet allocations that PodResources may not report yet
+const podReleaseGrace = 30 * time.Second
+
+// podReconcileInterval is the backstop reconcile period when no pod event arrives
+const podReconcileInterval = time.Minute
+
+// podReconcileDelay collects a burst of pod events (e.g. a node drain) into one reconcile
+const podReconcileDelay = time.Second
+
+// podReconcileKey is the work queue item of a node-wide reconcile; releases are keyed per allocation
+const podReconcileKey = "reconcile"
+
+// podReleaseKeyPrefix prefixes the work queue items releasing one terminal pod's devices (pod/<namespace>/<name>)
+const podReleaseKeyPrefix = "pod/"
+
+// podWorkMaxRetries bounds how often a failed reconcile or release is retried before it is dropped
+const podWorkMaxRetries = 5
+
+// Per-item retry backoff of failed work queue items
+const (
+	podRetryBaseDelay = 500 * time.Millisecond
+	podRetryMaxDelay  = time.Minute
+)
+
+// PodWatcher releases kubelet allocations once the pods holding them are gone
+// It only watches pods on this node, optionally narrowed by namespace, label and field selectors,
+// so RBAC can be limited to those namespaces and the cache stays small
+type PodWatcher struct {
+	client        *K8sClient
+	plugin        *VideoDevicePlugin
+	config        *DevicePluginConfig
+	fieldSelector string
+	labelSelector string
+	logger        *slog.Logger
+	queue         workqueue.TypedRateLimitingInterface[string]
+	cancel        context.CancelFunc
+
+	mu         sync.Mutex
+	releases   map[string]Allocation     // Release queue key -> allocation whose cleanup is pending
+	terminated map[string]podTermination // Pod release queue key -> when and why the pod ended
+}
+
+// podTermination records a pod seen Failed or Succeeded whose devices are released directly
+type podTermination struct {
+	pod    podRef
+	reason string // Decision action: pod_evicted, pod_failed or pod_succeeded
+	seenAt time.Time
+}
+
+// podWatchSelectors returns the field and label selectors of the pod watch
+// The field selector always includes spec.nodeName so only this node's pods are cached
+func podWatchSelectors(config *DevicePluginConfig) (string, string, error) {
+	selector := fields.OneTermEqualSelector("spec.nodeName", config.NodeName)
+	if config.PodWatchFieldSelector != "" {
+		extra, err := fields.ParseSelector(config.PodWatchFieldSelector)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid POD_WATCH_FIELD_SELECTOR: %w", err)
+		}
+		selector = fields.AndSelectors(selector, extra)
+	}
+	if _, err := labels.Parse(config.PodWatchLabelSelector); err != nil {
+		return "", "", fmt.Errorf("invalid POD_WATCH_LABEL_SELECTOR: %w", err)
+	}
+	return selector.String(), config.PodWatchLabelSelector, nil
+}
+
+// NewPodWatcher creates a new PodWatcher instance
+func NewPodWatcher(client *K8sClient, plugin *VideoDevicePlugin, config *DevicePluginConfig, logger *slog.Logger) (*PodWatcher, error) {
+	fieldSelector, labelSelector, err := podWatchSelectors(config)
+	if err != nil {
+		return nil, err
+	}
+	return &PodWatcher{
+		client:        client,
+		plugin:        plugin,
+		config:        config,
+		fieldSelector: fieldSelector,
+		labelSelector: labelSelector,
+		logger:        logger.With("component", "pod-watcher"),
+		queue:         newPodWorkQueue(config),
+		releases:      make(map[string]Allocation),
+		terminated:    make(map[string]podTermination),
+	}, nil
+}
+
+// newPodWorkQueue creates the work queue of reconciles and releases
+// Failed items back off exponentially; all items share a token bucket of POD_RELEASE_RATE per second
+// so a drain releasing many devices does not run their hooks and API calls at once
+func newPodWorkQueue(config *DevicePluginConfig) workqueue.TypedRateLimitingInterface[string] {
+	limiter := workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](podRetryBaseDelay, podRetryMaxDelay),
+		&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Limit(config.PodReleaseRate), config.PodReleaseRate)},
+	)
+	return workqueue.NewTypedRateLimitingQueueWithConfig(limiter, workqueue.TypedRateLimitingQueueConfig[string]{
+		Name: "pod-watcher",
+	})
+}
+
+// Start runs the shared informer and the reconcile loop in the background until ctx is canceled
+// or Stop is called; reconciles and releases are bounded by timeouts derived from ctx
+func (w *PodWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	factory := informers.NewSharedInformerFactoryWithOptions(w.client.clientset,
+		time.Duration(w.config.PodWatchResync)*time.Second,
+		informers.WithNamespace(w.config.PodWatchNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = w.fieldSelector
+			options.LabelSelector = w.labelSelector
+		}))
+
+	informer := factory.Core().V1().Pods().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.onAdd,
+		UpdateFunc: w.onUpdate,
+		DeleteFunc: w.onDelete,
+	}); err != nil {
+		w.logger.Error("Failed to register pod event handler", "error", err)
+		return
+	}
+
+	w.logger.Info("Starting pod watcher",
+		"namespace", w.config.PodWatchNamespace,
+		"field_selector", w.fieldSelector,
+		"label_selector", w.labelSelector,
+		"resync_seconds", w.config.PodWatchResync)
+	factory.Start(ctx.Done())
+	go func() {
+		for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				w.logger.Warn("Pod informer cache did not sync", "type", informerType.String())
+				return
+			}
+		}
+		w.logger.Info("Pod informer cache synced")
+		w.queueReconcile()
+	}()
+	go func() {
+		<-ctx.Done()
+		w.queue.ShutDown()
+	}()
+	go w.reconcileLoop(ctx)
+	go w.runWorker(ctx)
+}
+
+// onAdd handles pods seen for the first time, including the initial list
+// Pods already terminal when we start would otherwise never produce another event
+func (w *PodWatcher) onAdd(obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !w.holdsDevices(pod) {
+		return
+	}
+	if podTerminal(pod) {
+		w.queuePodRelease(pod)
+		return
+	}
+	if pod.Status.Phase == corev1.PodRunning {
+		w.queueReconcile()
+	}
+}
+
+// onUpdate handles phase changes and periodic resyncs
+// Pods starting to run confirm their assignment; pods turning Failed (evictions included) or Succeeded
+// release theirs at once, and terminal pods re-delivered on every resync are reconciled
+func (w *PodWatcher) onUpdate(oldObj, newObj any) {
+	oldPod, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	pod, ok := newObj.(*corev1.Pod)
+	if !ok || !w.holdsDevices(pod) {
+		return
+	}
+	if podTerminal(pod) && !podTerminal(oldPod) {
+		w.queuePodRelease(pod)
+		return
+	}
+	startedRunning := pod.Status.Phase == corev1.PodRunning && oldPod.Status.Phase != corev1.PodRunning
+	if startedRunning || podTerminal(pod) {
+		w.queueReconcile()
+	}
+}
+
+// onDelete handles deleted pods, including tombstones for deletions missed while the watch was down
+func (w *PodWatcher) onDelete(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		// A tombstone without a pod still means something was deleted
+		w.logger.Debug("Pod deletion with unexpected object", "type", fmt.Sprintf("%T", obj))
+		w.queueReconcile()
+		return
+	}
+	if w.holdsDevices(pod) {
+		w.queueReconcile()
+	}
+}
+
+// Stop ends the informers, the reconcile loop and the worker; queued releases are dropped
+func (w *PodWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.queue.ShutDown()
+}
+
+// holdsDevices reports whether a pod requests one of this plugin's resources
+func (w *PodWatcher) holdsDevices(pod *corev1.Pod) bool {
+	if podDeviceRequest(pod, w.config.ResourceName) > 0 {
+		return true
+	}
+	return w.config.AVBundleCount > 0 && podDeviceRequest(pod, w.config.AVBundleResourceName) > 0
+}
+
+// podTerminal reports whether a pod has finished and released its devices
+func podTerminal(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+// podTerminationReason returns the decision action of a terminal pod
+func podTerminationReason(pod *corev1.Pod) string {
+	switch {
+	case pod.Status.Phase == corev1.PodSucceeded:
+		return "pod_succeeded"
+	case pod.Status.Reason == "Evicted":
+		return "pod_evicted"
+	default:
+		return "pod_failed"
+	}
+}
+
+// queuePodRelease schedules the release of a terminal pod's devices without blocking the informer
+// Kubelet keeps a Failed pod's devices assigned until the pod object is deleted, which for evicted
+// pods can take until the pod garbage collector runs, so the node-wide reconcile cannot release them
+func (w *PodWatcher) queuePodRelease(pod *corev1.Pod) {
+	key := podReleaseKeyPrefix + pod.Namespace + "/" + pod.Name
+	w.mu.Lock()
+	if _, pending := w.terminated[key]; !pending {
+		w.terminated[key] = podTermination{
+			pod:    podRef{Namespace: pod.Namespace, Name: pod.Name},
+			reason: podTerminationReason(pod),
+			seenAt: time.Now(),
+		}
+	}
+	w.mu.Unlock()
+	w.queue.Add(key)
+}
+
+// queueReconcile schedules a reconcile without blocking the informer
+// Events arriving while one is already scheduled are coalesced into it
+func (w *PodWatcher) queueReconcile() {
+	w.queue.AddAfter(podReconcileKey, podReconcileDelay)
+}
+
+// reconcileLoop queues a reconcile periodically as a backstop for missed pod events
+func (w *PodWatcher) reconcileLoop(ctx context.Context) {
+	ticker := w.plugin.clock.NewTicker(podReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			w.queue.Add(podReconcileKey)
+		}
+	}
+}
+
+// runWorker processes queued reconciles and releases one at a time until the queue shuts down
+func (w *PodWatcher) runWorker(ctx context.Context) {
+	for {
+		key, shutdown := w.queue.Get()
+		if shutdown {
+			return
+		}
+		w.processItem(ctx, key)
+	}
+}
+
+// processItem runs one queue item and requeues it with backoff when it fails
+func (w *PodWatcher) processItem(ctx context.Context, key string) {
+	defer w.queue.Done(key)
+
+	var err error
+	switch {
+	case key == podReconcileKey:
+		err = w.reconcile(ctx)
+	case strings.HasPrefix(key, podReleaseKeyPrefix):
+		err = w.releasePod(ctx, key)
+	default:
+		err = w.release(ctx, key)
+	}
+	if err == nil {
+		w.queue.Forget(key)
+		return
+	}
+
+	if retries := w.queue.NumRequeues(key); retries < podWorkMaxRetries {
+		w.logger.Warn("Pod watcher work failed, retrying", "item", key, "retries", retries, "error", err)
+		w.queue.AddRateLimited(key)
+		return
+	}
+	w.logger.Error("Pod watcher work failed, giving up", "item", key, "retries", podWorkMaxRetries, "error", err)
+	w.queue.Forget(key)
+	if key != podReconcileKey {
+		w.mu.Lock()
+		delete(w.releases, key)
+		delete(w.terminated, key)
+		w.mu.Unlock()
+	}
+}
+
+// reconcile releases kubelet allocations of devices no container holds any more
+// The bookkeeping is released at once; handoff and hook cleanup is queued per allocation
+func (w *PodWatcher) reconcile(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	assigned, err := w.plugin.assignedVideoDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reconcile allocations with kubelet: %w", err)
+	}
+
+	for _, allocation := range w.plugin.allocations.ReleaseKubeletExcept(assigned, podReleaseGrace) {
+		w.logger.Info("Released kubelet allocation of terminated pod",
+			"device_id", allocation.DeviceID,
+			"correlation_id", allocation.CorrelationID,
+			"allocated_at", allocation.AllocatedAt)
+		w.plugin.decisions.Publish(DecisionEvent{
+			Kind:          DecisionRelease,
+			Action:        "pod_gone",
+			DeviceID:      allocation.DeviceID,
+			CorrelationID: allocation.CorrelationID,
+		})
+		w.queueRelease(allocation)
+	}
+	return nil
+}
+
+// releasePod releases the kubelet allocations of a pod that turned Failed or Succeeded
+// Only allocations made before the pod was seen terminal are released; a device kubelet has
+// already handed to another pod since keeps its new allocation
+func (w *PodWatcher) releasePod(ctx context.Context, key string) error {
+	w.mu.Lock()
+	termination, ok := w.terminated[key]
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	devices, err := w.plugin.podVideoDevices(ctx, termination.pod)
+	if err != nil {
+		return fmt.Errorf("cannot look up devices of pod %s: %w", termination.pod, err)
+	}
+	// The pod is one of the primary kubelet's; a device an additional kubelet assigns is not its own
+	elsewhere, err := w.plugin.endpointAssignedDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot look up devices of the additional kubelets: %w", err)
+	}
+	for deviceID := range elsewhere {
+		delete(devices, deviceID)
+	}
+
+	for _, allocation := range w.plugin.allocations.ReleaseKubeletDevices(devices, termination.seenAt) {
+		w.logger.Info("Released kubelet allocation of terminal pod",
+			"pod", termination.pod.String(),
+			"reason", termination.reason,
+			"device_id", allocation.DeviceID,
+			"correlation_id", allocation.CorrelationID,
+			"allocated_at", allocation.AllocatedAt)
+		w.plugin.decisions.Publish(DecisionEvent{
+			Kind:          DecisionRelease,
+			Action:        termination.reason,
+			DeviceID:      allocation.DeviceID,
+			CorrelationID: allocation.CorrelationID,
+			Message:       termination.pod.String(),
+		})
+		w.queueRelease(allocation)
+	}
+
+	w.mu.Lock()
+	delete(w.terminated, key)
+	w.mu.Unlock()
+	return nil
+}
+
+// queueRelease schedules the handoff and hook cleanup of a released allocation
+func (w *PodWatcher) queueRelease(allocation Allocation) {
+	key := allocation.CorrelationID + "/" + allocation.DeviceID
+	w.mu.Lock()
+	w.releases[key] = allocation
+	w.mu.Unlock()
+	w.queue.AddRateLimited(key)
+}
+
+// release removes the handoff files and runs the release hooks of a released allocation
+func (w *PodWatcher) release(ctx context.Context, key string) error {
+	w.mu.Lock()
+	allocation, ok := w.releases[key]
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	w.plugin.removeHandoff(allocation.CorrelationID, allocation.DeviceID)
+	if device, err := w.plugin.v4l2Manager.GetDeviceByID(allocation.DeviceID); err == nil {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(w.config.AllocationTimeout)*time.Second)
+		defer cancel()
+		if err := w.plugin.runDeviceHooks(ctx, HookStageRelease, device); err != nil {
+			return fmt.Errorf("release hooks failed for %s: %w", allocation.DeviceID, err)
+		}
+	}
+
+	w.mu.Lock()
+	delete(w.releases, key)
+	w.mu.Unlock()
+	return nil
+}
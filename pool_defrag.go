@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// poolDefragCheckInterval is how often the scheduled defragmentation looks at the pool in its window
+	poolDefragCheckInterval = 5 * time.Minute
+	// poolDefragQueryTimeout bounds the PodResources query proving the pool is idle
+	poolDefragQueryTimeout = 5 * time.Second
+)
+
+// ErrPoolBusy is returned when the pool cannot be defragmented because devices are allocated
+var ErrPoolBusy = errors.New("device pool has allocated devices")
+
+// PoolDefragReport describes a defragmentation of the device pool, as returned by /v1/pool/defragment
+type PoolDefragReport struct {
+	DryRun     bool              `json:"dry_run"`
+	Changed    bool              `json:"changed"`    // Whether anything was (or would be) changed
+	Recreated  []string          `json:"recreated"`  // Devices recreated in place: missing nodes and withdrawn devices
+	Removed    []string          `json:"removed"`    // Free stray loopback devices deleted above the range
+	Reassigned map[string]string `json:"reassigned"` // Device ID -> new pool role
+	Unhealthy  []string          `json:"unhealthy"`  // Devices still unhealthy afterwards, left to hot spare repair
+	Blocked    string            `json:"blocked,omitempty"`
+}
+
+// parsePoolDefragWindow parses POOL_DEFRAG_WINDOW ("HH:MM-HH:MM" UTC, may wrap midnight)
+// It returns the window bounds in minutes after midnight
+func parsePoolDefragWindow(value string) (int, int, error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("POOL_DEFRAG_WINDOW must be HH:MM-HH:MM, got %q", value)
+	}
+	var bounds [2]int
+	for i, clock := range []string{from, to} {
+		parsed, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return 0, 0, fmt.Errorf("POOL_DEFRAG_WINDOW must be HH:MM-HH:MM, got %q", value)
+		}
+		bounds[i] = parsed.Hour()*60 + parsed.Minute()
+	}
+	if bounds[0] == bounds[1] {
+		return 0, 0, fmt.Errorf("POOL_DEFRAG_WINDOW must not be empty, got %q", value)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// inPoolDefragWindow reports whether t falls into the window [from, to) in UTC
+func inPoolDefragWindow(t time.Time, from, to int) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// videoNumber returns N of a videoN device ID
+func videoNumber(deviceID string) int {
+	number, _ := strconv.Atoi(strings.TrimPrefix(deviceID, "video"))
+	return number
+}
+
+// arrange replaces every held-back role with roles
+func (s *HotSparePool) arrange(roles map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = roles
+	s.notifyChangeLocked()
+}
+
+// poolLayout returns the held-back roles of a compacted main pool: the configured spare slots
+// hold the spares (repairing while unhealthy), the highest remaining slots the parked devices and
+// the lowest ones are advertised, as if the pool had never been through promotions and resizes
+func (p *VideoDevicePlugin) poolLayout(unhealthy map[string]bool) map[string]string {
+	spareSlots := make(map[string]bool)
+	for _, id := range hotSpareIDs(p.config) {
+		spareSlots[id] = true
+	}
+
+	var slots []string
+	parked := 0
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		if p.deviceResource(deviceID) != p.config.ResourceName {
+			continue
+		}
+		if p.spares.Role(deviceID) == DeviceRoleParked {
+			parked++
+		}
+		if !spareSlots[deviceID] {
+			slots = append(slots, deviceID)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return videoNumber(slots[i]) < videoNumber(slots[j]) })
+
+	roles := make(map[string]string)
+	for id := range spareSlots {
+		if unhealthy[id] {
+			roles[id] = DeviceRoleRepairing
+		} else {
+			roles[id] = DeviceRoleSpare
+		}
+	}
+	for _, id := range slots[max(len(slots)-parked, 0):] {
+		roles[id] = DeviceRoleParked
+	}
+	return roles
+}
+
+// poolBusy returns the devices allocated to pods, from the tracker and from kubelet
+// Kubelet's view is required: the tracker misses allocations of restarts without a checkpoint
+func (p *VideoDevicePlugin) poolBusy(ctx context.Context) ([]string, error) {
+	busy := make(map[string]bool)
+	for _, allocation := range p.allocations.List() {
+		busy[allocation.DeviceID] = true
+	}
+	ctx, cancel := context.WithTimeout(ctx, poolDefragQueryTimeout)
+	defer cancel()
+	assigned, err := p.assignedVideoDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot confirm the pool is idle: %w", err)
+	}
+	for deviceID := range assigned {
+		busy[deviceID] = true
+	}
+
+	ids := make([]string, 0, len(busy))
+	for deviceID := range busy {
+		ids = append(ids, deviceID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// DefragmentPool compacts the device pool back to the configured contiguous range
+// Repeated v4l2loopback-ctl add/remove cycles, hot spare promotions and capacity changes leave
+// holes in the range, stray devices above it and the advertised devices scattered across it.
+// With no device allocated it recreates missing and withdrawn devices, deletes free stray
+// loopback devices and moves the spare, parked and advertised roles back to their slots.
+// It fails with ErrPoolBusy while any device is allocated; dryRun only reports the plan.
+func (p *VideoDevicePlugin) DefragmentPool(ctx context.Context, dryRun bool) (*PoolDefragReport, error) {
+	if p.shadow {
+		return nil, fmt.Errorf("a shadow instance never changes devices")
+	}
+	if p.v4l2Manager.IsFallbackMode() {
+		return nil, fmt.Errorf("devices are fallback placeholders: %s", p.v4l2Manager.GetFallbackReason())
+	}
+
+	p.resizeMu.Lock()
+	defer p.resizeMu.Unlock()
+
+	busy, err := p.poolBusy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(busy) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPoolBusy, strings.Join(busy, ", "))
+	}
+
+	report := &PoolDefragReport{
+		DryRun:     dryRun,
+		Recreated:  []string{},
+		Removed:    []string{},
+		Reassigned: map[string]string{},
+		Unhealthy:  []string{},
+	}
+
+	// Range-level holes and strays, as planned for a partially created device set
+	plan := planDeviceSetConvergence(p.config)
+	report.Blocked = plan.Blocked
+	recreate := make(map[string]bool)
+	if plan.Blocked == "" {
+		for _, n := range plan.Missing {
+			recreate[fmt.Sprintf("video%d", n)] = true
+		}
+		for _, n := range plan.Surplus {
+			report.Removed = append(report.Removed, fmt.Sprintf("/dev/video%d", n))
+		}
+	}
+	for _, deviceID := range p.spares.withRole(DeviceRoleRepairing) {
+		recreate[deviceID] = true
+	}
+	for deviceID := range recreate {
+		if _, err := p.v4l2Manager.GetDeviceByID(deviceID); err == nil {
+			report.Recreated = append(report.Recreated, deviceID)
+		}
+	}
+	sort.Slice(report.Recreated, func(i, j int) bool { return videoNumber(report.Recreated[i]) < videoNumber(report.Recreated[j]) })
+
+	if !dryRun {
+		recreated := []string{}
+		for _, deviceID := range report.Recreated {
+			device, _ := p.v4l2Manager.GetDeviceByID(deviceID)
+			resetCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+			err := p.resetDeviceWithContext(resetCtx, device.Path, p.deviceMaxBuffers(device))
+			cancel()
+			if err != nil {
+				p.logger.Warn("Failed to recreate device while defragmenting", "device_id", deviceID, "error", err)
+				continue
+			}
+			if err := p.v4l2Manager.RefreshDevice(deviceID); err != nil {
+				p.logger.Warn("Failed to refresh device metadata", "device_id", deviceID, "error", err)
+			}
+			recreated = append(recreated, deviceID)
+		}
+		report.Recreated = recreated
+		if len(report.Recreated) > 0 {
+			p.RotateDeviceIDs("recreated by pool defragmentation", report.Recreated)
+		}
+
+		// Stray devices are never advertised; removing them is tidy-up and failures are not fatal
+		removed := []string{}
+		for _, devicePath := range report.Removed {
+			removeCtx, cancel := context.WithTimeout(ctx, time.Duration(p.config.DeviceCreationTimeout)*time.Second)
+			out, err := privilegedCommand(removeCtx, "v4l2loopback-ctl", "delete", devicePath)
+			cancel()
+			if err != nil {
+				p.logger.Warn("Failed to remove stray device", "device_path", devicePath, "error", err, "output", strings.TrimSpace(string(out)))
+				continue
+			}
+			p.setCtlAdded(devicePath, false)
+			removed = append(removed, devicePath)
+		}
+		report.Removed = removed
+	}
+
+	// Devices still broken keep going through the hot spare repair
+	unhealthy := make(map[string]bool)
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		if p.deviceResource(deviceID) == p.config.ResourceName && !p.v4l2Manager.GetDeviceHealth(deviceID) && (!dryRun || !recreate[deviceID]) {
+			unhealthy[deviceID] = true
+			report.Unhealthy = append(report.Unhealthy, deviceID)
+		}
+	}
+	sort.Slice(report.Unhealthy, func(i, j int) bool { return videoNumber(report.Unhealthy[i]) < videoNumber(report.Unhealthy[j]) })
+
+	current := p.spares.Snapshot()
+	layout := p.poolLayout(unhealthy)
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		role, wanted := current[deviceID], layout[deviceID]
+		if role != wanted {
+			if wanted == "" {
+				wanted = DeviceRoleAdvertised
+			}
+			report.Reassigned[deviceID] = wanted
+		}
+	}
+	report.Changed = len(report.Recreated) > 0 || len(report.Removed) > 0 || len(report.Reassigned) > 0
+
+	if dryRun || !report.Changed {
+		return report, nil
+	}
+	if len(report.Reassigned) > 0 {
+		p.spares.arrange(layout)
+	}
+
+	p.logger.Info("Defragmented device pool",
+		"recreated", report.Recreated,
+		"removed", report.Removed,
+		"reassigned", report.Reassigned,
+		"unhealthy", report.Unhealthy)
+	p.decisions.Publish(DecisionEvent{
+		Kind:    DecisionReconcile,
+		Action:  "pool_defragmented",
+		Message: fmt.Sprintf("recreated %v, removed %v, reassigned %d devices", report.Recreated, report.Removed, len(report.Reassigned)),
+	})
+	p.requestListAndWatchRefresh()
+	return report, nil
+}
+
+// monitorPoolDefragmentation defragments the pool inside POOL_DEFRAG_WINDOW whenever it is
+// fragmented and idle; a busy pool is retried on the next check
+func (p *VideoDevicePlugin) monitorPoolDefragmentation() {
+	if p.config.PoolDefragWindow == "" || p.shadow || p.v4l2Manager.IsFallbackMode() {
+		return
+	}
+	from, to, _ := parsePoolDefragWindow(p.config.PoolDefragWindow)
+
+	ticker := p.clock.NewTicker(poolDefragCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		if !inPoolDefragWindow(p.clock.Now(), from, to) {
+			continue
+		}
+
+		plan, err := p.DefragmentPool(p.ctx, true)
+		if err != nil {
+			p.logger.Debug("Skipping scheduled pool defragmentation", "error", err)
+			continue
+		}
+		if !plan.Changed {
+			continue
+		}
+		// Hot spare repair would scatter the roles again right away
+		if len(plan.Unhealthy) > 0 {
+			p.logger.Debug("Skipping scheduled pool defragmentation while devices are unhealthy", "unhealthy", plan.Unhealthy)
+			continue
+		}
+		if _, err := p.DefragmentPool(p.ctx, false); err != nil {
+			p.logger.Warn("Scheduled pool defragmentation failed", "error", err)
+		}
+	}
+}
+
+// handleDefragmentPool compacts the device pool; ?dry_run=true only reports what would change
+func (a *AdminServer) handleDefragmentPool(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	report, err := a.plugin.DefragmentPool(r.Context(), dryRun)
+	switch {
+	case errors.Is(err, ErrPoolBusy):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, report)
+	}
+}
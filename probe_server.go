@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// readinessResponse is the body returned by /readyz
+type readinessResponse struct {
+	Ready   bool   `json:"ready"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// startProbeServer serves /healthz (liveness) and /readyz (readiness) on the given port in the background
+func startProbeServer(port int, plugin *VideoDevicePlugin, logger *slog.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", handleHealthz(plugin))
+	mux.HandleFunc("GET /healthz/schema", handleHealthSchema)
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready, reason, message := plugin.evaluateReadiness()
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, readinessResponse{Ready: ready, Reason: reason, Message: message})
+	})
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Starting probe server", "port", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Probe server failed", "error", err)
+		}
+	}()
+
+	return server
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Kubelet checks a registering resource name with IsExtendedResourceName: it must be a qualified
+// vendor-domain/name outside the kubernetes.io domains, and the quota name "requests.<name>"
+// derived from it must be a qualified name too, so the domain has 9 characters less than a
+// DNS subdomain
+const (
+	resourceQuotaPrefix     = "requests."
+	maxResourceNamePartSize = 63
+)
+
+// invalidResourceNameChars are the characters a resource name part cannot contain
+var invalidResourceNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// validateResourceName reports why kubelet would reject a resource name, naming the setting it came from
+func validateResourceName(key, name string) error {
+	domain, resource, ok := strings.Cut(name, "/")
+	if !ok || domain == "" || resource == "" {
+		return fmt.Errorf("%s must be vendor-domain/resource (e.g. meeting-baas.io/video-devices), got %q", key, name)
+	}
+	if strings.HasPrefix(name, resourceQuotaPrefix) {
+		return fmt.Errorf("%s must not start with %q, got %q", key, resourceQuotaPrefix, name)
+	}
+	if domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io") {
+		return fmt.Errorf("%s must not use the kubernetes.io domain reserved for native resources, got %q", key, name)
+	}
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("%s domain %q is invalid: %s", key, domain, strings.Join(errs, "; "))
+	}
+	if len(resource) > maxResourceNamePartSize {
+		return fmt.Errorf("%s resource part %q must be at most %d characters, got %d", key, resource, maxResourceNamePartSize, len(resource))
+	}
+	if errs := validation.IsQualifiedName(resourceQuotaPrefix + name); len(errs) > 0 {
+		return fmt.Errorf("%s %q is not a valid extended resource name: %s", key, name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sanitizeResourceName adapts a generated resource name to kubelet's naming rules
+// Invalid characters become dashes and an overlong name part is shortened, keeping a hash of the
+// original so different long names stay distinct. The domain is left alone: it comes from a
+// validated setting
+func sanitizeResourceName(name string) string {
+	domain, resource, _ := strings.Cut(name, "/")
+	cleaned := invalidResourceNameChars.ReplaceAllString(resource, "-")
+	cleaned = strings.Trim(cleaned, "-._")
+	if len(cleaned) > maxResourceNamePartSize {
+		sum := sha256.Sum256([]byte(resource))
+		suffix := "-" + hex.EncodeToString(sum[:])[:8]
+		cleaned = strings.TrimRight(cleaned[:maxResourceNamePartSize-len(suffix)], "-._") + suffix
+	}
+	return domain + "/" + cleaned
+}
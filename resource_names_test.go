@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateResourceName(t *testing.T) {
+	// Valid as a DNS subdomain, but "requests.<domain>" exceeds the 253 characters of a qualified name prefix
+	quotaDomain := strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("b", 50) + ".io"
+
+	tests := []struct {
+		name      string
+		resource  string
+		wantError string // Substring of the error, empty for a valid name
+	}{
+		{"valid", "meeting-baas.io/video-devices", ""},
+		{"subdomain", "video.meeting-baas.io/premium", ""},
+		{"no domain", "video-devices", "must be vendor-domain/resource"},
+		{"empty resource", "meeting-baas.io/", "must be vendor-domain/resource"},
+		{"quota prefix", "requests.meeting-baas.io/video", "must not start with"},
+		{"kubernetes.io", "kubernetes.io/video", "reserved for native resources"},
+		{"kubernetes.io subdomain", "video.kubernetes.io/devices", "reserved for native resources"},
+		{"invalid domain", "Meeting_BaaS.io/video", "domain"},
+		{"long resource", "meeting-baas.io/" + strings.Repeat("v", 64), "at most 63 characters"},
+		{"invalid resource", "meeting-baas.io/video devices", "not a valid extended resource name"},
+		{"domain too long for the quota name", quotaDomain + "/video", "not a valid extended resource name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResourceName("RESOURCE_NAME", tt.resource)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatalf("validateResourceName(%q) = %v, want nil", tt.resource, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateResourceName(%q) = nil, want an error containing %q", tt.resource, tt.wantError)
+			}
+			if !strings.Contains(err.Error(), tt.wantError) || !strings.Contains(err.Error(), "RESOURCE_NAME") {
+				t.Errorf("validateResourceName(%q) = %q, want it to name RESOURCE_NAME and contain %q", tt.resource, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSanitizeResourceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		want     string
+	}{
+		{"valid", "meeting-baas.io/video-premium", "meeting-baas.io/video-premium"},
+		{"invalid characters", "meeting-baas.io/video premium!", "meeting-baas.io/video-premium"},
+		{"leading and trailing separators", "meeting-baas.io/_video.", "meeting-baas.io/video"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeResourceName(tt.resource); got != tt.want {
+				t.Errorf("sanitizeResourceName(%q) = %q, want %q", tt.resource, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long", func(t *testing.T) {
+		long := "meeting-baas.io/" + strings.Repeat("premium-", 10)
+		got := sanitizeResourceName(long)
+		if err := validateResourceName("DEVICE_TIERS", got); err != nil {
+			t.Errorf("sanitized name %q is still invalid: %v", got, err)
+		}
+		if other := sanitizeResourceName(long + "x"); other == got {
+			t.Errorf("different long names both sanitize to %q", got)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedSetting is returned for ConfigMap keys this plugin version does not know
+var ErrUnsupportedSetting = errors.New("unsupported dynamic setting")
+
+// RuntimeSettings holds the subset of configuration that may change while the plugin runs
+// Values start from the environment configuration and are updated by the ConfigMap watcher
+type RuntimeSettings struct {
+	mu                  sync.RWMutex
+	logLevel            string
+	healthCheckInterval int
+	minHealthyDevices   int
+	maxDevices          int
+	grpcTrace           bool
+}
+
+// NewRuntimeSettings creates RuntimeSettings seeded from the static configuration
+func NewRuntimeSettings(config *DevicePluginConfig) *RuntimeSettings {
+	return &RuntimeSettings{
+		logLevel:            config.LogLevel,
+		healthCheckInterval: config.HealthCheckInterval,
+		minHealthyDevices:   config.MinHealthyDevices,
+		maxDevices:          config.MaxDevices,
+		grpcTrace:           config.GRPCTrace,
+	}
+}
+
+// HealthCheckInterval returns the current health check interval
+func (s *RuntimeSettings) HealthCheckInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Duration(s.healthCheckInterval) * time.Second
+}
+
+// MinHealthyDevices returns the configured readiness threshold (0 = all devices)
+func (s *RuntimeSettings) MinHealthyDevices() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.minHealthyDevices
+}
+
+// GRPCTrace reports whether gRPC calls are traced at debug level
+func (s *RuntimeSettings) GRPCTrace() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.grpcTrace
+}
+
+// Snapshot returns the current values keyed like the ConfigMap (and the configuration JSON)
+func (s *RuntimeSettings) Snapshot() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]any{
+		"log_level":             s.logLevel,
+		"health_check_interval": s.healthCheckInterval,
+		"min_healthy_devices":   s.minHealthyDevices,
+		"grpc_trace":            s.grpcTrace,
+	}
+}
+
+// Apply validates and applies dynamic settings from ConfigMap data
+// Keys are the lower-case form of the matching environment variables; invalid values are
+// rejected individually so one typo does not block the remaining settings
+func (s *RuntimeSettings) Apply(data map[string]string, logger *slog.Logger) []error {
+	var errs []error
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, raw := range data {
+		value := strings.TrimSpace(raw)
+		switch key {
+		case "log_level":
+			if _, ok := parseLogLevel(value); !ok {
+				errs = append(errs, fmt.Errorf("log_level must be debug, info, warn or error, got %q", value))
+				continue
+			}
+			if value != s.logLevel {
+				s.logLevel = value
+				setLogLevel(value)
+				logger.Info("Applied dynamic setting", "key", key, "value", value)
+			}
+		case "health_check_interval":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval <= 0 {
+				errs = append(errs, fmt.Errorf("health_check_interval must be > 0 seconds, got %q", value))
+				continue
+			}
+			if interval != s.healthCheckInterval {
+				s.healthCheckInterval = interval
+				logger.Info("Applied dynamic setting", "key", key, "value", interval)
+			}
+		case "min_healthy_devices":
+			minHealthy, err := strconv.Atoi(value)
+			if err != nil || minHealthy < 0 || minHealthy > s.maxDevices {
+				errs = append(errs, fmt.Errorf("min_healthy_devices must be between 0 and %d, got %q", s.maxDevices, value))
+				continue
+			}
+			if minHealthy != s.minHealthyDevices {
+				s.minHealthyDevices = minHealthy
+				logger.Info("Applied dynamic setting", "key", key, "value", minHealthy)
+			}
+		case "grpc_trace":
+			trace, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("grpc_trace must be true or false, got %q", value))
+				continue
+			}
+			if trace != s.grpcTrace {
+				s.grpcTrace = trace
+				logger.Info("Applied dynamic setting", "key", key, "value", trace)
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnsupportedSetting, key))
+		}
+	}
+
+	return errs
+}
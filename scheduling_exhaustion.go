@@ -0,0 +1,221 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// exhaustionReportInterval is how often the aggregated exhaustion log line is written
+const exhaustionReportInterval = time.Minute
+
+// exhaustionReportPods caps the pod names listed in the aggregated log line
+const exhaustionReportPods = 10
+
+// SchedulingExhaustionMonitor counts pods the scheduler could not place for lack of our devices
+// FailedScheduling events are cluster-wide ("0/N nodes are available: ..."), so they are only
+// attributed to this node while it has no free healthy device of the resource itself
+type SchedulingExhaustionMonitor struct {
+	client    *K8sClient
+	plugin    *VideoDevicePlugin
+	config    *DevicePluginConfig
+	resources []string
+	metrics   *Metrics
+	logger    *slog.Logger
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	counts  map[types.UID]int32        // Event UID -> last seen count
+	pending map[string]map[string]bool // Resource -> pods counted since the last report
+	total   map[string]int             // Resource -> failures counted since the last report
+}
+
+// NewSchedulingExhaustionMonitor creates a new SchedulingExhaustionMonitor instance
+func NewSchedulingExhaustionMonitor(client *K8sClient, plugin *VideoDevicePlugin, config *DevicePluginConfig, metrics *Metrics, logger *slog.Logger) *SchedulingExhaustionMonitor {
+	resources := []string{config.ResourceName}
+	if config.AVBundleCount > 0 {
+		resources = append(resources, config.AVBundleResourceName)
+	}
+	return &SchedulingExhaustionMonitor{
+		client:    client,
+		plugin:    plugin,
+		config:    config,
+		resources: resources,
+		metrics:   metrics,
+		logger:    logger.With("component", "scheduling-exhaustion"),
+		counts:    make(map[types.UID]int32),
+		pending:   make(map[string]map[string]bool),
+		total:     make(map[string]int),
+	}
+}
+
+// Start watches FailedScheduling events and reports in the background until ctx is canceled
+// or Stop is called
+func (m *SchedulingExhaustionMonitor) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	factory := informers.NewSharedInformerFactoryWithOptions(m.client.clientset, 0,
+		informers.WithNamespace(m.config.PodWatchNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.AndSelectors(
+				fields.OneTermEqualSelector("reason", "FailedScheduling"),
+				fields.OneTermEqualSelector("involvedObject.kind", "Pod"),
+			).String()
+		}))
+
+	informer := factory.Core().V1().Events().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if event, ok := obj.(*corev1.Event); ok {
+				m.observe(event)
+			}
+		},
+		UpdateFunc: func(_, newObj any) {
+			if event, ok := newObj.(*corev1.Event); ok {
+				m.observe(event)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if event, ok := obj.(*corev1.Event); ok {
+				m.mu.Lock()
+				delete(m.counts, event.UID)
+				m.mu.Unlock()
+			}
+		},
+	}); err != nil {
+		m.logger.Error("Failed to register event handler", "error", err)
+		return
+	}
+
+	m.logger.Info("Starting scheduling exhaustion monitor", "resources", m.resources, "namespace", m.config.PodWatchNamespace)
+	factory.Start(ctx.Done())
+	go m.reportLoop(ctx)
+}
+
+// Stop ends the event informer and the report loop
+func (m *SchedulingExhaustionMonitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+// observe counts new occurrences of an exhaustion event for each exhausted resource
+func (m *SchedulingExhaustionMonitor) observe(event *corev1.Event) {
+	count := max(event.Count, 1)
+
+	m.mu.Lock()
+	previous, seen := m.counts[event.UID]
+	m.counts[event.UID] = count
+	m.mu.Unlock()
+
+	// The initial list replays history; only count occurrences seen after startup
+	if !seen && time.Since(eventTime(event)) > exhaustionReportInterval {
+		return
+	}
+	occurrences := int(count - previous)
+	if occurrences <= 0 {
+		return
+	}
+
+	pod := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+	for _, resource := range m.resources {
+		if !strings.Contains(event.Message, "Insufficient "+resource) || m.plugin.freeDevices(resource) > 0 {
+			continue
+		}
+
+		m.metrics.AddSchedulingExhaustion(resource, occurrences)
+		m.mu.Lock()
+		if m.pending[resource] == nil {
+			m.pending[resource] = make(map[string]bool)
+		}
+		m.pending[resource][pod] = true
+		m.total[resource] += occurrences
+		m.mu.Unlock()
+	}
+}
+
+// eventTime returns when an event last occurred
+func eventTime(event *corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// reportLoop writes one aggregated log line per resource and interval with failures
+func (m *SchedulingExhaustionMonitor) reportLoop(ctx context.Context) {
+	ticker := time.NewTicker(exhaustionReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.report()
+		}
+	}
+}
+
+// report logs and resets the failures counted since the last report
+func (m *SchedulingExhaustionMonitor) report() {
+	m.mu.Lock()
+	pending, total := m.pending, m.total
+	m.pending = make(map[string]map[string]bool)
+	m.total = make(map[string]int)
+	m.mu.Unlock()
+
+	for resource, pods := range pending {
+		names := make([]string, 0, len(pods))
+		for pod := range pods {
+			names = append(names, pod)
+		}
+		sort.Strings(names)
+		if len(names) > exhaustionReportPods {
+			names = append(names[:exhaustionReportPods], "...")
+		}
+
+		m.logger.Warn("Pods could not be scheduled while this node's devices were exhausted",
+			"resource", resource,
+			"failures", total[resource],
+			"pods_affected", len(pods),
+			"pods", names,
+			"interval", exhaustionReportInterval.String())
+	}
+}
+
+// freeDevices returns the healthy advertised devices of a resource not allocated by kubelet
+func (p *VideoDevicePlugin) freeDevices(resource string) int {
+	allocated := make(map[string]bool)
+	for _, allocation := range p.allocations.List() {
+		allocated[allocation.DeviceID] = true
+	}
+
+	free := 0
+	for deviceID := range p.v4l2Manager.ListAllDevices() {
+		if p.deviceResource(deviceID) != resource || p.spares.HeldBack(deviceID) {
+			continue
+		}
+		if p.v4l2Manager.GetDeviceHealth(deviceID) && !allocated[deviceID] {
+			free++
+		}
+	}
+	return free
+}
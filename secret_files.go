@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// secretFileSuffix names the variable pointing at a file that holds a setting's value
+// (e.g. OTLP_METRICS_HEADERS_FILE=/etc/video-device-plugin/secrets/otlp-headers), so secrets
+// stay out of the pod spec and `kubectl describe pod`
+const secretFileSuffix = "_FILE"
+
+// mountedFileKeys are the settings read from a <KEY>_FILE file, keyed to the file path
+var mountedFileKeys = map[string]string{}
+
+// unreadableFileKeys are the settings whose <KEY>_FILE file could not be read, failed by validateConfig
+var unreadableFileKeys = map[string]error{}
+
+// envValue returns a setting from its <KEY>_FILE file when one is given, else from the environment
+// An unreadable file never falls back to <KEY>: a missing Secret mount must fail the start instead
+// of running without the credential
+func envValue(key string) string {
+	path := os.Getenv(key + secretFileSuffix)
+	if path == "" {
+		return os.Getenv(key)
+	}
+	value, err := readSecretFile(path)
+	if err != nil {
+		unreadableFileKeys[key] = err
+		return ""
+	}
+	mountedFileKeys[key] = path
+	return value
+}
+
+// secretFileError reports the <KEY>_FILE settings whose file could not be read
+func secretFileError() error {
+	if len(unreadableFileKeys) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(unreadableFileKeys))
+	for key := range unreadableFileKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	problems := make([]string, 0, len(keys))
+	for _, key := range keys {
+		problems = append(problems, fmt.Sprintf("%s%s: %v", key, secretFileSuffix, unreadableFileKeys[key]))
+	}
+	return fmt.Errorf("cannot read mounted setting files: %s", strings.Join(problems, "; "))
+}
+
+// envSet reports whether loadConfig took a setting from the environment or a mounted file
+func envSet(key string) bool {
+	return os.Getenv(key) != "" || mountedFileKeys[key] != ""
+}
+
+// readSecretFile reads a mounted value; the trailing newline editors and `kubectl create secret
+// --from-file` leave behind is not part of it
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// SecretFileWatcher re-reads settings taken from mounted files and applies changed values
+// Secret volumes are updated in place by kubelet, so a rotated credential is picked up
+// without restarting the plugin; settings without a handler are only reported
+type SecretFileWatcher struct {
+	interval time.Duration
+	clock    clock.WithTicker // Time source of the polling loop
+	logger   *slog.Logger
+	mu       sync.Mutex
+	values   map[string]string             // Last applied value per setting
+	handlers map[string]func(string) error // Settings applied at runtime
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSecretFileWatcher creates a watcher of the settings loadConfig read from files
+// It returns nil when no setting came from a file or reloading is disabled
+func NewSecretFileWatcher(config *DevicePluginConfig, logger *slog.Logger) *SecretFileWatcher {
+	if len(mountedFileKeys) == 0 || config.SecretFileReloadInterval <= 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(mountedFileKeys))
+	for key, path := range mountedFileKeys {
+		values[key], _ = readSecretFile(path)
+	}
+	return &SecretFileWatcher{
+		interval: time.Duration(config.SecretFileReloadInterval) * time.Second,
+		clock:    clock.RealClock{},
+		logger:   logger.With("component", "secret-files"),
+		values:   values,
+		handlers: make(map[string]func(string) error),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetClock replaces the time source so tests can drive the polling deterministically; call it before Start
+func (w *SecretFileWatcher) SetClock(c clock.WithTicker) {
+	w.clock = c
+}
+
+// Handle registers how a changed setting is applied; call it before Start
+func (w *SecretFileWatcher) Handle(key string, apply func(value string) error) {
+	if w == nil {
+		return
+	}
+	w.handlers[key] = apply
+}
+
+// Start polls the files in the background until ctx is canceled or Stop is called
+func (w *SecretFileWatcher) Start(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	keys := make([]string, 0, len(w.values))
+	for key := range w.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w.logger.Info("Watching settings read from mounted files", "settings", keys, "interval", w.interval.String())
+
+	go func() {
+		defer close(w.done)
+		ticker := w.clock.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				w.reload()
+			}
+		}
+	}()
+}
+
+// Stop ends the polling
+func (w *SecretFileWatcher) Stop() {
+	if w == nil || w.cancel == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		w.cancel()
+		<-w.done
+	})
+}
+
+// reload applies every setting whose file content changed; values are never logged
+func (w *SecretFileWatcher) reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, path := range mountedFileKeys {
+		value, err := readSecretFile(path)
+		if err != nil {
+			// Kubelet swaps the volume's symlink atomically; a failing read keeps the old value
+			w.logger.Warn("Failed to re-read setting file", "setting", key, "path", path, "error", err)
+			continue
+		}
+		if value == w.values[key] {
+			continue
+		}
+
+		// A rejected value is not retried until the file changes again
+		w.values[key] = value
+		apply, ok := w.handlers[key]
+		if !ok {
+			w.logger.Warn("Setting file changed, restart the plugin to apply it", "setting", key, "path", path)
+			continue
+		}
+		if err := apply(value); err != nil {
+			w.logger.Error("Rejected changed setting file, keeping the previous value", "setting", key, "path", path, "error", err)
+			continue
+		}
+		w.logger.Info("Applied changed setting file", "setting", key, "path", path)
+	}
+}
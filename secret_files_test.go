@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestSecretFileWatcherAppliesRotatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "otlp-headers")
+	if err := os.WriteFile(path, []byte("authorization=old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previous := mountedFileKeys
+	mountedFileKeys = map[string]string{"OTLP_METRICS_HEADERS": path}
+	t.Cleanup(func() { mountedFileKeys = previous })
+
+	watcher := NewSecretFileWatcher(&DevicePluginConfig{SecretFileReloadInterval: 30}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	watcher.SetClock(fakeClock)
+	applied := make(chan string, 1)
+	watcher.Handle("OTLP_METRICS_HEADERS", func(value string) error {
+		applied <- value
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher.Start(ctx)
+	defer watcher.Stop()
+	for !fakeClock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := os.WriteFile(path, []byte("authorization=new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is re-read before the interval elapsed
+	fakeClock.Step(29 * time.Second)
+	select {
+	case value := <-applied:
+		t.Fatalf("applied %q before the reload interval", value)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fakeClock.Step(time.Second)
+	select {
+	case value := <-applied:
+		if value != "authorization=new" {
+			t.Errorf("applied %q, want %q", value, "authorization=new")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotated file not applied after the reload interval")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// shadowCompareTimeout bounds each lookup of the production instance's device list
+const shadowCompareTimeout = 5 * time.Second
+
+// shadowAllocationMessage is returned for every allocation attempt on the shadow resource
+const shadowAllocationMessage = "shadow instance never allocates devices; request the production resource instead"
+
+// shadowConfig derives the configuration of a shadow instance from the production configuration
+// Everything that changes the node (module, device nodes, permissions, state, node objects) or
+// binds a port or socket of the production instance is switched off; discovery, health and the
+// device list logic stay as configured so their result can be compared with production
+func shadowConfig(config *DevicePluginConfig) *DevicePluginConfig {
+	shadow := *config
+	shadow.ResourceName = config.ShadowResourceName
+	shadow.SocketPath = config.ShadowSocketPath
+	shadow.AdditionalKubelets = ""
+	shadow.ManageModule = false
+	shadow.EnableSocketTakeover = false
+	shadow.EnableCheckpoint = false
+	shadow.EnableUdevRules = false
+	shadow.EnableBufferRecovery = false
+	shadow.EnableWarmupProducer = false
+	shadow.PreformatDevices = false
+	shadow.EnableLabelStamping = false
+	shadow.PermissionReconcileInterval = 0
+	shadow.DeviceHooksFile = ""
+	shadow.HandoffDir = ""
+	shadow.AVBundleCount = 0
+	shadow.EnableAdminAPI = false
+	shadow.EnableMetrics = false
+	shadow.ProbePort = 0
+	shadow.EnableSystemdNotify = false
+	shadow.EnableNodeCondition = false
+	shadow.EnableEvents = false
+	shadow.EnablePodWatch = false
+	shadow.EnableLivenessLease = false
+	shadow.EnableExhaustionWatch = false
+	return &shadow
+}
+
+// runShadow runs a side-by-side instance that computes everything the production instance does
+// but registers under SHADOW_RESOURCE_NAME, refuses every allocation and only logs how its device
+// list differs from what production advertises
+func runShadow(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) error {
+	shadow := shadowConfig(config)
+	logger = logger.With("mode", "shadow")
+	logger.Info("Starting shadow instance",
+		"version", pluginVersion,
+		"resource_name", shadow.ResourceName,
+		"production_resource_name", config.ResourceName)
+
+	// The production instance owns the range choice; never record one
+	if state, err := loadPluginState(shadow.StateDir); err == nil && state != nil && state.MaxDevices == shadow.MaxDevices {
+		shadow.VideoDeviceStart = state.VideoDeviceStart
+	}
+
+	// Module verification is read-only; a mismatch is what production would reload for
+	if loaded, err := isModuleLoaded("v4l2loopback"); err != nil {
+		logger.Warn("Shadow could not check the v4l2loopback module", "error", err)
+	} else if !loaded {
+		logger.Warn("Shadow found v4l2loopback not loaded")
+	} else if err := verifyV4L2Configuration(shadow, logger); err != nil {
+		logger.Warn("Shadow module verification failed, production would converge or reload the module", "error", err)
+	}
+	logConfigFindings(checkLoadedModuleRules(shadow), logger)
+
+	manager := NewV4L2Manager(logger, shadow.V4L2DevicePerm, shadow.V4L2DeviceGID, shadow.VideoDeviceStart, shadow.FallbackDevicePrefix)
+	manager.(*v4l2Manager).observeOnly = true
+	if err := manager.CreateDevices(shadow.MaxDevices); err != nil {
+		return fmt.Errorf("shadow device discovery failed: %w", err)
+	}
+
+	plugin := NewVideoDevicePlugin(shadow, manager, nil, nil, logger)
+	plugin.shadow = true
+	if err := plugin.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start shadow device plugin: %w", err)
+	}
+	plugin.prepareForAdvertisement()
+
+	go compareWithProduction(ctx, config, plugin, logger)
+
+	logger.Info("Shadow instance is running")
+	<-ctx.Done()
+	return plugin.Stop()
+}
+
+// shadowDiff is how the shadow device list differs from production's
+type shadowDiff struct {
+	OnlyShadow     []string
+	OnlyProduction []string
+	HealthDiffers  []string // device: shadow health/production health
+}
+
+// empty reports whether both instances advertise the same devices with the same health
+func (d shadowDiff) empty() bool {
+	return len(d.OnlyShadow) == 0 && len(d.OnlyProduction) == 0 && len(d.HealthDiffers) == 0
+}
+
+// compareWithProduction logs the difference between the shadow and production device lists on
+// every health interval; each change is logged once
+func compareWithProduction(ctx context.Context, config *DevicePluginConfig, plugin *VideoDevicePlugin, logger *slog.Logger) {
+	ticker := plugin.clock.NewTicker(plugin.settings.HealthCheckInterval())
+	defer ticker.Stop()
+
+	previous := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		compareCtx, cancel := context.WithTimeout(ctx, shadowCompareTimeout)
+		production, source, err := productionDeviceHealth(compareCtx, config)
+		cancel()
+		if err != nil {
+			logger.Warn("Shadow could not read the production device list", "error", err)
+			continue
+		}
+
+		devices, _ := plugin.buildDeviceList()
+		if source == "kubelet" {
+			// Kubelet only reports allocatable devices; compare the healthy part only
+			devices = healthyDevices(devices)
+		}
+		diff := diffDeviceHealth(devices, production)
+		signature := fmt.Sprint(diff)
+		if signature == previous {
+			continue
+		}
+		previous = signature
+
+		if diff.empty() {
+			logger.Info("Shadow device list matches production", "source", source, "devices", len(devices))
+			continue
+		}
+		logger.Warn("Shadow device list differs from production",
+			"source", source,
+			"only_shadow", diff.OnlyShadow,
+			"only_production", diff.OnlyProduction,
+			"health_differs", diff.HealthDiffers)
+	}
+}
+
+// productionDeviceHealth returns device ID -> health of what production last sent kubelet
+// It prefers production's admin API snapshot and falls back to kubelet's allocatable devices,
+// which only lists healthy ones
+func productionDeviceHealth(ctx context.Context, config *DevicePluginConfig) (map[string]string, string, error) {
+	if config.AdminSocketPath != "" && checkDeviceExists(config.AdminSocketPath) {
+		health, err := adminDeviceHealth(ctx, config.AdminSocketPath, config.ResourceName)
+		if err == nil {
+			return health, "admin_api", nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+	}
+
+	allocatable, err := listAllocatableDevices(ctx, config.PodResourcesSocket)
+	if err != nil {
+		return nil, "", err
+	}
+	health := make(map[string]string)
+	for deviceID := range allocatable[config.ResourceName] {
+		health[deviceID] = pluginapi.Healthy
+	}
+	return health, "kubelet", nil
+}
+
+// adminDeviceHealth reads production's last ListAndWatch snapshot from its admin socket
+func adminDeviceHealth(ctx context.Context, socketPath, resource string) (map[string]string, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/v1/listandwatch?resource="+url.QueryEscape(resource), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin API returned %s", resp.Status)
+	}
+
+	var report listAndWatchReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to decode ListAndWatch snapshot: %w", err)
+	}
+	for _, snapshot := range report.Snapshots {
+		if snapshot.Resource != resource {
+			continue
+		}
+		health := make(map[string]string, len(snapshot.Devices))
+		for _, device := range snapshot.Devices {
+			health[device.ID] = device.Health
+		}
+		return health, nil
+	}
+	return nil, fmt.Errorf("production has not sent a device list for %s yet", resource)
+}
+
+// healthyDevices filters a device list down to its Healthy devices
+func healthyDevices(devices []*pluginapi.Device) []*pluginapi.Device {
+	healthy := make([]*pluginapi.Device, 0, len(devices))
+	for _, device := range devices {
+		if device.Health == pluginapi.Healthy {
+			healthy = append(healthy, device)
+		}
+	}
+	return healthy
+}
+
+// diffDeviceHealth compares the shadow list with production by video device
+// Rotation suffixes are ignored since each instance rotates IDs on its own
+func diffDeviceHealth(shadow []*pluginapi.Device, production map[string]string) shadowDiff {
+	shadowHealth := make(map[string]string, len(shadow))
+	for _, device := range shadow {
+		deviceID, _ := splitKubeletDeviceID(device.ID)
+		shadowHealth[deviceID] = device.Health
+	}
+	productionHealth := make(map[string]string, len(production))
+	for kubeletID, health := range production {
+		deviceID, _ := splitKubeletDeviceID(kubeletID)
+		productionHealth[deviceID] = health
+	}
+
+	var diff shadowDiff
+	for deviceID, health := range shadowHealth {
+		other, ok := productionHealth[deviceID]
+		switch {
+		case !ok:
+			diff.OnlyShadow = append(diff.OnlyShadow, deviceID)
+		case other != health:
+			diff.HealthDiffers = append(diff.HealthDiffers, fmt.Sprintf("%s: %s/%s", deviceID, health, other))
+		}
+	}
+	for deviceID := range productionHealth {
+		if _, ok := shadowHealth[deviceID]; !ok {
+			diff.OnlyProduction = append(diff.OnlyProduction, deviceID)
+		}
+	}
+	sort.Strings(diff.OnlyShadow)
+	sort.Strings(diff.OnlyProduction)
+	sort.Strings(diff.HealthDiffers)
+	return diff
+}
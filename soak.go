@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// soakOptions configures a soak run
+type soakOptions struct {
+	Devices          int
+	Cycles           int
+	MaxAllocateP99   time.Duration
+	MaxHealthP99     time.Duration
+	MaxAllocsPerCall float64
+}
+
+// soakStats summarizes latencies of one operation type
+type soakStats struct {
+	Count       int     `json:"count"`
+	P50         string  `json:"p50"`
+	P99         string  `json:"p99"`
+	Max         string  `json:"max"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	p99         time.Duration
+}
+
+// soakReport is printed as JSON at the end of a soak run
+type soakReport struct {
+	Devices    int       `json:"devices"`
+	Cycles     int       `json:"cycles"`
+	Allocate   soakStats `json:"allocate"`
+	Health     soakStats `json:"health"`
+	Violations []string  `json:"violations,omitempty"`
+}
+
+// runSoak implements the "soak" subcommand: it drives thousands of Allocate and health
+// cycles against a fake device tree and fails when latency or allocation thresholds regress;
+// with -live it soaks the node's real devices instead (see runLiveSoak)
+func runSoak(args []string) int {
+	opts := soakOptions{}
+	live := liveSoakOptions{}
+	fs := flag.NewFlagSet("soak", flag.ContinueOnError)
+	fs.IntVar(&opts.Devices, "devices", 8, "number of fake devices")
+	fs.IntVar(&opts.Cycles, "cycles", 10000, "number of Allocate and health cycles")
+	fs.DurationVar(&opts.MaxAllocateP99, "max-allocate-p99", 2*time.Millisecond, "fail if Allocate p99 latency exceeds this")
+	fs.DurationVar(&opts.MaxHealthP99, "max-health-p99", 2*time.Millisecond, "fail if health cycle p99 latency exceeds this")
+	fs.Float64Var(&opts.MaxAllocsPerCall, "max-allocs-per-op", 500, "fail if heap allocations per Allocate exceed this")
+	fs.BoolVar(&live.Enabled, "live", false, "soak the node's real devices (root, v4l2loopback loaded, node drained, configured from the environment)")
+	fs.DurationVar(&live.Duration, "duration", 10*time.Minute, "with -live: how long to run")
+	fs.DurationVar(&live.Slice, "slice", 2*time.Second, "with -live: how long each allocation holds its device")
+	fs.IntVar(&live.FaultEvery, "fault-every", 10, "with -live: delete and recreate a free device every N slices (0 disables)")
+	fs.IntVar(&live.MaxFailures, "max-failures", 0, "with -live: fail if more operations fail than this")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if live.Enabled {
+		return runLiveSoak(live)
+	}
+	if opts.Devices < 1 || opts.Cycles < 1 {
+		fmt.Fprintln(os.Stderr, "soak: -devices and -cycles must be >= 1")
+		return 2
+	}
+
+	report, err := soak(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "soak: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if len(report.Violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// soak builds the fake device tree and plugin, then runs the measured cycles
+func soak(opts soakOptions) (*soakReport, error) {
+	dir, err := os.MkdirTemp("", "video-device-plugin-soak-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fake device tree: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	plugin, err := newSoakPlugin(dir, opts.Devices)
+	if err != nil {
+		return nil, err
+	}
+
+	allocateLatencies := make([]time.Duration, 0, opts.Cycles)
+	healthLatencies := make([]time.Duration, 0, opts.Cycles)
+	var allocateMallocs, healthMallocs uint64
+	var before, after runtime.MemStats
+
+	for i := 0; i < opts.Cycles; i++ {
+		deviceID := fmt.Sprintf("video%d", VideoDeviceStartNumber+i%opts.Devices)
+		req := &pluginapi.AllocateRequest{
+			ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{deviceID}}},
+		}
+
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		if _, err := plugin.Allocate(context.Background(), req); err != nil {
+			return nil, fmt.Errorf("allocate cycle %d failed: %w", i, err)
+		}
+		allocateLatencies = append(allocateLatencies, time.Since(start))
+		runtime.ReadMemStats(&after)
+		allocateMallocs += after.Mallocs - before.Mallocs
+
+		runtime.ReadMemStats(&before)
+		start = time.Now()
+		plugin.buildDeviceList()
+		plugin.GetHealthStatus()
+		healthLatencies = append(healthLatencies, time.Since(start))
+		runtime.ReadMemStats(&after)
+		healthMallocs += after.Mallocs - before.Mallocs
+	}
+
+	report := &soakReport{
+		Devices:  opts.Devices,
+		Cycles:   opts.Cycles,
+		Allocate: summarizeLatencies(allocateLatencies, allocateMallocs),
+		Health:   summarizeLatencies(healthLatencies, healthMallocs),
+	}
+
+	if report.Allocate.p99 > opts.MaxAllocateP99 {
+		report.Violations = append(report.Violations, fmt.Sprintf("allocate p99 %s exceeds %s", report.Allocate.P99, opts.MaxAllocateP99))
+	}
+	if report.Health.p99 > opts.MaxHealthP99 {
+		report.Violations = append(report.Violations, fmt.Sprintf("health p99 %s exceeds %s", report.Health.P99, opts.MaxHealthP99))
+	}
+	if report.Allocate.AllocsPerOp > opts.MaxAllocsPerCall {
+		report.Violations = append(report.Violations, fmt.Sprintf("allocate allocs/op %.0f exceeds %.0f", report.Allocate.AllocsPerOp, opts.MaxAllocsPerCall))
+	}
+
+	return report, nil
+}
+
+// newSoakPlugin builds a plugin serving devices fake device nodes created in dir
+// Regular files stand in for device nodes; existence/readability checks behave the same
+func newSoakPlugin(dir string, devices int) (*VideoDevicePlugin, error) {
+	for i := 0; i < devices; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("video%d", VideoDeviceStartNumber+i))
+		if err := os.WriteFile(path, nil, 0o666); err != nil {
+			return nil, fmt.Errorf("failed to create fake device: %w", err)
+		}
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	config := &DevicePluginConfig{
+		MaxDevices:          devices,
+		ResourceName:        "meeting-baas.io/video-devices",
+		HealthCheckInterval: 30,
+		V4L2DevicePerm:      0o666,
+		V4L2DeviceGID:       -1,
+		VideoDeviceStart:    VideoDeviceStartNumber,
+		AllocationTimeout:   30,
+	}
+
+	manager := NewV4L2Manager(logger, config.V4L2DevicePerm, config.V4L2DeviceGID, config.VideoDeviceStart, filepath.Join(dir, "dummy-video"))
+	manager.(*v4l2Manager).devicePathPrefix = filepath.Join(dir, "video")
+	manager.(*v4l2Manager).verifyNodes = false
+	if err := manager.CreateDevices(devices); err != nil {
+		return nil, err
+	}
+
+	plugin := NewVideoDevicePlugin(config, manager, nil, nil, logger)
+	plugin.prepareForAdvertisement()
+	return plugin, nil
+}
+
+// summarizeLatencies computes percentile statistics for a set of samples
+func summarizeLatencies(samples []time.Duration, mallocs uint64) soakStats {
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	percentile := func(p float64) time.Duration {
+		return samples[int(float64(len(samples)-1)*p)]
+	}
+
+	stats := soakStats{
+		Count:       len(samples),
+		P50:         percentile(0.50).String(),
+		P99:         percentile(0.99).String(),
+		Max:         samples[len(samples)-1].String(),
+		AllocsPerOp: float64(mallocs) / float64(len(samples)),
+		p99:         percentile(0.99),
+	}
+	return stats
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// liveSoakRecoveryTimeout bounds how long a device deleted by fault injection may take to
+// report healthy again after it was recreated
+const liveSoakRecoveryTimeout = 10 * time.Second
+
+// liveSoakMaxFailures bounds how many failure messages the report keeps
+const liveSoakMaxFailures = 50
+
+// liveSoakOptions configures a soak run against the node's real devices
+type liveSoakOptions struct {
+	Enabled     bool
+	Duration    time.Duration
+	Slice       time.Duration
+	FaultEvery  int
+	MaxFailures int
+}
+
+// liveSoakReport is printed as JSON at the end of a live soak run
+type liveSoakReport struct {
+	Kernel            string    `json:"kernel"`
+	Devices           int       `json:"devices"`
+	Duration          string    `json:"duration"`
+	Slices            int       `json:"slices"`
+	Allocations       int       `json:"allocations"`
+	Resets            int       `json:"resets"`
+	Faults            int       `json:"faults"`
+	HealthTransitions int       `json:"health_transitions"`
+	Damped            int       `json:"damped"`         // Devices damped after flapping during the run
+	CooldownPicks     int       `json:"cooldown_picks"` // Allocations of a settling device while every device was settling
+	Allocate          soakStats `json:"allocate"`
+	PreStart          soakStats `json:"prestart"`
+	FailureCount      int       `json:"failure_count"`
+	Failures          []string  `json:"failures,omitempty"`
+	TaintAdded        []string  `json:"taint_added,omitempty"`
+	Violations        []string  `json:"violations,omitempty"`
+	Passed            bool      `json:"passed"`
+}
+
+// fail records a failed operation
+func (r *liveSoakReport) fail(format string, args ...any) {
+	r.FailureCount++
+	if len(r.Failures) < liveSoakMaxFailures {
+		r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+	}
+}
+
+// runLiveSoak implements "soak -live": for the configured duration it allocates, starts and
+// releases the node's real devices one time slice at a time, bypassing kubelet, and
+// periodically deletes a free device to drive it through unhealthy and back. Allocate hooks,
+// release hooks, cooldowns and flap damping run exactly as in production. Used to qualify a
+// new kernel on a drained node before it rolls out to the fleet.
+func runLiveSoak(opts liveSoakOptions) int {
+	if opts.Duration <= 0 || opts.Slice <= 0 || opts.FaultEvery < 0 || opts.MaxFailures < 0 {
+		fmt.Fprintln(os.Stderr, "soak: -duration and -slice must be > 0, -fault-every and -max-failures >= 0")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := liveSoak(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "soak: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+// liveSoak sets up the manager and plugin from the environment and runs the time slices
+func liveSoak(ctx context.Context, opts liveSoakOptions) (*liveSoakReport, error) {
+	config := loadConfig()
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
+	// The report owns stdout
+	setLogLevel(config.LogLevel)
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})).With("mode", "soak")
+
+	if err := checkRoot(logger); err != nil {
+		return nil, err
+	}
+	if loaded, err := isModuleLoaded("v4l2loopback"); err != nil {
+		return nil, fmt.Errorf("failed to check the v4l2loopback module: %w", err)
+	} else if !loaded {
+		return nil, fmt.Errorf("v4l2loopback must be loaded")
+	}
+	// Devices are deleted and recreated; pods must not hold any of them
+	if assigned, err := kubeletAssignedDevices(ctx, config); err == nil && len(assigned) > 0 {
+		return nil, fmt.Errorf("%d device(s) are allocated to pods (%v); drain the node first", len(assigned), assigned)
+	}
+	if state, err := loadPluginState(config.StateDir); err == nil && state != nil && state.MaxDevices == config.MaxDevices {
+		config.VideoDeviceStart = state.VideoDeviceStart
+	}
+
+	manager := NewV4L2Manager(logger, config.V4L2DevicePerm, config.V4L2DeviceGID, config.VideoDeviceStart, config.FallbackDevicePrefix)
+	if err := manager.CreateDevices(config.MaxDevices); err != nil {
+		return nil, fmt.Errorf("device discovery failed: %w", err)
+	}
+	plugin := NewVideoDevicePlugin(config, manager, nil, nil, logger)
+	if config.DeviceHooksFile != "" {
+		hooks, err := LoadDeviceHooks(config.DeviceHooksFile, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load device hooks: %w", err)
+		}
+		plugin.hooks = hooks
+		plugin.prepareDevices()
+	}
+	plugin.applyTierParameters()
+	plugin.prepareForAdvertisement()
+
+	health := plugin.decisions.Subscribe([]string{DecisionHealth})
+	defer plugin.decisions.Unsubscribe(health)
+
+	_, taintBefore := readKernelTaint()
+	report := &liveSoakReport{
+		Kernel:  NewSystemInspector(logger).Inspect().KernelVersion,
+		Devices: len(manager.ListAllDevices()),
+	}
+	var allocateLatencies, prestartLatencies []time.Duration
+
+	start := time.Now()
+	deadline := start.Add(opts.Duration)
+	for slice := 1; time.Now().Before(deadline) && ctx.Err() == nil; slice++ {
+		report.Slices++
+		if opts.FaultEvery > 0 && slice%opts.FaultEvery == 0 {
+			liveSoakFault(ctx, plugin, report)
+		}
+		liveSoakSlice(ctx, plugin, opts.Slice, report, &allocateLatencies, &prestartLatencies)
+
+		for drained := false; !drained; {
+			select {
+			case event := <-health.events:
+				switch event.Action {
+				case "healthy", "unhealthy":
+					report.HealthTransitions++
+				case "damped":
+					report.Damped++
+				}
+			default:
+				drained = true
+			}
+		}
+	}
+	report.Duration = time.Since(start).Round(time.Second).String()
+
+	if len(allocateLatencies) > 0 {
+		report.Allocate = summarizeLatencies(allocateLatencies, 0)
+	}
+	if len(prestartLatencies) > 0 {
+		report.PreStart = summarizeLatencies(prestartLatencies, 0)
+	}
+
+	// A kernel warning, oops or lockup during the run disqualifies the kernel
+	_, taintAfter := readKernelTaint()
+	seen := make(map[string]bool, len(taintBefore))
+	for _, flag := range taintBefore {
+		seen[flag] = true
+	}
+	for _, flag := range taintAfter {
+		if !seen[flag] {
+			report.TaintAdded = append(report.TaintAdded, flag)
+		}
+	}
+	sort.Strings(report.TaintAdded)
+
+	if report.Allocations == 0 {
+		report.Violations = append(report.Violations, "no device was allocated")
+	}
+	if report.FailureCount > opts.MaxFailures {
+		report.Violations = append(report.Violations, fmt.Sprintf("%d failed operations exceed %d", report.FailureCount, opts.MaxFailures))
+	}
+	if len(report.TaintAdded) > 0 {
+		report.Violations = append(report.Violations, fmt.Sprintf("kernel became tainted: %v", report.TaintAdded))
+	}
+	report.Passed = len(report.Violations) == 0
+	return report, nil
+}
+
+// liveSoakSlice allocates the device the plugin prefers, starts it like a container would,
+// holds it for one slice and releases it
+func liveSoakSlice(ctx context.Context, plugin *VideoDevicePlugin, hold time.Duration, report *liveSoakReport, allocateLatencies, prestartLatencies *[]time.Duration) {
+	devices, _ := plugin.buildDeviceList()
+	var available []string
+	for _, device := range devices {
+		if device.Health == pluginapi.Healthy {
+			deviceID, _ := splitKubeletDeviceID(device.ID)
+			available = append(available, deviceID)
+		}
+	}
+	if len(available) == 0 {
+		report.fail("slice %d: no healthy device to allocate", report.Slices)
+		sleepContext(ctx, hold)
+		return
+	}
+
+	// Preferred allocation must skip settling devices while a settled one is free
+	deviceID := plugin.labels.PreferredDevices(available, nil, 1)[0]
+	now := time.Now()
+	if labels, ok := plugin.labels.Get(deviceID); ok && labels.InCooldown(now) {
+		settled := ""
+		for _, candidate := range available {
+			if other, ok := plugin.labels.Get(candidate); !ok || !other.InCooldown(now) {
+				settled = candidate
+				break
+			}
+		}
+		if settled != "" {
+			report.fail("slice %d: preferred %s in cooldown while %s was settled", report.Slices, deviceID, settled)
+		} else {
+			report.CooldownPicks++
+		}
+	}
+
+	kubeletID := plugin.rotation.KubeletID(deviceID)
+	began := time.Now()
+	response, err := plugin.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{kubeletID}}},
+	})
+	*allocateLatencies = append(*allocateLatencies, time.Since(began))
+	if err != nil {
+		report.fail("slice %d: allocate %s: %v", report.Slices, deviceID, err)
+		return
+	}
+	report.Allocations++
+	correlationID := plugin.allocations.CorrelationID(deviceID)
+	defer liveSoakRelease(plugin, correlationID, report)
+
+	// A released device must never be answered with the previous slice's response
+	if served := response.ContainerResponses[0].Envs["VIDEO_DEVICE_ALLOCATION_ID"]; correlationID == "" || served != correlationID {
+		report.fail("slice %d: allocate %s served allocation %q, tracked %q", report.Slices, deviceID, served, correlationID)
+	}
+
+	began = time.Now()
+	_, err = plugin.PreStartContainer(ctx, &pluginapi.PreStartContainerRequest{DevicesIDs: []string{kubeletID}})
+	*prestartLatencies = append(*prestartLatencies, time.Since(began))
+	if err != nil {
+		report.fail("slice %d: prestart %s: %v", report.Slices, deviceID, err)
+		return
+	}
+	report.Resets++
+	if !plugin.v4l2Manager.GetDeviceHealth(deviceID) {
+		report.fail("slice %d: %s unhealthy after reset", report.Slices, deviceID)
+	}
+
+	sleepContext(ctx, hold)
+	if !plugin.v4l2Manager.GetDeviceHealth(deviceID) {
+		report.fail("slice %d: %s turned unhealthy while allocated", report.Slices, deviceID)
+	}
+}
+
+// liveSoakRelease returns an allocation the way the pod watcher does when its pod ends
+func liveSoakRelease(plugin *VideoDevicePlugin, correlationID string, report *liveSoakReport) {
+	for _, allocation := range plugin.allocations.ReleaseCorrelation(correlationID) {
+		plugin.removeHandoff(correlationID, allocation.DeviceID)
+		device, err := plugin.v4l2Manager.GetDeviceByID(allocation.DeviceID)
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(plugin.config.AllocationTimeout)*time.Second)
+		if err := plugin.runDeviceHooks(ctx, HookStageRelease, device); err != nil {
+			report.fail("slice %d: release hooks %s: %v", report.Slices, allocation.DeviceID, err)
+		}
+		cancel()
+	}
+}
+
+// liveSoakFault deletes a free device and recreates it, expecting the health check to report
+// it unhealthy and then healthy again
+func liveSoakFault(ctx context.Context, plugin *VideoDevicePlugin, report *liveSoakReport) {
+	devices, _ := plugin.buildDeviceList()
+	var device *VideoDevice
+	for _, candidate := range devices {
+		deviceID, _ := splitKubeletDeviceID(candidate.ID)
+		if found, err := plugin.v4l2Manager.GetDeviceByID(deviceID); err == nil && candidate.Health == pluginapi.Healthy && !found.Fallback {
+			device = found
+			break
+		}
+	}
+	if device == nil {
+		return
+	}
+	report.Faults++
+
+	timeout := time.Duration(plugin.config.DeviceCreationTimeout) * time.Second
+	deleteCtx, cancel := context.WithTimeout(ctx, timeout)
+	out, err := privilegedCommand(deleteCtx, "v4l2loopback-ctl", "delete", device.Path)
+	cancel()
+	if err != nil {
+		report.fail("slice %d: delete %s: %v: %s", report.Slices, device.ID, err, out)
+		return
+	}
+	if plugin.v4l2Manager.GetDeviceHealth(device.ID) {
+		report.fail("slice %d: %s still healthy after it was deleted", report.Slices, device.ID)
+	}
+
+	resetCtx, cancel := context.WithTimeout(ctx, timeout)
+	err = plugin.resetDeviceWithContext(resetCtx, device.Path, plugin.deviceMaxBuffers(device))
+	cancel()
+	if err != nil {
+		report.fail("slice %d: recreate %s: %v", report.Slices, device.ID, err)
+		return
+	}
+	if err := plugin.v4l2Manager.RefreshDevice(device.ID); err != nil {
+		report.fail("slice %d: refresh %s: %v", report.Slices, device.ID, err)
+	}
+
+	recovery := time.Now().Add(liveSoakRecoveryTimeout)
+	for !plugin.v4l2Manager.GetDeviceHealth(device.ID) {
+		if check, ok := plugin.v4l2Manager.GetDeviceCheck(device.ID); ok && check.Error == "damped after flapping" {
+			// Flap damping holds it back on purpose; reported through the damped count
+			return
+		}
+		if time.Now().After(recovery) || ctx.Err() != nil {
+			report.fail("slice %d: %s did not recover within %s after it was recreated", report.Slices, device.ID, liveSoakRecoveryTimeout)
+			return
+		}
+		sleepContext(ctx, 200*time.Millisecond)
+	}
+}
+
+// sleepContext sleeps for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -5,9 +5,62 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
+// kernelTaintFlags maps /proc/sys/kernel/tainted bits to their documented letters and meaning
+var kernelTaintFlags = []string{
+	0:  "P: proprietary module loaded",
+	1:  "F: module force loaded",
+	2:  "S: kernel running on out-of-spec system",
+	3:  "R: module force unloaded",
+	4:  "M: machine check exception",
+	5:  "B: bad page referenced",
+	6:  "U: taint requested by userspace",
+	7:  "D: kernel died recently (OOPS or BUG)",
+	8:  "A: ACPI table overridden",
+	9:  "W: kernel issued warning",
+	10: "C: staging driver loaded",
+	11: "I: firmware bug workaround applied",
+	12: "O: out-of-tree module loaded",
+	13: "E: unsigned module loaded",
+	14: "L: soft lockup occurred",
+	15: "K: kernel live patched",
+	16: "X: auxiliary taint",
+	17: "T: kernel built with struct randomization plugin",
+	18: "N: in-kernel test run",
+}
+
+// SystemInfo is a snapshot of node facts relevant to triaging module and device failures
+type SystemInfo struct {
+	KernelVersion    string   `json:"kernel_version"`
+	Architecture     string   `json:"architecture"`
+	TotalMemory      string   `json:"total_memory,omitempty"`
+	V4L2ModuleCount  int      `json:"v4l2_module_count"`
+	TaintValue       int      `json:"taint_value"`
+	TaintFlags       []string `json:"taint_flags,omitempty"`
+	ModuleSigEnforce string   `json:"module_sig_enforce"` // "enforced", "permissive" or "unknown"
+	KernelLockdown   string   `json:"kernel_lockdown,omitempty"`
+	CgroupVersion    string   `json:"cgroup_version"`
+	ContainerRuntime string   `json:"container_runtime"`
+	DevFilesystem    string   `json:"dev_filesystem"` // Filesystem type mounted on /dev
+	DevIsDevtmpfs    bool     `json:"dev_is_devtmpfs"`
+
+	// Classified v4l2loopback/videodev kernel log signatures, e.g. of an earlier failed load
+	ModuleDiagnostics *ModuleDiagnostics `json:"module_diagnostics,omitempty"`
+}
+
+// SystemInspector gathers SystemInfo from the running node
+type SystemInspector struct {
+	logger *slog.Logger
+}
+
+// NewSystemInspector creates a new SystemInspector instance
+func NewSystemInspector(logger *slog.Logger) *SystemInspector {
+	return &SystemInspector{logger: logger}
+}
+
 // checkRoot checks if the application is running as root
 func checkRoot(logger *slog.Logger) error {
 	if os.Geteuid() != 0 {
@@ -19,28 +72,54 @@ func checkRoot(logger *slog.Logger) error {
 
 // displaySystemInfo displays system information
 func displaySystemInfo(logger *slog.Logger) {
+	info := NewSystemInspector(logger).Inspect()
+
 	logger.Info("System Information:")
+	logger.Info("   Kernel version: " + info.KernelVersion)
+	logger.Info("   Architecture: " + info.Architecture)
+	if info.TotalMemory != "" {
+		logger.Info("   Total memory: " + info.TotalMemory)
+	}
+	logger.Info("   Loaded modules: " + fmt.Sprintf("%d v4l2* modules", info.V4L2ModuleCount))
+	logger.Info("System inspection",
+		"taint_value", info.TaintValue,
+		"taint_flags", info.TaintFlags,
+		"module_sig_enforce", info.ModuleSigEnforce,
+		"kernel_lockdown", info.KernelLockdown,
+		"cgroup_version", info.CgroupVersion,
+		"container_runtime", info.ContainerRuntime,
+		"dev_filesystem", info.DevFilesystem,
+		"dev_is_devtmpfs", info.DevIsDevtmpfs)
+	if info.ModuleDiagnostics != nil {
+		logger.Warn("Kernel log shows module failure signatures",
+			"signatures", info.ModuleDiagnostics.Signatures,
+			"remediation", info.ModuleDiagnostics.Remediation)
+	}
+}
+
+// Inspect collects the current system information
+func (s *SystemInspector) Inspect() *SystemInfo {
+	info := &SystemInfo{}
 
 	// Get kernel version
 	if kernelInfo, err := exec.Command("uname", "-r").Output(); err == nil {
-		logger.Info("   Kernel version: " + strings.TrimSpace(string(kernelInfo)))
+		info.KernelVersion = strings.TrimSpace(string(kernelInfo))
 	} else {
-		logger.Warn("Failed to get kernel version", "error", err)
+		s.logger.Warn("Failed to get kernel version", "error", err)
 	}
 
 	// Get architecture
 	if archInfo, err := exec.Command("uname", "-m").Output(); err == nil {
-		logger.Info("   Architecture: " + strings.TrimSpace(string(archInfo)))
+		info.Architecture = strings.TrimSpace(string(archInfo))
 	} else {
-		logger.Warn("Failed to get architecture", "error", err)
+		s.logger.Warn("Failed to get architecture", "error", err)
 	}
 
 	// Get memory info
 	if memInfo, err := os.ReadFile("/proc/meminfo"); err == nil {
-		lines := strings.Split(string(memInfo), "\n")
-		for _, line := range lines {
+		for _, line := range strings.Split(string(memInfo), "\n") {
 			if strings.HasPrefix(line, "MemTotal:") {
-				logger.Info("   Total memory: " + strings.TrimSpace(strings.TrimPrefix(line, "MemTotal:")))
+				info.TotalMemory = strings.TrimSpace(strings.TrimPrefix(line, "MemTotal:"))
 				break
 			}
 		}
@@ -48,12 +127,126 @@ func displaySystemInfo(logger *slog.Logger) {
 
 	// Count loaded v4l2 modules
 	if lsmodOutput, err := exec.Command("lsmod").Output(); err == nil {
-		v4l2Count := 0
 		for _, line := range strings.Split(string(lsmodOutput), "\n") {
 			if strings.HasPrefix(line, "v4l2") {
-				v4l2Count++
+				info.V4L2ModuleCount++
 			}
 		}
-		logger.Info("   Loaded modules: " + fmt.Sprintf("%d v4l2* modules", v4l2Count))
 	}
+
+	info.TaintValue, info.TaintFlags = readKernelTaint()
+	info.ModuleSigEnforce = readModuleSigEnforce()
+	info.KernelLockdown = readKernelLockdown()
+	info.CgroupVersion = detectCgroupVersion()
+	info.ContainerRuntime = detectContainerRuntime()
+	info.DevFilesystem = mountFilesystemType("/dev")
+	info.DevIsDevtmpfs = info.DevFilesystem == "devtmpfs"
+	if diagnostics := collectModuleDiagnostics(s.logger); len(diagnostics.Signatures) > 0 {
+		info.ModuleDiagnostics = diagnostics
+	}
+
+	return info
+}
+
+// readKernelTaint decodes /proc/sys/kernel/tainted into flag descriptions
+func readKernelTaint() (int, []string) {
+	data, err := os.ReadFile("/proc/sys/kernel/tainted")
+	if err != nil {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, nil
+	}
+
+	var flags []string
+	for bit, description := range kernelTaintFlags {
+		if value&(1<<bit) != 0 {
+			flags = append(flags, description)
+		}
+	}
+	return value, flags
+}
+
+// readModuleSigEnforce reports whether the kernel only loads signed modules
+func readModuleSigEnforce() string {
+	data, err := os.ReadFile("/sys/module/module/parameters/sig_enforce")
+	if err != nil {
+		return "unknown"
+	}
+	if strings.TrimSpace(string(data)) == "Y" {
+		return "enforced"
+	}
+	return "permissive"
+}
+
+// readKernelLockdown returns the active kernel lockdown mode, if the LSM is present
+func readKernelLockdown() string {
+	data, err := os.ReadFile("/sys/kernel/security/lockdown")
+	if err != nil {
+		return ""
+	}
+	// Format: "none [integrity] confidentiality" with the active mode bracketed
+	content := string(data)
+	start := strings.Index(content, "[")
+	end := strings.Index(content, "]")
+	if start >= 0 && end > start {
+		return content[start+1 : end]
+	}
+	return strings.TrimSpace(content)
+}
+
+// detectCgroupVersion reports "v2" on the unified hierarchy and "v1" otherwise
+func detectCgroupVersion() string {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return "v2"
+	}
+	if _, err := os.Stat("/sys/fs/cgroup"); err == nil {
+		return "v1"
+	}
+	return "unknown"
+}
+
+// detectContainerRuntime guesses the container runtime from cgroup paths and marker files
+func detectContainerRuntime() string {
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		content := string(data)
+		switch {
+		case strings.Contains(content, "cri-containerd") || strings.Contains(content, "containerd"):
+			return "containerd"
+		case strings.Contains(content, "crio"):
+			return "cri-o"
+		case strings.Contains(content, "docker"):
+			return "docker"
+		}
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	if runtime := os.Getenv("container"); runtime != "" {
+		return runtime
+	}
+	return "unknown"
+}
+
+// mountFilesystemType returns the filesystem type mounted at mountPoint, or "" if not a mount point
+func mountFilesystemType(mountPoint string) string {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+
+	fsType := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		// Later entries shadow earlier ones on the same mount point
+		if len(fields) >= 3 && fields[1] == mountPoint {
+			fsType = fields[2]
+		}
+	}
+	return fsType
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SystemdNotifier reports readiness and watchdog pings to systemd when the plugin runs as a
+// host service (Type=notify, WatchdogSec=...). Outside systemd NOTIFY_SOCKET is unset and it does nothing.
+// Watchdog pings are only sent while the plugin reports itself healthy, so a stalled or
+// failing health check lets WatchdogSec expire and systemd restarts the service.
+type SystemdNotifier struct {
+	socket   string
+	watchdog time.Duration // Zero when the unit has no watchdog configured
+	plugin   *VideoDevicePlugin
+	logger   *slog.Logger
+	cancel   context.CancelFunc
+	doneCh   chan struct{}
+}
+
+// NewSystemdNotifier returns a notifier for the current process, or nil when not started by systemd
+func NewSystemdNotifier(plugin *VideoDevicePlugin, logger *slog.Logger) *SystemdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	watchdog, err := systemdWatchdogInterval()
+	if err != nil {
+		logger.Warn("Ignoring invalid systemd watchdog settings", "error", err)
+	}
+
+	return &SystemdNotifier{
+		socket:   socket,
+		watchdog: watchdog,
+		plugin:   plugin,
+		logger:   logger,
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// systemdWatchdogInterval returns WatchdogSec from WATCHDOG_USEC when it targets this process
+func systemdWatchdogInterval() (time.Duration, error) {
+	usecValue := os.Getenv("WATCHDOG_USEC")
+	if usecValue == "" {
+		return 0, nil
+	}
+	if pidValue := os.Getenv("WATCHDOG_PID"); pidValue != "" {
+		pid, err := strconv.Atoi(pidValue)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q: %w", pidValue, err)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	usec, err := strconv.ParseInt(usecValue, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usecValue)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// Start sends READY=1 and starts the watchdog loop until ctx is canceled; safe on a nil notifier
+func (n *SystemdNotifier) Start(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	ctx, n.cancel = context.WithCancel(ctx)
+
+	if err := n.notify("READY=1\nSTATUS=Serving video devices"); err != nil {
+		n.logger.Warn("Failed to notify systemd of readiness", "error", err)
+	} else {
+		n.logger.Info("Notified systemd of readiness", "watchdog", n.watchdog.String())
+	}
+
+	if n.watchdog == 0 {
+		close(n.doneCh)
+		return
+	}
+	go n.watchdogLoop(ctx)
+}
+
+// watchdogLoop pings the watchdog at half its timeout while the plugin is healthy
+func (n *SystemdNotifier) watchdogLoop(ctx context.Context) {
+	defer close(n.doneCh)
+
+	ticker := time.NewTicker(n.watchdog / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			health := n.plugin.GetHealthStatus()
+			if !health.Healthy {
+				n.logger.Warn("Withholding systemd watchdog ping, plugin unhealthy", "errors", health.Errors)
+				_ = n.notify(fmt.Sprintf("STATUS=Unhealthy: %v", health.Errors))
+				continue
+			}
+			if err := n.notify("WATCHDOG=1\nSTATUS=Serving video devices"); err != nil {
+				n.logger.Warn("Failed to ping systemd watchdog", "error", err)
+			}
+		}
+	}
+}
+
+// Stop ends the watchdog loop and tells systemd the service is stopping; safe on a nil notifier
+func (n *SystemdNotifier) Stop() {
+	if n == nil || n.cancel == nil {
+		return
+	}
+	n.cancel()
+	<-n.doneCh
+	_ = n.notify("STOPPING=1")
+}
+
+// notify sends a state datagram to NOTIFY_SOCKET ("@" prefixes an abstract socket)
+func (n *SystemdNotifier) notify(state string) error {
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", n.socket, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", n.socket, err)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// takeoverProtocolVersion is bumped on incompatible changes of the takeover messages
+const takeoverProtocolVersion = 1
+
+// takeoverRequest is sent by a starting instance asking the running one for its socket
+type takeoverRequest struct {
+	Version       int    `json:"version"`
+	ResourceName  string `json:"resource_name"`
+	PluginVersion string `json:"plugin_version"`
+}
+
+// takeoverState accompanies the listener fd: the live bookkeeping of the running instance
+type takeoverState struct {
+	Version       int         `json:"version"`
+	PluginVersion string      `json:"plugin_version"`
+	Checkpoint    *Checkpoint `json:"checkpoint"`
+}
+
+// takeoverAck is the new instance's answer once it serves the socket and registered
+type takeoverAck struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// TakeoverServer lets a new instance take over the plugin socket of this one during a rolling
+// update: the listen socket is passed with SCM_RIGHTS, so kubelet's endpoint path never stops
+// accepting connections and the node keeps its capacity while the instances swap
+type TakeoverServer struct {
+	plugin   *VideoDevicePlugin
+	path     string
+	logger   *slog.Logger
+	listener *net.UnixListener
+	stopOnce sync.Once
+}
+
+// NewTakeoverServer creates the takeover endpoint of a plugin
+func NewTakeoverServer(plugin *VideoDevicePlugin, logger *slog.Logger) *TakeoverServer {
+	return &TakeoverServer{
+		plugin: plugin,
+		path:   plugin.config.TakeoverSocketPath,
+		logger: logger.With("component", "takeover"),
+	}
+}
+
+// Start listens on the takeover socket; a stale socket of an exited instance is replaced
+func (t *TakeoverServer) Start() error {
+	if err := ensureDirectory(filepath.Dir(t.path)); err != nil {
+		return fmt.Errorf("failed to create takeover socket directory: %w", err)
+	}
+	if err := cleanupSocket(t.path); err != nil {
+		t.logger.Warn("Failed to cleanup existing takeover socket", "error", err)
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: t.path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("failed to listen on takeover socket: %w", err)
+	}
+	if err := os.Chmod(t.path, 0600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to restrict takeover socket: %w", err)
+	}
+	t.listener = listener
+
+	go t.serve()
+	t.logger.Info("Socket takeover endpoint started", "socket", t.path)
+	return nil
+}
+
+// Stop closes the takeover socket; after a takeover the path belongs to the new instance
+func (t *TakeoverServer) Stop() {
+	t.stopOnce.Do(func() {
+		if t.listener != nil {
+			_ = t.listener.Close()
+		}
+	})
+}
+
+// serve handles takeover requests one at a time until one succeeds
+func (t *TakeoverServer) serve() {
+	for {
+		conn, err := t.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+		err = t.handle(conn)
+		_ = conn.Close()
+		if err == nil {
+			// The new instance owns both socket paths now; closing must not unlink them
+			t.listener.SetUnlinkOnClose(false)
+			t.Stop()
+			return
+		}
+		t.logger.Warn("Socket takeover failed, continuing to serve", "error", err)
+	}
+}
+
+// handle passes the plugin socket to the requesting instance and waits for it to take over
+func (t *TakeoverServer) handle(conn *net.UnixConn) error {
+	timeout := time.Duration(t.plugin.config.SocketTakeoverTimeout) * time.Second
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(conn)
+
+	var request takeoverRequest
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read takeover request: %w", err)
+	}
+	if err := json.Unmarshal(line, &request); err != nil {
+		return fmt.Errorf("invalid takeover request: %w", err)
+	}
+	if request.Version != takeoverProtocolVersion {
+		return fmt.Errorf("unsupported takeover protocol version %d (want %d)", request.Version, takeoverProtocolVersion)
+	}
+	if request.ResourceName != t.plugin.config.ResourceName {
+		return fmt.Errorf("takeover requested for resource %q, this instance serves %q", request.ResourceName, t.plugin.config.ResourceName)
+	}
+	t.logger.Info("New instance requested the plugin socket", "plugin_version", request.PluginVersion)
+
+	// Freeze the bookkeeping: Allocate calls arriving from here on are refused so the
+	// snapshot the new instance restores stays complete
+	file, err := t.plugin.beginTakeover()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	handedOver := false
+	defer func() {
+		if !handedOver {
+			t.plugin.abortTakeover(file)
+		}
+	}()
+
+	// The fd travels with the first byte, the state follows as one JSON line
+	if _, _, err := conn.WriteMsgUnix([]byte{'\n'}, syscall.UnixRights(int(file.Fd())), nil); err != nil {
+		return fmt.Errorf("failed to pass the plugin socket: %w", err)
+	}
+	snapshot := t.plugin.checkpointSnapshot()
+	snapshot.Version = checkpointVersion
+	snapshot.SavedAt = t.plugin.clock.Now()
+	state := takeoverState{Version: takeoverProtocolVersion, PluginVersion: pluginVersion, Checkpoint: snapshot}
+	if err := json.NewEncoder(conn).Encode(state); err != nil {
+		return fmt.Errorf("failed to send takeover state: %w", err)
+	}
+
+	var ack takeoverAck
+	line, err = reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("new instance did not confirm the takeover: %w", err)
+	}
+	if err := json.Unmarshal(line, &ack); err != nil {
+		return fmt.Errorf("invalid takeover confirmation: %w", err)
+	}
+	if !ack.Ready {
+		return fmt.Errorf("new instance could not take over: %s", ack.Error)
+	}
+
+	handedOver = true
+	t.plugin.completeTakeover()
+	t.logger.Info("Plugin socket taken over by the new instance, idling until this pod is deleted")
+	return nil
+}
+
+// requestTakeover asks the running instance for the plugin socket
+// It returns nil without error when no instance offers a takeover
+func requestTakeover(ctx context.Context, config *DevicePluginConfig, logger *slog.Logger) (*pendingTakeover, error) {
+	timeout := time.Duration(config.SocketTakeoverTimeout) * time.Second
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "unix", config.TakeoverSocketPath)
+	if err != nil {
+		logger.Debug("No running instance offers a socket takeover", "socket", config.TakeoverSocketPath, "error", err)
+		return nil, nil
+	}
+	unixConn := conn.(*net.UnixConn)
+	_ = unixConn.SetDeadline(time.Now().Add(timeout))
+
+	fail := func(err error) (*pendingTakeover, error) {
+		_ = unixConn.Close()
+		return nil, err
+	}
+
+	request := takeoverRequest{Version: takeoverProtocolVersion, ResourceName: config.ResourceName, PluginVersion: pluginVersion}
+	if err := json.NewEncoder(unixConn).Encode(request); err != nil {
+		return fail(fmt.Errorf("failed to send takeover request: %w", err))
+	}
+
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := unixConn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return fail(fmt.Errorf("failed to receive the plugin socket: %w", err))
+	}
+	listener, err := listenerFromRights(oob[:oobn])
+	if err != nil {
+		return fail(err)
+	}
+
+	var state takeoverState
+	line, err := bufio.NewReader(unixConn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &state)
+	}
+	if err != nil {
+		_ = listener.Close()
+		return fail(fmt.Errorf("failed to receive takeover state: %w", err))
+	}
+	return &pendingTakeover{conn: unixConn, listener: listener, state: state}, nil
+}
+
+// listenerFromRights turns the fd of an SCM_RIGHTS message into a listener
+func listenerFromRights(oob []byte) (*net.UnixListener, error) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil || len(messages) == 0 {
+		return nil, errors.New("takeover message carries no socket")
+	}
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		return nil, errors.New("takeover message carries no socket")
+	}
+	file := os.NewFile(uintptr(fds[0]), "plugin-socket")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("received fd is not a listen socket: %w", err)
+	}
+	unixListener, ok := listener.(*net.UnixListener)
+	if !ok {
+		_ = listener.Close()
+		return nil, errors.New("received fd is not a unix socket")
+	}
+	return unixListener, nil
+}
+
+// pendingTakeover is a received plugin socket the new instance still has to confirm
+type pendingTakeover struct {
+	conn     *net.UnixConn
+	listener *net.UnixListener
+	state    takeoverState
+}
+
+// confirm tells the previous instance whether this one took over
+func (t *pendingTakeover) confirm(err error) {
+	ack := takeoverAck{Ready: err == nil}
+	if err != nil {
+		ack.Error = err.Error()
+	}
+	_ = json.NewEncoder(t.conn).Encode(ack)
+	_ = t.conn.Close()
+}
+
+// beginTakeover stops accepting plugin connections and returns a duplicate of the listen socket
+// Kubelet's open ListAndWatch stream keeps being served until completeTakeover
+func (p *VideoDevicePlugin) beginTakeover() (*os.File, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	listener, ok := p.listener.(*net.UnixListener)
+	if !ok || listener == nil {
+		return nil, errors.New("plugin socket is not being served")
+	}
+	file, err := listener.File()
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate the plugin socket: %w", err)
+	}
+
+	p.takingOver.Store(true)
+	listener.SetUnlinkOnClose(false)
+	_ = listener.Close()
+	p.listener = nil
+
+	// The new instance publishes its own registration socket; kubelet re-registers through it
+	if p.watcher != nil {
+		p.watcher.Stop()
+		p.watcher = nil
+	}
+	return file, nil
+}
+
+// abortTakeover resumes serving the plugin socket after a failed takeover
+func (p *VideoDevicePlugin) abortTakeover(file *os.File) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.takingOver.Store(false)
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		p.logger.Error("Cannot resume serving the plugin socket after a failed takeover", "error", err)
+		return
+	}
+	p.listener = listener
+	go p.serve(listener)
+
+	if usesWatcherRegistration(p.config) {
+		p.watcher = NewPluginWatcherServer(p.config.PluginRegistryDir, p.config.ResourceName, p.config.SocketPath, p.setWatcherRegistration, p.logger)
+		if err := p.watcher.Start(); err != nil {
+			p.logger.Error("Failed to restore plugin watcher registration after a failed takeover", "error", err)
+			p.watcher = nil
+		}
+	}
+}
+
+// completeTakeover stops serving kubelet and managing devices once the new instance registered
+// The process keeps running until its pod is deleted, so the DaemonSet does not restart it and
+// ask the new instance for the socket back
+func (p *VideoDevicePlugin) completeTakeover() {
+	p.takenOver.Store(true)
+
+	// Pod and lease watchers, the admin API and the companion sockets belong to the new instance;
+	// stopping them first keeps both instances from releasing the same pods
+	p.mu.Lock()
+	hooks := p.takeoverHooks
+	p.takeoverHooks = nil
+	p.mu.Unlock()
+	for _, stop := range hooks {
+		stop()
+	}
+
+	// The new instance writes the checkpoint from now on
+	p.checkpoint.Abandon()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.server != nil {
+		p.server.Stop()
+	}
+	p.stopKubeletEndpointServers()
+
+	// Stop the monitors; repairs and recoveries belong to the new instance
+	p.cancel()
+}
+
+// OnTakeover registers stop to run when a new instance takes over the socket, before the
+// plugin itself stops serving; it runs at once when the takeover already completed
+func (p *VideoDevicePlugin) OnTakeover(stop func()) {
+	p.mu.Lock()
+	if !p.IsTakenOver() {
+		p.takeoverHooks = append(p.takeoverHooks, stop)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	stop()
+}
+
+// IsTakenOver reports whether a new instance owns the plugin socket and the devices now
+func (p *VideoDevicePlugin) IsTakenOver() bool {
+	return p.takenOver.Load()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestSocketTakeoverBetweenInstances(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, too short for t.TempDir under some runners
+	dir, err := os.MkdirTemp("", "vdp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	configure := func(config *DevicePluginConfig) {
+		config.SocketPath = filepath.Join(dir, "video.sock")
+		config.TakeoverSocketPath = filepath.Join(dir, "takeover.sock")
+		config.EnableSocketTakeover = true
+		config.SocketTakeoverTimeout = 10
+	}
+	tier := DeviceTier{
+		Name:           "premium",
+		ResourceName:   "meeting-baas.io/video-premium",
+		SocketPath:     filepath.Join(dir, "premium.sock"),
+		VideoDeviceIDs: []string{"video11"},
+	}
+	ctx := context.Background()
+
+	old := newTestPlugin(t, newFakeV4L2Manager(2), configure)
+	if err := old.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = old.Stop() })
+	oldTier := NewDeviceTierPlugin(tier, old, old.logger)
+	if err := oldTier.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	old.OnTakeover(oldTier.handOver)
+	watcherStopped := make(chan struct{})
+	old.OnTakeover(func() { close(watcherStopped) })
+
+	resp, err := old.Allocate(ctx, &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: []string{"video10"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	correlationID := resp.ContainerResponses[0].Envs["VIDEO_DEVICE_ALLOCATION_ID"]
+
+	next := newTestPlugin(t, newFakeV4L2Manager(2), configure)
+	if err := next.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = next.Stop() })
+	// The tier socket of the old instance stays live until the takeover completes
+	nextTier := NewDeviceTierPlugin(tier, next, next.logger)
+	if err := nextTier.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nextTier.Stop)
+
+	select {
+	case <-watcherStopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("takeover hooks of the old instance did not run")
+	}
+	if !old.IsTakenOver() {
+		t.Error("old instance is not taken over")
+	}
+	if old.ctx.Err() == nil {
+		t.Error("old instance loops still run after the takeover")
+	}
+	if got := next.allocations.CorrelationID("video10"); got != correlationID {
+		t.Errorf("allocation of video10 after the takeover = %q, want %q", got, correlationID)
+	}
+
+	// Shutting the old instance down must leave the sockets of the new one in place
+	oldTier.Stop()
+	if err := old.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, socketPath := range []string{next.config.SocketPath, tier.SocketPath} {
+		if !probeSocketAlive(socketPath) {
+			t.Errorf("socket %s is not served after the old instance stopped", socketPath)
+		}
+	}
+}
@@ -1,53 +1,220 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
 // VideoDevice represents a virtual video device
 type VideoDevice struct {
-	ID   string `json:"id"`   // Device ID (e.g., "video0")
-	Path string `json:"path"` // Device path (e.g., "/dev/video0")
+	ID         string    `json:"id"`                    // Device ID (e.g., "video0")
+	Path       string    `json:"path"`                  // Device path (e.g., "/dev/video0")
+	CardLabel  string    `json:"card_label,omitempty"`  // Card label reported by the driver
+	Driver     string    `json:"driver,omitempty"`      // Driver name from VIDIOC_QUERYCAP (e.g., "v4l2 loopback")
+	SysfsPath  string    `json:"sysfs_path,omitempty"`  // Resolved /sys/devices path
+	Major      uint32    `json:"major"`                 // Device node major number
+	Minor      uint32    `json:"minor"`                 // Device node minor number
+	Generation int       `json:"generation"`            // Incremented every time the device is recreated
+	MaxBuffers int       `json:"max_buffers,omitempty"` // Reduced buffer count after a buffer exhaustion recovery (0 = V4L2_MAX_BUFFERS)
+	CreatedAt  time.Time `json:"created_at"`            // When the current generation was discovered or created
+	Fallback   bool      `json:"fallback,omitempty"`    // Placeholder standing in for a device the module could not create
 }
 
 // DevicePluginConfig holds configuration for the device plugin
 type DevicePluginConfig struct {
 	// Core Configuration
-	MaxDevices    int    `json:"max_devices"`    // Maximum number of video devices
-	NodeName      string `json:"node_name"`      // Kubernetes node name
-	KubeletSocket string `json:"kubelet_socket"` // Path to kubelet socket
-	ResourceName  string `json:"resource_name"`  // Resource name for device plugin
-	SocketPath    string `json:"socket_path"`    // Path to device plugin socket
-	LogLevel      string `json:"log_level"`      // Log level (debug, info, warn, error)
+	MaxDevices           int    `json:"max_devices"`            // Maximum number of video devices
+	HotSpareCount        int    `json:"hot_spare_count"`        // Devices created but held back to replace failing ones
+	DeviceTiers          string `json:"device_tiers"`           // Device ranges advertised as separate resources (name:count[:key=value,...];...)
+	ExcludedDevices      string `json:"excluded_devices"`       // Comma-separated /dev/videoN numbers created but never advertised
+	NodeName             string `json:"node_name"`              // Kubernetes node name
+	KubeletSocket        string `json:"kubelet_socket"`         // Path to kubelet socket
+	KubeletRootDir       string `json:"kubelet_root_dir"`       // Kubelet root the kubelet paths were derived from (empty when KUBELET_SOCKET is set)
+	AdditionalKubelets   string `json:"additional_kubelets"`    // Comma-separated sockets of further kubelets the resource is registered with
+	ResourceName         string `json:"resource_name"`          // Resource name for device plugin
+	SocketPath           string `json:"socket_path"`            // Path to device plugin socket
+	RegistrationMode     string `json:"registration_mode"`      // Kubelet registration: direct, watcher (plugins_registry) or both
+	PluginRegistryDir    string `json:"plugin_registry_dir"`    // Kubelet plugin watcher directory (watcher/both modes)
+	EnableSocketTakeover bool   `json:"enable_socket_takeover"` // Hand the plugin socket and bookkeeping to the next instance during rolling updates
+	TakeoverSocketPath   string `json:"takeover_socket_path"`   // Unix socket a starting instance requests the takeover on
+	LogLevel             string `json:"log_level"`              // Log level (debug, info, warn, error)
+	AuditLogPath         string `json:"audit_log_path"`         // Append-only JSON log of privileged host operations ("stderr" for the stream, empty disables)
+	Mode                 string `json:"mode"`                   // Run mode: plugin (per-node DaemonSet), aggregator (cluster summary) or shadow (rollout check)
 
 	// Development/Debugging
-	Debug bool `json:"debug"` // Enable debug mode
+	Debug     bool `json:"debug"`      // Enable debug mode
+	GRPCTrace bool `json:"grpc_trace"` // Log every gRPC call at debug level (also toggled by the grpc_trace ConfigMap key)
+
+	// gRPC Server Tuning (0 keeps the gRPC default)
+	GRPCMaxRecvMsgSize       int `json:"grpc_max_recv_msg_size"`      // Largest message the plugin servers accept in bytes
+	GRPCMaxSendMsgSize       int `json:"grpc_max_send_msg_size"`      // Largest message the plugin servers send in bytes
+	GRPCMaxConcurrentStreams int `json:"grpc_max_concurrent_streams"` // Concurrent streams per kubelet connection
+	GRPCWriteBufferSize      int `json:"grpc_write_buffer_size"`      // Transport write buffer in bytes
 
 	// V4L2 Configuration
-	V4L2MaxBuffers    int    `json:"v4l2_max_buffers"`    // Number of buffers for v4l2loopback
-	V4L2ExclusiveCaps int    `json:"v4l2_exclusive_caps"` // Enable exclusive capabilities (0,1) 0 is default and false, 1 is true
-	V4L2CardLabel     string `json:"v4l2_card_label"`     // Card label for devices
-	V4L2DevicePerm    int    `json:"v4l2_device_perm"`    // Device permissions (octal, e.g., 0666)
+	V4L2MaxBuffers         int    `json:"v4l2_max_buffers"`         // Number of buffers for v4l2loopback
+	V4L2ExclusiveCaps      int    `json:"v4l2_exclusive_caps"`      // Enable exclusive capabilities (0,1) 0 is default and false, 1 is true
+	V4L2CardLabel          string `json:"v4l2_card_label"`          // Card label for devices
+	V4L2DevicePerm         int    `json:"v4l2_device_perm"`         // Device permissions (octal, e.g., 0666)
+	V4L2DeviceGID          int    `json:"v4l2_device_gid"`          // Device group ID (-1 leaves ownership untouched)
+	VideoDevicePermissions string `json:"video_device_permissions"` // Device cgroup permissions granted to containers ("r", "rw", "rwm")
+	ManageModule           bool   `json:"manage_module"`            // Load/unload v4l2loopback (false when the host owns the module lifecycle)
+	KeepModuleOnExit       bool   `json:"keep_module_on_exit"`      // Leave the module loaded on shutdown, removing only ctl-added devices
+	ModuleUnloadMode       string `json:"module_unload_mode"`       // Shutdown unload timing: immediate, or deferred until devices are released
+	ModuleUnloadDeadline   int    `json:"module_unload_deadline"`   // Max seconds a deferred unload waits for devices to be released
+	ModuleChangePolicy     string `json:"module_change_policy"`     // Module reload while devices are allocated: defer (until drained) or refuse
+	ModuleExecMode         string `json:"module_exec_mode"`         // How modprobe/insmod run: auto, container or nsenter (host mount/pid namespaces)
+	ModuleLockPath         string `json:"module_lock_path"`         // Host-path flock serializing module operations across containers (empty disables)
+	ModuleLockTimeout      int    `json:"module_lock_timeout"`      // Max wait for the module lock in seconds
+	EnableBufferRecovery   bool   `json:"enable_buffer_recovery"`   // Recreate devices with fewer buffers when the kernel reports buffer allocation failures
+	VideoDeviceStart       int    `json:"video_device_start"`       // First /dev/videoN number of the range (may be moved on collisions)
+	VideoDeviceCeiling     int    `json:"video_device_ceiling"`     // Highest /dev/videoN number automatic range selection may use
+	StateDir               string `json:"state_dir"`                // Directory for persisted plugin state
+	EnableCheckpoint       bool   `json:"enable_checkpoint"`        // Persist device/allocation bookkeeping in StateDir and restore it on startup
+
+	// Udev Integration
+	EnableUdevRules bool   `json:"enable_udev_rules"` // Install a udev rules file for the loopback range
+	UdevRulesPath   string `json:"udev_rules_path"`   // Path of the generated udev rules file
+
+	// Device Preparation
+	DeviceHooksFile      string `json:"device_hooks_file"`      // JSON file of create/allocate/release hooks (empty disables)
+	HandoffDir           string `json:"handoff_dir"`            // Host directory for per-allocation device metadata files (empty disables)
+	HandoffContainerPath string `json:"handoff_container_path"` // Path the metadata file is mounted at in containers
+	EnableSysfsMount     bool   `json:"enable_sysfs_mount"`     // Mount the allocated device's sysfs directory read-only into containers
+	SysfsContainerPath   string `json:"sysfs_container_path"`   // Directory the sysfs directory is mounted under in containers
+	EnableLabelStamping  bool   `json:"enable_label_stamping"`  // Recreate allocated devices with a card label naming their meeting or pod
+	LabelStampAnnotation string `json:"label_stamp_annotation"` // Pod annotation whose value is stamped (empty stamps the pod name)
+	TopologyNUMANode     int    `json:"topology_numa_node"`     // NUMA node passed to consumers as a pinning hint (-1 derives it from sysfs)
+	TopologyCPUs         string `json:"topology_cpus"`          // CPU list passed to consumers (empty uses the NUMA node's CPUs)
+
+	// Admin API
+	EnableAdminAPI        bool   `json:"enable_admin_api"`         // Serve the local admin API (device leases)
+	AdminSocketPath       string `json:"admin_socket_path"`        // Unix socket path for the admin API
+	AdminAuthMode         string `json:"admin_auth_mode"`          // Caller authentication: none (socket permissions), peercred or tls
+	AdminAllowedUIDs      string `json:"admin_allowed_uids"`       // Comma-separated uids allowed with peercred
+	AdminAllowedGIDs      string `json:"admin_allowed_gids"`       // Comma-separated gids allowed with peercred
+	AdminTLSCertFile      string `json:"admin_tls_cert_file"`      // Server certificate (SVID) served with tls
+	AdminTLSKeyFile       string `json:"admin_tls_key_file"`       // Key of the server certificate
+	AdminTLSClientCAFile  string `json:"admin_tls_client_ca_file"` // CA bundle client certificates must chain to
+	AdminAllowedSPIFFEIDs string `json:"admin_allowed_spiffe_ids"` // Comma-separated SPIFFE IDs allowed with tls ("/" suffix: prefix)
+	LeaseDefaultTTL       int    `json:"lease_default_ttl"`        // Default local lease TTL in seconds
+	LeaseMaxTTL           int    `json:"lease_max_ttl"`            // Maximum local lease TTL in seconds
 
 	// Kubernetes Integration
-	KubernetesNamespace string `json:"kubernetes_namespace"` // Namespace for deployment
-	ServiceAccountName  string `json:"service_account_name"` // Service account name
+	EnableKubernetesAPI   bool   `json:"enable_kubernetes_api"`    // Talk to the API server; false keeps only the kubelet device plugin path
+	KubernetesNamespace   string `json:"kubernetes_namespace"`     // Namespace for deployment
+	ServiceAccountName    string `json:"service_account_name"`     // Service account name
+	EnableNodeCondition   bool   `json:"enable_node_condition"`    // Patch a node condition reflecting device readiness
+	NodeConditionType     string `json:"node_condition_type"`      // Node condition type (e.g., VideoDevicesReady)
+	ConfigMapName         string `json:"configmap_name"`           // ConfigMap with dynamic settings in KubernetesNamespace (empty disables)
+	EnableEvents          bool   `json:"enable_events"`            // Emit Kubernetes Events on the node for lifecycle operations
+	EnablePodWatch        bool   `json:"enable_pod_watch"`         // Watch this node's pods and release allocations of terminated pods
+	PodWatchNamespace     string `json:"pod_watch_namespace"`      // Namespace of watched pods (empty = all namespaces)
+	PodWatchLabelSelector string `json:"pod_watch_label_selector"` // Label selector of watched pods (empty = all)
+	PodWatchFieldSelector string `json:"pod_watch_field_selector"` // Extra field selector ANDed with spec.nodeName
+	PodWatchResync        int    `json:"pod_watch_resync"`         // Pod informer resync period in seconds (0 disables)
+	PodReleaseRate        int    `json:"pod_release_rate"`         // Released allocations cleaned up per second (hooks, handoff files)
+	PodResourcesSocket    string `json:"pod_resources_socket"`     // Kubelet PodResources API socket
+	EnableExhaustionWatch bool   `json:"enable_exhaustion_watch"`  // Count FailedScheduling events caused by device exhaustion on this node
+	EnableDeviceAffinity  bool   `json:"enable_device_affinity"`   // Prefer the device named by a pending pod's preferred-device annotation
+
+	// Pod Readiness Gate
+	EnablePodReadinessGate    bool   `json:"enable_pod_readiness_gate"`    // Set a pod condition once PreStartContainer verified the pod's devices
+	PodReadinessConditionType string `json:"pod_readiness_condition_type"` // Condition type pods list in spec.readinessGates
+
+	// Liveness Lease
+	EnableLivenessLease    bool   `json:"enable_liveness_lease"`    // Renew a coordination.k8s.io Lease after every successful health cycle
+	LivenessLeaseNamespace string `json:"liveness_lease_namespace"` // Namespace of the per-node Lease
+	LivenessLeaseDuration  int    `json:"liveness_lease_duration"`  // Seconds the Lease stays valid without a renewal
 
 	// Monitoring and Observability
-	EnableMetrics       bool `json:"enable_metrics"`        // Enable Prometheus metrics
-	MetricsPort         int  `json:"metrics_port"`          // Metrics port
-	HealthCheckInterval int  `json:"health_check_interval"` // Health check interval in seconds
+	EnableMetrics             bool   `json:"enable_metrics"`              // Enable Prometheus metrics
+	MetricsPort               int    `json:"metrics_port"`                // Metrics port
+	MetricsBackends           string `json:"metrics_backends"`            // Comma-separated metrics backends: prometheus, statsd, otlp
+	MetricsPushInterval       int    `json:"metrics_push_interval"`       // Seconds between pushes to the statsd and otlp backends
+	StatsDAddress             string `json:"statsd_address"`              // StatsD agent host:port (UDP)
+	OTLPMetricsEndpoint       string `json:"otlp_metrics_endpoint"`       // OTLP/HTTP metrics endpoint URL
+	OTLPMetricsHeaders        string `json:"otlp_metrics_headers"`        // Comma-separated Name=value headers sent to the OTLP endpoint (e.g. authorization)
+	HealthCheckInterval       int    `json:"health_check_interval"`       // Health check interval in seconds
+	MinHealthyDevices         int    `json:"min_healthy_devices"`         // Healthy devices required to report Ready (0 = all MAX_DEVICES)
+	ProbePort                 int    `json:"probe_port"`                  // Port serving /healthz and /readyz (0 disables)
+	EnableSystemdNotify       bool   `json:"enable_systemd_notify"`       // Send sd_notify READY/WATCHDOG when run as a systemd service
+	ConformanceCheckInterval  int    `json:"conformance_check_interval"`  // Seconds between kubelet view conformance checks (0 disables)
+	NamespaceQuotas           string `json:"namespace_quotas"`            // Per-node video device quotas (namespace=devices,...; * for unlisted namespaces)
+	NamespaceQuotaInterval    int    `json:"namespace_quota_interval"`    // Seconds between per-namespace usage exports (0 disables)
+	EnforceNamespaceQuotas    bool   `json:"enforce_namespace_quotas"`    // Refuse to start containers of namespaces over their quota
+	NamespaceQuotaWebhook     string `json:"namespace_quota_webhook"`     // URL deciding whether a container may start (overrides the static quota)
+	PoolDefragWindow          string `json:"pool_defrag_window"`          // Daily UTC window (HH:MM-HH:MM) an idle fragmented pool is compacted in ("" disables)
+	HealthHistorySize         int    `json:"health_history_size"`         // Health transitions kept per device
+	HealthFlapThreshold       int    `json:"health_flap_threshold"`       // Transitions within the flap window that mark a device flapping (0 disables)
+	HealthFlapWindow          int    `json:"health_flap_window"`          // Seconds of the flap detection window
+	HealthStabilizationWindow int    `json:"health_stabilization_window"` // Seconds a flapping device is reported Unhealthy
+	UnhealthyDevicePolicy     string `json:"unhealthy_device_policy"`     // How unavailable devices are reported: advertise (as Unhealthy) or remove
+	EnableDeviceIDRotation    bool   `json:"enable_device_id_rotation"`   // Advertise recovered devices under new IDs (video12-r1) so stale kubelet allocations fail
+
+	// Aggregator Mode
+	AggregatorPort     int `json:"aggregator_port"`      // Port serving the cluster summary
+	AggregatorCacheTTL int `json:"aggregator_cache_ttl"` // Seconds a computed summary is reused
+
+	// Shadow Mode
+	ShadowResourceName string `json:"shadow_resource_name"` // Dummy resource a shadow instance registers (never requested by pods)
+	ShadowSocketPath   string `json:"shadow_socket_path"`   // Device plugin socket of a shadow instance
+
+	// Load Smoothing
+	RegistrationDelay        int `json:"registration_delay"`          // Fixed delay before kubelet registration in seconds
+	RegistrationJitter       int `json:"registration_jitter"`         // Random extra registration delay in seconds
+	ReregisterMaxAttempts    int `json:"reregister_max_attempts"`     // Re-registration attempts after a kubelet restart before giving up (0 = unlimited)
+	ReregisterInitialBackoff int `json:"reregister_initial_backoff"`  // First re-registration retry delay in seconds, doubled per attempt
+	ReregisterMaxBackoff     int `json:"reregister_max_backoff"`      // Upper bound of the re-registration retry delay in seconds
+	ListAndWatchJitter       int `json:"list_and_watch_jitter"`       // Random delay before the initial ListAndWatch send in seconds
+	ListAndWatchHeartbeat    int `json:"list_and_watch_heartbeat"`    // Interval of the ListAndWatch stream heartbeat log in seconds (0 = off)
+	HealthCheckJitterPercent int `json:"health_check_jitter_percent"` // Health tick randomization (±percent of the interval)
+
+	// Fatal Error Diagnostics
+	EnableDiagnosticsBundle bool   `json:"enable_diagnostics_bundle"` // Write a diagnostics tarball before exiting on a fatal error
+	DiagnosticsDir          string `json:"diagnostics_dir"`           // Host directory receiving diagnostics bundles
+	DiagnosticsMaxBundles   int    `json:"diagnostics_max_bundles"`   // Newest bundles kept in DiagnosticsDir
+
+	// Mounted Settings
+	SecretFileReloadInterval int `json:"secret_file_reload_interval"` // Seconds between re-reads of settings given as <KEY>_FILE (0 disables)
+
+	// Permission Reconciliation
+	PermissionReconcileInterval int `json:"permission_reconcile_interval"` // Permission re-assertion interval in seconds (0 disables)
 
 	// Performance Tuning
-	AllocationTimeout     int `json:"allocation_timeout"`      // Device allocation timeout in seconds
-	DeviceCreationTimeout int `json:"device_creation_timeout"` // Device creation timeout in seconds
-	ShutdownTimeout       int `json:"shutdown_timeout"`        // Graceful shutdown timeout in seconds
-	CleanupTimeout        int `json:"cleanup_timeout"`         // Module cleanup timeout in seconds
+	AllocationTimeout        int `json:"allocation_timeout"`         // Device allocation timeout in seconds
+	AllocationReplayWindow   int `json:"allocation_replay_window"`   // How long Allocate responses are replayed for duplicates in seconds (0 disables)
+	MaxConcurrentAllocations int `json:"max_concurrent_allocations"` // Allocate calls preparing devices at once; others queue (0 = unbounded)
+	DeviceCreationTimeout    int `json:"device_creation_timeout"`    // Device creation timeout in seconds
+	ShutdownTimeout          int `json:"shutdown_timeout"`           // Graceful shutdown timeout in seconds
+	CleanupTimeout           int `json:"cleanup_timeout"`            // Module cleanup timeout in seconds
+	SocketTakeoverTimeout    int `json:"socket_takeover_timeout"`    // Max wait for a live previous instance to release the socket in seconds
+	DevicePrepareTimeout     int `json:"device_prepare_timeout"`     // Max hold of the initial device list for permissions and format priming in seconds
+	DeviceCooldown           int `json:"device_cooldown"`            // Seconds a recreated device is deprioritized by preferred allocation
+
+	// Device Warm-up
+	EnableWarmupProducer bool `json:"enable_warmup_producer"` // Write a placeholder frame until the real producer opens the device
+	WarmupFrameWidth     int  `json:"warmup_frame_width"`     // Placeholder frame width in pixels
+	WarmupFrameHeight    int  `json:"warmup_frame_height"`    // Placeholder frame height in pixels
+	WarmupTimeout        int  `json:"warmup_timeout"`         // Maximum placeholder duration in seconds
+	PreformatDevices     bool `json:"preformat_devices"`      // Set a default YUYV format on idle devices at startup and after recreation
+	PreformatWidth       int  `json:"preformat_width"`        // Default format width in pixels
+	PreformatHeight      int  `json:"preformat_height"`       // Default format height in pixels
+
+	// AV Bundles
+	AVBundleCount             int    `json:"av_bundle_count"`              // Video slots advertised as video+audio bundles (0 disables)
+	AVBundleResourceName      string `json:"av_bundle_resource_name"`      // Resource name for bundles
+	AVBundleSocketPath        string `json:"av_bundle_socket_path"`        // Device plugin socket for bundles
+	ALSACardStartIndex        int    `json:"alsa_card_start_index"`        // ALSA card index paired with the first bundle
+	AVBundleDevicePermissions string `json:"av_bundle_device_permissions"` // Device cgroup permissions for bundle devices
 
 	// Fallback Configuration
 	EnableFallbackMode   bool   `json:"enable_fallback_mode"`   // Enable fallback mode when kernel modules fail
+	EnableDevCheck       bool   `json:"enable_dev_check"`       // Verify at startup that /dev is the host devtmpfs
 	FallbackDevicePrefix string `json:"fallback_device_prefix"` // Prefix for dummy device paths
+	FallbackGranularity  string `json:"fallback_granularity"`   // node (whole pool on module failure) or device (also each missing slot)
 	FallbackModeReason   string `json:"fallback_mode_reason"`   // Reason for entering fallback mode
 }
 
@@ -71,6 +238,18 @@ type V4L2Manager interface {
 	// GetDeviceHealth returns health status for a specific device
 	GetDeviceHealth(deviceID string) bool
 
+	// GetDeviceCheck returns the result of a device's last health check, false if it was never checked
+	GetDeviceCheck(deviceID string) (DeviceCheck, bool)
+
+	// GetSkippedDevices returns devices that were unusable at discovery, keyed by ID with the reason
+	GetSkippedDevices() map[string]string
+
+	// ReconcilePermissions re-applies configured permissions/ownership and returns corrected device IDs
+	ReconcilePermissions() []string
+
+	// PermissionMismatches returns devices whose mode or group differs from the configured one, with the mismatch
+	PermissionMismatches() map[string]string
+
 	// IsFallbackMode returns true if the manager is in fallback mode
 	IsFallbackMode() bool
 
@@ -80,14 +259,36 @@ type V4L2Manager interface {
 	// EnableFallbackMode enables fallback mode and creates dummy devices
 	EnableFallbackMode(reason string, count int) error
 
+	// RefreshDevice re-reads metadata after a device was recreated and bumps its generation
+	RefreshDevice(deviceID string) error
+
+	// SetChangeHook registers a function called after every bookkeeping mutation
+	SetChangeHook(fn func())
+
+	// SetHealthHistory registers the history that records health transitions and damps flapping devices
+	SetHealthHistory(history *HealthHistory)
+
+	// SetSlotFallback makes CreateDevices give each missing slot a fallback placeholder
+	SetSlotFallback(enabled bool)
+
+	// RestoreDevices applies device bookkeeping saved by a previous instance and returns how many devices matched
+	RestoreDevices(saved []VideoDevice) int
+
+	// SetMaxBuffers records the buffer count a device was recreated with
+	SetMaxBuffers(deviceID string, maxBuffers int) error
+
+	// InvalidateDevices drops all devices before a module reload and returns the new module generation
+	// Devices rediscovered by CreateDevices continue from their previous generation
+	InvalidateDevices() int
+
 	// CleanupFallbackDevices removes the fallback device files
 	CleanupFallbackDevices()
 }
 
 // DevicePluginServer interface for the gRPC device plugin server
 type DevicePluginServer interface {
-	// Start starts the device plugin server
-	Start() error
+	// Start starts the device plugin server; ctx bounds startup and the plugin loops
+	Start(ctx context.Context) error
 
 	// Stop stops the device plugin server
 	Stop() error
@@ -96,16 +297,41 @@ type DevicePluginServer interface {
 	WaitForShutdown()
 
 	// RegisterWithKubelet registers the device plugin with kubelet
-	RegisterWithKubelet() error
+	RegisterWithKubelet(ctx context.Context) error
+}
+
+// DeviceCheck is the result of one device health check
+type DeviceCheck struct {
+	Healthy   bool
+	Error     string // Why the device is unhealthy, empty when healthy
+	CheckedAt time.Time
 }
 
 // HealthCheck represents the health status of the device plugin
+// Version follows healthStatusVersion; see healthStatusSchema for the layout
 type HealthCheck struct {
-	Healthy      bool      `json:"healthy"`
-	V4L2Healthy  bool      `json:"v4l2_healthy"`
-	DevicesReady bool      `json:"devices_ready"`
-	LastChecked  time.Time `json:"last_checked"`
-	Errors       []string  `json:"errors,omitempty"`
+	Version      int                  `json:"version"`
+	Healthy      bool                 `json:"healthy"`
+	V4L2Healthy  bool                 `json:"v4l2_healthy"`
+	DevicesReady bool                 `json:"devices_ready"`
+	LastChecked  time.Time            `json:"last_checked"`
+	Errors       []string             `json:"errors,omitempty"`
+	Devices      []DeviceHealthStatus `json:"devices,omitempty"`
+
+	PendingModuleChange string                  `json:"pending_module_change,omitempty"` // Module change deferred or refused while devices are allocated
+	KubeletEndpoints    []KubeletEndpointStatus `json:"kubelet_endpoints,omitempty"`     // Registration per kubelet, only with ADDITIONAL_KUBELET_SOCKETS
+}
+
+// DeviceHealthStatus is the health of one video device
+type DeviceHealthStatus struct {
+	ID           string     `json:"id"`
+	Path         string     `json:"path"`
+	Role         string     `json:"role"`
+	Healthy      bool       `json:"healthy"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastChecked  *time.Time `json:"last_checked,omitempty"` // Unset until the first health check
+	Allocated    bool       `json:"allocated"`
+	AllocatedPod string     `json:"allocated_pod,omitempty"` // namespace/name, or the owner of a local lease
 }
 
 // ModuleLoadError represents an error that occurred during kernel module loading
@@ -115,6 +341,9 @@ type ModuleLoadError struct {
 	Original             error  `json:"-"`                                // Omit from JSON to avoid serialization issues and potential leaks
 	OriginalErrorMessage string `json:"original_error_message,omitempty"` // String representation for JSON logging
 	CanFallback          bool   `json:"can_fallback"`
+
+	// Kernel log findings collected at failure time (nil when not collected)
+	Diagnostics *ModuleDiagnostics `json:"diagnostics,omitempty"`
 }
 
 func (e *ModuleLoadError) Error() string {
@@ -124,3 +353,27 @@ func (e *ModuleLoadError) Error() string {
 func (e *ModuleLoadError) Unwrap() error {
 	return e.Original
 }
+
+// FallbackReason returns the reason annotated with any classified kernel log signatures
+func (e *ModuleLoadError) FallbackReason() string {
+	if e.Diagnostics == nil || len(e.Diagnostics.Signatures) == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s [%s]", e.Reason, strings.Join(e.Diagnostics.Signatures, ","))
+}
+
+// EventMessage summarizes the failure for a Kubernetes event: the reason and each classified
+// kernel log signature with its remediation
+func (e *ModuleLoadError) EventMessage() string {
+	message := fmt.Sprintf("Failed to load %s: %s", e.Module, e.Reason)
+	if e.Diagnostics == nil {
+		return message
+	}
+	if e.Diagnostics.Unavailable != "" {
+		return message + "; kernel log unavailable: " + e.Diagnostics.Unavailable
+	}
+	for i, signature := range e.Diagnostics.Signatures {
+		message += fmt.Sprintf("; %s: %s", signature, e.Diagnostics.Remediation[i])
+	}
+	return message
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// udevRulesHeader marks files generated by the plugin so removal never touches foreign rules
+const udevRulesHeader = "# Generated by video-device-plugin - do not edit, removed on shutdown"
+
+// renderUdevRules builds the rules for the loopback device range
+func renderUdevRules(config *DevicePluginConfig) string {
+	var b strings.Builder
+	b.WriteString(udevRulesHeader + "\n")
+
+	for i := 0; i < config.MaxDevices; i++ {
+		kernelName := fmt.Sprintf("video%d", config.VideoDeviceStart+i)
+		fmt.Fprintf(&b, `SUBSYSTEM=="video4linux", KERNEL=="%s", MODE="%04o"`, kernelName, config.V4L2DevicePerm)
+		if config.V4L2DeviceGID >= 0 {
+			fmt.Fprintf(&b, `, GROUP="%d"`, config.V4L2DeviceGID)
+		}
+		fmt.Fprintf(&b, `, SYMLINK+="video-device-plugin/%s"`+"\n", kernelName)
+	}
+
+	return b.String()
+}
+
+// installUdevRules writes the rules file atomically and asks udev to reload it
+func installUdevRules(config *DevicePluginConfig, logger *slog.Logger) error {
+	path := config.UdevRulesPath
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create udev rules directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := privilegedWriteFile(tmpPath, []byte(renderUdevRules(config)), 0o644); err != nil {
+		return fmt.Errorf("failed to write udev rules: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to install udev rules: %w", err)
+	}
+
+	logger.Info("Installed udev rules", "path", path, "devices", config.MaxDevices)
+	reloadUdevRules(logger)
+	return nil
+}
+
+// removeUdevRules deletes the rules file if it was generated by the plugin
+func removeUdevRules(config *DevicePluginConfig, logger *slog.Logger) {
+	path := config.UdevRulesPath
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read udev rules for removal", "path", path, "error", err)
+		}
+		return
+	}
+
+	if !strings.HasPrefix(string(content), udevRulesHeader) {
+		logger.Warn("Refusing to remove udev rules not generated by the plugin", "path", path)
+		return
+	}
+
+	if err := privilegedRemove(path); err != nil {
+		logger.Warn("Failed to remove udev rules", "path", path, "error", err)
+		return
+	}
+
+	logger.Info("Removed udev rules", "path", path)
+	reloadUdevRules(logger)
+}
+
+// reloadUdevRules reloads udev rules and re-triggers video4linux devices (best effort)
+func reloadUdevRules(logger *slog.Logger) {
+	commands := [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-match=video4linux"},
+	}
+
+	for _, args := range commands {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		out, err := privilegedCommand(ctx, args[0], args[1:]...)
+		cancel()
+		if err != nil {
+			logger.Warn("udevadm command failed; rules apply on the next udev event",
+				"command", strings.Join(args, " "),
+				"error", err,
+				"output", strings.TrimSpace(string(out)))
+			return
+		}
+	}
+}
@@ -1,47 +1,68 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Constants for video device management
 const (
-	// VideoDeviceStartNumber is the first video device number to use
+	// VideoDeviceStartNumber is the default first video device number to use
 	// Starting from 10 to avoid conflicts with system video devices (video0-9)
 	VideoDeviceStartNumber = 10
 )
 
 // setupLogger creates and configures a structured logger
 func setupLogger(level string) *slog.Logger {
-	var logLevel slog.Level
+	setLogLevel(level)
+
+	opts := &slog.HandlerOptions{
+		Level:     logLevel,
+		AddSource: true,
+	}
+
+	// Recent records are kept for the diagnostics bundle written on fatal errors
+	handler := slog.NewJSONHandler(io.MultiWriter(os.Stdout, recentLogs), opts)
+	return slog.New(handler)
+}
+
+// logLevel is the process-wide log level, adjustable at runtime
+var logLevel = new(slog.LevelVar)
+
+// parseLogLevel maps a level name to a slog level
+func parseLogLevel(level string) (slog.Level, bool) {
 	switch strings.ToLower(level) {
 	case "debug":
-		logLevel = slog.LevelDebug
+		return slog.LevelDebug, true
 	case "info":
-		logLevel = slog.LevelInfo
+		return slog.LevelInfo, true
 	case "warn":
-		logLevel = slog.LevelWarn
+		return slog.LevelWarn, true
 	case "error":
-		logLevel = slog.LevelError
+		return slog.LevelError, true
 	default:
-		logLevel = slog.LevelInfo
-	}
-
-	opts := &slog.HandlerOptions{
-		Level:     logLevel,
-		AddSource: true,
+		return slog.LevelInfo, false
 	}
+}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	return slog.New(handler)
+// setLogLevel changes the process-wide log level; unknown names fall back to info
+func setLogLevel(level string) {
+	parsed, _ := parseLogLevel(level)
+	logLevel.Set(parsed)
 }
 
 // loadConfig loads configuration from environment variables
@@ -51,40 +72,195 @@ func loadConfig() *DevicePluginConfig {
 
 	config := &DevicePluginConfig{
 		// Core Configuration
-		MaxDevices:    getEnvInt("MAX_DEVICES", 8),
-		NodeName:      getEnv("NODE_NAME", ""),
-		KubeletSocket: getEnv("KUBELET_SOCKET", "/var/lib/kubelet/device-plugins/kubelet.sock"),
-		ResourceName:  getEnv("RESOURCE_NAME", "meeting-baas.io/video-devices"),
-		SocketPath:    getEnv("SOCKET_PATH", "/var/lib/kubelet/device-plugins/video-device-plugin.sock"),
-		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		MaxDevices:           getEnvInt("MAX_DEVICES", 8),
+		HotSpareCount:        getEnvInt("HOT_SPARE_COUNT", 0),
+		DeviceTiers:          getEnv("DEVICE_TIERS", ""),
+		ExcludedDevices:      getEnv("EXCLUDED_DEVICES", ""),
+		NodeName:             getEnv("NODE_NAME", ""),
+		KubeletSocket:        getEnv("KUBELET_SOCKET", "/var/lib/kubelet/device-plugins/kubelet.sock"),
+		AdditionalKubelets:   getEnv("ADDITIONAL_KUBELET_SOCKETS", ""),
+		ResourceName:         getEnv("RESOURCE_NAME", "meeting-baas.io/video-devices"),
+		SocketPath:           getEnv("SOCKET_PATH", "/var/lib/kubelet/device-plugins/video-device-plugin.sock"),
+		RegistrationMode:     getEnv("REGISTRATION_MODE", RegistrationModeDirect),
+		PluginRegistryDir:    getEnv("PLUGIN_REGISTRY_DIR", "/var/lib/kubelet/plugins_registry"),
+		EnableSocketTakeover: getEnvBool("ENABLE_SOCKET_TAKEOVER", false),
+		TakeoverSocketPath:   getEnv("TAKEOVER_SOCKET_PATH", "/var/lib/video-device-plugin/takeover.sock"),
+		LogLevel:             getEnv("LOG_LEVEL", "info"),
+		AuditLogPath:         getEnv("AUDIT_LOG_PATH", ""),
+		Mode:                 getEnv("MODE", "plugin"),
 
 		// Development/Debugging
-		Debug: getEnvBool("DEBUG", false),
+		Debug:     getEnvBool("DEBUG", false),
+		GRPCTrace: getEnvBool("GRPC_TRACE", false),
+
+		// gRPC Server Tuning
+		GRPCMaxRecvMsgSize:       getEnvInt("GRPC_MAX_RECV_MSG_SIZE", 0),
+		GRPCMaxSendMsgSize:       getEnvInt("GRPC_MAX_SEND_MSG_SIZE", 0),
+		GRPCMaxConcurrentStreams: getEnvInt("GRPC_MAX_CONCURRENT_STREAMS", 0),
+		GRPCWriteBufferSize:      getEnvInt("GRPC_WRITE_BUFFER_SIZE", 0),
 
 		// V4L2 Configuration
-		V4L2MaxBuffers:    getEnvInt("V4L2_MAX_BUFFERS", 2),
-		V4L2ExclusiveCaps: getEnvInt("V4L2_EXCLUSIVE_CAPS", 1),
-		V4L2CardLabel:     getEnv("V4L2_CARD_LABEL", "Default WebCam"),
-		V4L2DevicePerm:    getEnvPerm("V4L2_DEVICE_PERM", 0666),
+		V4L2MaxBuffers:         getEnvInt("V4L2_MAX_BUFFERS", 2),
+		V4L2ExclusiveCaps:      getEnvInt("V4L2_EXCLUSIVE_CAPS", 1),
+		V4L2CardLabel:          getEnv("V4L2_CARD_LABEL", "Default WebCam"),
+		V4L2DevicePerm:         getEnvPerm("V4L2_DEVICE_PERM", 0666),
+		V4L2DeviceGID:          getEnvInt("V4L2_DEVICE_GID", -1),
+		VideoDevicePermissions: getEnv("VIDEO_DEVICE_PERMISSIONS", "rw"),
+		ManageModule:           getEnvBool("MANAGE_MODULE", true),
+		KeepModuleOnExit:       getEnvBool("KEEP_MODULE_ON_EXIT", false),
+		ModuleUnloadMode:       getEnv("MODULE_UNLOAD_MODE", ModuleUnloadImmediate),
+		ModuleUnloadDeadline:   getEnvInt("MODULE_UNLOAD_DEADLINE", 300),
+		ModuleChangePolicy:     getEnv("MODULE_CHANGE_POLICY", ModuleChangeDefer),
+		ModuleExecMode:         getEnv("MODULE_EXEC_MODE", ModuleExecAuto),
+		ModuleLockPath:         getEnv("MODULE_LOCK_PATH", "/var/lib/video-device-plugin/module.lock"),
+		ModuleLockTimeout:      getEnvInt("MODULE_LOCK_TIMEOUT", 120),
+		EnableBufferRecovery:   getEnvBool("ENABLE_BUFFER_RECOVERY", true),
+		VideoDeviceStart:       getEnvInt("VIDEO_DEVICE_START", VideoDeviceStartNumber),
+		VideoDeviceCeiling:     getEnvInt("VIDEO_DEVICE_CEILING", 63),
+		StateDir:               getEnv("STATE_DIR", "/var/lib/video-device-plugin"),
+		EnableCheckpoint:       getEnvBool("ENABLE_CHECKPOINT", true),
+
+		// Udev Integration
+		EnableUdevRules: getEnvBool("ENABLE_UDEV_RULES", false),
+		UdevRulesPath:   getEnv("UDEV_RULES_PATH", "/etc/udev/rules.d/60-video-device-plugin.rules"),
+
+		// Device Preparation
+		DeviceHooksFile:      getEnv("DEVICE_HOOKS_FILE", ""),
+		HandoffDir:           getEnv("HANDOFF_DIR", ""),
+		HandoffContainerPath: getEnv("HANDOFF_CONTAINER_PATH", "/var/run/video-device-plugin/handoff.json"),
+		EnableSysfsMount:     getEnvBool("ENABLE_SYSFS_MOUNT", false),
+		SysfsContainerPath:   getEnv("SYSFS_CONTAINER_PATH", "/sys/class/video4linux"),
+		EnableLabelStamping:  getEnvBool("ENABLE_LABEL_STAMPING", false),
+		LabelStampAnnotation: getEnv("LABEL_STAMP_ANNOTATION", "meeting-baas.io/meeting-id"),
+		TopologyNUMANode:     getEnvInt("TOPOLOGY_NUMA_NODE", -1),
+		TopologyCPUs:         getEnv("TOPOLOGY_CPUS", ""),
+
+		// Admin API
+		EnableAdminAPI:        getEnvBool("ENABLE_ADMIN_API", false),
+		AdminSocketPath:       getEnv("ADMIN_SOCKET_PATH", "/var/lib/video-device-plugin/admin.sock"),
+		AdminAuthMode:         getEnv("ADMIN_AUTH_MODE", AdminAuthNone),
+		AdminAllowedUIDs:      getEnv("ADMIN_ALLOWED_UIDS", "0"),
+		AdminAllowedGIDs:      getEnv("ADMIN_ALLOWED_GIDS", ""),
+		AdminTLSCertFile:      getEnv("ADMIN_TLS_CERT_FILE", ""),
+		AdminTLSKeyFile:       getEnv("ADMIN_TLS_KEY_FILE", ""),
+		AdminTLSClientCAFile:  getEnv("ADMIN_TLS_CLIENT_CA_FILE", ""),
+		AdminAllowedSPIFFEIDs: getEnv("ADMIN_ALLOWED_SPIFFE_IDS", ""),
+		LeaseDefaultTTL:       getEnvInt("LEASE_DEFAULT_TTL", 300),
+		LeaseMaxTTL:           getEnvInt("LEASE_MAX_TTL", 3600),
 
 		// Kubernetes Integration
-		KubernetesNamespace: getEnv("KUBERNETES_NAMESPACE", "kube-system"),
-		ServiceAccountName:  getEnv("SERVICE_ACCOUNT_NAME", "video-device-plugin"),
+		EnableKubernetesAPI:   getEnvBool("ENABLE_KUBERNETES_API", kubernetesAPIBuilt),
+		KubernetesNamespace:   getEnv("KUBERNETES_NAMESPACE", "kube-system"),
+		ServiceAccountName:    getEnv("SERVICE_ACCOUNT_NAME", "video-device-plugin"),
+		EnableNodeCondition:   getEnvBool("ENABLE_NODE_CONDITION", false),
+		NodeConditionType:     getEnv("NODE_CONDITION_TYPE", "VideoDevicesReady"),
+		ConfigMapName:         getEnv("CONFIGMAP_NAME", ""),
+		EnableEvents:          getEnvBool("ENABLE_EVENTS", false),
+		EnablePodWatch:        getEnvBool("ENABLE_POD_WATCH", false),
+		PodWatchNamespace:     getEnv("POD_WATCH_NAMESPACE", ""),
+		PodWatchLabelSelector: getEnv("POD_WATCH_LABEL_SELECTOR", ""),
+		PodWatchFieldSelector: getEnv("POD_WATCH_FIELD_SELECTOR", ""),
+		PodWatchResync:        getEnvInt("POD_WATCH_RESYNC", 300),
+		PodReleaseRate:        getEnvInt("POD_RELEASE_RATE", 5),
+		PodResourcesSocket:    getEnv("POD_RESOURCES_SOCKET", "/var/lib/kubelet/pod-resources/kubelet.sock"),
+		EnableExhaustionWatch: getEnvBool("ENABLE_EXHAUSTION_WATCH", false),
+		EnableDeviceAffinity:  getEnvBool("ENABLE_DEVICE_AFFINITY", false),
+
+		// Pod Readiness Gate
+		EnablePodReadinessGate:    getEnvBool("ENABLE_POD_READINESS_GATE", false),
+		PodReadinessConditionType: getEnv("POD_READINESS_CONDITION_TYPE", "meeting-baas.io/DeviceReady"),
+
+		// Liveness Lease
+		EnableLivenessLease:    getEnvBool("ENABLE_LIVENESS_LEASE", false),
+		LivenessLeaseNamespace: getEnv("LIVENESS_LEASE_NAMESPACE", getEnv("KUBERNETES_NAMESPACE", "kube-system")),
+		LivenessLeaseDuration:  getEnvInt("LIVENESS_LEASE_DURATION", 90),
 
 		// Monitoring and Observability
-		EnableMetrics:       getEnvBool("ENABLE_METRICS", false),
-		MetricsPort:         getEnvInt("METRICS_PORT", 8080),
-		HealthCheckInterval: getEnvInt("HEALTH_CHECK_INTERVAL", 30),
+		EnableMetrics:             getEnvBool("ENABLE_METRICS", false),
+		MetricsPort:               getEnvInt("METRICS_PORT", 8080),
+		MetricsBackends:           getEnv("METRICS_BACKENDS", MetricsBackendPrometheus),
+		MetricsPushInterval:       getEnvInt("METRICS_PUSH_INTERVAL", 10),
+		StatsDAddress:             getEnv("STATSD_ADDRESS", "127.0.0.1:8125"),
+		OTLPMetricsEndpoint:       getEnv("OTLP_METRICS_ENDPOINT", "http://localhost:4318/v1/metrics"),
+		OTLPMetricsHeaders:        getEnv("OTLP_METRICS_HEADERS", ""),
+		HealthCheckInterval:       getEnvInt("HEALTH_CHECK_INTERVAL", 30),
+		MinHealthyDevices:         getEnvInt("MIN_HEALTHY_DEVICES", 0),
+		ProbePort:                 getEnvInt("PROBE_PORT", 0),
+		EnableSystemdNotify:       getEnvBool("ENABLE_SYSTEMD_NOTIFY", true),
+		ConformanceCheckInterval:  getEnvInt("CONFORMANCE_CHECK_INTERVAL", 0),
+		NamespaceQuotas:           getEnv("NAMESPACE_QUOTAS", ""),
+		NamespaceQuotaInterval:    getEnvInt("NAMESPACE_QUOTA_INTERVAL", 0),
+		EnforceNamespaceQuotas:    getEnvBool("ENFORCE_NAMESPACE_QUOTAS", false),
+		NamespaceQuotaWebhook:     getEnv("NAMESPACE_QUOTA_WEBHOOK", ""),
+		PoolDefragWindow:          getEnv("POOL_DEFRAG_WINDOW", ""),
+		HealthHistorySize:         getEnvInt("HEALTH_HISTORY_SIZE", 20),
+		HealthFlapThreshold:       getEnvInt("HEALTH_FLAP_THRESHOLD", 3),
+		HealthFlapWindow:          getEnvInt("HEALTH_FLAP_WINDOW", 300),
+		HealthStabilizationWindow: getEnvInt("HEALTH_STABILIZATION_WINDOW", 300),
+		UnhealthyDevicePolicy:     getEnv("UNHEALTHY_DEVICE_POLICY", UnhealthyPolicyAdvertise),
+		EnableDeviceIDRotation:    getEnvBool("ENABLE_DEVICE_ID_ROTATION", false),
+
+		// Aggregator Mode
+		AggregatorPort:     getEnvInt("AGGREGATOR_PORT", 8090),
+		AggregatorCacheTTL: getEnvInt("AGGREGATOR_CACHE_TTL", 15),
+
+		// Shadow Mode
+		ShadowResourceName: getEnv("SHADOW_RESOURCE_NAME", "meeting-baas.io/video-devices-shadow"),
+		ShadowSocketPath:   getEnv("SHADOW_SOCKET_PATH", "/var/lib/kubelet/device-plugins/video-device-plugin-shadow.sock"),
+
+		// Load Smoothing
+		RegistrationDelay:        getEnvInt("REGISTRATION_DELAY", 0),
+		RegistrationJitter:       getEnvInt("REGISTRATION_JITTER", 0),
+		ReregisterMaxAttempts:    getEnvInt("REREGISTER_MAX_ATTEMPTS", 0),
+		ReregisterInitialBackoff: getEnvInt("REREGISTER_INITIAL_BACKOFF", 5),
+		ReregisterMaxBackoff:     getEnvInt("REREGISTER_MAX_BACKOFF", 60),
+		ListAndWatchJitter:       getEnvInt("LIST_AND_WATCH_JITTER", 0),
+		ListAndWatchHeartbeat:    getEnvInt("LIST_AND_WATCH_HEARTBEAT", 300),
+		HealthCheckJitterPercent: getEnvInt("HEALTH_CHECK_JITTER_PERCENT", 0),
+
+		// Fatal Error Diagnostics
+		EnableDiagnosticsBundle: getEnvBool("ENABLE_DIAGNOSTICS_BUNDLE", true),
+		DiagnosticsDir:          getEnv("DIAGNOSTICS_DIR", "/var/lib/video-device-plugin/diagnostics"),
+		DiagnosticsMaxBundles:   getEnvInt("DIAGNOSTICS_MAX_BUNDLES", 5),
+
+		// Mounted Settings
+		SecretFileReloadInterval: getEnvInt("SECRET_FILE_RELOAD_INTERVAL", 60),
+
+		// Permission Reconciliation
+		PermissionReconcileInterval: getEnvInt("PERMISSION_RECONCILE_INTERVAL", 60),
 
 		// Performance Tuning
-		AllocationTimeout:     getEnvInt("ALLOCATION_TIMEOUT", 30),
-		DeviceCreationTimeout: getEnvInt("DEVICE_CREATION_TIMEOUT", 60),
-		ShutdownTimeout:       getEnvInt("SHUTDOWN_TIMEOUT", 10),
-		CleanupTimeout:        getEnvInt("CLEANUP_TIMEOUT", 15),
+		AllocationTimeout:        getEnvInt("ALLOCATION_TIMEOUT", 30),
+		AllocationReplayWindow:   getEnvInt("ALLOCATION_REPLAY_WINDOW", 600),
+		MaxConcurrentAllocations: getEnvInt("MAX_CONCURRENT_ALLOCATIONS", 1),
+		DeviceCreationTimeout:    getEnvInt("DEVICE_CREATION_TIMEOUT", 60),
+		ShutdownTimeout:          getEnvInt("SHUTDOWN_TIMEOUT", 10),
+		CleanupTimeout:           getEnvInt("CLEANUP_TIMEOUT", 15),
+		SocketTakeoverTimeout:    getEnvInt("SOCKET_TAKEOVER_TIMEOUT", 30),
+		DevicePrepareTimeout:     getEnvInt("DEVICE_PREPARE_TIMEOUT", 30),
+		DeviceCooldown:           getEnvInt("DEVICE_COOLDOWN", 10),
+
+		// Device Warm-up
+		EnableWarmupProducer: getEnvBool("ENABLE_WARMUP_PRODUCER", false),
+		WarmupFrameWidth:     getEnvInt("WARMUP_FRAME_WIDTH", 1280),
+		WarmupFrameHeight:    getEnvInt("WARMUP_FRAME_HEIGHT", 720),
+		WarmupTimeout:        getEnvInt("WARMUP_TIMEOUT", 300),
+		PreformatDevices:     getEnvBool("PREFORMAT_DEVICES", false),
+		PreformatWidth:       getEnvInt("PREFORMAT_WIDTH", 1280),
+		PreformatHeight:      getEnvInt("PREFORMAT_HEIGHT", 720),
+
+		// AV Bundles
+		AVBundleCount:             getEnvInt("AV_BUNDLE_COUNT", 0),
+		AVBundleResourceName:      getEnv("AV_BUNDLE_RESOURCE_NAME", "meeting-baas.io/av-bundle"),
+		AVBundleSocketPath:        getEnv("AV_BUNDLE_SOCKET_PATH", "/var/lib/kubelet/device-plugins/video-device-plugin-av.sock"),
+		ALSACardStartIndex:        getEnvInt("ALSA_CARD_START_INDEX", 10),
+		AVBundleDevicePermissions: getEnv("AV_BUNDLE_DEVICE_PERMISSIONS", "rw"),
 
 		// Fallback Configuration
 		EnableFallbackMode:   getEnvBool("ENABLE_FALLBACK_MODE", true),
+		EnableDevCheck:       getEnvBool("ENABLE_DEV_CHECK", true),
 		FallbackDevicePrefix: getEnv("FALLBACK_DEVICE_PREFIX", "/dev/dummy-video"),
+		FallbackGranularity:  getEnv("FALLBACK_GRANULARITY", FallbackGranularityNode),
 		FallbackModeReason:   "", // Will be set when fallback mode is activated
 	}
 
@@ -96,6 +272,9 @@ func loadConfig() *DevicePluginConfig {
 		config.MaxDevices = 1
 	}
 
+	// Follow the kubelet root of k3s, microk8s and k0s nodes unless paths are set explicitly
+	resolveKubeletRoot(config)
+
 	return config
 }
 
@@ -106,6 +285,15 @@ func loadEnvFile() error {
 		return nil
 	}
 
+	// Remember which variables the file provides; Load never overrides the environment
+	if values, err := godotenv.Read(); err == nil {
+		for key := range values {
+			if _, set := os.LookupEnv(key); !set {
+				envFileKeys[key] = true
+			}
+		}
+	}
+
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		return fmt.Errorf("error loading .env file: %w", err)
@@ -114,32 +302,419 @@ func loadEnvFile() error {
 	return nil
 }
 
+// kubernetesAPIFeatures returns the enabled settings that cannot work without the Kubernetes API
+// Label stamping is not among them: without the API it stamps the pod name instead of the annotation
+func kubernetesAPIFeatures(config *DevicePluginConfig) []string {
+	var features []string
+	for _, feature := range []struct {
+		key     string
+		enabled bool
+	}{
+		{"MODE=aggregator", config.Mode == "aggregator"},
+		{"ENABLE_NODE_CONDITION", config.EnableNodeCondition},
+		{"CONFIGMAP_NAME", config.ConfigMapName != ""},
+		{"ENABLE_EVENTS", config.EnableEvents},
+		{"ENABLE_POD_WATCH", config.EnablePodWatch},
+		{"ENABLE_EXHAUSTION_WATCH", config.EnableExhaustionWatch},
+		{"ENABLE_DEVICE_AFFINITY", config.EnableDeviceAffinity},
+		{"ENABLE_POD_READINESS_GATE", config.EnablePodReadinessGate},
+		{"ENABLE_LIVENESS_LEASE", config.EnableLivenessLease},
+	} {
+		if feature.enabled {
+			features = append(features, feature.key)
+		}
+	}
+	return features
+}
+
 // validateConfig validates the configuration
 func validateConfig(config *DevicePluginConfig) error {
+	if err := secretFileError(); err != nil {
+		return err
+	}
+
 	if config.MaxDevices <= 0 || config.MaxDevices > 8 {
 		return fmt.Errorf("MAX_DEVICES must be between 1 and 8, got %d", config.MaxDevices)
 	}
 
-	if config.NodeName == "" {
+	if config.Mode != "plugin" && config.Mode != "aggregator" && config.Mode != "shadow" {
+		return fmt.Errorf("MODE must be plugin, aggregator or shadow, got %q", config.Mode)
+	}
+
+	// The aggregator runs as a cluster Deployment, not on a specific node
+	if config.NodeName == "" && config.Mode != "aggregator" {
 		return fmt.Errorf("NODE_NAME is required")
 	}
 
+	// A shadow registered under the production name or socket would take over scheduling
+	if config.Mode == "shadow" {
+		if config.ShadowResourceName == "" || config.ShadowResourceName == config.ResourceName {
+			return fmt.Errorf("SHADOW_RESOURCE_NAME must be set and differ from RESOURCE_NAME")
+		}
+		if err := validateResourceName("SHADOW_RESOURCE_NAME", config.ShadowResourceName); err != nil {
+			return err
+		}
+		if config.ShadowSocketPath == "" || config.ShadowSocketPath == config.SocketPath {
+			return fmt.Errorf("SHADOW_SOCKET_PATH must be set and differ from SOCKET_PATH")
+		}
+	}
+
 	if config.ResourceName == "" {
 		return fmt.Errorf("RESOURCE_NAME is required")
 	}
+	if err := validateResourceName("RESOURCE_NAME", config.ResourceName); err != nil {
+		return err
+	}
+
+	// Without API access only the kubelet device plugin path runs; features needing more are refused
+	if config.EnableKubernetesAPI && !kubernetesAPIBuilt {
+		return fmt.Errorf("ENABLE_KUBERNETES_API requires a build with Kubernetes API support, this binary was built with -tags minimal")
+	}
+	if features := kubernetesAPIFeatures(config); !config.EnableKubernetesAPI && len(features) > 0 {
+		if !kubernetesAPIBuilt {
+			return fmt.Errorf("%s need the Kubernetes API, which this binary was built without (-tags minimal)", strings.Join(features, ", "))
+		}
+		return fmt.Errorf("%s need the Kubernetes API, which ENABLE_KUBERNETES_API=false disables", strings.Join(features, ", "))
+	}
 
 	if config.SocketPath == "" {
 		return fmt.Errorf("SOCKET_PATH is required")
 	}
 
+	if !usesDirectRegistration(config) && !usesWatcherRegistration(config) {
+		return fmt.Errorf("REGISTRATION_MODE must be direct, watcher or both, got %q", config.RegistrationMode)
+	}
+	if usesWatcherRegistration(config) && config.PluginRegistryDir == "" {
+		return fmt.Errorf("PLUGIN_REGISTRY_DIR is required when REGISTRATION_MODE=%s", config.RegistrationMode)
+	}
+	if _, err := parseAdditionalKubeletSockets(config); err != nil {
+		return err
+	}
+	if config.EnableSocketTakeover && config.TakeoverSocketPath == "" {
+		return fmt.Errorf("TAKEOVER_SOCKET_PATH is required when ENABLE_SOCKET_TAKEOVER=true")
+	}
+
 	if config.HealthCheckInterval <= 0 {
 		return fmt.Errorf("HEALTH_CHECK_INTERVAL must be > 0 seconds, got %d", config.HealthCheckInterval)
 	}
 
+	if config.MinHealthyDevices < 0 || config.MinHealthyDevices > config.MaxDevices {
+		return fmt.Errorf("MIN_HEALTHY_DEVICES must be between 0 and MAX_DEVICES (%d), got %d", config.MaxDevices, config.MinHealthyDevices)
+	}
+
+	if _, err := parseMetricsBackends(config.MetricsBackends); err != nil {
+		return err
+	}
+	if config.MetricsPushInterval < 1 {
+		return fmt.Errorf("METRICS_PUSH_INTERVAL must be at least 1 second, got %d", config.MetricsPushInterval)
+	}
+	if hasMetricsBackend(config, MetricsBackendStatsD) {
+		if _, _, err := net.SplitHostPort(config.StatsDAddress); err != nil {
+			return fmt.Errorf("STATSD_ADDRESS must be host:port, got %q", config.StatsDAddress)
+		}
+	}
+	if hasMetricsBackend(config, MetricsBackendOTLP) {
+		if err := validateOTLPEndpoint(config.OTLPMetricsEndpoint); err != nil {
+			return err
+		}
+		if _, err := parseOTLPHeaders(config.OTLPMetricsHeaders); err != nil {
+			return err
+		}
+	}
+
+	if config.ProbePort < 0 || config.ProbePort > 65535 {
+		return fmt.Errorf("PROBE_PORT must be 0 (disabled) or a valid port, got %d", config.ProbePort)
+	}
+
+	if config.EnableNodeCondition && config.NodeConditionType == "" {
+		return fmt.Errorf("NODE_CONDITION_TYPE is required when ENABLE_NODE_CONDITION=true")
+	}
+
+	if config.EnableUdevRules && !strings.HasSuffix(config.UdevRulesPath, ".rules") {
+		return fmt.Errorf("UDEV_RULES_PATH must end in .rules, got %q", config.UdevRulesPath)
+	}
+
+	if config.EnableAdminAPI {
+		if config.AdminSocketPath == "" {
+			return fmt.Errorf("ADMIN_SOCKET_PATH is required when ENABLE_ADMIN_API=true")
+		}
+		if config.LeaseDefaultTTL <= 0 || config.LeaseDefaultTTL > config.LeaseMaxTTL {
+			return fmt.Errorf("LEASE_DEFAULT_TTL must be between 1 and LEASE_MAX_TTL (%d), got %d", config.LeaseMaxTTL, config.LeaseDefaultTTL)
+		}
+	}
+
+	switch config.AdminAuthMode {
+	case AdminAuthNone:
+	case AdminAuthPeerCred:
+		uids, err := parseAdminIDs(config.AdminAllowedUIDs, "ADMIN_ALLOWED_UIDS")
+		if err != nil {
+			return err
+		}
+		gids, err := parseAdminIDs(config.AdminAllowedGIDs, "ADMIN_ALLOWED_GIDS")
+		if err != nil {
+			return err
+		}
+		if len(uids) == 0 && len(gids) == 0 {
+			return fmt.Errorf("ADMIN_ALLOWED_UIDS or ADMIN_ALLOWED_GIDS is required when ADMIN_AUTH_MODE=peercred")
+		}
+	case AdminAuthTLS:
+		if config.AdminTLSCertFile == "" || config.AdminTLSKeyFile == "" || config.AdminTLSClientCAFile == "" {
+			return fmt.Errorf("ADMIN_TLS_CERT_FILE, ADMIN_TLS_KEY_FILE and ADMIN_TLS_CLIENT_CA_FILE are required when ADMIN_AUTH_MODE=tls")
+		}
+		ids, err := parseAdminSPIFFEIDs(config.AdminAllowedSPIFFEIDs)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("ADMIN_ALLOWED_SPIFFE_IDS is required when ADMIN_AUTH_MODE=tls")
+		}
+	default:
+		return fmt.Errorf("ADMIN_AUTH_MODE must be none, peercred or tls, got %q", config.AdminAuthMode)
+	}
+
+	// Linux allocates at most 256 video4linux minors
+	if config.VideoDeviceCeiling > 255 {
+		return fmt.Errorf("VIDEO_DEVICE_CEILING must be <= 255, got %d", config.VideoDeviceCeiling)
+	}
+	if config.VideoDeviceStart < 0 || config.VideoDeviceStart+config.MaxDevices-1 > config.VideoDeviceCeiling {
+		return fmt.Errorf("VIDEO_DEVICE_START (%d) + MAX_DEVICES (%d) must fit below VIDEO_DEVICE_CEILING (%d)", config.VideoDeviceStart, config.MaxDevices, config.VideoDeviceCeiling)
+	}
+
+	if !validCgroupPermissions(config.VideoDevicePermissions) {
+		return fmt.Errorf("VIDEO_DEVICE_PERMISSIONS must be a combination of r, w and m (e.g., r, rw, rwm), got %q", config.VideoDevicePermissions)
+	}
+	if !validCgroupPermissions(config.AVBundleDevicePermissions) {
+		return fmt.Errorf("AV_BUNDLE_DEVICE_PERMISSIONS must be a combination of r, w and m (e.g., r, rw, rwm), got %q", config.AVBundleDevicePermissions)
+	}
+
+	if config.V4L2DeviceGID < -1 {
+		return fmt.Errorf("V4L2_DEVICE_GID must be -1 (unchanged) or a valid group ID, got %d", config.V4L2DeviceGID)
+	}
+
+	if config.RegistrationDelay < 0 || config.RegistrationJitter < 0 || config.ListAndWatchJitter < 0 || config.ListAndWatchHeartbeat < 0 {
+		return fmt.Errorf("REGISTRATION_DELAY, REGISTRATION_JITTER, LIST_AND_WATCH_JITTER and LIST_AND_WATCH_HEARTBEAT must be >= 0 seconds")
+	}
+
+	if config.ConformanceCheckInterval < 0 {
+		return fmt.Errorf("CONFORMANCE_CHECK_INTERVAL must be >= 0 seconds, got %d", config.ConformanceCheckInterval)
+	}
+	if config.NamespaceQuotaInterval < 0 {
+		return fmt.Errorf("NAMESPACE_QUOTA_INTERVAL must be >= 0 seconds, got %d", config.NamespaceQuotaInterval)
+	}
+	if _, err := parseNamespaceQuotas(config); err != nil {
+		return err
+	}
+	if config.PoolDefragWindow != "" {
+		if _, _, err := parsePoolDefragWindow(config.PoolDefragWindow); err != nil {
+			return err
+		}
+	}
+	if config.EnforceNamespaceQuotas && config.NamespaceQuotas == "" && config.NamespaceQuotaWebhook == "" {
+		return fmt.Errorf("NAMESPACE_QUOTAS or NAMESPACE_QUOTA_WEBHOOK is required when ENFORCE_NAMESPACE_QUOTAS=true")
+	}
+	if webhook := config.NamespaceQuotaWebhook; webhook != "" {
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("NAMESPACE_QUOTA_WEBHOOK must be an http(s) URL, got %q", webhook)
+		}
+	}
+	if config.UnhealthyDevicePolicy != UnhealthyPolicyAdvertise && config.UnhealthyDevicePolicy != UnhealthyPolicyRemove {
+		return fmt.Errorf("UNHEALTHY_DEVICE_POLICY must be advertise or remove, got %q", config.UnhealthyDevicePolicy)
+	}
+	if config.HealthHistorySize < 1 {
+		return fmt.Errorf("HEALTH_HISTORY_SIZE must be >= 1, got %d", config.HealthHistorySize)
+	}
+	if config.HealthFlapThreshold < 0 {
+		return fmt.Errorf("HEALTH_FLAP_THRESHOLD must be >= 0, got %d", config.HealthFlapThreshold)
+	}
+	if config.HealthFlapThreshold > 0 {
+		if config.HealthFlapThreshold > config.HealthHistorySize {
+			return fmt.Errorf("HEALTH_FLAP_THRESHOLD (%d) cannot exceed HEALTH_HISTORY_SIZE (%d)", config.HealthFlapThreshold, config.HealthHistorySize)
+		}
+		if config.HealthFlapWindow < 1 {
+			return fmt.Errorf("HEALTH_FLAP_WINDOW must be >= 1 second, got %d", config.HealthFlapWindow)
+		}
+		if config.HealthStabilizationWindow < 1 {
+			return fmt.Errorf("HEALTH_STABILIZATION_WINDOW must be >= 1 second, got %d", config.HealthStabilizationWindow)
+		}
+	}
+
+	if config.HandoffDir != "" && !filepath.IsAbs(config.HandoffContainerPath) {
+		return fmt.Errorf("HANDOFF_CONTAINER_PATH must be an absolute path, got %q", config.HandoffContainerPath)
+	}
+	if config.EnableSysfsMount && !filepath.IsAbs(config.SysfsContainerPath) {
+		return fmt.Errorf("SYSFS_CONTAINER_PATH must be an absolute path, got %q", config.SysfsContainerPath)
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"GRPC_MAX_RECV_MSG_SIZE", config.GRPCMaxRecvMsgSize},
+		{"GRPC_MAX_SEND_MSG_SIZE", config.GRPCMaxSendMsgSize},
+		{"GRPC_MAX_CONCURRENT_STREAMS", config.GRPCMaxConcurrentStreams},
+		{"GRPC_WRITE_BUFFER_SIZE", config.GRPCWriteBufferSize},
+	} {
+		if limit.value < 0 {
+			return fmt.Errorf("%s must be >= 0 (0 keeps the gRPC default), got %d", limit.key, limit.value)
+		}
+	}
+
+	if config.EnablePodReadinessGate && config.PodReadinessConditionType == "" {
+		return fmt.Errorf("POD_READINESS_CONDITION_TYPE is required when ENABLE_POD_READINESS_GATE is set")
+	}
+
+	if config.TopologyNUMANode < -1 {
+		return fmt.Errorf("TOPOLOGY_NUMA_NODE must be -1 (derive) or a node number, got %d", config.TopologyNUMANode)
+	}
+	if config.TopologyCPUs != "" {
+		if _, err := parseCPUList(config.TopologyCPUs); err != nil {
+			return fmt.Errorf("TOPOLOGY_CPUS must be a CPU list such as 0-7,16-23: %w", err)
+		}
+	}
+
+	if config.EnablePodWatch {
+		if config.NodeName == "" {
+			return fmt.Errorf("NODE_NAME is required when ENABLE_POD_WATCH is set")
+		}
+		if _, _, err := podWatchSelectors(config); err != nil {
+			return err
+		}
+		if config.PodWatchResync < 0 {
+			return fmt.Errorf("POD_WATCH_RESYNC must be >= 0, got %d", config.PodWatchResync)
+		}
+		if config.PodReleaseRate < 1 {
+			return fmt.Errorf("POD_RELEASE_RATE must be >= 1 per second, got %d", config.PodReleaseRate)
+		}
+	}
+
+	if config.ReregisterMaxAttempts < 0 {
+		return fmt.Errorf("REREGISTER_MAX_ATTEMPTS must be >= 0, got %d", config.ReregisterMaxAttempts)
+	}
+	if config.ReregisterInitialBackoff <= 0 || config.ReregisterMaxBackoff < config.ReregisterInitialBackoff {
+		return fmt.Errorf("REREGISTER_INITIAL_BACKOFF must be > 0 and <= REREGISTER_MAX_BACKOFF, got %d and %d", config.ReregisterInitialBackoff, config.ReregisterMaxBackoff)
+	}
+
+	if config.HealthCheckJitterPercent < 0 || config.HealthCheckJitterPercent > 50 {
+		return fmt.Errorf("HEALTH_CHECK_JITTER_PERCENT must be 0-50, got %d", config.HealthCheckJitterPercent)
+	}
+
+	switch config.ModuleExecMode {
+	case ModuleExecAuto, ModuleExecContainer, ModuleExecNsenter:
+	default:
+		return fmt.Errorf("MODULE_EXEC_MODE must be auto, container or nsenter, got %q", config.ModuleExecMode)
+	}
+
+	switch config.FallbackGranularity {
+	case FallbackGranularityNode, FallbackGranularityDevice:
+	default:
+		return fmt.Errorf("FALLBACK_GRANULARITY must be node or device, got %q", config.FallbackGranularity)
+	}
+
+	switch config.ModuleUnloadMode {
+	case ModuleUnloadImmediate, ModuleUnloadDeferred:
+	default:
+		return fmt.Errorf("MODULE_UNLOAD_MODE must be immediate or deferred, got %q", config.ModuleUnloadMode)
+	}
+	switch config.ModuleChangePolicy {
+	case ModuleChangeDefer, ModuleChangeRefuse:
+	default:
+		return fmt.Errorf("MODULE_CHANGE_POLICY must be defer or refuse, got %q", config.ModuleChangePolicy)
+	}
+	if config.ModuleUnloadMode == ModuleUnloadDeferred && config.ModuleUnloadDeadline < 1 {
+		return fmt.Errorf("MODULE_UNLOAD_DEADLINE must be at least 1 second, got %d", config.ModuleUnloadDeadline)
+	}
+
+	if config.ModuleLockPath != "" && config.ModuleLockTimeout <= 0 {
+		return fmt.Errorf("MODULE_LOCK_TIMEOUT must be > 0 seconds, got %d", config.ModuleLockTimeout)
+	}
+
+	if config.DeviceCooldown < 0 {
+		return fmt.Errorf("DEVICE_COOLDOWN must be >= 0 seconds, got %d", config.DeviceCooldown)
+	}
+
+	if config.MaxConcurrentAllocations < 0 {
+		return fmt.Errorf("MAX_CONCURRENT_ALLOCATIONS must be >= 0, got %d", config.MaxConcurrentAllocations)
+	}
+
+	if config.AllocationTimeout <= 0 {
+		return fmt.Errorf("ALLOCATION_TIMEOUT must be > 0 seconds, got %d", config.AllocationTimeout)
+	}
+
+	if config.DevicePrepareTimeout <= 0 {
+		return fmt.Errorf("DEVICE_PREPARE_TIMEOUT must be > 0 seconds, got %d", config.DevicePrepareTimeout)
+	}
+
+	if config.EnableLivenessLease && config.LivenessLeaseDuration <= config.HealthCheckInterval {
+		return fmt.Errorf("LIVENESS_LEASE_DURATION must exceed HEALTH_CHECK_INTERVAL (%ds), got %d", config.HealthCheckInterval, config.LivenessLeaseDuration)
+	}
+
+	if config.EnableDiagnosticsBundle && config.DiagnosticsMaxBundles < 1 {
+		return fmt.Errorf("DIAGNOSTICS_MAX_BUNDLES must be at least 1, got %d", config.DiagnosticsMaxBundles)
+	}
+
+	if config.SecretFileReloadInterval < 0 {
+		return fmt.Errorf("SECRET_FILE_RELOAD_INTERVAL must be >= 0 seconds, got %d", config.SecretFileReloadInterval)
+	}
+
+	if config.PermissionReconcileInterval < 0 {
+		return fmt.Errorf("PERMISSION_RECONCILE_INTERVAL must be >= 0 seconds, got %d", config.PermissionReconcileInterval)
+	}
+
+	if config.EnableWarmupProducer {
+		if config.WarmupFrameWidth <= 0 || config.WarmupFrameWidth%2 != 0 || config.WarmupFrameHeight <= 0 {
+			return fmt.Errorf("WARMUP_FRAME_WIDTH must be a positive even number and WARMUP_FRAME_HEIGHT positive, got %dx%d", config.WarmupFrameWidth, config.WarmupFrameHeight)
+		}
+		if config.WarmupTimeout <= 0 {
+			return fmt.Errorf("WARMUP_TIMEOUT must be > 0 seconds, got %d", config.WarmupTimeout)
+		}
+	}
+	if config.PreformatDevices && (config.PreformatWidth <= 0 || config.PreformatWidth%2 != 0 || config.PreformatHeight <= 0) {
+		return fmt.Errorf("PREFORMAT_WIDTH must be a positive even number and PREFORMAT_HEIGHT positive, got %dx%d", config.PreformatWidth, config.PreformatHeight)
+	}
+
+	if config.AVBundleCount < 0 || config.AVBundleCount > config.MaxDevices {
+		return fmt.Errorf("AV_BUNDLE_COUNT must be between 0 and MAX_DEVICES (%d), got %d", config.MaxDevices, config.AVBundleCount)
+	}
+	// Spares replace advertised devices, so at least one must remain advertised under RESOURCE_NAME
+	if config.HotSpareCount < 0 || (config.HotSpareCount > 0 && config.HotSpareCount+config.AVBundleCount >= config.MaxDevices) {
+		return fmt.Errorf("HOT_SPARE_COUNT must be >= 0 and HOT_SPARE_COUNT + AV_BUNDLE_COUNT below MAX_DEVICES (%d), got %d+%d", config.MaxDevices, config.HotSpareCount, config.AVBundleCount)
+	}
+
+	if _, err := parseDeviceTiers(config); err != nil {
+		return err
+	}
+	if _, err := parseExcludedDevices(config); err != nil {
+		return err
+	}
+
+	if config.AVBundleCount > 0 {
+		if config.AVBundleResourceName == "" || config.AVBundleResourceName == config.ResourceName {
+			return fmt.Errorf("AV_BUNDLE_RESOURCE_NAME must be set and differ from RESOURCE_NAME")
+		}
+		if err := validateResourceName("AV_BUNDLE_RESOURCE_NAME", config.AVBundleResourceName); err != nil {
+			return err
+		}
+		if config.AVBundleSocketPath == "" || config.AVBundleSocketPath == config.SocketPath {
+			return fmt.Errorf("AV_BUNDLE_SOCKET_PATH must be set and differ from SOCKET_PATH")
+		}
+		// ALSA supports at most 32 cards per system
+		if config.ALSACardStartIndex < 0 || config.ALSACardStartIndex+config.AVBundleCount > 32 {
+			return fmt.Errorf("ALSA_CARD_START_INDEX + AV_BUNDLE_COUNT must be within 0-32, got %d+%d", config.ALSACardStartIndex, config.AVBundleCount)
+		}
+	}
+
 	if config.V4L2DevicePerm < 0 || config.V4L2DevicePerm > 0777 {
 		return fmt.Errorf("V4L2_DEVICE_PERM must be 0000-0777, got %o", config.V4L2DevicePerm)
 	}
 
+	// Cross-setting rules: warnings (possibly clamping the value) are reported, errors refuse startup
+	for _, finding := range applyConfigRules(config) {
+		if finding.Severity == ConfigSeverityError {
+			return fmt.Errorf("%s", finding)
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", finding)
+	}
+
 	// Warn about overly permissive permissions
 	warnAboutPermissivePermissions(config.V4L2DevicePerm)
 
@@ -148,7 +723,7 @@ func validateConfig(config *DevicePluginConfig) error {
 
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := envValue(key); value != "" {
 		return value
 	}
 	return defaultValue
@@ -156,7 +731,7 @@ func getEnv(key, defaultValue string) string {
 
 // getEnvInt gets an environment variable as an integer with a default value
 func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+	if value := envValue(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
 			return intValue
 		}
@@ -166,7 +741,7 @@ func getEnvInt(key string, defaultValue int) int {
 
 // getEnvBool gets an environment variable as a boolean with a default value
 func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
+	if value := envValue(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
 			return boolValue
 		}
@@ -176,7 +751,7 @@ func getEnvBool(key string, defaultValue bool) bool {
 
 // getEnvPerm parses POSIX file modes; supports 0666, 0o666, or decimal
 func getEnvPerm(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+	if value := envValue(key); value != "" {
 		if v, err := strconv.ParseUint(value, 0, 32); err == nil {
 			return int(v)
 		}
@@ -197,6 +772,22 @@ func warnAboutPermissivePermissions(perm int) {
 	}
 }
 
+// validCgroupPermissions reports whether s is a non-empty device cgroup access string
+// made of distinct "r", "w" and "m" flags
+func validCgroupPermissions(s string) bool {
+	if s == "" || len(s) > 3 {
+		return false
+	}
+	seen := make(map[rune]bool)
+	for _, c := range s {
+		if !strings.ContainsRune("rwm", c) || seen[c] {
+			return false
+		}
+		seen[c] = true
+	}
+	return true
+}
+
 // checkDeviceExists checks if a device file exists and is accessible
 func checkDeviceExists(path string) bool {
 	_, err := os.Stat(path)
@@ -215,18 +806,28 @@ func checkDeviceReadable(path string) bool {
 	return true
 }
 
-// setupSignalHandling sets up signal handling for graceful shutdown
-func setupSignalHandling() chan os.Signal {
+// signalContext returns the root context of a run, canceled by SIGINT or SIGTERM with the
+// signal as its cause. Only the first signal is handled; a second one terminates the process.
+// stop releases the signal handler and cancels the context.
+func signalContext(parent context.Context, logger *slog.Logger) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	return sigChan
-}
 
-// waitForSignal waits for a shutdown signal
-func waitForSignal(sigChan chan os.Signal, logger *slog.Logger) {
-	sig := <-sigChan
-	logger.Info("Received shutdown signal", "signal", sig.String())
-	signal.Stop(sigChan)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			logger.Info("Received shutdown signal", "signal", sig.String())
+			signal.Stop(sigChan)
+			cancel(fmt.Errorf("received %s", sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigChan)
+		cancel(context.Canceled)
+	}
 }
 
 // ensureDirectory ensures a directory exists
@@ -241,3 +842,23 @@ func cleanupSocket(socketPath string) error {
 	}
 	return nil
 }
+
+// jitteredDuration returns base plus a random duration in [0, jitter)
+func jitteredDuration(base, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return base
+	}
+	return base + rand.N(jitter)
+}
+
+// jitteredInterval randomizes interval by up to ±percent
+func jitteredInterval(interval time.Duration, percent int) time.Duration {
+	if percent <= 0 || interval <= 0 {
+		return interval
+	}
+	spread := interval * time.Duration(percent) / 100
+	if spread <= 0 {
+		return interval
+	}
+	return interval - spread + rand.N(2*spread)
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// V4L2 constants from linux/videodev2.h
+const (
+	v4l2BufTypeVideoOutput = 2
+	v4l2FieldNone          = 1
+	v4l2ColorspaceSRGB     = 8
+
+	// V4L2PixFmtYUYV is the fourcc for packed YUV 4:2:2
+	V4L2PixFmtYUYV = uint32('Y') | uint32('U')<<8 | uint32('Y')<<16 | uint32('V')<<24
+)
+
+// v4l2PixFormat mirrors struct v4l2_pix_format
+type v4l2PixFormat struct {
+	Width        uint32
+	Height       uint32
+	PixelFormat  uint32
+	Field        uint32
+	BytesPerLine uint32
+	SizeImage    uint32
+	Colorspace   uint32
+	Priv         uint32
+	Flags        uint32
+	YcbcrEnc     uint32
+	Quantization uint32
+	XferFunc     uint32
+}
+
+// v4l2Format mirrors struct v4l2_format on 64-bit platforms
+// The format union is 8-byte aligned and 200 bytes long
+type v4l2Format struct {
+	Type uint32
+	_    uint32
+	Pix  v4l2PixFormat
+	_    [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+}
+
+// vidiocSFmt is VIDIOC_S_FMT, _IOWR('V', 5, struct v4l2_format)
+var vidiocSFmt = uintptr(3<<30 | uint32(unsafe.Sizeof(v4l2Format{}))<<16 | uint32('V')<<8 | 5)
+
+// setOutputFormat sets the output (producer side) pixel format on an open loopback device
+func setOutputFormat(fd int, width, height uint32) (uint32, error) {
+	format := v4l2Format{
+		Type: v4l2BufTypeVideoOutput,
+		Pix: v4l2PixFormat{
+			Width:        width,
+			Height:       height,
+			PixelFormat:  V4L2PixFmtYUYV,
+			Field:        v4l2FieldNone,
+			BytesPerLine: width * 2,
+			SizeImage:    width * height * 2,
+			Colorspace:   v4l2ColorspaceSRGB,
+		},
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), vidiocSFmt, uintptr(unsafe.Pointer(&format))); errno != 0 {
+		return 0, fmt.Errorf("VIDIOC_S_FMT failed: %w", errno)
+	}
+
+	// The driver may adjust the image size, return what it accepted
+	return format.Pix.SizeImage, nil
+}
+
+// v4l2Capability mirrors struct v4l2_capability
+type v4l2Capability struct {
+	Driver       [16]byte
+	Card         [32]byte
+	BusInfo      [32]byte
+	Version      uint32
+	Capabilities uint32
+	DeviceCaps   uint32
+	Reserved     [3]uint32
+}
+
+// vidiocQueryCap is VIDIOC_QUERYCAP, _IOR('V', 0, struct v4l2_capability)
+var vidiocQueryCap = uintptr(2<<30 | uint32(unsafe.Sizeof(v4l2Capability{}))<<16 | uint32('V')<<8 | 0)
+
+// queryCapability returns the driver and card names reported by a video device
+func queryCapability(devicePath string) (string, string, error) {
+	fd, err := unix.Open(devicePath, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open %s: %w", devicePath, err)
+	}
+	defer func() {
+		_ = unix.Close(fd)
+	}()
+
+	var capability v4l2Capability
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), vidiocQueryCap, uintptr(unsafe.Pointer(&capability))); errno != 0 {
+		return "", "", fmt.Errorf("VIDIOC_QUERYCAP failed: %w", errno)
+	}
+
+	return unix.ByteSliceToString(capability.Driver[:]), unix.ByteSliceToString(capability.Card[:]), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+// setOutputFormat is not supported off Linux
+func setOutputFormat(fd int, width, height uint32) (uint32, error) {
+	return 0, errUnsupportedPlatform
+}
+
+// queryCapability is not supported off Linux
+func queryCapability(devicePath string) (string, string, error) {
+	return "", "", errUnsupportedPlatform
+}
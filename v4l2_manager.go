@@ -5,31 +5,50 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-
-	"golang.org/x/sys/unix"
+	"time"
 )
 
 // v4l2Manager implements the V4L2Manager interface
 type v4l2Manager struct {
-	devices        map[string]*VideoDevice
-	logger         *slog.Logger
-	mu             sync.RWMutex
-	perm           os.FileMode
-	fallbackMode   bool
-	fallbackReason string
-	fallbackPrefix string
+	devices          map[string]*VideoDevice
+	logger           *slog.Logger
+	mu               sync.RWMutex
+	perm             os.FileMode
+	gid              int // Device group ID, -1 leaves ownership untouched
+	startNumber      int // First video device number of the range
+	fallbackMode     bool
+	fallbackReason   string
+	fallbackPrefix   string
+	devicePathPrefix string                 // Real device node prefix, overridden by the soak harness
+	skipped          map[string]string      // device ID -> reason it was unusable at discovery
+	onChange         func()                 // Called after every bookkeeping mutation
+	moduleGeneration int                    // Incremented on every module reload
+	retired          map[string]int         // device ID -> generation of the last invalidated device
+	health           *HealthHistory         // Records transitions and damps flapping devices, may be nil
+	verifyNodes      bool                   // Reject nodes that are not genuine v4l2loopback devices (off in the soak harness)
+	observeOnly      bool                   // Never change permissions or ownership of device nodes (shadow mode)
+	slotFallback     bool                   // Give missing slots a placeholder instead of skipping them (FALLBACK_GRANULARITY=device)
+	checks           map[string]DeviceCheck // device ID -> result of its last health check
 }
 
 // NewV4L2Manager creates a new V4L2Manager instance with fallback support
-func NewV4L2Manager(logger *slog.Logger, devicePerm int, fallbackPrefix string) V4L2Manager {
+// startNumber is the first video device number of the advertised range
+func NewV4L2Manager(logger *slog.Logger, devicePerm, deviceGID, startNumber int, fallbackPrefix string) V4L2Manager {
 	return &v4l2Manager{
-		devices:        make(map[string]*VideoDevice),
-		logger:         logger,
-		perm:           os.FileMode(devicePerm),
-		fallbackMode:   false,
-		fallbackPrefix: fallbackPrefix,
+		devices:          make(map[string]*VideoDevice),
+		skipped:          make(map[string]string),
+		checks:           make(map[string]DeviceCheck),
+		logger:           logger,
+		perm:             os.FileMode(devicePerm),
+		gid:              deviceGID,
+		startNumber:      startNumber,
+		fallbackMode:     false,
+		fallbackPrefix:   fallbackPrefix,
+		devicePathPrefix: "/dev/video",
+		verifyNodes:      true,
 	}
 }
 
@@ -48,11 +67,12 @@ func (v *v4l2Manager) EnableFallbackMode(reason string, count int) error {
 
 	// Clear existing devices
 	v.devices = make(map[string]*VideoDevice)
+	v.skipped = make(map[string]string)
 
 	// Create actual device files that Kubernetes can mount
 	for i := 0; i < count; i++ {
-		deviceID := fmt.Sprintf("video%d", VideoDeviceStartNumber+i)
-		devicePath := fmt.Sprintf("%s%d", v.fallbackPrefix, VideoDeviceStartNumber+i)
+		deviceID := fmt.Sprintf("video%d", v.startNumber+i)
+		devicePath := fmt.Sprintf("%s%d", v.fallbackPrefix, v.startNumber+i)
 
 		// Create the device file as a symbolic link to /dev/null
 		// This ensures the file exists and can be mounted by Kubernetes
@@ -65,8 +85,11 @@ func (v *v4l2Manager) EnableFallbackMode(reason string, count int) error {
 		}
 
 		device := &VideoDevice{
-			ID:   deviceID,
-			Path: devicePath,
+			ID:         deviceID,
+			Path:       devicePath,
+			Fallback:   true,
+			Generation: v.retired[deviceID] + 1,
+			CreatedAt:  time.Now(),
 		}
 
 		v.devices[deviceID] = device
@@ -80,6 +103,7 @@ func (v *v4l2Manager) EnableFallbackMode(reason string, count int) error {
 		"fallback_devices_created", len(v.devices),
 		"reason", reason)
 
+	v.notifyChange()
 	return nil
 }
 
@@ -106,17 +130,15 @@ func (v *v4l2Manager) createFallbackDeviceFile(devicePath string) error {
 	}
 
 	// Prefer a symlink to /dev/null (no chmod on symlinks - chmod on symlinks affects the target)
-	if err := os.Symlink("/dev/null", devicePath); err == nil {
+	if err := privilegedSymlink("/dev/null", devicePath); err == nil {
 		return nil
 	}
 
 	// Fallback: create a regular file safely without following symlinks
 	// Use O_NOFOLLOW to prevent following symlinks if one was created between Remove and Open
-	fd, err := unix.Open(devicePath, unix.O_CREAT|unix.O_EXCL|unix.O_WRONLY|unix.O_NOFOLLOW, uint32(v.perm))
-	if err != nil {
+	if err := createFileNoFollow(devicePath, v.perm); err != nil {
 		return fmt.Errorf("create regular fallback file: %w", err)
 	}
-	_ = unix.Close(fd)
 	return nil
 }
 
@@ -127,59 +149,111 @@ func (v *v4l2Manager) CreateDevices(count int) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
+	// Placeholders of the previous discovery are recreated below if their slot is still missing
+	v.removeSlotPlaceholders()
+
 	// Clear existing devices
 	v.devices = make(map[string]*VideoDevice)
+	v.skipped = make(map[string]string)
+	var missing []string
 
-	// Create devices from /dev/video{VideoDeviceStartNumber} to /dev/video{VideoDeviceStartNumber+count-1}
-	// Starting from video{VideoDeviceStartNumber} to avoid conflicts with system video devices
+	// Create devices from /dev/video{start} to /dev/video{start+count-1}
+	// Starting from video{start} (default 10) to avoid conflicts with system video devices
 	for i := 0; i < count; i++ {
-		deviceID := fmt.Sprintf("video%d", VideoDeviceStartNumber+i)
-		devicePath := fmt.Sprintf("/dev/video%d", VideoDeviceStartNumber+i)
+		deviceID := fmt.Sprintf("video%d", v.startNumber+i)
+		devicePath := fmt.Sprintf("%s%d", v.devicePathPrefix, v.startNumber+i)
+
+		// Broken slots stay registered (advertised as Unhealthy) so capacity reflects MaxDevices
+		device := &VideoDevice{
+			ID:         deviceID,
+			Path:       devicePath,
+			Generation: 1,
+			CreatedAt:  time.Now(),
+		}
+		v.devices[deviceID] = device
 
 		// Check if device exists
 		if !checkDeviceExists(devicePath) {
-			v.logger.Warn("Device does not exist", "device_path", devicePath)
+			v.skipped[deviceID] = "device does not exist"
+			missing = append(missing, deviceID)
+			v.logger.Warn("Device does not exist", "device_id", deviceID, "device_path", devicePath)
+			continue
+		}
+
+		// Symlinks, regular files or foreign nodes planted by another workload are never advertised
+		if reason := v.impostor(devicePath); reason != "" {
+			v.skipped[deviceID] = "impostor device: " + reason
+			v.logger.Error("Rejecting impostor video device", "device_id", deviceID, "device_path", devicePath, "reason", reason)
 			continue
 		}
 
 		// Check if device is readable
 		if !checkDeviceReadable(devicePath) {
-			v.logger.Warn("Device is not readable", "device_path", devicePath)
+			v.skipped[deviceID] = "device is not readable"
+			v.logger.Warn("Device is not readable", "device_id", deviceID, "device_path", devicePath)
 			continue
 		}
 
-		// Set configured permissions on the device
-		if err := os.Chmod(devicePath, v.perm); err != nil {
-			v.logger.Warn("Failed to set permissions", "device", devicePath, "error", err)
-		} else {
-			v.logger.Debug("Set permissions", "device", devicePath, "permissions", fmt.Sprintf("%#o", v.perm))
-		}
+		// A shadow instance leaves the nodes to the production instance
+		if !v.observeOnly {
+			// Set configured permissions on the device
+			if err := privilegedChmod(devicePath, v.perm); err != nil {
+				v.logger.Warn("Failed to set permissions", "device", devicePath, "error", err)
+			} else {
+				v.logger.Debug("Set permissions", "device", devicePath, "permissions", fmt.Sprintf("%#o", v.perm))
+			}
 
-		// Create device entry
-		device := &VideoDevice{
-			ID:   deviceID,
-			Path: devicePath,
+			// Set configured group ownership on the device
+			if v.gid >= 0 {
+				if err := privilegedChown(devicePath, -1, v.gid); err != nil {
+					v.logger.Warn("Failed to set group ownership", "device", devicePath, "gid", v.gid, "error", err)
+				}
+			}
 		}
 
-		v.devices[deviceID] = device
-		v.logger.Debug("Registered device", "device_id", deviceID, "device_path", devicePath)
+		populateDeviceMetadata(device)
+		if reason := impostorDriverReason(device); v.verifyNodes && reason != "" {
+			v.skipped[deviceID] = "impostor device: " + reason
+			v.logger.Error("Rejecting impostor video device", "device_id", deviceID, "device_path", devicePath, "reason", reason)
+			continue
+		}
+		v.logger.Debug("Registered device",
+			"device_id", deviceID,
+			"device_path", devicePath,
+			"driver", device.Driver,
+			"card_label", device.CardLabel,
+			"rdev", deviceNumbers(device),
+			"sysfs_path", device.SysfsPath)
 	}
 
-	actualCount := len(v.devices)
-	if actualCount == 0 {
+	usableCount := len(v.devices) - len(v.skipped)
+	if usableCount == 0 {
 		return fmt.Errorf("no video devices were found")
 	}
 
-	if actualCount < count {
-		v.logger.Warn("Found fewer devices than requested",
+	// Real devices stay real; only the slots the module failed to create are stood in for
+	if v.slotFallback && len(missing) > 0 {
+		placeholders := v.createSlotPlaceholders(missing)
+		usableCount += len(placeholders)
+		v.logger.Warn("Missing device slots replaced by fallback placeholders",
+			"missing", missing,
+			"placeholders", placeholders,
+			"fallback_prefix", v.fallbackPrefix)
+	}
+
+	if len(v.skipped) > 0 {
+		v.logger.Warn("Some device slots are unusable and will be advertised as unhealthy",
 			"requested", count,
-			"found", actualCount)
+			"usable", usableCount,
+			"skipped", v.skipped)
 	}
 
 	v.logger.Info("Successfully registered video devices",
 		"requested", count,
-		"registered", actualCount)
+		"registered", len(v.devices),
+		"usable", usableCount)
 
+	v.notifyChange()
 	return nil
 }
 
@@ -194,10 +268,8 @@ func (v *v4l2Manager) GetDeviceByID(deviceID string) (*VideoDevice, error) {
 	}
 
 	// Return a copy of the device (no allocation state tracking)
-	return &VideoDevice{
-		ID:   device.ID,
-		Path: device.Path,
-	}, nil
+	copied := *device
+	return &copied, nil
 }
 
 // IsHealthy checks if the V4L2 system is healthy
@@ -213,7 +285,11 @@ func (v *v4l2Manager) IsHealthy(maxDevices int) bool {
 	// If we have devices in our map, check them
 	if len(v.devices) > 0 {
 		// Check if all devices still exist and are accessible
+		// Slots already unusable at discovery are reported separately via GetSkippedDevices
 		for _, device := range v.devices {
+			if _, skipped := v.skipped[device.ID]; skipped {
+				continue
+			}
 			if !checkDeviceExists(device.Path) || !checkDeviceReadable(device.Path) {
 				v.logger.Warn("Device is not healthy", "device_id", device.ID, "device_path", device.Path)
 				return false
@@ -225,7 +301,7 @@ func (v *v4l2Manager) IsHealthy(maxDevices int) bool {
 	// If no devices in our map, check if devices exist in the system
 	// This handles the case where devices are created by startup script
 	for i := 0; i < maxDevices; i++ {
-		devicePath := fmt.Sprintf("/dev/video%d", VideoDeviceStartNumber+i)
+		devicePath := fmt.Sprintf("%s%d", v.devicePathPrefix, v.startNumber+i)
 		if !checkDeviceExists(devicePath) || !checkDeviceReadable(devicePath) {
 			v.logger.Warn("System device is not healthy", "device_path", devicePath)
 			return false
@@ -249,7 +325,7 @@ func (v *v4l2Manager) GetDeviceCount(maxDevices int) int {
 	// This handles the case where devices are created by startup script
 	count := 0
 	for i := 0; i < maxDevices; i++ {
-		devicePath := fmt.Sprintf("/dev/video%d", VideoDeviceStartNumber+i)
+		devicePath := fmt.Sprintf("%s%d", v.devicePathPrefix, v.startNumber+i)
 		if checkDeviceExists(devicePath) {
 			count++
 		}
@@ -264,10 +340,8 @@ func (v *v4l2Manager) ListAllDevices() map[string]*VideoDevice {
 
 	devices := make(map[string]*VideoDevice)
 	for id, device := range v.devices {
-		devices[id] = &VideoDevice{
-			ID:   device.ID,
-			Path: device.Path,
-		}
+		copied := *device
+		devices[id] = &copied
 	}
 
 	return devices
@@ -275,8 +349,8 @@ func (v *v4l2Manager) ListAllDevices() map[string]*VideoDevice {
 
 // GetDeviceHealth returns health status for a specific device
 func (v *v4l2Manager) GetDeviceHealth(deviceID string) bool {
-	v.mu.RLock()
-	defer v.mu.RUnlock()
+	v.mu.Lock()
+	defer v.mu.Unlock()
 
 	device, exists := v.devices[deviceID]
 	if !exists {
@@ -285,19 +359,80 @@ func (v *v4l2Manager) GetDeviceHealth(deviceID string) bool {
 	}
 
 	// In fallback mode, always report devices as healthy (they're dummy paths)
-	if v.fallbackMode {
+	if v.fallbackMode || device.Fallback {
+		v.checks[deviceID] = DeviceCheck{Healthy: true, CheckedAt: time.Now()}
 		return true
 	}
 
-	// Check if device exists and is readable
-	healthy := checkDeviceExists(device.Path) && checkDeviceReadable(device.Path)
+	// Check if device exists, is readable and was not replaced by an impostor
+	check := DeviceCheck{CheckedAt: time.Now()}
+	switch {
+	case !checkDeviceExists(device.Path):
+		check.Error = "device node does not exist"
+	case !checkDeviceReadable(device.Path):
+		check.Error = "device node is not readable"
+	}
+	healthy := check.Error == ""
+	if healthy {
+		if reason := v.impostor(device.Path); reason != "" {
+			healthy = false
+			check.Error = "impostor device: " + reason
+			v.skipped[deviceID] = check.Error
+			v.logger.Error("Video device was replaced by an impostor", "device_id", deviceID, "device_path", device.Path, "reason", reason)
+		}
+	}
+	effective := v.health.Observe(deviceID, healthy)
+	if healthy && !effective {
+		check.Error = "damped after flapping"
+	}
+	check.Healthy = effective
+	v.checks[deviceID] = check
 	if !healthy {
 		v.logger.Warn("Device health check failed",
 			"device_id", deviceID,
-			"device_path", device.Path)
+			"device_path", device.Path,
+			"skip_reason", v.skipped[deviceID])
+	} else if reason, skipped := v.skipped[deviceID]; skipped {
+		// A slot broken at discovery has recovered
+		delete(v.skipped, deviceID)
+		v.logger.Info("Previously unusable device is now healthy",
+			"device_id", deviceID,
+			"device_path", device.Path,
+			"previous_reason", reason)
+	}
+	if healthy && !effective {
+		v.logger.Debug("Device is healthy but damped after flapping", "device_id", deviceID)
+	}
+
+	return effective
+}
+
+// impostor returns why a node is not a genuine v4l2loopback device, or "" when it is or checks are off
+func (v *v4l2Manager) impostor(devicePath string) string {
+	if !v.verifyNodes {
+		return ""
 	}
+	return impostorReason(devicePath)
+}
+
+// GetDeviceCheck returns the result of a device's last health check
+func (v *v4l2Manager) GetDeviceCheck(deviceID string) (DeviceCheck, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	check, ok := v.checks[deviceID]
+	return check, ok
+}
+
+// GetSkippedDevices returns the devices that were unusable at discovery and why
+func (v *v4l2Manager) GetSkippedDevices() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 
-	return healthy
+	skipped := make(map[string]string, len(v.skipped))
+	for id, reason := range v.skipped {
+		skipped[id] = reason
+	}
+	return skipped
 }
 
 // IsFallbackMode returns true if the manager is in fallback mode
@@ -320,6 +455,7 @@ func (v *v4l2Manager) CleanupFallbackDevices() {
 	defer v.mu.Unlock()
 
 	if !v.fallbackMode {
+		v.removeSlotPlaceholders()
 		return
 	}
 
@@ -343,3 +479,246 @@ func (v *v4l2Manager) CleanupFallbackDevices() {
 		}
 	}
 }
+
+// ReconcilePermissions re-applies configured permissions and ownership on devices that drifted
+// (e.g., reset by udev rules) and returns the IDs of corrected devices
+func (v *v4l2Manager) ReconcilePermissions() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	// Fallback devices are symlinks to /dev/null, never touch them
+	if v.fallbackMode || v.observeOnly {
+		return nil
+	}
+
+	var corrected []string
+	for _, device := range v.devices {
+		if _, skipped := v.skipped[device.ID]; skipped || device.Fallback {
+			continue
+		}
+
+		stat, err := os.Stat(device.Path)
+		if err != nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+			continue
+		}
+
+		drifted := false
+		if stat.Mode().Perm() != v.perm.Perm() {
+			if err := privilegedChmod(device.Path, v.perm); err != nil {
+				v.logger.Warn("Failed to re-apply permissions", "device", device.Path, "error", err)
+			} else {
+				drifted = true
+				v.logger.Info("Re-applied drifted device permissions",
+					"device", device.Path,
+					"expected", fmt.Sprintf("%#o", v.perm.Perm()),
+					"actual", fmt.Sprintf("%#o", stat.Mode().Perm()))
+			}
+		}
+
+		if _, gid, ok := fileOwner(stat); ok && v.gid >= 0 && gid != v.gid {
+			if err := privilegedChown(device.Path, -1, v.gid); err != nil {
+				v.logger.Warn("Failed to re-apply group ownership", "device", device.Path, "gid", v.gid, "error", err)
+			} else {
+				drifted = true
+				v.logger.Info("Re-applied drifted device ownership",
+					"device", device.Path,
+					"expected_gid", v.gid,
+					"actual_gid", gid)
+			}
+		}
+
+		if drifted {
+			corrected = append(corrected, device.ID)
+		}
+	}
+
+	return corrected
+}
+
+// PermissionMismatches returns the devices whose mode or group differs from the configured one,
+// keyed by ID with the mismatch
+func (v *v4l2Manager) PermissionMismatches() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	mismatches := make(map[string]string)
+	if v.fallbackMode {
+		return mismatches
+	}
+
+	for _, device := range v.devices {
+		if _, skipped := v.skipped[device.ID]; skipped || device.Fallback {
+			continue
+		}
+
+		// Missing or replaced nodes are caught by the health checks
+		stat, err := os.Stat(device.Path)
+		if err != nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+			continue
+		}
+		if stat.Mode().Perm() != v.perm.Perm() {
+			mismatches[device.ID] = fmt.Sprintf("mode %#o, expected %#o", stat.Mode().Perm(), v.perm.Perm())
+			continue
+		}
+		if _, gid, ok := fileOwner(stat); ok && v.gid >= 0 && gid != v.gid {
+			mismatches[device.ID] = fmt.Sprintf("group %d, expected %d", gid, v.gid)
+		}
+	}
+
+	return mismatches
+}
+
+// cleanupOrphanedFallbackDevices removes fallback device files left behind by a previous
+// crashed instance. Only paths matching <prefix><number> that look like our placeholders
+// (symlinks to /dev/null or empty regular files) are removed; anything else is reported.
+func cleanupOrphanedFallbackDevices(prefix string, logger *slog.Logger) int {
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		logger.Warn("Failed to scan for orphaned fallback devices", "prefix", prefix, "error", err)
+		return 0
+	}
+
+	removed := 0
+	for _, path := range matches {
+		suffix := strings.TrimPrefix(path, prefix)
+		if _, err := strconv.Atoi(suffix); err != nil {
+			continue
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+
+		placeholder := false
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			placeholder = err == nil && target == "/dev/null"
+		case info.Mode().IsRegular():
+			placeholder = info.Size() == 0
+		}
+
+		if !placeholder {
+			logger.Warn("Leaving unexpected file matching fallback prefix",
+				"path", path,
+				"mode", info.Mode().String())
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove orphaned fallback device", "path", path, "error", err)
+			continue
+		}
+		removed++
+		logger.Debug("Removed orphaned fallback device", "path", path)
+	}
+
+	if removed > 0 {
+		logger.Info("Removed orphaned fallback devices from a previous instance",
+			"count", removed,
+			"prefix", prefix)
+	}
+	return removed
+}
+
+// SetMaxBuffers records the buffer count a device was recreated with
+func (v *v4l2Manager) SetMaxBuffers(deviceID string, maxBuffers int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	device, exists := v.devices[deviceID]
+	if !exists {
+		return fmt.Errorf("device not found: %s", deviceID)
+	}
+	device.MaxBuffers = maxBuffers
+	v.notifyChange()
+	return nil
+}
+
+// RefreshDevice re-reads metadata after a device was recreated and bumps its generation
+func (v *v4l2Manager) RefreshDevice(deviceID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	device, exists := v.devices[deviceID]
+	if !exists {
+		return fmt.Errorf("device not found: %s", deviceID)
+	}
+
+	device.Generation++
+	device.CreatedAt = time.Now()
+	if !v.fallbackMode && !device.Fallback {
+		populateDeviceMetadata(device)
+	}
+	v.notifyChange()
+	return nil
+}
+
+// InvalidateDevices drops all devices so none is advertised or allocated until rediscovery
+func (v *v4l2Manager) InvalidateDevices() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.retired == nil {
+		v.retired = make(map[string]int)
+	}
+	for deviceID, device := range v.devices {
+		v.retired[deviceID] = device.Generation
+	}
+	v.devices = make(map[string]*VideoDevice)
+	v.skipped = make(map[string]string)
+	v.moduleGeneration++
+
+	v.logger.Info("Invalidated all video devices", "module_generation", v.moduleGeneration, "retired", len(v.retired))
+	v.notifyChange()
+	return v.moduleGeneration
+}
+
+// SetChangeHook registers fn to be called after every bookkeeping mutation
+func (v *v4l2Manager) SetChangeHook(fn func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.onChange = fn
+}
+
+// SetHealthHistory registers the history that records health transitions and damps flapping devices
+func (v *v4l2Manager) SetHealthHistory(history *HealthHistory) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.health = history
+}
+
+// notifyChange calls the change hook; caller must hold v.mu
+func (v *v4l2Manager) notifyChange() {
+	if v.onChange != nil {
+		v.onChange()
+	}
+}
+
+// RestoreDevices carries generations, creation times and buffer downgrades of a previous
+// instance over to rediscovered devices, as long as the device node is still the same one
+func (v *v4l2Manager) RestoreDevices(saved []VideoDevice) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	restored := 0
+	for _, previous := range saved {
+		device, exists := v.devices[previous.ID]
+		if !exists || device.Path != previous.Path {
+			continue
+		}
+
+		// A different device number means the node was recreated while no plugin was running
+		if device.Major != previous.Major || device.Minor != previous.Minor {
+			device.Generation = previous.Generation + 1
+			continue
+		}
+
+		device.Generation = previous.Generation
+		device.CreatedAt = previous.CreatedAt
+		device.MaxBuffers = previous.MaxBuffers
+		restored++
+	}
+	return restored
+}
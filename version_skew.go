@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pluginVersion is the build version, set with -ldflags "-X main.pluginVersion=<version>"
+var pluginVersion = "dev"
+
+// settingsSchemaVersion is the newest dynamic-settings ConfigMap layout this plugin understands
+// Bump it whenever a key is added, so ConfigMaps written for newer plugins are recognized as such
+const settingsSchemaVersion = 1
+
+// settingsSchemaKey declares the settings schema a ConfigMap was written for
+const settingsSchemaKey = "schema_version"
+
+// Node annotations publishing what this plugin speaks, read by companion components before
+// they rely on a newer layout
+const (
+	PluginVersionAnnotation  = "meeting-baas.io/video-device-plugin-version"
+	SettingsSchemaAnnotation = "meeting-baas.io/video-device-plugin-settings-schema"
+)
+
+// settingsSkew compares the schema a ConfigMap declares with the one this plugin understands
+// It returns the declared version and whether the ConfigMap is newer than this plugin
+func settingsSkew(data map[string]string) (int, bool, error) {
+	raw, ok := data[settingsSchemaKey]
+	if !ok {
+		return settingsSchemaVersion, false, nil
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || version < 1 {
+		return 0, false, fmt.Errorf("%s must be a positive integer, got %q", settingsSchemaKey, raw)
+	}
+	return version, version > settingsSchemaVersion, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// warmupFrameInterval is how often the placeholder frame is re-written (5 fps)
+const warmupFrameInterval = 200 * time.Millisecond
+
+// WarmupProducer writes a static "waiting" frame to allocated devices until
+// the pod's real producer opens them, avoiding a black screen during bot startup
+// It only runs on devices created with exclusive_caps=0: with exclusive_caps=1 the device turns
+// capture-only while the placeholder writes, and the real producer could no longer open it
+type WarmupProducer struct {
+	config  *DevicePluginConfig
+	logger  *slog.Logger
+	mu      sync.Mutex
+	running map[string]*warmupRun // device path -> running producer
+}
+
+// warmupRun tracks a single running placeholder producer
+type warmupRun struct {
+	cancel context.CancelFunc
+	doneCh chan struct{}
+}
+
+// NewWarmupProducer creates a new WarmupProducer instance
+func NewWarmupProducer(config *DevicePluginConfig, logger *slog.Logger) *WarmupProducer {
+	return &WarmupProducer{
+		config:  config,
+		logger:  logger,
+		running: make(map[string]*warmupRun),
+	}
+}
+
+// Start launches the placeholder producer for a device until ctx is canceled or Stop is called;
+// it is a no-op if one is already running
+func (w *WarmupProducer) Start(ctx context.Context, devicePath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, exists := w.running[devicePath]; exists {
+		return
+	}
+
+	run := &warmupRun{doneCh: make(chan struct{})}
+	ctx, run.cancel = context.WithCancel(ctx)
+	w.running[devicePath] = run
+	go func() {
+		defer close(run.doneCh)
+		defer w.forget(devicePath, run)
+		if err := w.produce(ctx, devicePath); err != nil {
+			w.logger.Warn("Warm-up producer stopped with error", "device_path", devicePath, "error", err)
+		}
+	}()
+}
+
+// Stop stops the placeholder producer for a device and waits until it has closed the device
+func (w *WarmupProducer) Stop(devicePath string) {
+	w.mu.Lock()
+	run, exists := w.running[devicePath]
+	if exists {
+		delete(w.running, devicePath)
+		run.cancel()
+	}
+	w.mu.Unlock()
+
+	if exists {
+		<-run.doneCh
+	}
+}
+
+// StopAll stops every running placeholder producer and waits for them to exit
+func (w *WarmupProducer) StopAll() {
+	w.mu.Lock()
+	runs := make([]*warmupRun, 0, len(w.running))
+	for devicePath, run := range w.running {
+		delete(w.running, devicePath)
+		run.cancel()
+		runs = append(runs, run)
+	}
+	w.mu.Unlock()
+
+	for _, run := range runs {
+		<-run.doneCh
+	}
+}
+
+// forget removes a finished producer from the running set if it is still the current one
+func (w *WarmupProducer) forget(devicePath string, run *warmupRun) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if current, exists := w.running[devicePath]; exists && current == run {
+		delete(w.running, devicePath)
+	}
+}
+
+// produce writes the placeholder frame until stopped, a real producer appears, or the timeout expires
+func (w *WarmupProducer) produce(ctx context.Context, devicePath string) error {
+	fd, err := openVideoOutput(devicePath)
+	if err != nil {
+		return fmt.Errorf("failed to open device: %w", err)
+	}
+	defer closeVideoOutput(fd)
+
+	width := uint32(w.config.WarmupFrameWidth)
+	height := uint32(w.config.WarmupFrameHeight)
+	sizeImage, err := setOutputFormat(fd, width, height)
+	if err != nil {
+		return err
+	}
+	frame := buildWaitingFrame(width, height, sizeImage)
+
+	w.logger.Info("Warm-up producer started",
+		"device_path", devicePath,
+		"width", width,
+		"height", height)
+
+	timeout := time.NewTimer(time.Duration(w.config.WarmupTimeout) * time.Second)
+	defer timeout.Stop()
+	ticker := time.NewTicker(warmupFrameInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := writeVideoFrame(fd, frame); err != nil {
+			return fmt.Errorf("failed to write placeholder frame: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			w.logger.Debug("Warm-up producer stopped", "device_path", devicePath)
+			return nil
+		case <-timeout.C:
+			w.logger.Info("Warm-up producer timed out waiting for real producer",
+				"device_path", devicePath,
+				"timeout_seconds", w.config.WarmupTimeout)
+			return nil
+		case <-ticker.C:
+			// Hand over the device as soon as another process opens it for writing;
+			// consumers opening it read-only keep seeing the placeholder
+			for _, holder := range findDeviceHolders(devicePath) {
+				if !holder.Writer {
+					continue
+				}
+				w.logger.Info("Real producer detected, stopping warm-up producer",
+					"device_path", devicePath,
+					"holder_pid", holder.PID,
+					"holder_comm", holder.Comm)
+				return nil
+			}
+		}
+	}
+}
+
+// buildWaitingFrame renders a static YUYV frame: dark background with a lighter centre band
+func buildWaitingFrame(width, height, sizeImage uint32) []byte {
+	frame := make([]byte, max(sizeImage, width*height*2))
+	bandStart := height * 2 / 5
+	bandEnd := height * 3 / 5
+
+	for y := uint32(0); y < height; y++ {
+		luma := byte(0x30)
+		if y >= bandStart && y < bandEnd {
+			luma = 0x80
+		}
+		row := frame[y*width*2 : (y+1)*width*2]
+		for x := 0; x < len(row); x += 2 {
+			row[x] = luma   // Y
+			row[x+1] = 0x80 // U/V (neutral chroma)
+		}
+	}
+
+	return frame[:sizeImage]
+}